@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+)
+
+// bootPlan returns the servers in the order they should be restored to their
+// previous state when Wings boots, highest priority first. Servers with the same
+// priority keep the order they were provided in.
+func bootPlan(servers []*server.Server) []*server.Server {
+	cfg := config.Get().System.BootStartup
+
+	priorities := make(map[string]int, len(servers))
+	for _, s := range servers {
+		priorities[s.ID()] = cfg.Priority(s.ID(), s.Config().Labels)
+	}
+	out := make([]*server.Server, len(servers))
+	copy(out, servers)
+	sort.SliceStable(out, func(i, j int) bool {
+		return priorities[out[i].ID()] > priorities[out[j].ID()]
+	})
+
+	log.WithFields(log.Fields{"servers": len(out), "concurrency": cfg.Concurrency, "delay": time.Duration(cfg.Delay) * time.Second}).
+		Info("restoring servers to their previous state")
+	for i, s := range out {
+		log.WithFields(log.Fields{"server": s.ID(), "position": i + 1, "priority": priorities[s.ID()]}).Debug("planned server boot order")
+	}
+	return out
+}
+
+// startPacer ensures that at least the configured delay passes between starting
+// each server when Wings boots.
+type startPacer struct {
+	mu    sync.Mutex
+	delay time.Duration
+	last  time.Time
+}
+
+// Wait blocks until the delay has passed since the previous call returned.
+func (p *startPacer) Wait() {
+	if p.delay <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if d := time.Until(p.last.Add(p.delay)); d > 0 {
+		time.Sleep(d)
+	}
+	p.last = time.Now()
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// preflightListeners attempts to bind to the addresses used by the API and SFTP
+// server before Wings begins booting, so that a conflicting process is reported
+// clearly rather than failing with a bare "address in use" error much later. The
+// process holding the address is never terminated, Wings refuses to boot instead.
+func preflightListeners() error {
+	cfg := config.Get()
+	listeners := []struct {
+		name string
+		addr string
+	}{
+		{"api", net.JoinHostPort(cfg.Api.Host, strconv.Itoa(cfg.Api.Port))},
+		{"sftp", net.JoinHostPort(cfg.System.Sftp.Address, strconv.Itoa(cfg.System.Sftp.Port))},
+	}
+
+	for _, l := range listeners {
+		err := tryBind(l.addr)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return errors.Wrapf(err, "cmd/root: failed to bind %s listener on %s", l.name, l.addr)
+		}
+
+		_, port, _ := net.SplitHostPort(l.addr)
+		p, _ := strconv.Atoi(port)
+		pid, name := findListeningProcess(p)
+		holder := "an unknown process"
+		if pid > 0 {
+			holder = fmt.Sprintf("process \"%s\" (pid %d)", name, pid)
+		}
+		return errors.Errorf("cmd/root: %s listener address %s is already in use by %s; stop that process or change the configured port", l.name, l.addr, holder)
+	}
+	return nil
+}
+
+// tryBind attempts to listen on the TCP address provided, closing the listener
+// immediately if successful.
+func tryBind(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// findListeningProcess returns the PID and name of the process listening on the
+// given TCP port by matching the socket inode from /proc/net/tcp against the open
+// file descriptors of each process. If the process cannot be found a PID of 0 is
+// returned.
+func findListeningProcess(port int) (int, string) {
+	inodes := make(map[string]bool)
+	for _, f := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		for _, inode := range listeningInodes(f, port) {
+			inodes["socket:["+inode+"]"] = true
+		}
+	}
+	if len(inodes) == 0 {
+		return 0, ""
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+		if err != nil || !inodes[target] {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.Split(fd, "/")[2])
+		if err != nil {
+			continue
+		}
+		name, _ := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+		return pid, strings.TrimSpace(string(name))
+	}
+	return 0, ""
+}
+
+// listeningInodes returns the socket inodes for all sockets in the listening
+// state on the given port in a /proc/net/tcp formatted file.
+func listeningInodes(file string, port int) []string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var out []string
+	suffix := fmt.Sprintf(":%04X", port)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		// The fourth field is the state of the socket, 0A is TCP_LISTEN.
+		if len(fields) < 10 || fields[3] != "0A" || !strings.HasSuffix(fields[1], suffix) {
+			continue
+		}
+		out = append(out, fields[9])
+	}
+	return out
+}
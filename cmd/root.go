@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	log2 "log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -27,12 +28,21 @@ import (
 
 	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/internal/audit"
+	"github.com/pterodactyl/wings/internal/certificates"
 	"github.com/pterodactyl/wings/internal/cron"
 	"github.com/pterodactyl/wings/internal/database"
+	"github.com/pterodactyl/wings/internal/logforward"
+	"github.com/pterodactyl/wings/internal/masking"
+	"github.com/pterodactyl/wings/internal/memlimit"
+	"github.com/pterodactyl/wings/internal/metrics"
+	"github.com/pterodactyl/wings/internal/readiness"
 	"github.com/pterodactyl/wings/loggers/cli"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/router"
 	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/server/backup"
+	"github.com/pterodactyl/wings/server/transfer"
 	"github.com/pterodactyl/wings/sftp"
 	"github.com/pterodactyl/wings/system"
 )
@@ -101,6 +111,10 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		}
 	}
 
+	if err := preflightListeners(); err != nil {
+		log.WithField("error", err).Fatal("failed to bind configured listeners")
+	}
+
 	if err := config.ConfigureTimezone(); err != nil {
 		log.WithField("error", err).Fatal("failed to detect system timezone or use supplied configuration value")
 	}
@@ -109,6 +123,17 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		log.WithField("error", err).Fatal("failed to configure system directories for pterodactyl")
 		return
 	}
+	if err := config.DiskSpaceCheck(); err != nil {
+		log.WithField("error", err).Fatal("not enough free disk space available for server data")
+		return
+	}
+	if err := config.DataWritableCheck(); err != nil {
+		log.WithField("error", err).Fatal("data directory is not writable")
+		return
+	}
+	if err := config.CleanupTmpDirectory(); err != nil {
+		log.WithField("error", err).Warn("failed to clean up stale temporary files")
+	}
 	if err := config.EnsurePterodactylUser(); err != nil {
 		log.WithField("error", err).Fatal("failed to create pterodactyl system user")
 	}
@@ -122,23 +147,93 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		return
 	}
 
+	if soft, hard := config.Get().System.SelfMemoryLimitMB, config.Get().System.SelfMemoryHardLimitMB; soft > 0 || hard > 0 {
+		if err := memlimit.Configure(soft*1024*1024, hard*1024*1024); err != nil {
+			log.WithField("error", err).Warn("failed to configure the memory limit of the wings process")
+		}
+		if soft > 0 {
+			go memlimit.Monitor(cmd.Context(), soft*1024*1024, 5*time.Second)
+		}
+		log.WithField("soft_limit_mb", soft).WithField("hard_limit_mb", hard).Info("configured memory limit of the wings process")
+	}
+
 	pclient := remote.New(
 		config.Get().PanelLocation,
 		remote.WithCredentials(config.Get().AuthenticationTokenId, config.Get().AuthenticationToken),
 		remote.WithHttpClient(&http.Client{
 			Timeout: time.Second * time.Duration(config.Get().RemoteQuery.Timeout),
 		}),
+		remote.WithOfflineBehavior(config.Get().RemoteQuery.OfflineBehavior, config.Get().RemoteQuery.OfflineQueueSize),
+		remote.WithCircuitBreaker(config.Get().RemoteQuery.CircuitBreaker),
 	)
 
 	if err := database.Initialize(); err != nil {
 		log.WithField("error", err).Fatal("failed to initialize database")
 	}
 
+	if err := audit.Start(cmd.Context()); err != nil {
+		log.WithField("error", err).Fatal("failed to initialize audit log")
+	}
+
+	if err := logforward.Start(cmd.Context()); err != nil {
+		log.WithField("error", err).Fatal("failed to initialize log forwarding")
+	}
+
+	// Wait for the dependencies of Wings before connecting to Docker and loading the
+	// servers from the Panel, which would otherwise fail outright if either of them
+	// is still starting up.
+	if r := config.Get().System.Readiness; r.Enabled {
+		if r.Delay > 0 {
+			log.WithField("delay", r.Delay).Info("waiting before running readiness checks")
+			time.Sleep(time.Duration(r.Delay) * time.Second)
+		}
+		gates := []readiness.Gate{
+			{Name: "docker", Check: func(ctx context.Context) error {
+				cli, err := environment.Docker()
+				if err != nil {
+					return err
+				}
+				_, err = cli.Ping(ctx)
+				return err
+			}},
+			{Name: "data_directory", Check: func(ctx context.Context) error {
+				f, err := os.CreateTemp(config.Get().System.Data, ".wings-readiness-*")
+				if err != nil {
+					return err
+				}
+				_ = f.Close()
+				return os.Remove(f.Name())
+			}},
+		}
+		if r.CheckPanel {
+			gates = append(gates, readiness.Gate{Name: "panel", Check: func(ctx context.Context) error {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Get().PanelLocation, nil)
+				if err != nil {
+					return err
+				}
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					return err
+				}
+				return res.Body.Close()
+			}})
+		}
+		readiness.Wait(cmd.Context(), time.Duration(r.MaxWait)*time.Second, time.Duration(r.Interval)*time.Second, gates...)
+	}
+
+	if err := environment.WaitForDocker(cmd.Context()); err != nil {
+		log.WithField("error", err).Fatal("failed to connect to docker daemon")
+	}
+
 	manager, err := server.NewManager(cmd.Context(), pclient)
 	if err != nil {
 		log.WithField("error", err).Fatal("failed to load server configurations")
 	}
 
+	if _, err := pclient.MeasureClockSkew(cmd.Context()); err != nil {
+		log.WithField("error", err).Warn("failed to check the clock of this node against the panel")
+	}
+
 	if err := environment.ConfigureDocker(cmd.Context()); err != nil {
 		log.WithField("error", err).Fatal("failed to configure docker environment")
 	}
@@ -147,6 +242,12 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		log.WithField("error", err).Fatal("failed to write configuration to disk")
 	}
 
+	// Check the remote storage for backups now rather than when the first backup is
+	// created, so that invalid credentials are noticed straight away.
+	if err := backup.CheckRemoteStorage(cmd.Context()); err != nil {
+		log.WithField("error", err).Error("backups will fail until the remote storage for backups can be accessed")
+	}
+
 	// Just for some nice log output.
 	for _, s := range manager.All() {
 		log.WithField("server", s.ID()).Info("finished loading configuration for server")
@@ -157,6 +258,10 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		log.WithField("error", err).Error("failed to retrieve locally cached server states from disk, assuming all servers in offline state")
 	}
 
+	if config.Get().System.StatePersistence.Enabled {
+		go manager.PersistStatesOnChange(cmd.Context())
+	}
+
 	ticker := time.NewTicker(time.Minute)
 	// Every minute, write the current server states to the disk to allow for a more
 	// seamless hard-reboot process in which wings will re-sync server states based
@@ -175,11 +280,13 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		}
 	}()
 
-	// Create a new workerpool that limits us to 4 servers being bootstrapped at a time
-	// on Wings. This allows us to ensure the environment exists, write configurations,
+	// Create a new workerpool that limits the number of servers being bootstrapped at a
+	// time on Wings. This allows us to ensure the environment exists, write configurations,
 	// and reboot processes without causing a slow-down due to sequential booting.
-	pool := workerpool.New(4)
-	for _, serv := range manager.All() {
+	boot := config.Get().System.BootStartup
+	pool := workerpool.New(boot.Concurrency)
+	pacer := &startPacer{delay: time.Duration(boot.Delay) * time.Second}
+	for _, serv := range bootPlan(manager.All()) {
 		s := serv
 
 		// For each server we encounter make sure the root data directory exists.
@@ -221,7 +328,8 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 			// This does mean that booting wings after a catastrophic machine crash and wiping out the Docker images
 			// as a result will result in a slow boot.
 			if !r && (st == environment.ProcessRunningState || st == environment.ProcessStartingState) {
-				if err := s.HandlePowerAction(server.PowerActionStart); err != nil {
+				pacer.Wait()
+				if err := s.HandlePowerActionFrom(server.PowerTriggerBoot, server.PowerActionStart); err != nil {
 					s.Log().WithField("error", err).Warn("failed to return server to running state")
 				}
 			} else if r || (!r && s.IsRunning()) {
@@ -255,6 +363,10 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 
 	// Wait until all the servers are ready to go before we fire up the SFTP and HTTP servers.
 	pool.StopWait()
+
+	// Recover any transfers that were interrupted the last time Wings was stopped, now
+	// that the state of every server is known.
+	transfer.Recover(cmd.Context(), manager)
 	defer func() {
 		// Cancel the context on all the running servers at this point, even though the
 		// program is just shutting down.
@@ -298,13 +410,20 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		log.WithField("error", err).Error("创建备份目录失败")
 	}
 
-	autotls, _ := cmd.Flags().GetBool("auto-tls")
-	tlshostname, _ := cmd.Flags().GetString("tls-hostname")
-	if autotls && tlshostname == "" {
-		autotls = false
+	api := config.Get().Api
+
+	// Certificates are managed automatically if either the ACME configuration is enabled
+	// or the --auto-tls flag is passed, in which case the hostname from the flag is used
+	// in place of the configured domains.
+	acmeCfg := api.Ssl.Acme
+	if autotls, _ := cmd.Flags().GetBool("auto-tls"); autotls {
+		if tlshostname, _ := cmd.Flags().GetString("tls-hostname"); tlshostname != "" {
+			acmeCfg.Enabled = true
+			acmeCfg.Domains = []string{tlshostname}
+		}
 	}
+	autotls := acmeCfg.Enabled
 
-	api := config.Get().Api
 	log.WithFields(log.Fields{
 		"use_ssl":      api.Ssl.Enabled,
 		"use_auto_tls": autotls,
@@ -320,6 +439,19 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		TLSConfig: config.DefaultTLSConfig,
 	}
 
+	if mc := config.Get().Metrics; mc.Enabled {
+		metrics.Register(manager.CollectMetrics)
+		mux := http.NewServeMux()
+		mux.Handle(mc.Path, metrics.Handler())
+		addr := net.JoinHostPort(mc.Host, strconv.Itoa(mc.Port))
+		log.WithField("address", addr).WithField("path", mc.Path).Info("serving prometheus metrics")
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				log.WithField("error", err).Error("failed to serve prometheus metrics")
+			}
+		}()
+	}
+
 	profile, _ := cmd.Flags().GetBool("pprof")
 	if profile {
 		if r, _ := cmd.Flags().GetInt("pprof-block-rate"); r > 0 {
@@ -336,13 +468,19 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 
 	// Check if the server should run with TLS but using autocert.
 	if autotls {
+		cache := acmeCfg.CachePath
+		if cache == "" {
+			cache = path.Join(sys.RootDirectory, "/.tls-cache")
+		}
 		m := autocert.Manager{
 			Prompt:     autocert.AcceptTOS,
-			Cache:      autocert.DirCache(path.Join(sys.RootDirectory, "/.tls-cache")),
-			HostPolicy: autocert.HostWhitelist(tlshostname),
+			Cache:      autocert.DirCache(cache),
+			HostPolicy: autocert.HostWhitelist(acmeCfg.Domains...),
+			Email:      acmeCfg.Email,
+			Client:     &acme.Client{DirectoryURL: acmeCfg.DirectoryURL},
 		}
 
-		log.WithField("hostname", tlshostname).Info("Web 服务器现在正在侦听并启用自动 TLS； 证书将由 Let's Encrypt 自动生成")
+		log.WithField("domains", acmeCfg.Domains).Info("Web 服务器现在正在侦听并启用自动 TLS； 证书将由 Let's Encrypt 自动生成")
 
 		// Hook autocert into the main http server.
 		s.TLSConfig.GetCertificate = m.GetCertificate
@@ -356,7 +494,7 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		}()
 		// Start the main http server with TLS using autocert.
 		if err := s.ListenAndServeTLS("", ""); err != nil {
-			log.WithFields(log.Fields{"auto_tls": true, "tls_hostname": tlshostname, "error": err}).Fatal("使用 auto-tls 配置 HTTP 服务器失败")
+			log.WithFields(log.Fields{"auto_tls": true, "domains": acmeCfg.Domains, "error": err}).Fatal("使用 auto-tls 配置 HTTP 服务器失败")
 		}
 		return
 	}
@@ -364,7 +502,15 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 	// Check if main http server should run with TLS. Otherwise, reset the TLS
 	// config on the server and then serve it over normal HTTP.
 	if api.Ssl.Enabled {
-		if err := s.ListenAndServeTLS(api.Ssl.CertificateFile, api.Ssl.KeyFile); err != nil {
+		store, err := certificates.New(api.Ssl.CertificateFile, api.Ssl.KeyFile, api.Ssl.Certificates)
+		if err != nil {
+			log.WithField("error", err).Fatal("failed to load TLS certificates for HTTPS server")
+		}
+		s.TLSConfig.GetCertificate = store.GetCertificate
+		if api.Ssl.ReloadInterval > 0 {
+			go store.Watch(cmd.Context(), time.Duration(api.Ssl.ReloadInterval)*time.Second)
+		}
+		if err := s.ListenAndServeTLS("", ""); err != nil {
 			log.WithFields(log.Fields{"auto_tls": false, "error": err}).Fatal("配置 HTTPS 服务器失败")
 		}
 		return
@@ -414,7 +560,7 @@ func initLogging() {
 	if config.Get().Debug {
 		log.SetLevel(log.DebugLevel)
 	}
-	log.SetHandler(multi.New(cli.Default, cli.New(w.File, false)))
+	log.SetHandler(masking.Handler{Handler: multi.New(cli.Default, cli.New(w.File, false), logforward.Handler{})})
 	log.WithField("path", p).Info("writing log files to disk")
 }
 
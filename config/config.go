@@ -1,15 +1,22 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
+	"math/rand"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/user"
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,6 +28,8 @@ import (
 	"github.com/apex/log"
 	"github.com/creasty/defaults"
 	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
 	"golang.org/x/sys/unix"
 	"gopkg.in/yaml.v2"
 
@@ -49,6 +58,8 @@ var DefaultTLSConfig = &tls.Config{
 	CurvePreferences:         []tls.CurveID{tls.X25519, tls.CurveP256},
 }
 
+var envNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 var (
 	mu            sync.RWMutex
 	_config       *Configuration
@@ -68,6 +79,117 @@ type SftpConfiguration struct {
 	Port int `default:"2022" json:"bind_port" yaml:"bind_port"`
 	// If set to true, no write actions will be allowed on the SFTP server.
 	ReadOnly bool `default:"false" yaml:"read_only"`
+	// The maximum number of concurrent SFTP connections that may be open for a single
+	// server. Set to 0 to allow an unlimited number of connections.
+	MaxConnectionsPerServer int `default:"0" yaml:"max_connections_per_server"`
+	// The maximum number of concurrent SFTP connections that may be open from a single
+	// source IP address. Set to 0 to allow an unlimited number of connections.
+	MaxConnectionsPerIP int `default:"0" yaml:"max_connections_per_ip"`
+	// If set to true, a file that is being uploaded when the server runs out of disk space
+	// is removed once the upload is aborted, rather than keeping the partially written file.
+	RemovePartialOnDiskFull bool `default:"true" yaml:"remove_partial_on_disk_full"`
+	// The maximum number of directories a file or directory created over SFTP may be
+	// nested within, counted from the root of the server.
+	MaxPathDepth int `default:"64" yaml:"max_path_depth"`
+	// The maximum length in bytes of the name of a file or directory created or renamed
+	// over SFTP.
+	MaxNameLength int `default:"255" yaml:"max_name_length"`
+	// DiskCheckStrategy determines how the disk usage of a server is determined when
+	// checking if a file can be uploaded over SFTP.
+	//
+	// "walk" -> the cached usage is used, and the server's directory is walked in the
+	//           background to refresh it once it is older than system.disk_check_interval
+	// "cached" -> only the cached usage is used, which is updated by writes made through
+	//             Wings and by walks started elsewhere, so uploads never cause a walk
+	// "statfs" -> the used space of the filesystem containing the server's directory is
+	//             used, which is only accurate when each server has its own filesystem
+	//             or a filesystem quota
+	DiskCheckStrategy string `default:"walk" yaml:"disk_check_strategy"`
+}
+
+const (
+	SftpDiskCheckWalk   = "walk"
+	SftpDiskCheckCached = "cached"
+	SftpDiskCheckStatfs = "statfs"
+)
+
+// SslCertificate defines a certificate and key pair that is served by the API for
+// a specific hostname. The hostname may start with "*." to match any subdomain.
+type SslCertificate struct {
+	Hostname        string `json:"hostname" yaml:"hostname"`
+	CertificateFile string `json:"cert" yaml:"cert"`
+	KeyFile         string `json:"key" yaml:"key"`
+}
+
+// SslAcme defines the configuration for automatically obtaining and renewing the
+// certificates used by the API from an ACME provider such as Let's Encrypt.
+type SslAcme struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// Email is the contact address registered with the ACME provider, which is used to
+	// send notices about problems with the certificates.
+	Email string `json:"email" yaml:"email"`
+
+	// Domains are the hostnames that certificates will be obtained for. Requests for any
+	// other hostname are refused.
+	Domains []string `json:"domains" yaml:"domains"`
+
+	// DirectoryURL is the directory endpoint of the ACME provider.
+	DirectoryURL string `default:"https://acme-v02.api.letsencrypt.org/directory" json:"directory_url" yaml:"directory_url"`
+
+	// CachePath is the directory that obtained certificates and the account key are stored
+	// in. If empty a ".tls-cache" directory within the root directory is used.
+	CachePath string `json:"cache_path" yaml:"cache_path"`
+}
+
+// validate checks that the domains and email of the ACME configuration are usable.
+func (a SslAcme) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	if len(a.Domains) == 0 {
+		return errors.New("config: api.ssl.acme.domains must contain at least one domain")
+	}
+	for _, d := range a.Domains {
+		if !isValidDomain(d) {
+			return errors.Errorf("config: api.ssl.acme.domains entry \"%s\" is not a valid domain name", d)
+		}
+	}
+	if a.Email != "" {
+		if addr, err := mail.ParseAddress(a.Email); err != nil || addr.Address != a.Email {
+			return errors.New("config: api.ssl.acme.email must be a valid email address")
+		}
+	}
+	if u, err := url.Parse(a.DirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("config: api.ssl.acme.directory_url must be a valid https URL")
+	}
+	if a.CachePath != "" && !filepath.IsAbs(a.CachePath) {
+		return errors.New("config: api.ssl.acme.cache_path must be an absolute path")
+	}
+	return nil
+}
+
+// isValidDomain checks that the given value is a fully qualified domain name that
+// certificates can be issued for. Wildcards and IP addresses are not allowed.
+func isValidDomain(d string) bool {
+	if len(d) > 253 || net.ParseIP(d) != nil {
+		return false
+	}
+	labels := strings.Split(d, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if len(l) < 1 || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for _, c := range l {
+			if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // ApiConfiguration defines the configuration for the internal API that is
@@ -84,6 +206,20 @@ type ApiConfiguration struct {
 		Enabled         bool   `json:"enabled" yaml:"enabled"`
 		CertificateFile string `json:"cert" yaml:"cert"`
 		KeyFile         string `json:"key" yaml:"key"`
+
+		// Certificates defines additional certificates that are served based on the
+		// hostname requested by the client (SNI). The certificate and key defined above
+		// are used for any hostname that does not match one of these.
+		Certificates []SslCertificate `json:"certificates" yaml:"certificates"`
+
+		// ReloadInterval is the number of seconds between checks for changes to the
+		// certificate files, which are reloaded without restarting Wings when they
+		// change. Set to 0 to disable reloading.
+		ReloadInterval int `default:"60" json:"reload_interval" yaml:"reload_interval"`
+
+		// Acme configures certificates to be obtained and renewed automatically rather
+		// than being loaded from the certificate and key files.
+		Acme SslAcme `json:"acme" yaml:"acme"`
 	}
 
 	// Determines if functionality for allowing remote download of files into server directories
@@ -91,11 +227,231 @@ type ApiConfiguration struct {
 	// servers.
 	DisableRemoteDownload bool `json:"-" yaml:"disable_remote_download"`
 
+	// RemoteDownload restricts the URLs that files can be downloaded from into server
+	// directories, and the size and duration of those downloads.
+	RemoteDownload RemoteDownloadConfiguration `json:"-" yaml:"remote_download"`
+
 	// The maximum size for files uploaded through the Panel in MB.
 	UploadLimit int64 `default:"100" json:"upload_limit" yaml:"upload_limit"`
 
+	// MaxAssembledUploadSize is the maximum size in MB of a file uploaded in chunks, once
+	// all of its chunks have been assembled. Each chunk is limited to the UploadLimit. If
+	// set to 0 the UploadLimit also applies to the assembled file.
+	MaxAssembledUploadSize int64 `default:"0" json:"max_assembled_upload_size" yaml:"max_assembled_upload_size"`
+
+	// MaxRequestBody is the maximum size in MiB of the body of a request to the API. This
+	// applies to every endpoint that does not have its own limit, which are the endpoints
+	// that only accept JSON. File uploads and server transfers are not limited by default,
+	// and writing a file is limited to the UploadLimit.
+	MaxRequestBody int64 `default:"4" json:"max_request_body" yaml:"max_request_body"`
+
+	// RequestBodyLimits overrides the maximum request body size in MiB for individual
+	// endpoints, keyed by the method and route of the endpoint, for example
+	// "POST /api/servers/:server/files/write". A limit of 0 disables the limit for
+	// that endpoint.
+	RequestBodyLimits map[string]int64 `json:"request_body_limits" yaml:"request_body_limits"`
+
 	// A list of IP address of proxies that may send a X-Forwarded-For header to set the true clients IP
 	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+
+	// Cors defines the cross-origin resource sharing policy applied to responses
+	// from the API.
+	Cors CorsConfiguration `json:"cors" yaml:"cors"`
+
+	// Websocket defines the limits applied to inbound messages on server console
+	// websocket connections.
+	Websocket WebsocketConfiguration `json:"websocket" yaml:"websocket"`
+}
+
+// MaxAssembledUploadSizeBytes returns the maximum size in bytes of a file uploaded
+// in chunks.
+func (a ApiConfiguration) MaxAssembledUploadSizeBytes() int64 {
+	if a.MaxAssembledUploadSize > 0 {
+		return a.MaxAssembledUploadSize * 1024 * 1024
+	}
+	return a.UploadLimit * 1024 * 1024
+}
+
+// CorsConfiguration defines the CORS headers that are returned by the API. The Panel
+// location is always an allowed origin, so these settings only need to be changed to
+// allow browsers on other origins to make requests to Wings.
+type CorsConfiguration struct {
+	// AllowedOrigins is a list of additional origins, such as "https://example.com",
+	// that are allowed to make requests to the API. These are combined with the
+	// top-level allowed_origins setting. An origin of "*" allows any origin.
+	AllowedOrigins []string `json:"allowed_origins" yaml:"allowed_origins"`
+
+	// AllowedMethods is the list of request methods returned in the
+	// Access-Control-Allow-Methods header.
+	AllowedMethods []string `default:"[\"GET\",\"POST\",\"PATCH\",\"PUT\",\"DELETE\",\"OPTIONS\"]" json:"allowed_methods" yaml:"allowed_methods"`
+
+	// AllowedHeaders is the list of request headers returned in the
+	// Access-Control-Allow-Headers header.
+	AllowedHeaders []string `default:"[\"Accept\",\"Accept-Encoding\",\"Authorization\",\"Cache-Control\",\"Content-Type\",\"Content-Length\",\"Origin\",\"X-Real-IP\",\"X-CSRF-Token\"]" json:"allowed_headers" yaml:"allowed_headers"`
+
+	// AllowCredentials determines if browsers are allowed to send credentials, such
+	// as cookies and authorization headers, along with cross-origin requests.
+	AllowCredentials bool `default:"true" json:"allow_credentials" yaml:"allow_credentials"`
+}
+
+// validate checks that each of the allowed origins is either "*" or a scheme and
+// host without a path, which is the only form a browser will send.
+func (c CorsConfiguration) validate() error {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			if c.AllowCredentials {
+				log.WithField("origin", o).Warn("api.cors.allowed_origins contains \"*\" while api.cors.allow_credentials is enabled: any website will be able to make authenticated requests to this node, this is almost certainly not what you want")
+			}
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return errors.Errorf("config: api.cors.allowed_origins entry \"%s\" must be \"*\" or an origin such as \"https://example.com\"", o)
+		}
+	}
+	return nil
+}
+
+// RemoteDownloadConfiguration restricts the remote file downloads that the Panel can
+// request for a server. Loopback and link-local addresses, which include the cloud
+// metadata service at 169.254.169.254, are always refused.
+type RemoteDownloadConfiguration struct {
+	// AllowedSchemes is the list of URL schemes that files may be downloaded from.
+	AllowedSchemes []string `default:"[\"http\",\"https\"]" json:"allowed_schemes" yaml:"allowed_schemes"`
+
+	// BlockPrivateNetworks refuses downloads from addresses within the private network
+	// ranges defined by RFC 1918, and unique local IPv6 addresses.
+	BlockPrivateNetworks bool `default:"true" json:"block_private_networks" yaml:"block_private_networks"`
+
+	// DeniedRanges is a list of additional IP ranges in CIDR notation that files may
+	// not be downloaded from.
+	DeniedRanges []string `json:"denied_ranges" yaml:"denied_ranges"`
+
+	// MaxSize is the maximum size in MiB of a downloaded file. Set to 0 to only limit
+	// downloads to the disk space available to the server.
+	MaxSize int64 `default:"0" json:"max_size" yaml:"max_size"`
+
+	// Timeout is the maximum number of seconds that a download may take.
+	Timeout int `default:"43200" json:"timeout" yaml:"timeout"`
+}
+
+// SchemeAllowed returns true if files may be downloaded from URLs with the scheme
+// provided.
+func (r RemoteDownloadConfiguration) SchemeAllowed(scheme string) bool {
+	return slices.ContainsFunc(r.AllowedSchemes, func(s string) bool {
+		return strings.EqualFold(s, scheme)
+	})
+}
+
+// DeniedNetworks returns the parsed denied_ranges, any invalid ranges are skipped
+// since they are refused when the configuration is validated.
+func (r RemoteDownloadConfiguration) DeniedNetworks() []*net.IPNet {
+	out := make([]*net.IPNet, 0, len(r.DeniedRanges))
+	for _, v := range r.DeniedRanges {
+		if _, n, err := net.ParseCIDR(v); err == nil {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (r RemoteDownloadConfiguration) validate() error {
+	if len(r.AllowedSchemes) == 0 {
+		return errors.New("config: api.remote_download.allowed_schemes must contain at least one scheme")
+	}
+	for _, s := range r.AllowedSchemes {
+		if !strings.EqualFold(s, "http") && !strings.EqualFold(s, "https") {
+			return errors.Errorf("config: api.remote_download.allowed_schemes entry \"%s\" must be either \"http\" or \"https\"", s)
+		}
+	}
+	for _, v := range r.DeniedRanges {
+		if _, _, err := net.ParseCIDR(v); err != nil {
+			return errors.Errorf("config: api.remote_download.denied_ranges entry \"%s\" is not a valid CIDR range", v)
+		}
+	}
+	if r.MaxSize < 0 {
+		return errors.New("config: api.remote_download.max_size must not be negative")
+	}
+	if r.Timeout < 1 {
+		return errors.New("config: api.remote_download.timeout must be at least 1 second")
+	}
+	return nil
+}
+
+// WebsocketConfiguration defines the limits applied to messages sent by clients
+// over a server's console websocket. These complement the process output throttles
+// by capping what a single client is able to push into Wings.
+type WebsocketConfiguration struct {
+	// The maximum size in bytes of a single message sent by a client. If a client sends
+	// a message larger than this the connection is closed.
+	MaxMessageBytes int64 `default:"32768" json:"max_message_bytes" yaml:"max_message_bytes"`
+
+	// The maximum number of messages a single client may send in a one second period
+	// before the connection is closed.
+	MaxMessagesPerSecond uint64 `default:"50" json:"max_messages_per_second" yaml:"max_messages_per_second"`
+
+	// The maximum number of authenticated console websocket connections that may be open
+	// for a single server at once. Connections that authenticate beyond this are closed.
+	// Set to 0 to allow an unlimited number of connections.
+	MaxConnectionsPerServer int `default:"0" json:"max_connections_per_server" yaml:"max_connections_per_server"`
+
+	// TokenRefresh allows a client to send a new token from the Panel over an open
+	// connection before its current token expires, keeping the connection alive. The new
+	// token must be for the same server and user. If false the connection is closed once
+	// its token expires and the client has to reconnect.
+	TokenRefresh bool `default:"true" json:"token_refresh" yaml:"token_refresh"`
+
+	// MaxSessionLifetime is the maximum number of seconds a connection may remain open,
+	// regardless of how many times its token is refreshed. Set to 0 for no limit.
+	MaxSessionLifetime int `default:"0" json:"max_session_lifetime" yaml:"max_session_lifetime"`
+}
+
+// MetricsConfiguration defines the configuration for the Prometheus metrics
+// endpoint. This is served by its own webserver so that it can be bound to an
+// interface that is only reachable by the monitoring system.
+type MetricsConfiguration struct {
+	// Enabled determines if the metrics endpoint is served.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// The interface that the metrics webserver should bind to.
+	Host string `default:"127.0.0.1" json:"host" yaml:"host"`
+
+	// The port that the metrics webserver should bind to. This avoids 9100, which is
+	// commonly used by the Prometheus node exporter on the same host.
+	Port int `default:"9191" json:"port" yaml:"port"`
+
+	// The path that metrics are served on.
+	Path string `default:"/metrics" json:"path" yaml:"path"`
+}
+
+// validate checks that the metrics webserver can be bound without colliding
+// with the API webserver.
+func (m MetricsConfiguration) validate(api ApiConfiguration) error {
+	if !m.Enabled {
+		return nil
+	}
+	if m.Port < 1 || m.Port > 65535 {
+		return errors.New("config: metrics.port must be between 1 and 65535")
+	}
+	if !strings.HasPrefix(m.Path, "/") {
+		return errors.New("config: metrics.path must start with \"/\"")
+	}
+	if m.Host != "" && net.ParseIP(m.Host) == nil {
+		return errors.New("config: metrics.host must be an IP address")
+	}
+	if m.Port == api.Port && (m.Host == api.Host || isUnspecifiedHost(m.Host) || isUnspecifiedHost(api.Host)) {
+		return errors.Errorf("config: metrics.host and metrics.port collide with the API, which is bound to %s:%d", api.Host, api.Port)
+	}
+	return nil
+}
+
+// isUnspecifiedHost returns whether the host binds to all interfaces.
+func isUnspecifiedHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
 }
 
 // RemoteQueryConfiguration defines the configuration settings for remote requests
@@ -118,6 +474,872 @@ type RemoteQueryConfiguration struct {
 	// 50 servers is likely just as quick as two for 100 or one for 400, and will certainly
 	// be less likely to cause performance issues on the Panel.
 	BootServersPerPage int `default:"50" yaml:"boot_servers_per_page"`
+
+	// OfflineBehavior controls what happens to status updates for installations, backups,
+	// restorations, archives and transfers that cannot be sent because the Panel is
+	// unreachable.
+	//
+	// "queue" -> updates are kept and sent in order once the Panel is reachable again
+	// "reject" -> updates fail, and fail immediately while the Panel is known to be unreachable
+	// "continue" -> only the latest update for each server or backup is kept, and is sent
+	//               once the Panel is reachable again to reconcile it with the node
+	OfflineBehavior string `default:"queue" yaml:"offline_behavior"`
+
+	// OfflineQueueSize is the maximum number of status updates kept while the Panel is
+	// unreachable. Once full the oldest update is dropped.
+	OfflineQueueSize int `default:"100" yaml:"offline_queue_size"`
+
+	// CircuitBreaker stops requests from being sent to the Panel while it is failing,
+	// rather than adding retries to the load of a struggling Panel.
+	CircuitBreaker CircuitBreaker `yaml:"circuit_breaker"`
+}
+
+// CircuitBreaker defines when requests to the Panel are stopped after repeated
+// failures, and how the Panel is probed before requests are sent normally again.
+type CircuitBreaker struct {
+	Enabled bool `default:"true" yaml:"enabled"`
+
+	// FailureThreshold is the number of consecutive requests that must fail because the
+	// Panel could not be reached, or returned a 5XX response, before the breaker opens.
+	FailureThreshold int `default:"5" yaml:"failure_threshold"`
+
+	// OpenDuration is the number of seconds requests are refused for once the breaker
+	// opens, after which requests are sent as probes to determine if the Panel has
+	// recovered.
+	OpenDuration int `default:"30" yaml:"open_duration"`
+
+	// HalfOpenProbes is the number of probe requests that may be sent at once after the
+	// open duration passes. A successful probe closes the breaker, while a failed probe
+	// opens it again.
+	HalfOpenProbes int `default:"1" yaml:"half_open_probes"`
+}
+
+// validate checks that the thresholds of the circuit breaker are usable.
+func (b CircuitBreaker) validate() error {
+	if !b.Enabled {
+		return nil
+	}
+	if b.FailureThreshold < 1 {
+		return errors.New("config: remote_query.circuit_breaker.failure_threshold must be greater than 0")
+	}
+	if b.OpenDuration < 1 {
+		return errors.New("config: remote_query.circuit_breaker.open_duration must be greater than 0")
+	}
+	if b.HalfOpenProbes < 1 {
+		return errors.New("config: remote_query.circuit_breaker.half_open_probes must be greater than 0")
+	}
+	return nil
+}
+
+const (
+	PanelOfflineQueue    = "queue"
+	PanelOfflineReject   = "reject"
+	PanelOfflineContinue = "continue"
+)
+
+// OwnershipRetry defines how failed attempts to change the ownership of server
+// files are retried. Only errors that may be transient are retried, errors such as
+// a file not existing or the operation not being permitted fail immediately.
+type OwnershipRetry struct {
+	// Attempts is the number of times a failed change of ownership is retried. Set to 0
+	// to disable retrying.
+	Attempts int `default:"2" json:"attempts" yaml:"attempts"`
+
+	// Backoff is the number of milliseconds to wait before the first retry, which is
+	// doubled for each following retry.
+	Backoff int `default:"200" json:"backoff" yaml:"backoff"`
+}
+
+// validate checks that the number of attempts and backoff are usable.
+func (r OwnershipRetry) validate() error {
+	if r.Attempts < 0 || r.Attempts > 10 {
+		return errors.New("config: system.ownership_retry.attempts must be between 0 and 10")
+	}
+	if r.Backoff < 0 || r.Backoff > 30000 {
+		return errors.New("config: system.ownership_retry.backoff must be between 0 and 30000")
+	}
+	return nil
+}
+
+// ServerLogRetention defines the limits applied to the log files that servers write
+// into their data directories. Only files in the configured directories with ".log"
+// in their name are considered.
+type ServerLogRetention struct {
+	// Enabled determines if log files are pruned. This is disabled by default since
+	// it deletes files from server data directories.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// Directories are the directories, relative to the root of each server, that
+	// contain log files.
+	Directories []string `default:"[\"logs\"]" json:"directories" yaml:"directories"`
+
+	// MaxSizeMB is the maximum combined size in MiB of the log files of a server. Once
+	// this is exceeded the oldest log files are deleted. Set to 0 for no limit.
+	MaxSizeMB int64 `default:"1024" json:"max_size_mb" yaml:"max_size_mb"`
+
+	// MaxAgeDays is the number of days after a log file was last written that it is
+	// deleted. Set to 0 for no limit.
+	MaxAgeDays int `default:"90" json:"max_age_days" yaml:"max_age_days"`
+}
+
+// validate checks that the limits are usable and that the directories are
+// relative to the root of a server.
+func (r ServerLogRetention) validate() error {
+	if r.MaxSizeMB < 0 {
+		return errors.New("config: system.server_log_retention.max_size_mb must not be negative")
+	}
+	if r.MaxAgeDays < 0 {
+		return errors.New("config: system.server_log_retention.max_age_days must not be negative")
+	}
+	if r.Enabled && r.MaxSizeMB == 0 && r.MaxAgeDays == 0 {
+		return errors.New("config: system.server_log_retention must define max_size_mb or max_age_days when enabled")
+	}
+	for _, d := range r.Directories {
+		if d == "" || filepath.IsAbs(d) || !filepath.IsLocal(d) {
+			return errors.Errorf("config: system.server_log_retention.directories entry \"%s\" must be a path relative to the server root", d)
+		}
+	}
+	return nil
+}
+
+// StaleLocks defines the lock files that are removed from the data directory of a
+// server before it is started, which are left behind when a server crashes and
+// prevent it from starting again until they are deleted.
+type StaleLocks struct {
+	// Patterns are the glob patterns of the lock files to remove, relative to the root
+	// of each server. A pattern without a slash matches files with that name in any
+	// directory, such as "session.lock" for the world directories of a Minecraft server.
+	Patterns []string `default:"[\"session.lock\"]" json:"patterns" yaml:"patterns"`
+
+	// Eggs are additional patterns for the servers using an egg, keyed by the UUID of
+	// the egg on the Panel.
+	Eggs map[string][]string `json:"eggs" yaml:"eggs"`
+}
+
+// PatternsFor returns the patterns of the lock files to remove for a server using
+// the egg provided.
+func (l StaleLocks) PatternsFor(egg string) []string {
+	out := make([]string, 0, len(l.Patterns)+len(l.Eggs[egg]))
+	out = append(out, l.Patterns...)
+	return append(out, l.Eggs[egg]...)
+}
+
+// validate checks that every pattern is a well-formed glob relative to the root of
+// a server.
+func (l StaleLocks) validate() error {
+	check := func(p string) error {
+		if p == "" || path.IsAbs(p) || !filepath.IsLocal(p) {
+			return errors.Errorf("config: system.stale_locks pattern \"%s\" must be a path relative to the server root", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Errorf("config: system.stale_locks pattern \"%s\" is not a valid glob pattern", p)
+		}
+		return nil
+	}
+	for _, p := range l.Patterns {
+		if err := check(p); err != nil {
+			return err
+		}
+	}
+	for _, patterns := range l.Eggs {
+		for _, p := range patterns {
+			if err := check(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SecretMasking defines the environment variables of servers whose values are
+// treated as secrets and replaced with "***" in the logs written by Wings and in
+// the console output forwarded to external log targets.
+type SecretMasking struct {
+	// Enabled controls whether the values of matching environment variables are
+	// masked.
+	Enabled bool `default:"true" json:"enabled" yaml:"enabled"`
+
+	// Patterns are the glob patterns of the names of environment variables to mask,
+	// matched without regard to case.
+	Patterns []string `default:"[\"*PASSWORD*\",\"*PASSWD*\",\"*SECRET*\",\"*TOKEN*\",\"*API_KEY*\",\"*APIKEY*\",\"*PRIVATE_KEY*\",\"*_PASS\"]" json:"patterns" yaml:"patterns"`
+}
+
+// Matches returns true if the name of the environment variable provided matches
+// one of the configured patterns.
+func (m SecretMasking) Matches(name string) bool {
+	if !m.Enabled {
+		return false
+	}
+	name = strings.ToUpper(name)
+	for _, p := range m.Patterns {
+		if ok, _ := path.Match(strings.ToUpper(p), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that every pattern is a non-empty, well-formed glob.
+func (m SecretMasking) validate() error {
+	for _, p := range m.Patterns {
+		if p == "" {
+			return errors.New("config: system.secret_masking.patterns must not contain empty patterns")
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Errorf("config: system.secret_masking pattern \"%s\" is not a valid glob pattern", p)
+		}
+	}
+	return nil
+}
+
+const (
+	AutoStopProbeConnections = "connections"
+	AutoStopProbeNetwork     = "network"
+	AutoStopProbeLogPattern  = "log_pattern"
+)
+
+// AutoStop defines the configuration for stopping servers that are idle. A server is
+// idle once the activity probe has not detected any activity for the idle timeout.
+type AutoStop struct {
+	// Enabled determines if idle servers are stopped.
+	Enabled bool `default:"false" yaml:"enabled"`
+
+	// IdleTimeout is the number of seconds a running server must be idle for before it
+	// is stopped.
+	IdleTimeout int `default:"1800" yaml:"idle_timeout"`
+
+	// CheckInterval is the number of seconds between checks for server activity.
+	CheckInterval int `default:"60" yaml:"check_interval"`
+
+	// Probe is the method used to detect activity on a server.
+	//
+	// "connections" -> there are established TCP connections to one of the server's ports
+	// "network" -> the server's container received at least min_network_bytes since the
+	//              last check, which also works for servers that only use UDP
+	// "log_pattern" -> a line of console output matched log_pattern since the last check
+	Probe string `default:"connections" yaml:"probe"`
+
+	// MinNetworkBytes is the number of bytes a server must receive between checks to be
+	// considered active when using the "network" probe.
+	MinNetworkBytes uint64 `default:"4096" yaml:"min_network_bytes"`
+
+	// LogPattern is the regular expression matched against console output when using
+	// the "log_pattern" probe, for example "joined the game|logged in".
+	LogPattern string `yaml:"log_pattern"`
+
+	// WakeOnConnection listens on the default allocation of a server once it has been
+	// stopped for being idle, and starts the server again when a TCP connection is made
+	// to it. The connection that wakes the server is closed, so the client will need to
+	// reconnect once the server has started.
+	WakeOnConnection bool `default:"false" yaml:"wake_on_connection"`
+}
+
+// validate checks that the durations are usable and that the probe is known.
+func (a AutoStop) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	if a.IdleTimeout < 1 {
+		return errors.New("config: system.auto_stop.idle_timeout must be greater than 0")
+	}
+	if a.CheckInterval < 1 || a.CheckInterval > a.IdleTimeout {
+		return errors.New("config: system.auto_stop.check_interval must be greater than 0 and no more than idle_timeout")
+	}
+	switch a.Probe {
+	case AutoStopProbeConnections, AutoStopProbeNetwork:
+	case AutoStopProbeLogPattern:
+		if a.LogPattern == "" {
+			return errors.New("config: system.auto_stop.log_pattern must be set when using the \"log_pattern\" probe")
+		}
+		if _, err := regexp.Compile(a.LogPattern); err != nil {
+			return errors.Wrap(err, "config: system.auto_stop.log_pattern is not a valid regular expression")
+		}
+	default:
+		return errors.New("config: system.auto_stop.probe must be one of \"connections\", \"network\" or \"log_pattern\"")
+	}
+	return nil
+}
+
+const (
+	EnvironmentPolicyDrop   = "drop"
+	EnvironmentPolicyReject = "reject"
+)
+
+// PanelEnvironmentPolicy defines the environment variables that the Panel may set
+// for a server. Variable names are matched after being converted to uppercase, and
+// the allow and deny lists accept shell patterns such as "LD_*". The variables set
+// by Wings itself (TZ, STARTUP, SERVER_MEMORY, SERVER_IP and SERVER_PORT) are not
+// affected by the policy.
+//
+// Denying variables that change how processes are loaded, such as "LD_*", protects
+// the node against a compromised Panel injecting them into server containers.
+type PanelEnvironmentPolicy struct {
+	// Allowed is the list of variables that may be set. If empty any variable that
+	// is not denied may be set.
+	Allowed []string `yaml:"allowed"`
+
+	// Denied is the list of variables that may never be set. This takes priority
+	// over the allowed list.
+	Denied []string `yaml:"denied"`
+
+	// Values maps a variable name to a regular expression that its whole value must
+	// match.
+	Values map[string]string `yaml:"values"`
+
+	// Action determines what happens when a variable violates the policy.
+	//
+	// "drop" -> the variable is removed from the server's environment
+	// "reject" -> the server is not started or installed until the Panel sends a
+	//             compliant environment, offending variables are also removed
+	Action string `default:"drop" yaml:"action"`
+}
+
+// valueRegexps caches the compiled value patterns of the environment policy.
+var valueRegexps sync.Map
+
+// Check returns an error describing why the variable violates the policy, or nil
+// if the Panel is allowed to set it.
+func (p PanelEnvironmentPolicy) Check(key, value string) error {
+	key = strings.ToUpper(key)
+	for _, pattern := range p.Denied {
+		if ok, _ := path.Match(pattern, key); ok {
+			return errors.Errorf("environment variable %s is denied by pattern \"%s\"", key, pattern)
+		}
+	}
+	if len(p.Allowed) > 0 {
+		allowed := false
+		for _, pattern := range p.Allowed {
+			if ok, _ := path.Match(pattern, key); ok {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.Errorf("environment variable %s is not in the allowed list", key)
+		}
+	}
+	if pattern, ok := p.Values[key]; ok {
+		var re *regexp.Regexp
+		if v, ok := valueRegexps.Load(pattern); ok {
+			re = v.(*regexp.Regexp)
+		} else {
+			var err error
+			if re, err = regexp.Compile("^(?:" + pattern + ")$"); err != nil {
+				return errors.Errorf("environment variable %s has an invalid value pattern", key)
+			}
+			valueRegexps.Store(pattern, re)
+		}
+		if !re.MatchString(value) {
+			return errors.Errorf("value of environment variable %s does not match \"%s\"", key, pattern)
+		}
+	}
+	return nil
+}
+
+// validate checks that all of the patterns in the policy can be used.
+func (p PanelEnvironmentPolicy) validate() error {
+	for _, pattern := range append(append([]string{}, p.Allowed...), p.Denied...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Errorf("config: system.panel_environment_policy pattern \"%s\" is invalid", pattern)
+		}
+		// Variable names are uppercased before being matched, so a pattern with
+		// lowercase letters would never match anything.
+		if pattern != strings.ToUpper(pattern) {
+			return errors.Errorf("config: system.panel_environment_policy pattern \"%s\" must be uppercase", pattern)
+		}
+	}
+	for key, pattern := range p.Values {
+		if key != strings.ToUpper(key) {
+			return errors.Errorf("config: system.panel_environment_policy.values key \"%s\" must be uppercase", key)
+		}
+		if _, err := regexp.Compile("^(?:" + pattern + ")$"); err != nil {
+			return errors.Wrapf(err, "config: system.panel_environment_policy.values pattern for %s is invalid", key)
+		}
+	}
+	if p.Action != EnvironmentPolicyDrop && p.Action != EnvironmentPolicyReject {
+		return errors.New("config: system.panel_environment_policy.action must be either \"drop\" or \"reject\"")
+	}
+	return nil
+}
+
+const (
+	ResourceBoundsClamp  = "clamp"
+	ResourceBoundsReject = "reject"
+)
+
+// ResourceBound is the range of values the Panel may assign for a resource. A
+// maximum of 0 leaves the resource without an upper bound.
+type ResourceBound struct {
+	Min int64 `yaml:"min"`
+	Max int64 `yaml:"max"`
+}
+
+// Clamp returns the value moved into the range of the bound.
+func (b ResourceBound) Clamp(v int64) int64 {
+	if b.Max > 0 && v > b.Max {
+		return b.Max
+	}
+	if v < b.Min {
+		return b.Min
+	}
+	return v
+}
+
+func (b ResourceBound) validate(name string) error {
+	if b.Min < 0 || b.Max < 0 {
+		return errors.Errorf("config: system.resource_bounds.%s values must not be negative", name)
+	}
+	if b.Max > 0 && b.Min > b.Max {
+		return errors.Errorf("config: system.resource_bounds.%s.min must not be greater than max", name)
+	}
+	return nil
+}
+
+// ResourceBounds limits the resources that the Panel is able to assign to servers
+// on this node, protecting it from mistaken or malicious assignments. Unlimited
+// assignments are treated as exceeding any configured maximum. By default no
+// bounds are applied.
+type ResourceBounds struct {
+	// Mode determines what happens when an assignment is out of bounds.
+	//
+	// "clamp" -> the assignment is moved into the bounds before being applied
+	// "reject" -> the server is not started, and running servers are not updated,
+	//             until the Panel sends an assignment within the bounds
+	Mode string `default:"clamp" yaml:"mode"`
+
+	// Memory is the range of memory in MiB that may be assigned.
+	Memory ResourceBound `yaml:"memory"`
+
+	// Disk is the range of disk space in MiB that may be assigned.
+	Disk ResourceBound `yaml:"disk"`
+
+	// Cpu is the range of CPU percentages that may be assigned, where 100 is one
+	// full thread.
+	Cpu ResourceBound `yaml:"cpu"`
+
+	// Swap is the range of swap in MiB that may be assigned.
+	Swap ResourceBound `yaml:"swap"`
+}
+
+func (b ResourceBounds) validate() error {
+	if b.Mode != ResourceBoundsClamp && b.Mode != ResourceBoundsReject {
+		return errors.New("config: system.resource_bounds.mode must be either \"clamp\" or \"reject\"")
+	}
+	for name, bound := range map[string]ResourceBound{"memory": b.Memory, "disk": b.Disk, "cpu": b.Cpu, "swap": b.Swap} {
+		if err := bound.validate(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StateTransitionLog defines the log of changes in the power state of servers. Each
+// entry includes the previous and new state, what triggered the change, such as the
+// Panel, a crash or the auto-stop feature, and how long the server was in the
+// previous state.
+type StateTransitionLog struct {
+	// Enabled determines if state transitions are logged.
+	Enabled bool `default:"false" yaml:"enabled"`
+
+	// File is the absolute path of a file that state transitions are written to as
+	// JSON lines. If empty they are written to the main Wings log.
+	File string `default:"" yaml:"file"`
+}
+
+func (l StateTransitionLog) validate() error {
+	if l.File != "" && !filepath.IsAbs(l.File) {
+		return errors.New("config: system.state_transition_log.file must be an absolute path")
+	}
+	return nil
+}
+
+const (
+	OwnershipNormalizationPostStop = "post_stop"
+	OwnershipNormalizationPeriodic = "periodic"
+	OwnershipNormalizationBoth     = "both"
+)
+
+// OwnershipNormalization controls changing the ownership of server files that were
+// written by the server process as another user, commonly root, back to the system
+// user. Only entries with a different owner are changed.
+type OwnershipNormalization struct {
+	// Enabled determines if server files have their ownership normalized.
+	Enabled bool `default:"false" yaml:"enabled"`
+
+	// Mode determines when the ownership of server files is normalized.
+	//
+	// "post_stop" -> after the server process stops
+	// "periodic" -> every interval seconds for all servers that are not running
+	// "both" -> both of the above
+	Mode string `default:"post_stop" yaml:"mode"`
+
+	// Interval is the number of seconds between periodic normalizations.
+	Interval int `default:"3600" yaml:"interval"`
+
+	// UsernsOffset is added to the uid and gid of the system user when the Docker
+	// daemon has user namespace remapping enabled, so that files are owned by the
+	// remapped system user. This should be the start of the subordinate ID range
+	// used by the daemon. Set to 0 to always use the system user.
+	UsernsOffset int `default:"0" yaml:"userns_offset"`
+}
+
+// PostStop returns true if the ownership of server files is normalized after the
+// server process stops.
+func (o OwnershipNormalization) PostStop() bool {
+	return o.Enabled && (o.Mode == OwnershipNormalizationPostStop || o.Mode == OwnershipNormalizationBoth)
+}
+
+// Periodic returns true if the ownership of server files is normalized on a
+// schedule.
+func (o OwnershipNormalization) Periodic() bool {
+	return o.Enabled && (o.Mode == OwnershipNormalizationPeriodic || o.Mode == OwnershipNormalizationBoth)
+}
+
+func (o OwnershipNormalization) validate() error {
+	if !o.Enabled {
+		return nil
+	}
+	switch o.Mode {
+	case OwnershipNormalizationPostStop, OwnershipNormalizationPeriodic, OwnershipNormalizationBoth:
+	default:
+		return errors.New("config: system.ownership_normalization.mode must be one of \"post_stop\", \"periodic\" or \"both\"")
+	}
+	if o.Periodic() && o.Interval < 60 {
+		return errors.New("config: system.ownership_normalization.interval must be at least 60 seconds")
+	}
+	if o.UsernsOffset < 0 {
+		return errors.New("config: system.ownership_normalization.userns_offset must not be negative")
+	}
+	return nil
+}
+
+const (
+	StateReconciliationTrustContainer = "trust_container"
+	StateReconciliationTrustState     = "trust_state"
+	StateReconciliationTrustPanel     = "trust_panel"
+)
+
+// StateReconciliation controls periodically comparing the state recorded by Wings for
+// each server with the state of its container, and repairing any discrepancy, such
+// as a server that is shown as offline while its container is running after Wings
+// missed events from Docker.
+type StateReconciliation struct {
+	// Enabled determines if the states of servers are reconciled.
+	Enabled bool `default:"false" yaml:"enabled"`
+
+	// Policy determines how a discrepancy is repaired.
+	//
+	// "trust_container" -> the recorded state is updated to match the container
+	// "trust_state" -> the container is started or stopped to match the recorded state
+	// "trust_panel" -> the configuration of the server is synced from the Panel first
+	// and the container of a suspended server is stopped, otherwise the recorded state
+	// is updated to match the container since the Panel does not track whether a
+	// server is running
+	Policy string `default:"trust_container" yaml:"policy"`
+
+	// Interval is the number of seconds between reconciliations.
+	Interval int `default:"300" yaml:"interval"`
+}
+
+func (r StateReconciliation) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	switch r.Policy {
+	case StateReconciliationTrustContainer, StateReconciliationTrustState, StateReconciliationTrustPanel:
+	default:
+		return errors.New("config: system.state_reconciliation.policy must be one of \"trust_container\", \"trust_state\" or \"trust_panel\"")
+	}
+	if r.Interval < 30 {
+		return errors.New("config: system.state_reconciliation.interval must be at least 30 seconds")
+	}
+	return nil
+}
+
+const (
+	AuditDestinationFile    = "file"
+	AuditDestinationWebhook = "webhook"
+	AuditDestinationSyslog  = "syslog"
+)
+
+// AuditOperations are the file operations that can be recorded in the audit log.
+var AuditOperations = []string{
+	"read", "write", "delete", "rename", "copy", "create_directory",
+	"chmod", "compress", "decompress", "upload", "pull", "symlink",
+}
+
+// AuditConfiguration defines the audit log of file operations that are performed
+// on servers through the API and the SFTP server. Records are written in batches
+// in the background so that file operations are never blocked by the audit log.
+type AuditConfiguration struct {
+	Enabled bool `default:"false" yaml:"enabled"`
+
+	// Destination is where audit records are written to.
+	//
+	// "file" -> each record is appended to File as a line of JSON
+	// "webhook" -> batches of records are sent to WebhookUrl as a JSON array
+	// "syslog" -> each record is sent to syslog as JSON
+	Destination string `default:"file" yaml:"destination"`
+
+	// File is the path of the audit log file, defaulting to audit.log in the log
+	// directory.
+	File string `yaml:"file"`
+
+	// WebhookUrl is the URL that batches of records are POSTed to.
+	WebhookUrl string `yaml:"webhook_url"`
+
+	// SyslogNetwork and SyslogAddress define the syslog server to send records to.
+	// If the network is empty the local syslog server is used.
+	SyslogNetwork string `yaml:"syslog_network"`
+	SyslogAddress string `yaml:"syslog_address"`
+
+	// Operations is the list of operations to record, if empty every operation is
+	// recorded.
+	Operations []string `yaml:"operations"`
+
+	// BufferSize is the number of records that may be waiting to be written. Once
+	// the buffer is full new records are dropped and a warning is logged.
+	BufferSize int `default:"1024" yaml:"buffer_size"`
+}
+
+// Includes returns true if the given operation is one of the operations listed in
+// Operations, regardless of whether the audit log is enabled.
+func (a AuditConfiguration) Includes(operation string) bool {
+	if len(a.Operations) == 0 {
+		return true
+	}
+	for _, op := range a.Operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func (a AuditConfiguration) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	switch a.Destination {
+	case AuditDestinationFile:
+		if a.File != "" && !filepath.IsAbs(a.File) {
+			return errors.New("config: system.audit.file must be an absolute path")
+		}
+	case AuditDestinationWebhook:
+		u, err := url.Parse(a.WebhookUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("config: system.audit.webhook_url must be a valid http or https URL")
+		}
+	case AuditDestinationSyslog:
+		switch a.SyslogNetwork {
+		case "":
+		case "udp", "tcp", "unix":
+			if a.SyslogAddress == "" {
+				return errors.New("config: system.audit.syslog_address must be set when system.audit.syslog_network is set")
+			}
+		default:
+			return errors.New("config: system.audit.syslog_network must be one of \"udp\", \"tcp\" or \"unix\"")
+		}
+	default:
+		return errors.New("config: system.audit.destination must be one of \"file\", \"webhook\" or \"syslog\"")
+	}
+	for _, op := range a.Operations {
+		if !slices.Contains(AuditOperations, op) {
+			return errors.Errorf("config: system.audit.operations contains unknown operation \"%s\"", op)
+		}
+	}
+	if a.BufferSize < 1 {
+		return errors.New("config: system.audit.buffer_size must be at least 1")
+	}
+	return nil
+}
+
+const (
+	CpuLimitModeHard = "hard"
+	CpuLimitModeSoft = "soft"
+	CpuLimitModeBoth = "both"
+)
+
+const (
+	LogForwardingLoki          = "loki"
+	LogForwardingElasticsearch = "elasticsearch"
+	LogForwardingSyslog        = "syslog"
+)
+
+// LogForwardingStreams are the logs that can be forwarded to an external collector.
+var LogForwardingStreams = []string{"daemon", "console", "audit"}
+
+// LogForwardingConfiguration defines the forwarding of logs to an external log
+// collector. Logs are queued and sent in batches in the background, if the
+// collector is unable to keep up the queue fills and further entries are dropped
+// rather than slowing down Wings.
+type LogForwardingConfiguration struct {
+	Enabled bool `default:"false" yaml:"enabled"`
+
+	// Destination is the type of collector that logs are sent to, one of "loki",
+	// "elasticsearch" or "syslog".
+	Destination string `default:"loki" yaml:"destination"`
+
+	// Endpoint is the base URL of the Loki or Elasticsearch server.
+	Endpoint string `yaml:"endpoint"`
+
+	// Index is the Elasticsearch index that logs are written to.
+	Index string `default:"wings" yaml:"index"`
+
+	// SyslogNetwork and SyslogAddress define the syslog server that logs are sent to.
+	// If both are empty the local syslog daemon is used.
+	SyslogNetwork string `yaml:"syslog_network"`
+	SyslogAddress string `yaml:"syslog_address"`
+
+	// Streams are the logs that are forwarded, any of "daemon" for the Wings log,
+	// "console" for the console output of servers, and "audit" for the audit log.
+	// The audit log is forwarded even if system.audit is not enabled, filtered by
+	// system.audit.operations.
+	Streams []string `default:"[\"daemon\"]" yaml:"streams"`
+
+	// BatchSize is the maximum number of entries sent to the collector at once.
+	BatchSize int `default:"100" yaml:"batch_size"`
+
+	// FlushInterval is the maximum number of seconds entries are queued for before
+	// they are sent to the collector.
+	FlushInterval int `default:"5" yaml:"flush_interval"`
+
+	// BufferSize is the number of entries that can be queued before further entries
+	// are dropped.
+	BufferSize int `default:"4096" yaml:"buffer_size"`
+}
+
+// Forwards returns true if the given log stream is forwarded.
+func (l LogForwardingConfiguration) Forwards(stream string) bool {
+	return l.Enabled && slices.Contains(l.Streams, stream)
+}
+
+func (l LogForwardingConfiguration) validate() error {
+	if !l.Enabled {
+		return nil
+	}
+	switch l.Destination {
+	case LogForwardingLoki, LogForwardingElasticsearch:
+		u, err := url.Parse(l.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("config: system.log_forwarding.endpoint must be a valid http or https URL")
+		}
+		if l.Destination == LogForwardingElasticsearch && l.Index == "" {
+			return errors.New("config: system.log_forwarding.index must be set when forwarding to elasticsearch")
+		}
+	case LogForwardingSyslog:
+		switch l.SyslogNetwork {
+		case "":
+		case "udp", "tcp", "unix":
+			if l.SyslogAddress == "" {
+				return errors.New("config: system.log_forwarding.syslog_address must be set when system.log_forwarding.syslog_network is set")
+			}
+		default:
+			return errors.New("config: system.log_forwarding.syslog_network must be one of \"udp\", \"tcp\" or \"unix\"")
+		}
+	default:
+		return errors.New("config: system.log_forwarding.destination must be one of \"loki\", \"elasticsearch\" or \"syslog\"")
+	}
+	if len(l.Streams) == 0 {
+		return errors.New("config: system.log_forwarding.streams must contain at least one stream")
+	}
+	for _, s := range l.Streams {
+		if !slices.Contains(LogForwardingStreams, s) {
+			return errors.Errorf("config: system.log_forwarding.streams contains unknown stream \"%s\"", s)
+		}
+	}
+	if l.BatchSize < 1 {
+		return errors.New("config: system.log_forwarding.batch_size must be at least 1")
+	}
+	if l.FlushInterval < 1 {
+		return errors.New("config: system.log_forwarding.flush_interval must be at least 1 second")
+	}
+	if l.BufferSize < 1 {
+		return errors.New("config: system.log_forwarding.buffer_size must be at least 1")
+	}
+	return nil
+}
+
+const (
+	StorageDriverActionWarn   = "warn"
+	StorageDriverActionRefuse = "refuse"
+)
+
+const (
+	ConsoleInvalidUtf8Replace = "replace"
+	ConsoleInvalidUtf8Strip   = "strip"
+	ConsoleInvalidUtf8Hex     = "hex"
+)
+
+const (
+	ServerIdentifiersSanitize = "sanitize"
+	ServerIdentifiersReject   = "reject"
+)
+
+const (
+	KernelFeatureActionWarn   = "warn"
+	KernelFeatureActionRefuse = "refuse"
+)
+
+const (
+	ClockSkewActionWarn   = "warn"
+	ClockSkewActionRefuse = "refuse"
+)
+
+// ClockSkewCheck controls checking the clock of this node against the clock of the
+// Panel, using the Date header of responses from the Panel. A clock that is off
+// causes tokens issued by the Panel to be rejected, and schedules to run at the
+// wrong time.
+type ClockSkewCheck struct {
+	// Threshold is the number of seconds the clocks may differ by before a warning is
+	// logged. Since the Date header only has a resolution of one second this must be
+	// at least 2.
+	Threshold int `default:"30" yaml:"threshold"`
+
+	// Interval is the number of seconds between checks of the clock once Wings has
+	// booted, in addition to the check made when booting and the measurements taken
+	// from every other request to the Panel. Set to 0 to only check when booting.
+	Interval int `default:"3600" yaml:"interval"`
+
+	// Action determines what happens while the clocks differ by more than the threshold.
+	//
+	// "warn" -> a warning is logged
+	// "refuse" -> a warning is logged and tokens issued by the Panel are rejected, which
+	// refuses console connections, file uploads and downloads, and transfers. Tokens are
+	// only rejected while the measurement was taken within twice the interval, so this
+	// requires an interval to be set.
+	Action string `default:"warn" yaml:"action"`
+}
+
+func (c ClockSkewCheck) validate() error {
+	if c.Threshold < 2 {
+		return errors.New("config: system.clock_skew.threshold must be at least 2 seconds")
+	}
+	if c.Interval != 0 && c.Interval < 60 {
+		return errors.New("config: system.clock_skew.interval must be 0, or at least 60 seconds")
+	}
+	if c.Action != ClockSkewActionWarn && c.Action != ClockSkewActionRefuse {
+		return errors.New("config: system.clock_skew.action must be either \"warn\" or \"refuse\"")
+	}
+	if c.Action == ClockSkewActionRefuse && c.Interval == 0 {
+		return errors.New("config: system.clock_skew.interval must be set when system.clock_skew.action is \"refuse\"")
+	}
+	return nil
+}
+
+// discouragedStorageDrivers are the Docker storage drivers that are not allowed
+// when no allowlist is configured.
+var discouragedStorageDrivers = []string{"vfs", "devicemapper"}
+
+var storageDriverRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// StorageDriverAllowed returns true if Wings may run with the Docker storage
+// driver provided.
+func (sc SystemConfiguration) StorageDriverAllowed(driver string) bool {
+	if len(sc.AllowedStorageDrivers) == 0 {
+		return !slices.Contains(discouragedStorageDrivers, driver)
+	}
+	return slices.Contains(sc.AllowedStorageDrivers, driver)
 }
 
 // SystemConfiguration defines basic system configuration settings.
@@ -141,9 +1363,24 @@ type SystemConfiguration struct {
 	// should be created. This supports environments running docker-in-docker.
 	TmpDirectory string `default:"/tmp/pterodactyl" json:"-" yaml:"tmp_directory"`
 
+	// InstallEnvironment is a set of additional environment variables that are passed only into
+	// installation containers. This can be used to provide mirror URLs or proxy settings to the
+	// installation scripts without affecting the environment of the running server process.
+	InstallEnvironment map[string]string `json:"-" yaml:"install_environment"`
+
+	// If set to true, the proxy environment variables set for the Wings process (HTTP_PROXY,
+	// HTTPS_PROXY, NO_PROXY and their lowercase variants) are passed through into installation
+	// containers. Any value defined in InstallEnvironment takes priority.
+	InstallUseHostProxy bool `default:"false" json:"-" yaml:"install_use_host_proxy"`
+
 	// The user that should own all of the server files, and be used for containers.
 	Username string `default:"pterodactyl" yaml:"username"`
 
+	// If set to true, server containers are allowed to use the "host" network mode. This gives
+	// a container full access to the host's network stack and should only be enabled if every
+	// server on this node is trusted.
+	AllowHostNetwork bool `default:"false" yaml:"allow_host_network"`
+
 	// The timezone for this Wings instance. This is detected by Wings automatically if possible,
 	// and falls back to UTC if not able to be detected. If you need to set this manually, that
 	// can also be done.
@@ -182,6 +1419,116 @@ type SystemConfiguration struct {
 	// disk usage is not a concern.
 	DiskCheckInterval int64 `default:"150" yaml:"disk_check_interval"`
 
+	// DiskCheckMaxFiles is the maximum number of files and directories that will be walked
+	// when calculating the disk usage of a server. Once this limit is exceeded the walk is
+	// aborted, the partial size is used as the disk usage for the server, and the server is
+	// flagged as having too many files. Set to 0 to disable the limit.
+	DiskCheckMaxFiles int64 `default:"0" yaml:"disk_check_max_files"`
+
+	// If set to true, servers that have been flagged as having too many files by the disk
+	// check are not allowed to write any additional data until their file count is reduced
+	// below system.disk_check_max_files. This effectively acts as an inode quota.
+	EnforceDiskCheckMaxFiles bool `default:"false" yaml:"enforce_disk_check_max_files"`
+
+	// ServerLogRetention controls the pruning of log files written by servers into their
+	// data directories, which is run on the same interval as disk checking.
+	ServerLogRetention ServerLogRetention `json:"server_log_retention" yaml:"server_log_retention"`
+
+	// CleanStaleLocksOnStart removes the lock files defined in stale_locks from the data
+	// directory of a server before it is started, as long as its container is not
+	// running. This is disabled by default since it deletes files from server data
+	// directories.
+	CleanStaleLocksOnStart bool `default:"false" yaml:"clean_stale_locks_on_start"`
+
+	// StaleLocks defines the lock files removed when clean_stale_locks_on_start is
+	// enabled.
+	StaleLocks StaleLocks `json:"stale_locks" yaml:"stale_locks"`
+
+	// SecretMasking controls the masking of the values of secret environment variables,
+	// such as passwords and API keys, in daemon logs and forwarded console output.
+	SecretMasking SecretMasking `json:"secret_masking" yaml:"secret_masking"`
+
+	// If set to true, writes made through SFTP are tracked against an incremental disk usage
+	// counter for the server, which is seeded by the last full disk check. Once a server exceeds
+	// its disk limit any further writes are refused immediately rather than waiting for the next
+	// disk check to occur. This trades some accuracy for immediacy, since changes made to the
+	// files by the server process itself are only picked up by the next full disk check.
+	EnforceDiskQuotaOnWrite bool `default:"false" yaml:"enforce_disk_quota_on_write"`
+
+	// UploadStagingDir is a directory that files uploaded through the API and remote
+	// downloads are written to while they are in progress, before being moved into the
+	// server directory once complete. This allows in-progress uploads to be placed on
+	// faster storage than the server data. If the directory is on a different filesystem
+	// the completed file is copied into place instead. If empty, files are written in
+	// place within the server directory.
+	UploadStagingDir string `yaml:"upload_staging_dir"`
+
+	// FollowSymlinks controls how symlinks within server directories are handled by the file
+	// manager, SFTP server, and archive extraction.
+	//
+	// "deny" -> any path that traverses a symlink is rejected, symlinks cannot be created,
+	//           and symlinks in archives are skipped during extraction
+	// "contain" -> symlinks are followed as long as their target resolves within the server
+	//              root, and symlinks that point outside the root cannot be created
+	// "allow" -> symlinks can be created pointing anywhere, however file operations still
+	//            never resolve outside the server root
+	//
+	// Defaults to "contain". Previously symlinks pointing anywhere could be created through
+	// SFTP, which is no longer possible unless this is set to "allow".
+	FollowSymlinks string `default:"contain" yaml:"follow_symlinks"`
+
+	// MinFreeDiskMB is the minimum amount of free space in MB that must be available on the
+	// filesystem containing the server data directory when Wings boots. When less space than
+	// this is available the action defined by MinFreeDiskAction is taken. Set to 0 to disable
+	// this check.
+	MinFreeDiskMB int64 `default:"0" yaml:"min_free_disk_mb"`
+
+	// MinFreeDiskAction determines what happens when the free space on the data directory's
+	// filesystem is below MinFreeDiskMB at boot.
+	//
+	// "error" -> Wings refuses to boot
+	// "warn" -> a warning is logged and Wings continues booting
+	MinFreeDiskAction string `default:"error" yaml:"min_free_disk_action"`
+
+	// CheckDataWritable creates and removes a temporary file in the server data directory
+	// when Wings boots, refusing to boot if that fails. Disable this when the data directory
+	// is intentionally mounted read-only.
+	CheckDataWritable bool `default:"true" yaml:"check_data_writable"`
+
+	// Readiness controls the dependency checks that are run while Wings boots, before it
+	// connects to Docker and loads the servers from the Panel.
+	Readiness Readiness `yaml:"readiness"`
+
+	// Extraction defines the limits applied when extracting archives through the file manager
+	// to protect against archives that decompress to an excessive size or number of files.
+	Extraction ExtractionLimits `yaml:"extraction"`
+
+	// PortRange is the range of ports that may be assigned to servers on this node. Any
+	// server assigned a port outside of this range will fail to start.
+	PortRange PortRange `yaml:"port_range"`
+
+	// TmpCleanup controls the removal of stale files left in the TmpDirectory by crashed
+	// or interrupted installation processes when Wings boots.
+	TmpCleanup struct {
+		// Enabled controls whether the temporary directory is cleaned up at boot.
+		Enabled bool `default:"false" yaml:"enabled"`
+
+		// MinAge is the minimum age in seconds a file must be before it is removed.
+		MinAge int `default:"86400" yaml:"min_age"`
+	} `yaml:"tmp_cleanup"`
+
+	// PostInstallHook defines a command that is executed on the host after a server has
+	// been successfully installed.
+	PostInstallHook PostInstallHook `yaml:"post_install_hook"`
+
+	// JwtClockSkew is the amount of time in seconds that the expiration and not-before times of
+	// tokens issued by the Panel may be off by before the token is rejected. This accounts for
+	// minor clock drift between the Panel and this node.
+	JwtClockSkew int `default:"30" json:"-" yaml:"jwt_clock_skew"`
+
+	// ClockSkew controls checking the clock of this node against the clock of the Panel.
+	ClockSkew ClockSkewCheck `yaml:"clock_skew"`
+
 	// ActivitySendInterval is the amount of time that should ellapse between aggregated server activity
 	// being sent to the Panel. By default this will send activity collected over the last minute. Keep
 	// in mind that only a fixed number of activity log entries, defined by ActivitySendCount, will be sent
@@ -197,6 +1544,193 @@ type SystemConfiguration struct {
 	// frequently modifying a servers' files.
 	CheckPermissionsOnBoot bool `default:"true" yaml:"check_permissions_on_boot"`
 
+	// ValidateStartupVariables checks the environment variables of a server against the
+	// validation rules of the variables defined by its egg before starting it, refusing to
+	// start the server with an error listing every variable that is missing or invalid.
+	// This only applies to servers whose egg variables are sent by the Panel.
+	ValidateStartupVariables bool `default:"true" yaml:"validate_startup_variables"`
+
+	// FixOwnershipOnStart controls what happens when check_permissions_on_boot is disabled
+	// and the server's root directory, or an entry directly within it, is found not to be
+	// owned by the system user when the server is started. This commonly happens after
+	// files are restored by root outside of Wings. If true the ownership of the directory
+	// is fixed before starting the server, otherwise the server fails to start with an
+	// error explaining the problem.
+	FixOwnershipOnStart bool `default:"true" yaml:"fix_ownership_on_start"`
+
+	// StopSuspendedServers determines if a server that is running when it is suspended
+	// is stopped immediately. If false the server is left running, but cannot be started
+	// again once it stops. Suspended servers can never be started, and their files cannot
+	// be accessed through the API or SFTP.
+	StopSuspendedServers bool `default:"true" yaml:"stop_suspended_servers"`
+
+	// StateTransitionLog records every change in the power state of a server along
+	// with what caused it.
+	StateTransitionLog StateTransitionLog `yaml:"state_transition_log"`
+
+	// OwnershipNormalization changes the ownership of server files written by the
+	// server process as another user back to the system user.
+	OwnershipNormalization OwnershipNormalization `yaml:"ownership_normalization"`
+
+	// StateReconciliation repairs servers whose recorded state does not match the
+	// state of their container.
+	StateReconciliation StateReconciliation `yaml:"state_reconciliation"`
+
+	// OwnershipRetry controls how changing the ownership of server files is retried when
+	// it fails with an error that may be transient, which commonly happens when server
+	// data is stored on network storage such as NFS or Ceph.
+	OwnershipRetry OwnershipRetry `yaml:"ownership_retry"`
+
+	// AllowDeviceAccess allows the devices and device requests defined under the docker
+	// configuration to be applied to server containers. Any device made available to a
+	// server gives it direct access to host hardware, so this must be explicitly enabled.
+	AllowDeviceAccess bool `default:"false" yaml:"allow_device_access"`
+
+	// AllowPrivilegedContainers allows servers that request a privileged container to be
+	// started. A privileged container has full access to the host system, so when this is
+	// false any server requesting privileged mode is refused from starting.
+	AllowPrivilegedContainers bool `default:"false" yaml:"allow_privileged_containers"`
+
+	// StrictServerUuids controls what happens when more than one server is found with the
+	// same UUID, either in the list of servers returned by the Panel, or differing only by
+	// case in the data directory or server containers. If true none of the conflicting
+	// servers are loaded and new servers cannot be created using a UUID that is already in
+	// use, otherwise the conflicts are only logged.
+	StrictServerUuids bool `default:"true" yaml:"strict_server_uuids"`
+
+	// InstallTimeout is the maximum number of seconds that the installation script of a
+	// server may run for. Once exceeded the installation container is removed and the
+	// installation is reported to the Panel as having failed. Set to 0 for no limit.
+	InstallTimeout int `default:"0" yaml:"install_timeout"`
+
+	// AutoRecoverStuckInstalls periodically checks for installation containers that are
+	// no longer being tracked by Wings, such as those left behind when Wings is restarted
+	// during an installation, or that have exceeded the install timeout. These containers
+	// are removed and the result of the installation is reported to the Panel so that the
+	// server does not remain in the installing state.
+	AutoRecoverStuckInstalls bool `default:"false" yaml:"auto_recover_stuck_installs"`
+
+	// StuckInstallCheckInterval is the number of seconds between checks for stuck
+	// installation containers.
+	StuckInstallCheckInterval int `default:"300" yaml:"stuck_install_check_interval"`
+
+	// SelfMemoryLimitMB is a soft limit in MiB on the memory used by the Wings process. As
+	// it is approached the garbage collector runs more often, and above 90% of it new file
+	// uploads and remote downloads are rejected until memory has been freed. Set to 0 for
+	// no limit.
+	SelfMemoryLimitMB int64 `default:"0" yaml:"self_memory_limit_mb"`
+
+	// SelfMemoryHardLimitMB is a hard limit in MiB set on the cgroup that Wings is running
+	// in, at which point the kernel will kill Wings rather than letting it use more memory.
+	// This requires Wings to be running in its own cgroup, as it is when started by systemd.
+	// Set to 0 to leave the cgroup unchanged.
+	SelfMemoryHardLimitMB int64 `default:"0" yaml:"self_memory_hard_limit_mb"`
+
+	// AutoStop stops servers that have had no activity for a period of time.
+	AutoStop AutoStop `yaml:"auto_stop"`
+
+	// StatePersistence controls how the state of servers is saved to the disk so that
+	// it can be restored when Wings boots.
+	StatePersistence StatePersistence `yaml:"state_persistence"`
+
+	// ConfigVersionHistory is the number of previous versions of the configuration file
+	// to keep. Each time the configuration is written and has changed, the previous file
+	// is copied to "config.yml.1", with older copies shifted up to "config.yml.N". Set to
+	// 0 to disable keeping previous versions.
+	ConfigVersionHistory int `default:"0" yaml:"config_version_history"`
+
+	// Capacity defines the resources of this node that are available to servers, which is
+	// used when reporting the utilization of the node.
+	Capacity NodeCapacity `yaml:"capacity"`
+
+	// BootStartup controls the order and pacing in which servers are restored to their
+	// previous state when Wings boots.
+	BootStartup BootStartup `yaml:"boot_startup"`
+
+	// BulkPowerConcurrency is the maximum number of servers that a bulk power action
+	// sent by the Panel is processed for at the same time.
+	BulkPowerConcurrency int `default:"4" yaml:"bulk_power_concurrency"`
+
+	// BulkPowerDelay is the minimum amount of time in seconds between beginning the
+	// power action for each server in a bulk power action.
+	BulkPowerDelay int `default:"0" yaml:"bulk_power_delay"`
+
+	// If set to true, the environment variables for a server are written to a file in the
+	// server's root directory in KEY=VALUE form each time the server is started, for games
+	// that read their configuration from a file rather than the process environment. The
+	// file is only readable by the owner since it may contain secrets.
+	WriteEnvFile bool `default:"false" yaml:"write_env_file"`
+
+	// EnvFilePath is the path of the environment file relative to the root directory of
+	// the server.
+	EnvFilePath string `default:".env" yaml:"env_file_path"`
+
+	// PanelEnvironmentPolicy restricts the environment variables that the Panel is able
+	// to set for servers. By default every variable is allowed.
+	PanelEnvironmentPolicy PanelEnvironmentPolicy `yaml:"panel_environment_policy"`
+
+	// ResourceBounds restricts the memory, disk, CPU and swap that the Panel is able
+	// to assign to servers. By default any assignment is allowed.
+	ResourceBounds ResourceBounds `yaml:"resource_bounds"`
+
+	// Audit configures the audit log of file operations performed through the API
+	// and the SFTP server.
+	Audit AuditConfiguration `yaml:"audit"`
+
+	// LogForwarding configures the forwarding of logs to an external log collector
+	// such as Loki, Elasticsearch or a syslog server.
+	LogForwarding LogForwardingConfiguration `yaml:"log_forwarding"`
+
+	// AllowedStorageDrivers is the list of Docker storage drivers that Wings may run
+	// with. If empty, every driver except those known to perform poorly or to break
+	// disk quotas ("vfs" and "devicemapper") is allowed.
+	AllowedStorageDrivers []string `yaml:"allowed_storage_drivers"`
+
+	// StorageDriverAction determines what happens when Docker uses a storage driver
+	// that is not allowed.
+	//
+	// "warn" -> a warning is logged and Wings continues to boot
+	// "refuse" -> Wings refuses to boot
+	StorageDriverAction string `default:"warn" yaml:"storage_driver_action"`
+
+	// KernelFeatureAction determines what happens when the host kernel does not support
+	// a feature that is required by the configuration, such as the cgroup pids controller
+	// when docker.container_pid_limit is set. Without the feature the related limits are
+	// silently ignored by Docker.
+	//
+	// "warn" -> a warning is logged and Wings continues to boot
+	// "refuse" -> Wings refuses to boot
+	KernelFeatureAction string `default:"warn" yaml:"kernel_feature_action"`
+
+	// CpuLimitMode determines how the CPU limit assigned to a server by the Panel is
+	// applied to its container.
+	//
+	// "hard" -> the server may never use more CPU than its limit (CPU quota)
+	// "soft" -> the limit only sets the share of CPU time the server receives when
+	//           the host is under contention, allowing it to use idle CPU (CPU shares)
+	// "both" -> the limit is applied as both a hard cap and CPU shares
+	//
+	// CPU shares assigned to a server by the Panel, or set by docker.default_cpu_shares,
+	// take precedence over the shares derived from the limit.
+	CpuLimitMode string `default:"hard" yaml:"cpu_limit_mode"`
+
+	// PermissionFixConcurrency is the maximum number of servers that can have the permissions
+	// of their files fixed at the same time. This prevents nodes with a large number of servers
+	// from exhausting memory and file descriptors when many servers are started at once.
+	PermissionFixConcurrency int `default:"4" yaml:"permission_fix_concurrency"`
+
+	// If set to true, Wings will compare the image assigned to a running server against the image
+	// used by its container whenever the server is synced with the Panel. Servers running an
+	// outdated image are flagged and their container is re-created with the new image the next
+	// time the server process is restarted.
+	AutoRecreateOnImageChange bool `default:"false" yaml:"auto_recreate_on_image_change"`
+
+	// If set to true, servers that are flagged as running an outdated image are restarted right
+	// away rather than waiting for the next restart. Servers that are suspended, installing,
+	// transferring, or restoring a backup are never restarted by this. Requires that
+	// AutoRecreateOnImageChange is also enabled.
+	RecreateImmediatelyOnImageChange bool `default:"false" yaml:"recreate_immediately_on_image_change"`
+
 	// If set to false Wings will not attempt to write a log rotate configuration to the disk
 	// when it boots and one is not detected.
 	EnableLogRotate bool `default:"true" yaml:"enable_log_rotate"`
@@ -204,6 +1738,32 @@ type SystemConfiguration struct {
 	// The number of lines to send when a server connects to the websocket.
 	WebsocketLogCount int `default:"150" yaml:"websocket_log_count"`
 
+	// ConsoleBufferLines is the number of lines of recent console output retained in
+	// memory for each server, which are sent to clients when they connect to the console
+	// instead of reading the logs of the container from Docker. Each retained line uses
+	// memory for every server on the node. Set to 0 to read the last websocket_log_count
+	// lines from the container logs instead.
+	ConsoleBufferLines int `default:"0" yaml:"console_buffer_lines"`
+
+	// ConsoleInvalidUtf8 determines how invalid UTF-8 in the console output of a server
+	// is handled before it is sent to clients, since it cannot be encoded in websocket
+	// messages.
+	//
+	// "replace" -> each run of invalid bytes is replaced with the replacement character
+	// "strip" -> invalid bytes are removed
+	// "hex" -> each invalid byte is replaced with an escape sequence such as "\xff"
+	ConsoleInvalidUtf8 string `default:"replace" yaml:"console_invalid_utf8"`
+
+	// ServerIdentifiers determines how the name, description, metadata and labels of a
+	// server received from the Panel are handled when they contain values that are not
+	// safe to use as container labels, such as control characters, invalid UTF-8, or
+	// label keys that Docker does not accept. The UUID of a server is used in paths on
+	// the host and is always required to be a valid UUIDv4, regardless of this setting.
+	//
+	// "sanitize" -> unsafe characters are removed and invalid label keys are dropped
+	// "reject" -> the server is refused with an error describing the malformed value
+	ServerIdentifiers string `default:"sanitize" yaml:"server_identifiers"`
+
 	Sftp SftpConfiguration `yaml:"sftp"`
 
 	CrashDetection CrashDetection `yaml:"crash_detection"`
@@ -212,53 +1772,570 @@ type SystemConfiguration struct {
 
 	Transfers Transfers `yaml:"transfers"`
 
-	OpenatMode string `default:"auto" yaml:"openat_mode"`
+	OpenatMode string `default:"auto" yaml:"openat_mode"`
+}
+
+// Readiness defines the dependency checks that must pass before Wings connects to
+// Docker and loads its servers. If the checks do not pass within MaxWait Wings
+// continues booting in a degraded state and logs a warning.
+type Readiness struct {
+	// Enabled controls whether the readiness checks are run at all.
+	Enabled bool `default:"true" yaml:"enabled"`
+
+	// Delay is an additional amount of time in seconds to wait before running the checks.
+	Delay int `default:"0" yaml:"delay"`
+
+	// MaxWait is the maximum amount of time in seconds to wait for all the checks to pass.
+	MaxWait int `default:"60" yaml:"max_wait"`
+
+	// Interval is the amount of time in seconds between each attempt of a failed check.
+	Interval int `default:"2" yaml:"interval"`
+
+	// CheckPanel controls whether the Panel must be reachable for Wings to be ready.
+	CheckPanel bool `default:"true" yaml:"check_panel"`
+}
+
+// ExtractionLimits defines the limits applied when a user extracts an archive. If any
+// of these limits are exceeded while extracting, the extraction is aborted and any
+// files created by it are removed.
+type ExtractionLimits struct {
+	// MaxTotalBytes is the maximum total size in bytes of the extracted files.
+	MaxTotalBytes int64 `default:"53687091200" yaml:"max_total_bytes"`
+
+	// MaxFiles is the maximum number of files that can be extracted from an archive.
+	MaxFiles int `default:"100000" yaml:"max_files"`
+
+	// MaxCompressionRatio is the maximum ratio between the total size of the extracted
+	// files and the size of the archive itself.
+	MaxCompressionRatio float64 `default:"1000" yaml:"max_compression_ratio"`
+}
+
+// PortRange defines an inclusive range of ports that servers are allowed to use.
+type PortRange struct {
+	Start int `default:"1" yaml:"start"`
+	End   int `default:"65535" yaml:"end"`
+}
+
+// IsPortAllowed returns true if the port provided is within the range.
+func (r PortRange) IsPortAllowed(port int) bool {
+	return port >= r.Start && port <= r.End
+}
+
+// AllocatePort returns a port within the range that is not currently bound to by
+// any process on the system for either TCP or UDP. Ports are checked starting from
+// a random position within the range to avoid always handing out the same ports.
+func (r PortRange) AllocatePort() (int, error) {
+	size := r.End - r.Start + 1
+	offset := rand.Intn(size)
+	for i := 0; i < size; i++ {
+		port := r.Start + (offset+i)%size
+		t, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+		if err != nil {
+			continue
+		}
+		_ = t.Close()
+		u, err := net.ListenPacket("udp", ":"+strconv.Itoa(port))
+		if err != nil {
+			continue
+		}
+		_ = u.Close()
+		return port, nil
+	}
+	return 0, errors.Errorf("config: no free ports available between %d and %d", r.Start, r.End)
+}
+
+// PostInstallHook defines a command that is run on the host machine once a server
+// has finished installing, for example to register it with an external monitoring
+// system or to open firewall ports.
+type PostInstallHook struct {
+	// Command is the command to execute using "/bin/sh -c". This is a template that is
+	// provided with the {{.Uuid}}, {{.Ip}} and {{.Port}} of the server's default
+	// allocation, which are quoted for the shell when substituted. The same values are
+	// also available to the command as the WINGS_SERVER_UUID, WINGS_SERVER_IP and
+	// WINGS_SERVER_PORT environment variables. If empty no hook is executed.
+	Command string `default:"" yaml:"command"`
+
+	// Timeout is the maximum amount of time in seconds the command may run before it
+	// is killed.
+	Timeout int `default:"60" yaml:"timeout"`
+
+	// FailInstall controls whether a failing hook causes the installation to be reported
+	// to the Panel as failed. By default failures are only logged.
+	FailInstall bool `default:"false" yaml:"fail_install"`
+}
+
+// PostInstallHookData is the data provided to the post-install hook command template.
+type PostInstallHookData struct {
+	Uuid string
+	Ip   string
+	Port int
+}
+
+// Env returns the server details as environment variables for the post-install
+// hook command.
+func (d PostInstallHookData) Env() []string {
+	return []string{
+		"WINGS_SERVER_UUID=" + d.Uuid,
+		"WINGS_SERVER_IP=" + d.Ip,
+		"WINGS_SERVER_PORT=" + strconv.Itoa(d.Port),
+	}
+}
+
+// shellQuote quotes a value so that it is passed to "/bin/sh -c" as a single word
+// without being interpreted by the shell.
+func shellQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'"'"'`) + "'"
+}
+
+// Render returns the post-install hook command with the server details substituted
+// into the template. The values are provided by the Panel, so they are quoted for
+// the shell rather than being spliced into the command as-is.
+func (h PostInstallHook) Render(data PostInstallHookData) (string, error) {
+	t, err := template.New("post_install_hook").Option("missingkey=error").Parse(h.Command)
+	if err != nil {
+		return "", errors.Wrap(err, "config: failed to parse post-install hook template")
+	}
+	quoted := PostInstallHookData{Uuid: shellQuote(data.Uuid), Ip: shellQuote(data.Ip), Port: data.Port}
+	var b strings.Builder
+	if err := t.Execute(&b, quoted); err != nil {
+		return "", errors.Wrap(err, "config: failed to render post-install hook template")
+	}
+	return b.String(), nil
+}
+
+type CrashDetection struct {
+	// CrashDetectionEnabled sets if crash detection is enabled globally for all servers on this node.
+	CrashDetectionEnabled bool `default:"true" yaml:"enabled"`
+
+	// Determines if Wings should detect a server that stops with a normal exit code of
+	// "0" as being crashed if the process stopped without any Wings interaction. E.g.
+	// the user did not press the stop button, but the process stopped cleanly.
+	DetectCleanExitAsCrash bool `default:"true" yaml:"detect_clean_exit_as_crash"`
+
+	// Timeout specifies the timeout between crashes that will not cause the server
+	// to be automatically restarted, this value is used to prevent servers from
+	// becoming stuck in a boot-loop after multiple consecutive crashes.
+	Timeout int `default:"60" json:"timeout"`
+}
+
+type Backups struct {
+	// WriteLimit imposes a Disk I/O write limit on backups to the disk, this affects all
+	// backup drivers as the archiver must first write the file to the disk in order to
+	// upload it to any external storage provider.
+	//
+	// If the value is less than 1, the write speed is unlimited,
+	// if the value is greater than 0, the write speed is the value in MiB/s.
+	//
+	// Defaults to 0 (unlimited)
+	WriteLimit int `default:"0" yaml:"write_limit"`
+
+	// Format determines the archive format used for backups created by wings.
+	//
+	// "targz" -> a gzip compressed tar archive
+	// "zip" -> a zip archive using deflate compression
+	// "zstd" -> a zstd compressed tar archive
+	//
+	// Defaults to "targz". Backups are restored based on their contents, so changing
+	// the format does not affect restoring backups that have already been created.
+	Format string `default:"targz" yaml:"format"`
+
+	// CompressionLevel determines how much backups created by wings should be compressed.
+	//
+	// "none" -> no compression will be applied, this is not supported by zstd
+	// "best_speed" -> uses level 1 for fast speed
+	// "best_compression" -> uses level 9 (19 for zstd) for minimal disk space useage
+	//
+	// A numeric level may also be provided, between 0 and 9 for targz and zip, or
+	// between 1 and 22 for zstd.
+	//
+	// Defaults to "best_speed" (level 1)
+	CompressionLevel string `default:"best_speed" yaml:"compression_level"`
+
+	// Exclude are gitignore style patterns of the files that are left out of every
+	// backup, such as caches that the server regenerates. These can be overridden on a
+	// per-server basis. The ignored files of a backup, or the .pteroignore file of the
+	// server, are applied after these so that a pattern starting with "!" in them can
+	// include a file excluded here again. Defaults to backing up everything.
+	Exclude []string `yaml:"exclude"`
+
+	// MaxConcurrentRestores is the maximum number of backups that are restored at the
+	// same time on this node. Any other restorations are queued until one completes.
+	MaxConcurrentRestores int `default:"2" yaml:"max_concurrent_restores"`
+
+	// Schedule controls the automatic creation of backups for servers on this node
+	// without needing an external cron or the Panel to trigger them.
+	Schedule BackupSchedule `yaml:"schedule"`
+
+	// RemoteStorage defines where backups created with the local adapter are copied to
+	// once they have been created, and how backups are uploaded to S3.
+	RemoteStorage BackupRemoteStorage `yaml:"remote_storage"`
+}
+
+// Drivers for the remote storage of backups.
+const (
+	BackupStorageLocal = "local"
+	BackupStorageS3    = "s3"
+)
+
+// BackupRemoteStorage defines an S3 compatible bucket that local backups are
+// copied to once they have been created. The local copy of the backup is kept
+// so that it can still be downloaded and restored through the Panel, and the
+// copy in the bucket is removed when the backup is deleted. A backup that cannot
+// be copied to the bucket is still kept locally and reported as successful, with
+// the failure logged and shown in the server console.
+type BackupRemoteStorage struct {
+	// Driver is either "local", in which case backups are only stored on this node,
+	// or "s3" to copy them to the bucket defined below.
+	Driver string `default:"local" yaml:"driver"`
+
+	// Endpoint is the URL of the S3 compatible API, such as "https://s3.amazonaws.com".
+	Endpoint string `yaml:"endpoint"`
+
+	// Region is the region of the bucket, used when signing requests.
+	Region string `default:"us-east-1" yaml:"region"`
+
+	// Bucket is the name of the bucket that backups are stored in.
+	Bucket string `yaml:"bucket"`
+
+	// Prefix is prepended to the name of every backup stored in the bucket, such as
+	// "node-1/".
+	Prefix string `yaml:"prefix"`
+
+	// AccessKeyID and SecretAccessKey are the credentials used to access the bucket.
+	// These are never logged or returned by the API.
+	AccessKeyID     string `json:"-" yaml:"access_key_id"`
+	SecretAccessKey string `json:"-" yaml:"secret_access_key"`
+
+	// PathStyle addresses the bucket as part of the path of the endpoint rather than
+	// as a subdomain of it, which most self-hosted S3 compatible services require.
+	PathStyle bool `default:"false" yaml:"path_style"`
+
+	// PartSize is the size in MiB of each part of a multipart upload to the bucket,
+	// between 5 and 5120. The part size of backups uploaded with the s3 adapter is
+	// decided by the Panel.
+	PartSize int64 `default:"64" yaml:"part_size"`
+
+	// Concurrency is the number of parts of a backup that are uploaded at the same
+	// time. This applies to both uploads to the bucket defined here and uploads of
+	// backups created with the s3 adapter.
+	Concurrency int `default:"4" yaml:"concurrency"`
+}
+
+// validate checks that the remote storage for backups is usable if it is enabled.
+// This does not check that the bucket can be accessed with the credentials, which
+// happens when Wings boots.
+func (r BackupRemoteStorage) validate() error {
+	if r.Concurrency < 1 || r.Concurrency > 64 {
+		return errors.New("config: system.backups.remote_storage.concurrency must be between 1 and 64")
+	}
+	switch r.Driver {
+	case BackupStorageLocal:
+		return nil
+	case BackupStorageS3:
+	default:
+		return errors.New("config: system.backups.remote_storage.driver must be either \"local\" or \"s3\"")
+	}
+	if u, err := url.Parse(r.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("config: system.backups.remote_storage.endpoint must be an http or https URL")
+	}
+	if r.Bucket == "" || r.Region == "" {
+		return errors.New("config: system.backups.remote_storage.bucket and region must be set")
+	}
+	if r.AccessKeyID == "" || r.SecretAccessKey == "" {
+		return errors.New("config: system.backups.remote_storage.access_key_id and secret_access_key must be set")
+	}
+	if r.PartSize < 5 || r.PartSize > 5120 {
+		return errors.New("config: system.backups.remote_storage.part_size must be between 5 and 5120")
+	}
+	return nil
+}
+
+// NodeCapacity defines the resources of a node that are available to servers. A
+// value of 0 means that the capacity for that resource is not defined.
+type NodeCapacity struct {
+	// Memory is the amount of memory in MiB available to servers.
+	Memory int64 `default:"0" json:"memory" yaml:"memory"`
+	// Disk is the amount of disk space in MiB available to servers.
+	Disk int64 `default:"0" json:"disk" yaml:"disk"`
+	// Cpu is the amount of CPU available to servers, as a percentage of a single
+	// thread, for example 400 for four threads.
+	Cpu int64 `default:"0" json:"cpu" yaml:"cpu"`
+}
+
+// IsConfigured returns true if the capacity of at least one resource is defined.
+func (n NodeCapacity) IsConfigured() bool {
+	return n.Memory > 0 || n.Disk > 0 || n.Cpu > 0
+}
+
+// BootStartup defines how servers are restored to their previous state when Wings
+// boots, allowing the servers on a node to be started in a defined order without
+// overwhelming the host by starting all of them at once.
+type BootStartup struct {
+	// Concurrency is the maximum number of servers that are restored at the same time.
+	Concurrency int `default:"4" yaml:"concurrency"`
+
+	// Delay is the minimum amount of time in seconds between starting each server that
+	// was running before Wings booted. Servers that are still running are re-attached
+	// to immediately.
+	Delay int `default:"0" yaml:"delay"`
+
+	// Priorities maps a server UUID, or a container label in "key=value" form, to the
+	// priority of the matching servers. Servers with a higher priority are restored first,
+	// and servers that do not match an entry have a priority of 0. If a server matches
+	// more than one entry, its UUID takes precedence, followed by the highest label
+	// priority.
+	Priorities map[string]int `yaml:"priorities"`
+}
+
+// Priority returns the boot priority of the server with the UUID and container
+// labels provided.
+func (b BootStartup) Priority(uuid string, labels map[string]string) int {
+	if p, ok := b.Priorities[uuid]; ok {
+		return p
+	}
+	var priority int
+	var matched bool
+	for k, v := range labels {
+		if p, ok := b.Priorities[k+"="+v]; ok && (!matched || p > priority) {
+			priority, matched = p, true
+		}
+	}
+	return priority
+}
+
+// Archive formats that backups can be created in.
+const (
+	BackupFormatTarGz = "targz"
+	BackupFormatZip   = "zip"
+	BackupFormatZstd  = "zstd"
+)
+
+// Level returns the numeric compression level for the archive format provided
+// based on the configured compression level, or an error if the level is not
+// valid for the format.
+func (b Backups) Level(format string) (int, error) {
+	min, max, best := 0, 9, 9
+	switch format {
+	case BackupFormatTarGz, BackupFormatZip:
+	case BackupFormatZstd:
+		min, max, best = 1, 22, 19
+	default:
+		return 0, errors.Errorf("config: system.backups.format must be one of \"targz\", \"zip\", or \"zstd\", got \"%s\"", format)
+	}
+	switch b.CompressionLevel {
+	case "", "best_speed":
+		return 1, nil
+	case "best_compression":
+		return best, nil
+	case "none":
+		if min > 0 {
+			return 0, errors.Errorf("config: system.backups.compression_level \"none\" is not supported by the %s format", format)
+		}
+		return 0, nil
+	}
+	n, err := strconv.Atoi(b.CompressionLevel)
+	if err != nil || n < min || n > max {
+		return 0, errors.Errorf("config: system.backups.compression_level must be \"none\", \"best_speed\", \"best_compression\", or a level between %d and %d for the %s format", min, max, format)
+	}
+	return n, nil
+}
+
+// ValidateBackupExclude checks that each of the backup exclusion patterns provided
+// is a single well-formed gitignore style pattern.
+func ValidateBackupExclude(patterns []string) error {
+	for _, p := range patterns {
+		v := strings.TrimPrefix(strings.TrimSpace(p), "!")
+		if v == "" || strings.HasPrefix(p, "#") || strings.ContainsAny(p, "\r\n\x00") {
+			return errors.Errorf("config: backup exclude pattern \"%s\" must be a single non-empty pattern", p)
+		}
+		if _, err := path.Match(v, ""); err != nil {
+			return errors.Errorf("config: backup exclude pattern \"%s\" is not a valid pattern", p)
+		}
+		for _, part := range strings.Split(v, "/") {
+			if part == ".." {
+				return errors.Errorf("config: backup exclude pattern \"%s\" must not reference parent directories", p)
+			}
+		}
+	}
+	return nil
 }
 
-type CrashDetection struct {
-	// CrashDetectionEnabled sets if crash detection is enabled globally for all servers on this node.
-	CrashDetectionEnabled bool `default:"true" yaml:"enabled"`
+// BackupSchedule defines the configuration for automatically backing up the servers
+// on this node. Scheduled backups are always created using the local adapter and are
+// not tracked by the Panel, so Wings prunes them itself using Keep and MaxAgeDays.
+type BackupSchedule struct {
+	// Enabled controls whether servers are automatically backed up.
+	Enabled bool `default:"false" yaml:"enabled"`
+
+	// CronExpression is a standard five field cron expression defining when the scheduled
+	// backups should run, evaluated in the configured system timezone. If set, this takes
+	// priority over Interval.
+	CronExpression string `default:"" yaml:"cron_expression"`
+
+	// Interval is the amount of time in seconds between each scheduled backup run when
+	// no cron expression is configured.
+	Interval int `default:"86400" yaml:"interval"`
+
+	// MaxConcurrent is the maximum number of servers that will be backed up at the
+	// same time during a scheduled run.
+	MaxConcurrent int `default:"1" yaml:"max_concurrent"`
+
+	// Keep is the number of scheduled backups retained for each server. Once a new
+	// scheduled backup is created the oldest ones beyond this count are deleted. Set
+	// to 0 for no limit.
+	Keep int `default:"7" yaml:"keep"`
+
+	// MaxAgeDays is the number of days after which a scheduled backup is deleted. Set
+	// to 0 for no limit.
+	MaxAgeDays int `default:"0" yaml:"max_age_days"`
+}
 
-	// Determines if Wings should detect a server that stops with a normal exit code of
-	// "0" as being crashed if the process stopped without any Wings interaction. E.g.
-	// the user did not press the stop button, but the process stopped cleanly.
-	DetectCleanExitAsCrash bool `default:"true" yaml:"detect_clean_exit_as_crash"`
+const (
+	TransferCompressionNone = "none"
+	TransferCompressionGzip = "gzip"
+	TransferCompressionZstd = "zstd"
+)
 
-	// Timeout specifies the timeout between crashes that will not cause the server
-	// to be automatically restarted, this value is used to prevent servers from
-	// becoming stuck in a boot-loop after multiple consecutive crashes.
-	Timeout int `default:"60" json:"timeout"`
-}
+const (
+	TransferRecoveryRollback = "rollback"
+	TransferRecoveryResume   = "resume"
+)
 
-type Backups struct {
-	// WriteLimit imposes a Disk I/O write limit on backups to the disk, this affects all
-	// backup drivers as the archiver must first write the file to the disk in order to
-	// upload it to any external storage provider.
+type Transfers struct {
+	// DownloadLimit imposes a Network I/O read limit when downloading a transfer archive.
 	//
 	// If the value is less than 1, the write speed is unlimited,
 	// if the value is greater than 0, the write speed is the value in MiB/s.
 	//
 	// Defaults to 0 (unlimited)
-	WriteLimit int `default:"0" yaml:"write_limit"`
+	DownloadLimit int `default:"0" yaml:"download_limit"`
 
-	// CompressionLevel determines how much backups created by wings should be compressed.
+	// AllowedNodes restricts the nodes that are allowed to transfer servers to this node.
+	// Entries may be IP addresses, CIDR ranges, or the UUIDs of nodes in the Panel. Node
+	// UUIDs are only trusted when a HandshakeToken is configured, since otherwise they can
+	// be set by anyone.
 	//
-	// "none" -> no compression will be applied
-	// "best_speed" -> uses gzip level 1 for fast speed
-	// "best_compression" -> uses gzip level 9 for minimal disk space useage
+	// If empty, any node holding a valid transfer token issued by the Panel is allowed.
+	AllowedNodes []string `yaml:"allowed_nodes"`
+
+	// HandshakeToken is a secret shared between nodes that must be provided by the source
+	// node when transferring a server to this node, in addition to the transfer token
+	// issued by the Panel. It is also sent by this node when transferring servers out.
+	HandshakeToken string `json:"-" yaml:"handshake_token"`
+
+	// Compression is the compression applied to the archive of a server when it is
+	// transferred to another node. One of "none", "gzip", or "zstd". Compression other
+	// than gzip is only used when the destination node advertises support for it, since
+	// older nodes can only receive gzip compressed archives, and gzip is used otherwise.
+	Compression string `default:"none" yaml:"compression"`
+
+	// CompressionLevel is the level of compression used for the archive. Set to 0 to
+	// use the default level for the compression, otherwise this must be between 1 and 9
+	// for gzip, or between 1 and 22 for zstd.
+	CompressionLevel int `default:"0" yaml:"compression_level"`
+
+	// RequireTls requires transfers to be sent and received over TLS. When enabled this
+	// node refuses to transfer servers to a node that does not use HTTPS, and refuses
+	// incoming transfers that were not received over HTTPS. The X-Forwarded-Proto header
+	// is only trusted when it is sent by one of the api.trusted_proxies.
+	RequireTls bool `default:"true" yaml:"require_tls"`
+
+	// Checkpoints records transfers that are in progress on the disk, allowing a
+	// transfer that was interrupted by Wings shutting down to be recovered when Wings
+	// boots again rather than leaving partial server data behind.
+	Checkpoints bool `default:"false" yaml:"checkpoints"`
+
+	// Recovery determines how interrupted transfers are recovered when checkpoints are
+	// enabled.
 	//
-	// Defaults to "best_speed" (level 1)
-	CompressionLevel string `default:"best_speed" yaml:"compression_level"`
+	// "rollback" -> the transfer is reported to the Panel as failed, and any partial
+	//               server data received by the destination node is removed
+	// "resume" -> the source node sends the server again, and the destination node
+	//             keeps the partial server data while it waits for the transfer to
+	//             resume. If the transfer is not resumed within ResumeTimeout it is
+	//             rolled back. The transfer token issued by the Panel is never written
+	//             to the disk, so a transfer interrupted by the source node stopping is
+	//             rolled back and must be started again from the Panel.
+	Recovery string `default:"rollback" yaml:"recovery"`
+
+	// ResumeTimeout is the number of seconds that an interrupted transfer may take to
+	// resume before it is rolled back.
+	ResumeTimeout int `default:"600" yaml:"resume_timeout"`
 }
 
-type Transfers struct {
-	// DownloadLimit imposes a Network I/O read limit when downloading a transfer archive.
-	//
-	// If the value is less than 1, the write speed is unlimited,
-	// if the value is greater than 0, the write speed is the value in MiB/s.
-	//
-	// Defaults to 0 (unlimited)
-	DownloadLimit int `default:"0" yaml:"download_limit"`
+// Level returns the compression level that should be used for transfer archives,
+// resolving a level of 0 to the default for the configured compression.
+func (t Transfers) Level() int {
+	if t.CompressionLevel != 0 {
+		return t.CompressionLevel
+	}
+	if t.Compression == TransferCompressionZstd {
+		return 3
+	}
+	return 6
+}
+
+// IsNodeAllowed returns true if a transfer from the node with the given IP address
+// and claimed UUID is permitted by the allowlist.
+func (t Transfers) IsNodeAllowed(ip net.IP, nodeUuid string) bool {
+	if len(t.AllowedNodes) == 0 {
+		return true
+	}
+	for _, entry := range t.AllowedNodes {
+		if _, n, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && n.Contains(ip) {
+				return true
+			}
+		} else if a := net.ParseIP(entry); a != nil {
+			if a.Equal(ip) {
+				return true
+			}
+		} else if t.HandshakeToken != "" && nodeUuid != "" && strings.EqualFold(entry, nodeUuid) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that each of the allowed nodes is an IP address, CIDR range,
+// or UUID.
+func (t Transfers) validate() error {
+	for _, entry := range t.AllowedNodes {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+		if net.ParseIP(entry) != nil {
+			continue
+		}
+		if _, err := uuid.Parse(entry); err == nil {
+			continue
+		}
+		return errors.Errorf("config: system.transfers.allowed_nodes entry \"%s\" is not a valid IP address, CIDR range, or node UUID", entry)
+	}
+	max := 9
+	switch t.Compression {
+	case TransferCompressionNone, TransferCompressionGzip:
+	case TransferCompressionZstd:
+		max = 22
+	default:
+		return errors.New("config: system.transfers.compression must be one of \"none\", \"gzip\", or \"zstd\"")
+	}
+	if t.CompressionLevel < 0 || t.CompressionLevel > max {
+		return errors.Errorf("config: system.transfers.compression_level must be between 0 and %d for \"%s\"", max, t.Compression)
+	}
+	if t.Recovery != TransferRecoveryRollback && t.Recovery != TransferRecoveryResume {
+		return errors.New("config: system.transfers.recovery must be either \"rollback\" or \"resume\"")
+	}
+	if t.Checkpoints && t.Recovery == TransferRecoveryResume && t.ResumeTimeout < 60 {
+		return errors.New("config: system.transfers.resume_timeout must be at least 60 seconds")
+	}
+	return nil
+}
+
+// Resumes returns true if interrupted transfers should be resumed rather than
+// rolled back.
+func (t Transfers) Resumes() bool {
+	return t.Checkpoints && t.Recovery == TransferRecoveryResume
 }
 
 type ConsoleThrottles struct {
@@ -273,12 +2350,33 @@ type ConsoleThrottles struct {
 	// a constant loop and is not affected by the current console output volumes. By default, this
 	// will reset the processed line count back to 0 every 100ms.
 	Period uint64 `json:"line_reset_interval" yaml:"line_reset_interval" default:"100"`
+
+	// The algorithm used to decide when console output is throttled. With "linear" the
+	// number of lines processed is reset to 0 every Period. With "leaky-bucket" the count
+	// drains continuously at a rate of Lines per Period, and up to Burst additional lines
+	// may be output at once, which avoids throttling servers that legitimately output
+	// data in short bursts.
+	Algorithm string `json:"algorithm" yaml:"algorithm" default:"linear"`
+
+	// The number of lines above Lines that may be output in a single burst when using
+	// the "leaky-bucket" algorithm. This has no effect on the "linear" algorithm.
+	Burst uint64 `json:"burst" yaml:"burst" default:"0"`
 }
 
+// Throttle algorithms that can be used for console output.
+const (
+	ThrottleAlgorithmLinear      = "linear"
+	ThrottleAlgorithmLeakyBucket = "leaky-bucket"
+)
+
 type Configuration struct {
 	// The location from which this configuration instance was instantiated.
 	path string
 
+	// The fields that were overridden by environment variables along with the
+	// values they had before, so that they can be left out of the file on disk.
+	envOverrides []envOverride
+
 	// Determines if wings should be running in debug mode. This value is ignored
 	// if the debug flag is passed through the command line arguments.
 	Debug bool
@@ -300,6 +2398,9 @@ type Configuration struct {
 	System SystemConfiguration `json:"system" yaml:"system"`
 	Docker DockerConfiguration `json:"docker" yaml:"docker"`
 
+	// Metrics defines the configuration for the Prometheus metrics endpoint.
+	Metrics MetricsConfiguration `json:"metrics" yaml:"metrics"`
+
 	// Defines internal throttling configurations for server processes to prevent
 	// someone from running an endless loop that spams data to logs.
 	Throttles ConsoleThrottles
@@ -313,6 +2414,14 @@ type Configuration struct {
 	// This is required to have the "Server Mounts" feature work properly.
 	AllowedMounts []string `json:"-" yaml:"allowed_mounts"`
 
+	// MountPropagation sets the bind propagation of server mounts, keyed by the allowed
+	// mount point they come from. The propagation must be one of "private", "rprivate",
+	// "shared", "rshared", "slave" or "rslave". Mounts from allowed mount points without
+	// an entry use the Docker default of "rprivate". This is needed for mounts made on
+	// the host after the container has started, such as a FUSE filesystem, to appear in
+	// the container, which also requires the mount point on the host to be shared.
+	MountPropagation map[string]string `json:"-" yaml:"mount_propagation"`
+
 	// AllowedOrigins is a list of allowed request origins.
 	// The Panel URL is automatically allowed, this is only needed for adding
 	// additional origins.
@@ -412,115 +2521,597 @@ func WriteToDisk(c *Configuration) error {
 	if _debugViaFlag {
 		ccopy.Debug = false
 	}
-	if c.path == "" {
-		return errors.New("cannot write configuration, no path defined in struct")
+	// Likewise, values coming from the environment are written out as they were
+	// in the configuration file so that they are not persisted.
+	ccopy.restoreEnvironmentOverrides()
+	if c.path == "" {
+		return errors.New("cannot write configuration, no path defined in struct")
+	}
+	b, err := yaml.Marshal(&ccopy)
+	if err != nil {
+		return err
+	}
+	if n := c.System.ConfigVersionHistory; n > 0 {
+		if err := rotateConfigHistory(c.path, b, n); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(c.path, b, 0o600); err != nil {
+		return err
+	}
+	return nil
+}
+
+// rotateConfigHistory copies the configuration file at the path provided to
+// "<path>.1" before it is overwritten, shifting the existing copies up by one and
+// keeping at most n copies. Nothing is rotated if the file does not exist yet or
+// if its contents are unchanged.
+func rotateConfigHistory(p string, contents []byte, n int) error {
+	current, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "config: failed to read configuration for version history")
+	}
+	if bytes.Equal(current, contents) {
+		return nil
+	}
+	if err := os.Remove(p + "." + strconv.Itoa(n)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "config: failed to remove oldest configuration version")
+	}
+	for i := n - 1; i > 0; i-- {
+		if err := os.Rename(p+"."+strconv.Itoa(i), p+"."+strconv.Itoa(i+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "config: failed to rotate configuration versions")
+		}
+	}
+	if err := os.WriteFile(p+".1", current, 0o600); err != nil {
+		return errors.Wrap(err, "config: failed to write configuration version")
+	}
+	return nil
+}
+
+// EnsurePterodactylUser ensures that the Pterodactyl core user exists on the
+// system. This user will be the owner of all data in the root data directory
+// and is used as the user within containers. If files are not owned by this
+// user there will be issues with permissions on Docker mount points.
+//
+// This function IS NOT thread safe and should only be called in the main thread
+// when the application is booting.
+func EnsurePterodactylUser() error {
+	sysName, err := getSystemName()
+	if err != nil {
+		return err
+	}
+
+	// Our way of detecting if wings is running inside of Docker.
+	if sysName == "distroless" {
+		_config.System.Username = system.FirstNotEmpty(os.Getenv("WINGS_USERNAME"), "pterodactyl")
+		_config.System.User.Uid = system.MustInt(system.FirstNotEmpty(os.Getenv("WINGS_UID"), "988"))
+		_config.System.User.Gid = system.MustInt(system.FirstNotEmpty(os.Getenv("WINGS_GID"), "988"))
+		return nil
+	}
+
+	if _config.System.User.Rootless.Enabled {
+		log.Info("rootless mode is enabled, skipping user creation...")
+		u, err := user.Current()
+		if err != nil {
+			return err
+		}
+		_config.System.Username = u.Username
+		_config.System.User.Uid = system.MustInt(u.Uid)
+		_config.System.User.Gid = system.MustInt(u.Gid)
+		return nil
+	}
+
+	log.WithField("username", _config.System.Username).Info("checking for pterodactyl system user")
+	u, err := user.Lookup(_config.System.Username)
+	// If an error is returned but it isn't the unknown user error just abort
+	// the process entirely. If we did find a user, return it immediately.
+	if err != nil {
+		if _, ok := err.(user.UnknownUserError); !ok {
+			return err
+		}
+	} else {
+		_config.System.User.Uid = system.MustInt(u.Uid)
+		_config.System.User.Gid = system.MustInt(u.Gid)
+		return nil
+	}
+
+	command := fmt.Sprintf("useradd --system --no-create-home --shell /usr/sbin/nologin %s", _config.System.Username)
+	// Alpine Linux is the only OS we currently support that doesn't work with the useradd
+	// command, so in those cases we just modify the command a bit to work as expected.
+	if strings.HasPrefix(sysName, "alpine") {
+		command = fmt.Sprintf("adduser -S -D -H -G %[1]s -s /sbin/nologin %[1]s", _config.System.Username)
+		// We have to create the group first on Alpine, so do that here before continuing on
+		// to the user creation process.
+		if _, err := exec.Command("addgroup", "-S", _config.System.Username).Output(); err != nil {
+			return err
+		}
+	}
+
+	split := strings.Split(command, " ")
+	if _, err := exec.Command(split[0], split[1:]...).Output(); err != nil {
+		return err
+	}
+	u, err = user.Lookup(_config.System.Username)
+	if err != nil {
+		return err
+	}
+	_config.System.User.Uid = system.MustInt(u.Uid)
+	_config.System.User.Gid = system.MustInt(u.Gid)
+	return nil
+}
+
+// FromFile reads the configuration from the provided file and stores it in the
+// global singleton for this instance.
+func FromFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	c, err := NewAtPath(path)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return err
+	}
+	if err := c.ApplyEnvironmentOverrides(); err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	// Store this configuration in the global state.
+	Set(c)
+	return nil
+}
+
+// validateCertificates checks that each of the SNI certificates has a unique
+// hostname and that its certificate and key can be loaded and match each other.
+func (a ApiConfiguration) validateCertificates() error {
+	if a.Ssl.ReloadInterval < 0 {
+		return errors.New("config: api.ssl.reload_interval must not be negative")
+	}
+	if err := a.Ssl.Acme.validate(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(a.Ssl.Certificates))
+	for _, cert := range a.Ssl.Certificates {
+		host := strings.ToLower(cert.Hostname)
+		if host == "" {
+			return errors.New("config: api.ssl.certificates entries must define a hostname")
+		}
+		if seen[host] {
+			return errors.Errorf("config: api.ssl.certificates contains more than one entry for \"%s\"", host)
+		}
+		seen[host] = true
+		if _, err := tls.LoadX509KeyPair(cert.CertificateFile, cert.KeyFile); err != nil {
+			return errors.Wrapf(err, "config: api.ssl.certificates entry for \"%s\" could not be loaded", host)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the values provided for the configuration are usable,
+// returning an error describing the first invalid value that is encountered.
+func (c *Configuration) Validate() error {
+	if err := c.Api.validateCertificates(); err != nil {
+		return err
+	}
+	if c.Api.MaxRequestBody < 1 {
+		return errors.New("config: api.max_request_body must be greater than 0")
+	}
+	for route, limit := range c.Api.RequestBodyLimits {
+		method, p, ok := strings.Cut(route, " ")
+		if !ok || method == "" || method != strings.ToUpper(method) || !strings.HasPrefix(p, "/") {
+			return errors.Errorf("config: api.request_body_limits key \"%s\" must be a method and route, such as \"POST /api/update\"", route)
+		}
+		if limit < 0 {
+			return errors.Errorf("config: api.request_body_limits limit for \"%s\" must not be negative", route)
+		}
+	}
+	if err := c.Api.RemoteDownload.validate(); err != nil {
+		return err
+	}
+	if err := c.Api.Cors.validate(); err != nil {
+		return err
+	}
+	if c.Api.MaxAssembledUploadSize != 0 && c.Api.MaxAssembledUploadSize < c.Api.UploadLimit {
+		return errors.New("config: api.max_assembled_upload_size must be either 0 or not less than api.upload_limit")
+	}
+	switch c.RemoteQuery.OfflineBehavior {
+	case PanelOfflineQueue, PanelOfflineReject, PanelOfflineContinue:
+	default:
+		return errors.New("config: remote_query.offline_behavior must be one of \"queue\", \"reject\" or \"continue\"")
+	}
+	if c.RemoteQuery.OfflineQueueSize < 1 {
+		return errors.New("config: remote_query.offline_queue_size must be greater than 0")
+	}
+	if err := c.RemoteQuery.CircuitBreaker.validate(); err != nil {
+		return err
+	}
+	if err := c.Metrics.validate(c.Api); err != nil {
+		return err
+	}
+	if c.Api.Websocket.MaxMessageBytes < 1 {
+		return errors.New("config: api.websocket.max_message_bytes must be greater than 0")
+	}
+	if c.Api.Websocket.MaxMessagesPerSecond < 1 {
+		return errors.New("config: api.websocket.max_messages_per_second must be greater than 0")
+	}
+	if c.Api.Websocket.MaxConnectionsPerServer < 0 {
+		return errors.New("config: api.websocket.max_connections_per_server must not be negative")
+	}
+	if c.Api.Websocket.MaxSessionLifetime < 0 {
+		return errors.New("config: api.websocket.max_session_lifetime must not be negative")
+	}
+	if c.System.PostInstallHook.Command != "" {
+		if _, err := c.System.PostInstallHook.Render(PostInstallHookData{Uuid: "00000000-0000-0000-0000-000000000000", Ip: "127.0.0.1", Port: 25565}); err != nil {
+			return errors.WithMessage(err, "config: system.post_install_hook.command is not a valid template")
+		}
+		if c.System.PostInstallHook.Timeout < 1 {
+			return errors.New("config: system.post_install_hook.timeout must be greater than 0")
+		}
+	}
+	switch c.Throttles.Algorithm {
+	case ThrottleAlgorithmLinear, ThrottleAlgorithmLeakyBucket:
+	default:
+		return errors.New("config: throttles.algorithm must be one of \"linear\" or \"leaky-bucket\"")
+	}
+	if c.System.PortRange.Start < 1 || c.System.PortRange.End > 65535 || c.System.PortRange.Start > c.System.PortRange.End {
+		return errors.New("config: system.port_range must be between 1 and 65535, and start must not be greater than end")
+	}
+	if err := c.System.Transfers.validate(); err != nil {
+		return err
+	}
+	if c.System.PermissionFixConcurrency < 1 {
+		return errors.New("config: system.permission_fix_concurrency must be at least 1")
+	}
+	if c.System.Readiness.Delay < 0 {
+		return errors.New("config: system.readiness.delay must not be negative")
+	}
+	if c.System.Readiness.MaxWait < 1 || c.System.Readiness.Interval < 1 {
+		return errors.New("config: system.readiness.max_wait and system.readiness.interval must be greater than 0")
+	}
+	if c.System.Extraction.MaxTotalBytes < 1 || c.System.Extraction.MaxFiles < 1 || c.System.Extraction.MaxCompressionRatio <= 0 {
+		return errors.New("config: system.extraction.max_total_bytes, max_files, and max_compression_ratio must be greater than 0")
+	}
+	switch c.System.FollowSymlinks {
+	case "deny", "contain", "allow":
+	default:
+		return errors.New("config: system.follow_symlinks must be one of \"deny\", \"contain\", or \"allow\"")
+	}
+	if c.System.TmpCleanup.MinAge < 1 {
+		return errors.New("config: system.tmp_cleanup.min_age must be greater than 0")
+	}
+	if err := c.System.ClockSkew.validate(); err != nil {
+		return err
+	}
+	if c.System.JwtClockSkew < 0 {
+		return errors.New("config: system.jwt_clock_skew must not be negative")
+	}
+	if c.System.JwtClockSkew > 300 {
+		log.WithField("jwt_clock_skew", c.System.JwtClockSkew).Warn("system.jwt_clock_skew is set to more than 5 minutes, consider fixing the clock synchronization on this node instead")
+	}
+	// A session that ends within the clock skew could end before the token it was
+	// opened with is even considered valid.
+	if c.Api.Websocket.MaxSessionLifetime > 0 && c.Api.Websocket.MaxSessionLifetime <= c.System.JwtClockSkew {
+		return errors.New("config: api.websocket.max_session_lifetime must be greater than system.jwt_clock_skew")
+	}
+	if c.System.DiskCheckMaxFiles < 0 {
+		return errors.New("config: system.disk_check_max_files must not be negative")
+	}
+	if c.System.EnforceDiskCheckMaxFiles && c.System.DiskCheckMaxFiles == 0 {
+		return errors.New("config: system.enforce_disk_check_max_files requires system.disk_check_max_files to be set")
+	}
+	if c.System.InstallTimeout < 0 {
+		return errors.New("config: system.install_timeout must not be negative")
+	}
+	if c.System.AutoRecoverStuckInstalls && c.System.StuckInstallCheckInterval < 30 {
+		return errors.New("config: system.stuck_install_check_interval must be at least 30 seconds")
 	}
-	b, err := yaml.Marshal(&ccopy)
-	if err != nil {
+	if c.System.ConsoleBufferLines < 0 || c.System.ConsoleBufferLines > 100000 {
+		return errors.New("config: system.console_buffer_lines must be between 0 and 100000")
+	}
+	switch c.System.ConsoleInvalidUtf8 {
+	case ConsoleInvalidUtf8Replace, ConsoleInvalidUtf8Strip, ConsoleInvalidUtf8Hex:
+	default:
+		return errors.New("config: system.console_invalid_utf8 must be one of \"replace\", \"strip\" or \"hex\"")
+	}
+	if c.System.ServerIdentifiers != ServerIdentifiersSanitize && c.System.ServerIdentifiers != ServerIdentifiersReject {
+		return errors.New("config: system.server_identifiers must be one of \"sanitize\" or \"reject\"")
+	}
+	if c.System.MinFreeDiskMB < 0 {
+		return errors.New("config: system.min_free_disk_mb must not be negative")
+	}
+	if c.System.MinFreeDiskAction != "error" && c.System.MinFreeDiskAction != "warn" {
+		return errors.New("config: system.min_free_disk_action must be one of \"error\" or \"warn\"")
+	}
+	if err := c.System.ServerLogRetention.validate(); err != nil {
 		return err
 	}
-	if err := os.WriteFile(c.path, b, 0o600); err != nil {
+	if err := c.System.StaleLocks.validate(); err != nil {
 		return err
 	}
-	return nil
-}
-
-// EnsurePterodactylUser ensures that the Pterodactyl core user exists on the
-// system. This user will be the owner of all data in the root data directory
-// and is used as the user within containers. If files are not owned by this
-// user there will be issues with permissions on Docker mount points.
-//
-// This function IS NOT thread safe and should only be called in the main thread
-// when the application is booting.
-func EnsurePterodactylUser() error {
-	sysName, err := getSystemName()
-	if err != nil {
+	if err := c.System.SecretMasking.validate(); err != nil {
 		return err
 	}
-
-	// Our way of detecting if wings is running inside of Docker.
-	if sysName == "distroless" {
-		_config.System.Username = system.FirstNotEmpty(os.Getenv("WINGS_USERNAME"), "pterodactyl")
-		_config.System.User.Uid = system.MustInt(system.FirstNotEmpty(os.Getenv("WINGS_UID"), "988"))
-		_config.System.User.Gid = system.MustInt(system.FirstNotEmpty(os.Getenv("WINGS_GID"), "988"))
-		return nil
+	if err := c.System.OwnershipRetry.validate(); err != nil {
+		return err
 	}
-
-	if _config.System.User.Rootless.Enabled {
-		log.Info("rootless mode is enabled, skipping user creation...")
-		u, err := user.Current()
-		if err != nil {
-			return err
+	if c.System.ConfigVersionHistory < 0 || c.System.ConfigVersionHistory > 100 {
+		return errors.New("config: system.config_version_history must be between 0 and 100")
+	}
+	if n := c.System.Capacity; n.Memory < 0 || n.Disk < 0 || n.Cpu < 0 {
+		return errors.New("config: system.capacity values must not be negative")
+	}
+	if c.System.BootStartup.Concurrency < 1 {
+		return errors.New("config: system.boot_startup.concurrency must be at least 1")
+	}
+	if c.System.BootStartup.Delay < 0 {
+		return errors.New("config: system.boot_startup.delay must not be negative")
+	}
+	if c.System.BulkPowerConcurrency < 1 {
+		return errors.New("config: system.bulk_power_concurrency must be at least 1")
+	}
+	if c.System.BulkPowerDelay < 0 || c.System.BulkPowerDelay > 300 {
+		return errors.New("config: system.bulk_power_delay must be between 0 and 300 seconds")
+	}
+	if soft, hard := c.System.SelfMemoryLimitMB, c.System.SelfMemoryHardLimitMB; soft != 0 || hard != 0 {
+		if soft < 0 || hard < 0 {
+			return errors.New("config: system.self_memory_limit_mb and system.self_memory_hard_limit_mb must not be negative")
+		}
+		if (soft > 0 && soft < 128) || (hard > 0 && hard < 128) {
+			return errors.New("config: system.self_memory_limit_mb and system.self_memory_hard_limit_mb must be at least 128 MiB")
+		}
+		if soft > 0 && hard > 0 && hard < soft {
+			return errors.New("config: system.self_memory_hard_limit_mb must not be less than system.self_memory_limit_mb")
+		}
+		if mem := hostMemory() / 1024 / 1024; mem > 0 && (soft > mem || hard > mem) {
+			return errors.Errorf("config: system.self_memory_limit_mb and system.self_memory_hard_limit_mb must not exceed the memory of this system (%d MiB)", mem)
 		}
-		_config.System.Username = u.Username
-		_config.System.User.Uid = system.MustInt(u.Uid)
-		_config.System.User.Gid = system.MustInt(u.Gid)
-		return nil
 	}
-
-	log.WithField("username", _config.System.Username).Info("checking for pterodactyl system user")
-	u, err := user.Lookup(_config.System.Username)
-	// If an error is returned but it isn't the unknown user error just abort
-	// the process entirely. If we did find a user, return it immediately.
-	if err != nil {
-		if _, ok := err.(user.UnknownUserError); !ok {
-			return err
+	if err := c.System.StatePersistence.validate(); err != nil {
+		return err
+	}
+	if d := c.System.UploadStagingDir; d != "" && !filepath.IsAbs(d) {
+		return errors.New("config: system.upload_staging_dir must be an absolute path")
+	}
+	if err := c.System.AutoStop.validate(); err != nil {
+		return err
+	}
+	if err := c.System.PanelEnvironmentPolicy.validate(); err != nil {
+		return err
+	}
+	if err := c.System.StateTransitionLog.validate(); err != nil {
+		return err
+	}
+	if err := c.System.OwnershipNormalization.validate(); err != nil {
+		return err
+	}
+	if err := c.System.StateReconciliation.validate(); err != nil {
+		return err
+	}
+	if err := c.System.ResourceBounds.validate(); err != nil {
+		return err
+	}
+	if err := c.System.Audit.validate(); err != nil {
+		return err
+	}
+	if err := c.System.LogForwarding.validate(); err != nil {
+		return err
+	}
+	for _, driver := range c.System.AllowedStorageDrivers {
+		if !storageDriverRegexp.MatchString(driver) {
+			return errors.Errorf("config: system.allowed_storage_drivers entry \"%s\" is not a valid storage driver name", driver)
 		}
-	} else {
-		_config.System.User.Uid = system.MustInt(u.Uid)
-		_config.System.User.Gid = system.MustInt(u.Gid)
-		return nil
 	}
-
-	command := fmt.Sprintf("useradd --system --no-create-home --shell /usr/sbin/nologin %s", _config.System.Username)
-	// Alpine Linux is the only OS we currently support that doesn't work with the useradd
-	// command, so in those cases we just modify the command a bit to work as expected.
-	if strings.HasPrefix(sysName, "alpine") {
-		command = fmt.Sprintf("adduser -S -D -H -G %[1]s -s /sbin/nologin %[1]s", _config.System.Username)
-		// We have to create the group first on Alpine, so do that here before continuing on
-		// to the user creation process.
-		if _, err := exec.Command("addgroup", "-S", _config.System.Username).Output(); err != nil {
-			return err
+	switch c.System.CpuLimitMode {
+	case CpuLimitModeHard, CpuLimitModeSoft, CpuLimitModeBoth:
+	default:
+		return errors.New("config: system.cpu_limit_mode must be one of \"hard\", \"soft\", or \"both\"")
+	}
+	if a := c.System.StorageDriverAction; a != StorageDriverActionWarn && a != StorageDriverActionRefuse {
+		return errors.New("config: system.storage_driver_action must be either \"warn\" or \"refuse\"")
+	}
+	if a := c.System.KernelFeatureAction; a != KernelFeatureActionWarn && a != KernelFeatureActionRefuse {
+		return errors.New("config: system.kernel_feature_action must be either \"warn\" or \"refuse\"")
+	}
+	if c.System.WriteEnvFile {
+		p := c.System.EnvFilePath
+		if p == "" || filepath.IsAbs(p) || filepath.Clean(p) != p || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+			return errors.New("config: system.env_file_path must be a clean path relative to the server root directory")
 		}
 	}
-
-	split := strings.Split(command, " ")
-	if _, err := exec.Command(split[0], split[1:]...).Output(); err != nil {
+	if _, err := c.System.Backups.Level(c.System.Backups.Format); err != nil {
 		return err
 	}
-	u, err = user.Lookup(_config.System.Username)
-	if err != nil {
+	if err := ValidateBackupExclude(c.System.Backups.Exclude); err != nil {
 		return err
 	}
-	_config.System.User.Uid = system.MustInt(u.Uid)
-	_config.System.User.Gid = system.MustInt(u.Gid)
-	return nil
-}
-
-// FromFile reads the configuration from the provided file and stores it in the
-// global singleton for this instance.
-func FromFile(path string) error {
-	b, err := os.ReadFile(path)
-	if err != nil {
+	if c.System.Backups.MaxConcurrentRestores < 1 {
+		return errors.New("config: system.backups.max_concurrent_restores must be at least 1")
+	}
+	if err := c.System.Backups.RemoteStorage.validate(); err != nil {
 		return err
 	}
-	c, err := NewAtPath(path)
-	if err != nil {
+	if schedule := c.System.Backups.Schedule; schedule.Enabled {
+		if schedule.CronExpression != "" {
+			if _, err := cron.ParseStandard(schedule.CronExpression); err != nil {
+				return errors.Wrap(err, "config: system.backups.schedule.cron_expression is not a valid cron expression")
+			}
+		} else if schedule.Interval <= 0 {
+			return errors.New("config: system.backups.schedule.interval must be greater than 0")
+		}
+		if schedule.MaxConcurrent < 1 {
+			return errors.New("config: system.backups.schedule.max_concurrent must be at least 1")
+		}
+		if schedule.Keep < 0 {
+			return errors.New("config: system.backups.schedule.keep must not be negative")
+		}
+		if schedule.MaxAgeDays < 0 {
+			return errors.New("config: system.backups.schedule.max_age_days must not be negative")
+		}
+	}
+	for k, v := range c.System.InstallEnvironment {
+		if !envNameRegexp.MatchString(k) {
+			return errors.Errorf("config: system.install_environment contains an invalid variable name \"%s\"", k)
+		}
+		if strings.ContainsAny(v, "\x00\n") {
+			return errors.Errorf("config: system.install_environment value for \"%s\" must not contain null bytes or newlines", k)
+		}
+	}
+	if c.System.Sftp.MaxConnectionsPerServer < 0 {
+		return errors.New("config: system.sftp.max_connections_per_server must not be negative")
+	}
+	if c.System.Sftp.MaxConnectionsPerIP < 0 {
+		return errors.New("config: system.sftp.max_connections_per_ip must not be negative")
+	}
+	if c.System.Sftp.MaxPathDepth < 1 {
+		return errors.New("config: system.sftp.max_path_depth must be at least 1")
+	}
+	if c.System.Sftp.MaxNameLength < 1 {
+		return errors.New("config: system.sftp.max_name_length must be at least 1")
+	}
+	switch c.System.Sftp.DiskCheckStrategy {
+	case SftpDiskCheckWalk, SftpDiskCheckCached, SftpDiskCheckStatfs:
+	default:
+		return errors.New("config: system.sftp.disk_check_strategy must be one of \"walk\", \"cached\" or \"statfs\"")
+	}
+	if c.System.RecreateImmediatelyOnImageChange && !c.System.AutoRecreateOnImageChange {
+		return errors.New("config: system.recreate_immediately_on_image_change requires system.auto_recreate_on_image_change to be enabled")
+	}
+	if err := c.Docker.validateHost(); err != nil {
 		return err
 	}
-
-	if err := yaml.Unmarshal(b, c); err != nil {
+	if err := c.Docker.validateWeights(); err != nil {
+		return err
+	}
+	if _, err := c.Docker.IoThrottle(nil); err != nil {
+		return err
+	}
+	if _, err := c.Docker.ContainerGroupAdd(nil); err != nil {
+		return err
+	}
+	if _, err := ValidateTmpfs(c.Docker.Tmpfs); err != nil {
+		return err
+	}
+	if shm, err := c.Docker.ShmSizeBytes(""); err != nil {
+		return errors.New("config: docker.shm_size must be a size greater than 0, such as \"256m\" or \"1g\"")
+	} else if mem := hostMemory(); mem > 0 && shm > mem/2 {
+		log.WithField("shm_size", c.Docker.ShmSize).Warn("docker.shm_size is more than half of the memory on this system")
+	}
+	if err := c.Docker.validateMetadataLabels(); err != nil {
+		return err
+	}
+	if err := c.Docker.validateUsernsMode(); err != nil {
+		return err
+	}
+	if _, err := c.Docker.ContainerHostname("00000000-0000-0000-0000-000000000000"); err != nil {
+		return err
+	}
+	if err := c.Docker.validateStopChain(); err != nil {
+		return err
+	}
+	if c.Docker.MaxConcurrentPulls < 1 {
+		return errors.New("config: docker.max_concurrent_pulls must be at least 1")
+	}
+	if err := c.Docker.validateDevices(c.System.AllowDeviceAccess); err != nil {
+		return err
+	}
+	if _, _, err := c.Docker.NetworkRates("", ""); err != nil {
+		return errors.WithMessage(err, "config: docker.network_rate_limit is not valid")
+	}
+	if err := c.Docker.AllocationResolver.validate(); err != nil {
+		return err
+	}
+	if !c.System.AllowDeviceAccess && (len(c.Docker.Devices) > 0 || len(c.Docker.DeviceRequests) > 0) {
+		log.Warn("docker.devices and docker.device_requests are not applied to containers unless system.allow_device_access is enabled")
+	}
+	if r := c.Docker.Reconnect; r.Enabled && (r.InitialInterval < 1 || r.MaxInterval < r.InitialInterval || r.MaxWait < 1) {
+		return errors.New("config: docker.reconnect intervals must be greater than 0, max_interval must not be less than initial_interval, and max_wait must be greater than 0")
+	}
+	if c.Docker.OrphanedContainers.Enabled && c.Docker.OrphanedContainers.Interval < 1 {
+		return errors.New("config: docker.orphaned_containers.interval must be greater than 0")
+	}
+	if c.Docker.StopGracePeriod < 0 {
+		return errors.New("config: docker.stop_grace_period must not be negative")
+	}
+	if c.Docker.MaxStopGracePeriod < 0 {
+		return errors.New("config: docker.max_stop_grace_period must not be negative")
+	}
+	if c.Docker.MaxStopGracePeriod > 0 && c.Docker.StopGracePeriod > c.Docker.MaxStopGracePeriod {
+		return errors.New("config: docker.stop_grace_period must not be greater than docker.max_stop_grace_period")
+	}
+	if c.Docker.ConnectRetries < 0 {
+		return errors.New("config: docker.connect_retries must not be negative")
+	}
+	if c.Docker.ConnectRetryDelay < 1 {
+		return errors.New("config: docker.connect_retry_delay must be greater than 0")
+	}
+	if c.Docker.DefaultNetworkMode != "" {
+		if err := ValidateNetworkMode(c.Docker.DefaultNetworkMode, c.System.AllowHostNetwork); err != nil {
+			return err
+		}
+	}
+	if c.Docker.ImagePruning.Enabled {
+		if c.Docker.ImagePruning.Interval < 1 {
+			return errors.New("config: docker.image_pruning.interval must be greater than 0")
+		}
+		if c.Docker.ImagePruning.MinAge < 0 {
+			return errors.New("config: docker.image_pruning.min_age must not be negative")
+		}
+	}
+	if err := c.validateMountPropagation(); err != nil {
 		return err
 	}
+	return nil
+}
 
-	// Store this configuration in the global state.
-	Set(c)
+// validMountPropagations are the bind propagation modes accepted by Docker.
+var validMountPropagations = []string{"private", "rprivate", "shared", "rshared", "slave", "rslave"}
+
+// validateMountPropagation checks that the mount propagation is only set for
+// allowed mount points, and that each propagation is accepted by Docker.
+func (c *Configuration) validateMountPropagation() error {
+	for p, propagation := range c.MountPropagation {
+		allowed := false
+		for _, m := range c.AllowedMounts {
+			if filepath.Clean(m) == filepath.Clean(p) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.Errorf("config: mount_propagation path \"%s\" must be one of the allowed_mounts", p)
+		}
+		if !slices.Contains(validMountPropagations, propagation) {
+			return errors.Errorf("config: mount_propagation for \"%s\" must be one of \"%s\"", p, strings.Join(validMountPropagations, "\", \""))
+		}
+	}
 	return nil
 }
 
+// MountPropagationFor returns the bind propagation of mounts from the allowed
+// mount point provided, or an empty string if the Docker default should be used.
+func (c *Configuration) MountPropagationFor(allowed string) string {
+	for p, propagation := range c.MountPropagation {
+		if filepath.Clean(p) == filepath.Clean(allowed) {
+			return propagation
+		}
+	}
+	return ""
+}
+
 // ConfigureDirectories ensures that all the system directories exist on the
 // system. These directories are created so that only the owner can read the data,
-// and no other users.
+// and no other users. The upload staging directory and the directory of the state
+// file are also checked to be writable, which is done here rather than in Validate
+// since it touches the disk.
 //
 // This function IS NOT thread-safe.
 func ConfigureDirectories() error {
@@ -561,9 +3152,170 @@ func ConfigureDirectories() error {
 		return err
 	}
 
+	if d := _config.System.UploadStagingDir; d != "" {
+		log.WithField("path", d).Debug("ensuring upload staging directory exists")
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return errors.Wrap(err, "config: system.upload_staging_dir could not be created")
+		}
+		if err := checkWritable(d); err != nil {
+			return errors.Wrap(err, "config: system.upload_staging_dir must be writable")
+		}
+	}
+
+	if p := _config.System.StatePersistence.Path; p != "" {
+		log.WithField("path", p).Debug("ensuring state persistence directory is writable")
+		if err := checkWritable(filepath.Dir(p)); err != nil {
+			return errors.Wrap(err, "config: system.state_persistence.path must be in a writable directory")
+		}
+	}
+
+	return nil
+}
+
+// checkWritable ensures that files can be created in the given directory by
+// creating and then removing a temporary file within it.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".wings-writable-*")
+	if err != nil {
+		return err
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+// DiskSpaceCheck ensures that the filesystem containing the server data directory
+// has at least the configured minimum amount of free space available. Depending on
+// the configured action an error is returned, or a warning is logged, when there is
+// not enough space available. Running this early in the boot process avoids a cascade
+// of confusing errors from operations that fail because the disk is full.
+//
+// This function IS NOT thread-safe.
+func DiskSpaceCheck() error {
+	min := _config.System.MinFreeDiskMB
+	if min == 0 {
+		return nil
+	}
+
+	var st unix.Statfs_t
+	if err := unix.Statfs(_config.System.Data, &st); err != nil {
+		return errors.Wrap(err, "config: failed to stat server data directory filesystem")
+	}
+
+	free := int64(st.Bavail) * st.Frsize / 1024 / 1024
+	if free >= min {
+		return nil
+	}
+	if _config.System.MinFreeDiskAction == "warn" {
+		log.WithFields(log.Fields{"path": _config.System.Data, "free_mb": free, "min_free_mb": min}).
+			Warn("server data directory is running low on free disk space")
+		return nil
+	}
+	return errors.Errorf("config: only %d MB of free disk space is available for the server data directory (%s), at least %d MB is required", free, _config.System.Data, min)
+}
+
+// DataWritableCheck ensures that files can be created in the server data directory,
+// which is otherwise reported as a cascade of confusing errors from every server when
+// the directory is accidentally mounted read-only.
+//
+// This function IS NOT thread-safe.
+func DataWritableCheck() error {
+	if !_config.System.CheckDataWritable {
+		return nil
+	}
+	f, err := os.CreateTemp(_config.System.Data, ".wings-writable-*")
+	if err != nil {
+		return errors.Wrapf(err, "config: data directory is not writable (%s)", _config.System.Data)
+	}
+	_ = f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return errors.Wrapf(err, "config: data directory is not writable (%s)", _config.System.Data)
+	}
+	return nil
+}
+
+// CleanupTmpDirectory removes files from the temporary directory that are older
+// than the configured minimum age. These are generally left behind by installation
+// processes that were interrupted by Wings or the system crashing. Files that are
+// currently held open by any process on the system are skipped, as are any
+// directories that are not empty once the files within them have been removed.
+//
+// Backups are not swept since they are written directly into the backup directory
+// alongside completed local backups.
+//
+// This function IS NOT thread-safe.
+func CleanupTmpDirectory() error {
+	if !_config.System.TmpCleanup.Enabled {
+		return nil
+	}
+
+	root := _config.System.TmpDirectory
+	cutoff := time.Now().Add(-time.Duration(_config.System.TmpCleanup.MinAge) * time.Second)
+	open := openFiles()
+
+	var dirs []string
+	var removed, reclaimed int64
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		st, err := d.Info()
+		if err != nil || st.ModTime().After(cutoff) {
+			return nil
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+		if _, ok := open[p]; ok {
+			log.WithField("path", p).Debug("skipping temporary file that is currently in use")
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			log.WithField("path", p).WithField("error", err).Warn("failed to remove stale temporary file")
+			return nil
+		}
+		removed++
+		reclaimed += st.Size()
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "config: failed to walk temporary directory")
+	}
+
+	// Remove any stale directories that are now empty, starting with the deepest ones
+	// so that their parents can be removed after them. Removing a directory that still
+	// has contents fails, which is expected and ignored.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+
+	log.WithFields(log.Fields{
+		"path":      root,
+		"files":     removed,
+		"reclaimed": system.FormatBytes(reclaimed),
+	}).Info("cleaned up stale temporary files")
 	return nil
 }
 
+// openFiles returns the paths of all files currently held open by any process
+// on the system that Wings is able to inspect.
+func openFiles() map[string]struct{} {
+	out := make(map[string]struct{})
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		if p, err := os.Readlink(fd); err == nil {
+			out[p] = struct{}{}
+		}
+	}
+	return out
+}
+
 // EnableLogRotation writes a logrotate file for wings to the system logrotate
 // configuration directory if one exists and a logrotate file is not found. This
 // allows us to basically automate away the log rotation for most installs, but
@@ -614,8 +3366,38 @@ func EnableLogRotation() error {
 	return errors.Wrap(t.Execute(f, _config.System), "config: failed to write logrotate to disk")
 }
 
+// StatePersistence defines how the state of servers is saved to the disk. The states
+// are always saved once a minute, enabling this also saves them whenever the state
+// of a server changes.
+type StatePersistence struct {
+	// Enabled saves the desired state of servers each time a server changes state,
+	// rather than the state they happen to be in once a minute. A server that stops
+	// without being stopped through Wings, such as when the host is shutting down,
+	// keeps its desired state of running and is started again when Wings boots.
+	Enabled bool `default:"false" yaml:"enabled"`
+
+	// Path is the location of the file the states are saved to. If empty the states
+	// are saved to "states.json" in the root directory.
+	Path string `yaml:"path"`
+}
+
+// validate checks that the path the states are saved to is absolute. Whether its
+// directory can be written to is checked by ConfigureDirectories when booting.
+func (sp StatePersistence) validate() error {
+	if sp.Path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(sp.Path) {
+		return errors.New("config: system.state_persistence.path must be an absolute path")
+	}
+	return nil
+}
+
 // GetStatesPath returns the location of the JSON file that tracks server states.
 func (sc *SystemConfiguration) GetStatesPath() string {
+	if sc.StatePersistence.Path != "" {
+		return sc.StatePersistence.Path
+	}
 	return path.Join(sc.RootDirectory, "/states.json")
 }
 
@@ -2,13 +2,29 @@ package config
 
 import (
 	"encoding/base64"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
 
+	"emperror.dev/errors"
+	"github.com/docker/docker/api/types/blkiodev"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/registry"
 	"github.com/goccy/go-json"
+	"golang.org/x/sys/unix"
 )
 
+var networkNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
 type dockerNetworkInterfaces struct {
 	V4 struct {
 		Subnet  string `default:"172.18.0.0/16"`
@@ -44,13 +60,75 @@ type DockerNetworkConfiguration struct {
 // DockerConfiguration defines the docker configuration used by the daemon when
 // interacting with containers and networks on the system.
 type DockerConfiguration struct {
+	// Host is the address of the Docker daemon that Wings should connect to. This supports
+	// "unix://", "tcp://", and "ssh://" endpoints. If left empty the DOCKER_HOST environment
+	// variable is used, falling back to the default Docker socket.
+	Host string `default:"" json:"-" yaml:"host"`
+
+	// TLS defines the certificates used to connect to the Docker daemon when connecting
+	// to it over "tcp://".
+	TLS struct {
+		CAFile   string `json:"-" yaml:"ca_file"`
+		CertFile string `json:"-" yaml:"cert_file"`
+		KeyFile  string `json:"-" yaml:"key_file"`
+	} `json:"-" yaml:"tls"`
+
 	// Network configuration that should be used when creating a new network
 	// for containers run through the daemon.
 	Network DockerNetworkConfiguration `json:"network" yaml:"network"`
 
+	// DefaultNetworkMode is the network mode used for server containers that do not have one
+	// assigned to them. This can be "bridge", "host", "none", "container:<name|id>", or the name
+	// of an existing network. If left empty the network managed by Wings is used. Containers in
+	// the "host", "none" and "container:" modes have no network stack of their own, so their
+	// allocations are not published and no hostname or DNS servers are set for them.
+	DefaultNetworkMode string `default:"" json:"default_network_mode" yaml:"default_network_mode"`
+
+	// ConnectRetries is the number of times Wings will retry connecting to the Docker daemon
+	// when booting before giving up. This avoids Wings exiting if the Docker daemon is started
+	// slightly after Wings.
+	ConnectRetries int `default:"4" json:"connect_retries" yaml:"connect_retries"`
+
+	// ConnectRetryDelay is the initial amount of time in seconds to wait between attempts to
+	// connect to the Docker daemon. The delay doubles after each failed attempt.
+	ConnectRetryDelay int `default:"2" json:"connect_retry_delay" yaml:"connect_retry_delay"`
+
+	// Reconnect controls how Wings handles losing its connection to the Docker daemon while
+	// servers are running, such as when the daemon is restarted during an upgrade.
+	Reconnect DockerReconnect `json:"reconnect" yaml:"reconnect"`
+
+	// StopGracePeriod is the amount of time in seconds Docker waits for a container to stop
+	// after sending the stop signal before it is killed with SIGKILL. This is applied when a
+	// container is created and is used whenever the container is stopped natively by Docker,
+	// such as when a server has no stop configuration defined or the Docker daemon is shutting
+	// down. The stop command or signal defined by the server's Egg is always sent first and
+	// is not affected by this value. A value of 0 leaves the Docker defaults in place, which
+	// waits indefinitely for a container to stop when Wings stops it natively.
+	StopGracePeriod int `default:"0" json:"stop_grace_period" yaml:"stop_grace_period"`
+
+	// MaxStopGracePeriod is the maximum stop grace period in seconds that a server may be
+	// assigned. Any larger value assigned to a server is reduced to this value. A value of
+	// 0 means there is no maximum.
+	MaxStopGracePeriod int `default:"0" json:"max_stop_grace_period" yaml:"max_stop_grace_period"`
+
+	// StopChain defines the stages used to stop a server, in order. Each stage is given its
+	// timeout in seconds to stop the server before moving on to the next stage, although no
+	// stage waits longer than the action stopping the server allows. If empty the server is
+	// sent the stop command defined by its Egg with 600 seconds to stop, then SIGTERM with
+	// 30 seconds to stop, and is then killed.
+	StopChain []StopStage `json:"stop_chain" yaml:"stop_chain"`
+
 	// Domainname is the Docker domainname for all containers.
 	Domainname string `default:"" json:"domainname" yaml:"domainname"`
 
+	// HostnameTemplate is a Go template for the hostname of server containers, such as
+	// "{{.ServerUuid}}" or "mc-{{.ShortUuid}}". The fields available are ServerUuid,
+	// ShortUuid (the first 8 characters of the UUID), and NodeHostname (the hostname of
+	// this machine). The result is lowercased, characters that are not allowed in a
+	// hostname are replaced with "-", and it is truncated to 63 characters. If empty the
+	// hostname is the UUID of the server.
+	HostnameTemplate string `default:"" json:"hostname_template" yaml:"hostname_template"`
+
 	// Registries .
 	Registries map[string]RegistryConfiguration `json:"registries" yaml:"registries"`
 
@@ -59,12 +137,119 @@ type DockerConfiguration struct {
 	// keep track of the space used there, so avoid allocating too much to a server.
 	TmpfsSize uint `default:"100" json:"tmpfs_size" yaml:"tmpfs_size"`
 
+	// Tmpfs defines additional tmpfs mounts for server containers, mapping the path inside
+	// the container to the mount options, such as "size=100m". Every mount must define a
+	// size. These can be overridden on a per-server basis. The combined size of all tmpfs
+	// mounts for a container, including /tmp, may not exceed the memory of the host.
+	Tmpfs map[string]string `json:"tmpfs" yaml:"tmpfs"`
+
+	// MetadataLabels maps server metadata fields provided by the Panel to the container
+	// labels they should be applied as, for example {"customer": "com.example.customer"}.
+	// Only the fields listed here are ever applied to a container, which avoids leaking
+	// any sensitive metadata to tooling that can read container labels. The "name" and
+	// "description" fields of the server are also available.
+	MetadataLabels map[string]string `json:"metadata_labels" yaml:"metadata_labels"`
+
+	// ReadonlyRootfs controls whether the root filesystem of server containers is mounted
+	// as read-only, leaving only the server data directory, additional mounts, and the
+	// tmpfs mounts writable. This can be overridden on a per-server basis.
+	//
+	// Most eggs are compatible with this since their images keep everything the server
+	// writes in the data directory (/home/container) or /tmp. Eggs whose startup command
+	// installs packages, or writes to paths such as /etc, /var or the user's home outside
+	// of the data directory at runtime are not, unless those paths are added as tmpfs
+	// mounts.
+	ReadonlyRootfs bool `default:"true" json:"readonly_rootfs" yaml:"readonly_rootfs"`
+
+	// ShmSize is the size of /dev/shm in server containers, for example "256m" or "1g".
+	// Some servers and browser based tools crash with the Docker default of 64MB. Memory
+	// used in /dev/shm counts towards the memory limit of the container. This can be
+	// overridden on a per-server basis. If empty the Docker default is used.
+	ShmSize string `default:"" json:"shm_size" yaml:"shm_size"`
+
+	// Init runs an init process (docker-init, which is tini) as PID 1 in server containers
+	// that forwards signals to the server process and reaps any orphaned child processes.
+	// Without it, servers that spawn child processes without waiting on them accumulate
+	// zombie processes. This can be overridden on a per-server basis, and has no effect
+	// if the Docker daemon does not have an init binary available. When disabled and not
+	// overridden, the Docker daemon's default-init setting applies.
+	Init bool `default:"false" json:"init" yaml:"init"`
+
+	// NetworkRateLimit limits the bandwidth available to each server container. The limits
+	// are applied with tc inside the network namespace of the container once it has been
+	// started, which requires the tc (iproute2) and nsenter (util-linux) binaries on the
+	// host, and a kernel with the sch_tbf, sch_ingress, cls_u32, and act_police modules
+	// available. These can be overridden on a per-server basis, and are not applied to
+	// containers in the "host", "none" or "container:" network modes, which have no
+	// network stack of their own to limit.
+	NetworkRateLimit NetworkRateLimit `json:"network_rate_limit" yaml:"network_rate_limit"`
+
+	// AllocationResolver configures how allocations that are assigned by the Panel
+	// using a hostname rather than an IP address are resolved when binding them.
+	AllocationResolver AllocationResolver `json:"allocation_resolver" yaml:"allocation_resolver"`
+
+	// Devices are host devices made available to server containers, such as /dev/net/tun
+	// for servers running a VPN. These are only applied if system.allow_device_access is
+	// enabled.
+	Devices []DockerDevice `json:"devices" yaml:"devices"`
+
+	// DeviceRequests request devices from a Docker device driver for server containers,
+	// most commonly GPUs using the "nvidia" driver. These are only applied if
+	// system.allow_device_access is enabled.
+	DeviceRequests []DockerDeviceRequest `json:"device_requests" yaml:"device_requests"`
+
 	// ContainerPidLimit sets the total number of processes that can be active in a container
 	// at any given moment. This is a security concern in shared-hosting environments where a
 	// malicious process could create enough processes to cause the host node to run out of
 	// available pids and crash.
 	ContainerPidLimit int64 `default:"512" json:"container_pid_limit" yaml:"container_pid_limit"`
 
+	// MaxConcurrentPulls is the maximum number of different images that are pulled at the
+	// same time, with any other pulls being queued until one completes. Servers that need
+	// an image that is already being pulled always wait on that pull rather than pulling
+	// it again. Changes to this value take effect when Wings is restarted.
+	MaxConcurrentPulls int `default:"4" json:"max_concurrent_pulls" yaml:"max_concurrent_pulls"`
+
+	// DefaultCpuShares is the relative CPU weight given to server containers that do not have
+	// one assigned to them. This only has an effect when the host CPU is under contention. A
+	// value of 0 leaves the weight unset, which Docker treats as 1024.
+	DefaultCpuShares int64 `default:"0" json:"default_cpu_shares" yaml:"default_cpu_shares"`
+
+	// BlkioWeight is the relative IO weight given to server containers that do not have one
+	// assigned to them, between 10 and 1000. A value of 0 leaves the weight unset.
+	BlkioWeight uint16 `default:"0" json:"blkio_weight" yaml:"blkio_weight"`
+
+	// MemorySwap is the maximum amount of swap in MiB that a server container may use
+	// in addition to its memory. The swap assigned to a server by the Panel is used as
+	// long as it is within this limit. The default of 0 disables swap for all servers,
+	// regardless of what the Panel assigns, so that servers do not thrash the disk of
+	// the node once they exceed their memory. Set to -1 to use the swap assigned by
+	// the Panel, including unlimited swap.
+	MemorySwap int64 `default:"0" json:"memory_swap" yaml:"memory_swap"`
+
+	// MemorySwappiness is the tendency of the kernel to swap out the memory of server
+	// containers, between 0 and 100, unless the server has its own value assigned. Set
+	// to -1 to use the default of the host. This is only supported on hosts using
+	// cgroups v1, Docker ignores it on hosts using cgroups v2.
+	MemorySwappiness int64 `default:"-1" json:"memory_swappiness" yaml:"memory_swappiness"`
+
+	// IoLimits limit the rate at which server containers can read from and write to
+	// block devices on the host, with at most one entry per device. These can be
+	// overridden on a per-server basis for the same device, and are unlimited if not
+	// set. The limits are enforced by the kernel's block IO throttling, which requires
+	// the blkio controller on hosts using cgroups v1, or the io controller on hosts
+	// using cgroups v2. On cgroups v1 only direct IO is throttled, writes that go
+	// through the page cache are not. The device must be the whole block device that
+	// holds the data directory, such as /dev/sda or /dev/nvme0n1, not a partition.
+	IoLimits []DockerIoLimit `json:"io_limits" yaml:"io_limits"`
+
+	// GroupAdd are the additional groups the server process is a member of inside the
+	// container, such as "render" or "video" for servers that need access to a GPU.
+	// Each group is either a numeric GID or the name of a group on the host, which is
+	// resolved to its GID on the host since the image is unlikely to define the same
+	// groups. This can be overridden on a per-server basis.
+	GroupAdd []string `json:"group_add" yaml:"group_add"`
+
 	// InstallerLimits defines the limits on the installer containers that prevents a server's
 	// installation process from unintentionally consuming more resources than expected. This
 	// is used in conjunction with the server's defined limits. Whichever value is higher will
@@ -85,9 +270,24 @@ type DockerConfiguration struct {
 	//
 	// If the value is blank, the daemon's user namespace remapping configuration is used,
 	// if the value is "host", then the pterodactyl containers are started with user namespace
-	// remapping disabled
+	// remapping disabled. These are the only values accepted by Docker, the remapping itself
+	// (the "userns-remap" option) is configured on the Docker daemon.
+	//
+	// When remapping is in use the user inside the container is offset on the host by the
+	// start of the subordinate ID range assigned to the remap user in /etc/subuid and
+	// /etc/subgid. Wings changes the ownership of server files to the UID and GID of the
+	// system user (system.username), so unless that user is created with the offset IDs
+	// the server process will not own its files and will be unable to write to them.
 	UsernsMode string `default:"" json:"userns_mode" yaml:"userns_mode"`
 
+	// ImagePruning controls the automatic removal of old images that are no longer
+	// used by any server on this node.
+	ImagePruning ImagePruning `json:"image_pruning" yaml:"image_pruning"`
+
+	// OrphanedContainers controls the automatic cleanup of server containers that are
+	// still present on this node after their server has been deleted on the Panel.
+	OrphanedContainers OrphanedContainers `json:"orphaned_containers" yaml:"orphaned_containers"`
+
 	LogConfig struct {
 		Type   string            `default:"local" json:"type" yaml:"type"`
 		Config map[string]string `default:"{\"max-size\":\"5m\",\"max-file\":\"1\",\"compress\":\"false\",\"mode\":\"non-blocking\"}" json:"config" yaml:"config"`
@@ -105,6 +305,813 @@ func (c DockerConfiguration) ContainerLogConfig() container.LogConfig {
 	}
 }
 
+// ContainerNetworkMode returns the network mode that should be used for containers
+// that do not have a specific network mode assigned to them.
+func (c DockerConfiguration) ContainerNetworkMode() string {
+	if c.DefaultNetworkMode != "" {
+		return c.DefaultNetworkMode
+	}
+	return c.Network.Mode
+}
+
+// ContainerStopTimeout returns the stop grace period in seconds for a container. If
+// the server has its own grace period assigned it is used in place of the default,
+// but is never allowed to exceed the configured maximum. If no grace period or
+// maximum is configured -1 is returned, which waits for the container to stop
+// without ever killing it.
+func (c DockerConfiguration) ContainerStopTimeout(override int) int {
+	timeout := c.StopGracePeriod
+	if override > 0 {
+		timeout = override
+	}
+	if c.MaxStopGracePeriod > 0 && (timeout == 0 || timeout > c.MaxStopGracePeriod) {
+		return c.MaxStopGracePeriod
+	}
+	if timeout == 0 {
+		return -1
+	}
+	return timeout
+}
+
+// Types of stages that can be used to stop a server.
+const (
+	// StopStageCommand stops the server using the stop command or signal defined by
+	// its Egg.
+	StopStageCommand = "command"
+	// StopStageSignal sends a signal to the server process.
+	StopStageSignal = "signal"
+	// StopStageKill kills the server process immediately.
+	StopStageKill = "kill"
+)
+
+// stopSignals are the signals that may be sent by a signal stop stage.
+var stopSignals = map[string]bool{
+	"SIGTERM": true, "SIGINT": true, "SIGQUIT": true, "SIGHUP": true,
+	"SIGABRT": true, "SIGUSR1": true, "SIGUSR2": true,
+}
+
+// StopStage is a single stage used to stop a server.
+type StopStage struct {
+	// Type is one of "command", "signal" or "kill".
+	Type string `json:"type" yaml:"type"`
+
+	// Signal is the signal sent by a "signal" stage, defaulting to SIGTERM.
+	Signal string `json:"signal" yaml:"signal"`
+
+	// Timeout is the number of seconds to wait for the server to stop before moving on
+	// to the next stage. This is not used by a "kill" stage.
+	Timeout int `json:"timeout" yaml:"timeout"`
+}
+
+// StopStages returns the stages used to stop a server.
+func (c DockerConfiguration) StopStages() []StopStage {
+	if len(c.StopChain) > 0 {
+		return c.StopChain
+	}
+	return []StopStage{
+		{Type: StopStageCommand, Timeout: 600},
+		{Type: StopStageSignal, Signal: "SIGTERM", Timeout: 30},
+		{Type: StopStageKill},
+	}
+}
+
+// validateStopChain checks that every stage of the stop chain is valid, and that
+// a kill stage is only used as the final stage.
+func (c DockerConfiguration) validateStopChain() error {
+	for i, s := range c.StopChain {
+		switch s.Type {
+		case StopStageCommand:
+		case StopStageSignal:
+			if s.Signal != "" && !stopSignals[strings.ToUpper(s.Signal)] {
+				return errors.Errorf("config: docker.stop_chain signal \"%s\" is not a supported signal", s.Signal)
+			}
+		case StopStageKill:
+			if i != len(c.StopChain)-1 {
+				return errors.New("config: docker.stop_chain may only have a kill stage as the final stage")
+			}
+			continue
+		default:
+			return errors.New("config: docker.stop_chain stage type must be one of \"command\", \"signal\" or \"kill\"")
+		}
+		if s.Timeout < 1 {
+			return errors.New("config: docker.stop_chain stage timeout must be at least 1 second")
+		}
+	}
+	return nil
+}
+
+// tmpfsFlags are the tmpfs mount options that do not take a value.
+var tmpfsFlags = map[string]bool{
+	"rw": true, "ro": true, "exec": true, "noexec": true, "suid": true, "nosuid": true,
+	"dev": true, "nodev": true, "sync": true, "async": true,
+}
+
+// sizeRegexp matches the size of a tmpfs mount or of /dev/shm, for example "100m"
+// or "1g".
+var sizeRegexp = regexp.MustCompile(`^([0-9]+)([kmg]?)$`)
+
+// parseSize returns the size in bytes of a value matching sizeRegexp.
+func parseSize(v string) (int64, bool) {
+	m := sizeRegexp.FindStringSubmatch(strings.ToLower(v))
+	if m == nil {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch m[2] {
+	case "k":
+		size <<= 10
+	case "m":
+		size <<= 20
+	case "g":
+		size <<= 30
+	}
+	return size, true
+}
+
+// NetworkRateLimit defines the bandwidth limits of a container, as a rate such as
+// "500kbit", "100mbit", or "1gbit". An empty rate is unlimited.
+type NetworkRateLimit struct {
+	// Egress is the limit on traffic sent by the container.
+	Egress string `json:"egress" yaml:"egress"`
+
+	// Ingress is the limit on traffic received by the container. Traffic exceeding the
+	// limit is dropped rather than queued, so this is less precise than the egress limit.
+	Ingress string `json:"ingress" yaml:"ingress"`
+
+	// FailOpen allows a server to keep running without its limits when they cannot be
+	// applied. By default the container is killed and the server fails to start.
+	FailOpen bool `default:"false" json:"fail_open" yaml:"fail_open"`
+}
+
+// rateRegexp matches a network rate, for example "100mbit".
+var rateRegexp = regexp.MustCompile(`^([0-9]+)(kbit|mbit|gbit)$`)
+
+// ParseNetworkRate returns the rate in bits per second for a network rate such as
+// "100mbit". A rate of 0 is returned for an empty value, meaning unlimited.
+func ParseNetworkRate(v string) (uint64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	m := rateRegexp.FindStringSubmatch(strings.ToLower(v))
+	if m == nil {
+		return 0, errors.Errorf("config: network rate \"%s\" must be a rate such as \"500kbit\", \"100mbit\", or \"1gbit\"", v)
+	}
+	rate, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil || rate == 0 {
+		return 0, errors.Errorf("config: network rate \"%s\" must be greater than 0", v)
+	}
+	switch m[2] {
+	case "kbit":
+		rate *= 1000
+	case "mbit":
+		rate *= 1000 * 1000
+	case "gbit":
+		rate *= 1000 * 1000 * 1000
+	}
+	return rate, nil
+}
+
+// NetworkRates returns the egress and ingress limits in bits per second for a
+// container, using the server specific limits if they are set. A rate of 0 means
+// that direction is unlimited.
+func (c DockerConfiguration) NetworkRates(egress string, ingress string) (uint64, uint64, error) {
+	if egress == "" {
+		egress = c.NetworkRateLimit.Egress
+	}
+	if ingress == "" {
+		ingress = c.NetworkRateLimit.Ingress
+	}
+	e, err := ParseNetworkRate(egress)
+	if err != nil {
+		return 0, 0, err
+	}
+	i, err := ParseNetworkRate(ingress)
+	if err != nil {
+		return 0, 0, err
+	}
+	return e, i, nil
+}
+
+const (
+	AllocationFamilyAny  = "any"
+	AllocationFamilyIPv4 = "ipv4"
+	AllocationFamilyIPv6 = "ipv6"
+)
+
+// AllocationResolver defines how the hostnames of allocations are resolved to the
+// IP addresses that ports are bound to.
+type AllocationResolver struct {
+	// Servers is the list of DNS servers to query, as IP addresses with an optional
+	// port. If empty the resolver of the system is used.
+	Servers []string `json:"servers" yaml:"servers"`
+
+	// CacheTtl is the number of seconds that a resolved hostname is cached for, so
+	// that every allocation using the hostname is bound to the same address. Set to
+	// 0 to disable the cache.
+	CacheTtl int `default:"300" json:"cache_ttl" yaml:"cache_ttl"`
+
+	// PreferredFamily is the address family that is bound to when a hostname resolves
+	// to both IPv4 and IPv6 addresses. One of "any", "ipv4", or "ipv6", where "any"
+	// uses the order returned by the DNS server.
+	PreferredFamily string `default:"any" json:"preferred_family" yaml:"preferred_family"`
+}
+
+// ServerAddresses returns the addresses of the DNS servers, using port 53 for the
+// servers without a port.
+func (r AllocationResolver) ServerAddresses() []string {
+	out := make([]string, 0, len(r.Servers))
+	for _, s := range r.Servers {
+		if _, _, err := net.SplitHostPort(s); err == nil {
+			out = append(out, s)
+		} else {
+			out = append(out, net.JoinHostPort(s, "53"))
+		}
+	}
+	return out
+}
+
+func (r AllocationResolver) validate() error {
+	for _, s := range r.ServerAddresses() {
+		host, port, _ := net.SplitHostPort(s)
+		if net.ParseIP(host) == nil {
+			return errors.Errorf("config: docker.allocation_resolver.servers entry \"%s\" must be an IP address", s)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return errors.Errorf("config: docker.allocation_resolver.servers entry \"%s\" has an invalid port", s)
+		}
+	}
+	if r.CacheTtl < 0 {
+		return errors.New("config: docker.allocation_resolver.cache_ttl must not be negative")
+	}
+	switch r.PreferredFamily {
+	case AllocationFamilyAny, AllocationFamilyIPv4, AllocationFamilyIPv6:
+	default:
+		return errors.New("config: docker.allocation_resolver.preferred_family must be one of \"any\", \"ipv4\", or \"ipv6\"")
+	}
+	return nil
+}
+
+// DockerDevice is a device on the host that is made available inside of server
+// containers.
+type DockerDevice struct {
+	// PathOnHost is the path of the device on the host, such as "/dev/net/tun".
+	PathOnHost string `json:"path_on_host" yaml:"path_on_host"`
+
+	// PathInContainer is the path of the device inside the container. If empty the
+	// path on the host is used.
+	PathInContainer string `json:"path_in_container" yaml:"path_in_container"`
+
+	// Permissions are the cgroup permissions for the device, any combination of "r"
+	// (read), "w" (write) and "m" (mknod). If empty "rwm" is used.
+	Permissions string `json:"permissions" yaml:"permissions"`
+}
+
+// DockerDeviceRequest requests devices from a Docker device driver.
+type DockerDeviceRequest struct {
+	// Driver is the name of the device driver, such as "nvidia".
+	Driver string `json:"driver" yaml:"driver"`
+
+	// Count is the number of devices to request, -1 requests all devices. This is
+	// ignored if DeviceIDs is set.
+	Count int `json:"count" yaml:"count"`
+
+	// DeviceIDs are the specific devices to request, such as the index or UUID of a GPU.
+	DeviceIDs []string `json:"device_ids" yaml:"device_ids"`
+
+	// Capabilities are the capabilities the devices must have, such as [["gpu"]].
+	Capabilities [][]string `json:"capabilities" yaml:"capabilities"`
+
+	// Options are passed to the device driver.
+	Options map[string]string `json:"options" yaml:"options"`
+}
+
+// DockerIoLimit limits the disk IO of a container on a single block device. Rates
+// are a size per second such as "50m", and a rate of 0 or an empty rate is unlimited.
+type DockerIoLimit struct {
+	// Device is the path of the block device on the host, such as /dev/sda.
+	Device string `json:"device" yaml:"device"`
+
+	// ReadBps and WriteBps are the maximum number of bytes read from and written to
+	// the device per second.
+	ReadBps  string `json:"read_bps" yaml:"read_bps"`
+	WriteBps string `json:"write_bps" yaml:"write_bps"`
+
+	// ReadIops and WriteIops are the maximum number of read and write operations
+	// performed on the device per second.
+	ReadIops  uint64 `json:"read_iops" yaml:"read_iops"`
+	WriteIops uint64 `json:"write_iops" yaml:"write_iops"`
+}
+
+// ContainerIoLimits holds the block IO throttling applied to a container.
+type ContainerIoLimits struct {
+	ReadBps   []*blkiodev.ThrottleDevice
+	WriteBps  []*blkiodev.ThrottleDevice
+	ReadIops  []*blkiodev.ThrottleDevice
+	WriteIops []*blkiodev.ThrottleDevice
+}
+
+// parseIoRate returns the number of bytes per second for a rate such as "50m". A
+// rate of 0 is returned for an empty value, meaning unlimited.
+func parseIoRate(v string) (uint64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	size, ok := parseSize(v)
+	if !ok || size < 0 {
+		return 0, errors.Errorf("config: io rate \"%s\" must be a size per second, such as \"512k\" or \"50m\"", v)
+	}
+	return uint64(size), nil
+}
+
+// IoThrottle returns the block IO throttling to apply to a container, using the
+// server specific limits in place of the defaults for the same device. Devices are
+// checked to exist on the host, and limits of 0 are left out since they are
+// unlimited.
+func (c DockerConfiguration) IoThrottle(overrides []DockerIoLimit) (ContainerIoLimits, error) {
+	var out ContainerIoLimits
+	limits := make([]DockerIoLimit, 0, len(c.IoLimits)+len(overrides))
+	for _, l := range c.IoLimits {
+		overridden := false
+		for _, o := range overrides {
+			if o.Device == l.Device {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			limits = append(limits, l)
+		}
+	}
+	limits = append(limits, overrides...)
+
+	seen := make(map[string]bool, len(limits))
+	for _, l := range limits {
+		if err := validateIoLimit(l); err != nil {
+			return out, err
+		}
+		if seen[l.Device] {
+			return out, errors.Errorf("config: io limits for device \"%s\" are defined more than once", l.Device)
+		}
+		seen[l.Device] = true
+		readBps, _ := parseIoRate(l.ReadBps)
+		writeBps, _ := parseIoRate(l.WriteBps)
+		add := func(dst *[]*blkiodev.ThrottleDevice, rate uint64) {
+			if rate > 0 {
+				*dst = append(*dst, &blkiodev.ThrottleDevice{Path: l.Device, Rate: rate})
+			}
+		}
+		add(&out.ReadBps, readBps)
+		add(&out.WriteBps, writeBps)
+		add(&out.ReadIops, l.ReadIops)
+		add(&out.WriteIops, l.WriteIops)
+	}
+	return out, nil
+}
+
+// validateIoLimit checks that the device of an IO limit is a block device that
+// exists on the host and that its rates are well-formed.
+func validateIoLimit(l DockerIoLimit) error {
+	if !path.IsAbs(l.Device) {
+		return errors.Errorf("config: io limit device \"%s\" must be an absolute path", l.Device)
+	}
+	st, err := os.Stat(l.Device)
+	if err != nil {
+		return errors.Errorf("config: io limit device \"%s\" does not exist on this system", l.Device)
+	}
+	if st.Mode()&os.ModeDevice == 0 || st.Mode()&os.ModeCharDevice != 0 {
+		return errors.Errorf("config: io limit device \"%s\" is not a block device", l.Device)
+	}
+	if _, err := parseIoRate(l.ReadBps); err != nil {
+		return err
+	}
+	if _, err := parseIoRate(l.WriteBps); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ContainerGroupAdd returns the GIDs of the additional groups for a container, using
+// the server specific groups in place of the defaults if any are set. Groups given
+// by name are resolved to their GID on the host.
+func (c DockerConfiguration) ContainerGroupAdd(override []string) ([]string, error) {
+	groups := c.GroupAdd
+	if len(override) > 0 {
+		groups = override
+	}
+	out := make([]string, 0, len(groups))
+	for _, g := range groups {
+		if g == "" {
+			return nil, errors.New("config: group_add cannot contain an empty group")
+		}
+		if gid, err := strconv.ParseUint(g, 10, 32); err == nil {
+			out = append(out, strconv.FormatUint(gid, 10))
+			continue
+		}
+		grp, err := user.LookupGroup(g)
+		if err != nil {
+			return nil, errors.Errorf("config: group_add group \"%s\" does not exist on this system", g)
+		}
+		out = append(out, grp.Gid)
+	}
+	return out, nil
+}
+
+// ContainerDevices returns the devices and device requests to apply to server
+// containers.
+func (c DockerConfiguration) ContainerDevices() ([]container.DeviceMapping, []container.DeviceRequest) {
+	devices := make([]container.DeviceMapping, 0, len(c.Devices))
+	for _, d := range c.Devices {
+		m := container.DeviceMapping{PathOnHost: d.PathOnHost, PathInContainer: d.PathInContainer, CgroupPermissions: d.Permissions}
+		if m.PathInContainer == "" {
+			m.PathInContainer = m.PathOnHost
+		}
+		if m.CgroupPermissions == "" {
+			m.CgroupPermissions = "rwm"
+		}
+		devices = append(devices, m)
+	}
+	requests := make([]container.DeviceRequest, 0, len(c.DeviceRequests))
+	for _, r := range c.DeviceRequests {
+		requests = append(requests, container.DeviceRequest{
+			Driver:       r.Driver,
+			Count:        r.Count,
+			DeviceIDs:    r.DeviceIDs,
+			Capabilities: r.Capabilities,
+			Options:      r.Options,
+		})
+	}
+	return devices, requests
+}
+
+// validateDevices checks that every configured device has valid paths and
+// permissions, and that every device request identifies the devices to use. The
+// devices are only checked to exist on the host if device access is allowed, since
+// they are not used otherwise.
+func (c DockerConfiguration) validateDevices(allowAccess bool) error {
+	for _, d := range c.Devices {
+		if !path.IsAbs(d.PathOnHost) {
+			return errors.New("config: docker.devices path_on_host must be an absolute path")
+		}
+		if allowAccess {
+			if _, err := os.Stat(d.PathOnHost); err != nil {
+				return errors.Errorf("config: docker.devices path_on_host \"%s\" does not exist on this system", d.PathOnHost)
+			}
+		}
+		if d.PathInContainer != "" && !path.IsAbs(d.PathInContainer) {
+			return errors.New("config: docker.devices path_in_container must be an absolute path")
+		}
+		if d.Permissions != "" && strings.Trim(d.Permissions, "rwm") != "" {
+			return errors.New("config: docker.devices permissions must be a combination of \"r\", \"w\" and \"m\"")
+		}
+	}
+	for _, r := range c.DeviceRequests {
+		if r.Driver == "" && len(r.Capabilities) == 0 {
+			return errors.New("config: docker.device_requests must set a driver or capabilities")
+		}
+		if len(r.DeviceIDs) == 0 && r.Count == 0 {
+			return errors.New("config: docker.device_requests must set a count or device_ids")
+		}
+	}
+	return nil
+}
+
+// ShmSizeBytes returns the size in bytes of the /dev/shm mount for a container,
+// using the server specific size if it is set. A size of 0 is returned if neither
+// is set, in which case the Docker default of 64MB is used.
+func (c DockerConfiguration) ShmSizeBytes(override string) (int64, error) {
+	v := c.ShmSize
+	if override != "" {
+		v = override
+	}
+	if v == "" {
+		return 0, nil
+	}
+	size, ok := parseSize(v)
+	if !ok || size <= 0 {
+		return 0, errors.Errorf("config: shm size \"%s\" must be a size greater than 0, such as \"256m\" or \"1g\"", v)
+	}
+	return size, nil
+}
+
+// ValidateTmpfs checks that each of the tmpfs mounts provided has an absolute path
+// and well-formed options including a size, and returns the combined size of the
+// mounts in bytes.
+func ValidateTmpfs(mounts map[string]string) (int64, error) {
+	var total int64
+	for p, opts := range mounts {
+		if !path.IsAbs(p) || path.Clean(p) != p {
+			return 0, errors.Errorf("config: tmpfs mount path \"%s\" must be a clean absolute path", p)
+		}
+		var size int64 = -1
+		for _, opt := range strings.Split(opts, ",") {
+			k, v, ok := strings.Cut(opt, "=")
+			if !ok {
+				if !tmpfsFlags[k] {
+					return 0, errors.Errorf("config: tmpfs mount \"%s\" has an unknown option \"%s\"", p, opt)
+				}
+				continue
+			}
+			switch k {
+			case "size":
+				var ok bool
+				if size, ok = parseSize(v); !ok {
+					return 0, errors.Errorf("config: tmpfs mount \"%s\" has an invalid size \"%s\"", p, v)
+				}
+			case "mode", "uid", "gid", "nr_inodes":
+				if _, err := strconv.ParseUint(v, 0, 32); err != nil {
+					return 0, errors.Errorf("config: tmpfs mount \"%s\" has an invalid value for \"%s\"", p, k)
+				}
+			default:
+				return 0, errors.Errorf("config: tmpfs mount \"%s\" has an unknown option \"%s\"", p, k)
+			}
+		}
+		if size <= 0 {
+			return 0, errors.Errorf("config: tmpfs mount \"%s\" must define a size greater than 0", p)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// hostMemory returns the total memory of the system in bytes, or 0 if it could
+// not be determined.
+func hostMemory() int64 {
+	var si unix.Sysinfo_t
+	if err := unix.Sysinfo(&si); err != nil {
+		return 0
+	}
+	return int64(si.Totalram) * int64(si.Unit)
+}
+
+// ContainerTmpfs returns the tmpfs mounts for a container, merging the server
+// specific mounts over the defaults defined in the configuration.
+func (c DockerConfiguration) ContainerTmpfs(override map[string]string) map[string]string {
+	out := map[string]string{
+		"/tmp": "rw,exec,nosuid,size=" + strconv.Itoa(int(c.TmpfsSize)) + "M",
+	}
+	for k, v := range c.Tmpfs {
+		out[k] = v
+	}
+	for k, v := range override {
+		out[k] = v
+	}
+	return out
+}
+
+// labelKeyRegexp matches a valid container label key, which must be lowercase and
+// made up of alphanumeric characters separated by dots or dashes.
+var labelKeyRegexp = regexp.MustCompile(`^[a-z0-9]+([.-][a-z0-9]+)*$`)
+
+// IsValidLabelKey returns whether the key provided can be applied to a container
+// as a label. Unlike the keys in the configuration any casing and punctuation is
+// accepted, since the Panel may assign keys such as "traefik.http.routers.myRouter.rule",
+// but the key must not be empty, must be valid UTF-8 without control characters,
+// and must not be in one of the namespaces reserved for use by Docker.
+func IsValidLabelKey(key string) bool {
+	if key == "" || !utf8.ValidString(key) || isReservedLabel(key) {
+		return false
+	}
+	return strings.IndexFunc(key, unicode.IsControl) == -1
+}
+
+// reservedLabelPrefixes are the label namespaces reserved for use by Docker.
+var reservedLabelPrefixes = []string{"com.docker.", "io.docker.", "org.dockerproject."}
+
+// isReservedLabel returns whether the container label key provided is in one of
+// the namespaces reserved for use by Docker.
+func isReservedLabel(key string) bool {
+	for _, prefix := range reservedLabelPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerMetadataLabels returns the container labels for the server metadata
+// provided, including only the fields that are listed in the metadata label
+// configuration.
+func (c DockerConfiguration) ContainerMetadataLabels(metadata map[string]string) map[string]string {
+	out := make(map[string]string, len(c.MetadataLabels))
+	for field, label := range c.MetadataLabels {
+		if v, ok := metadata[field]; ok {
+			out[label] = v
+		}
+	}
+	return out
+}
+
+// hostnameTemplateData is the data available to the hostname template.
+type hostnameTemplateData struct {
+	ServerUuid   string
+	ShortUuid    string
+	NodeHostname string
+}
+
+// ContainerHostname returns the hostname for the container of the server with
+// the UUID provided, based on the hostname template.
+func (c DockerConfiguration) ContainerHostname(uuid string) (string, error) {
+	if c.HostnameTemplate == "" {
+		return uuid, nil
+	}
+	t, err := template.New("hostname").Option("missingkey=error").Parse(c.HostnameTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "config: docker.hostname_template is not a valid template")
+	}
+	data := hostnameTemplateData{ServerUuid: uuid, ShortUuid: uuid}
+	if len(uuid) > 8 {
+		data.ShortUuid = uuid[:8]
+	}
+	data.NodeHostname, _ = os.Hostname()
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", errors.Wrap(err, "config: docker.hostname_template could not be executed")
+	}
+	h := sanitizeHostname(b.String())
+	if h == "" {
+		return "", errors.New("config: docker.hostname_template must produce a hostname containing at least one letter or number")
+	}
+	return h, nil
+}
+
+// sanitizeHostname converts s into a hostname that is valid according to RFC 1123,
+// replacing invalid characters with "-" and truncating it to 63 characters.
+func sanitizeHostname(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.ToLower(s))
+	var labels []string
+	for _, l := range strings.Split(s, ".") {
+		if len(l) > 63 {
+			l = l[:63]
+		}
+		if l = strings.Trim(l, "-"); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	s = strings.Join(labels, ".")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-.")
+	}
+	return s
+}
+
+// validateMetadataLabels checks that each of the metadata label keys is a valid
+// container label that is not in a namespace reserved by Docker.
+func (c DockerConfiguration) validateMetadataLabels() error {
+	for field, label := range c.MetadataLabels {
+		if field == "" {
+			return errors.New("config: docker.metadata_labels cannot contain an empty metadata field")
+		}
+		if !labelKeyRegexp.MatchString(label) {
+			return errors.Errorf("config: docker.metadata_labels label \"%s\" for field \"%s\" must be lowercase alphanumeric characters separated by dots or dashes", label, field)
+		}
+		for _, prefix := range reservedLabelPrefixes {
+			if strings.HasPrefix(label, prefix) {
+				return errors.Errorf("config: docker.metadata_labels label \"%s\" uses the reserved namespace \"%s\"", label, prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// validateUsernsMode checks that the user namespace mode is one that is accepted
+// by Docker when creating a container.
+func (c DockerConfiguration) validateUsernsMode() error {
+	if !container.UsernsMode(c.UsernsMode).Valid() {
+		return errors.New("config: docker.userns_mode must be either empty or \"host\", user namespace remapping is configured on the Docker daemon")
+	}
+	return nil
+}
+
+// validateWeights checks that the default CPU shares, block IO weight and swap
+// settings are within the ranges accepted by Docker, if they are set.
+func (c DockerConfiguration) validateWeights() error {
+	if c.DefaultCpuShares != 0 && c.DefaultCpuShares < 2 {
+		return errors.New("config: docker.default_cpu_shares must be at least 2")
+	}
+	if c.BlkioWeight != 0 && (c.BlkioWeight < 10 || c.BlkioWeight > 1000) {
+		return errors.New("config: docker.blkio_weight must be between 10 and 1000")
+	}
+	if c.MemorySwap < -1 {
+		return errors.New("config: docker.memory_swap must be -1 for unlimited, or at least 0")
+	}
+	if c.MemorySwappiness < -1 || c.MemorySwappiness > 100 {
+		return errors.New("config: docker.memory_swappiness must be -1, or between 0 and 100")
+	}
+	return nil
+}
+
+// validateHost checks that the Docker host, if one is configured, uses a supported
+// scheme and that the TLS configuration is only provided for TCP connections.
+func (c DockerConfiguration) validateHost() error {
+	tls := c.TLS.CAFile != "" || c.TLS.CertFile != "" || c.TLS.KeyFile != ""
+	if c.Host == "" {
+		if tls {
+			return errors.New("config: docker.tls can only be used when docker.host is set to a tcp:// endpoint")
+		}
+		return nil
+	}
+	u, err := url.Parse(c.Host)
+	if err != nil {
+		return errors.Wrap(err, "config: docker.host is not a valid address")
+	}
+	switch u.Scheme {
+	case "unix", "ssh":
+		if tls {
+			return errors.New("config: docker.tls can only be used when docker.host is set to a tcp:// endpoint")
+		}
+	case "tcp":
+		if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+			return errors.New("config: docker.tls.cert_file and docker.tls.key_file must be provided together")
+		}
+	default:
+		return errors.Errorf("config: docker.host must use one of the unix://, tcp://, or ssh:// schemes, got \"%s\"", u.Scheme)
+	}
+	return nil
+}
+
+// ValidateNetworkMode checks that the container network mode provided is one that
+// can be used by server containers. The "host" mode is only permitted if allowHost
+// is true, since it gives the container full access to the host's network stack.
+func ValidateNetworkMode(mode string, allowHost bool) error {
+	switch {
+	case mode == "bridge" || mode == "none":
+		return nil
+	case mode == "host":
+		if !allowHost {
+			return errors.New("config: the \"host\" network mode is not allowed unless system.allow_host_network is enabled")
+		}
+		return nil
+	case strings.HasPrefix(mode, "container:"):
+		if networkNameRegexp.MatchString(strings.TrimPrefix(mode, "container:")) {
+			return nil
+		}
+	case networkNameRegexp.MatchString(mode):
+		return nil
+	}
+	return errors.Errorf("config: \"%s\" is not a valid container network mode", mode)
+}
+
+// ImagePruning defines the configuration for automatically removing unused Docker
+// images from the system. Only images that Wings pulled itself, which are tracked in
+// the root directory, are ever considered for removal.
+type ImagePruning struct {
+	// Enabled controls whether unused images are automatically pruned.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// Interval is the amount of time in seconds between each pruning run.
+	Interval int `default:"86400" json:"interval" yaml:"interval"`
+
+	// MinAge is the minimum age in seconds an image must be before it can be pruned.
+	MinAge int `default:"604800" json:"min_age" yaml:"min_age"`
+}
+
+// DockerReconnect defines how Wings reconnects to the Docker daemon when the
+// connection is lost while servers are running.
+type DockerReconnect struct {
+	// Enabled controls whether Wings waits for the Docker daemon to return when the
+	// console stream for a server is lost because the daemon went away. If the container
+	// is still running once the daemon returns Wings re-attaches to it rather than
+	// marking the server as offline.
+	Enabled bool `default:"true" json:"enabled" yaml:"enabled"`
+
+	// InitialInterval is the initial amount of time in seconds between attempts to
+	// reconnect to the daemon. The interval doubles after each failed attempt.
+	InitialInterval int `default:"1" json:"initial_interval" yaml:"initial_interval"`
+
+	// MaxInterval is the maximum amount of time in seconds between attempts to reconnect.
+	MaxInterval int `default:"30" json:"max_interval" yaml:"max_interval"`
+
+	// MaxWait is the maximum amount of time in seconds to wait for the daemon to return
+	// before giving up and marking the affected servers as offline.
+	MaxWait int `default:"300" json:"max_wait" yaml:"max_wait"`
+}
+
+// OrphanedContainers defines the configuration for the cleanup of server
+// containers that no longer belong to a server on this node.
+type OrphanedContainers struct {
+	// Enabled controls whether orphaned containers are automatically cleaned up.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// Interval is the amount of time in seconds between each check for orphaned
+	// containers.
+	Interval int `default:"3600" json:"interval" yaml:"interval"`
+
+	// Remove controls whether orphaned containers are removed after being stopped. If
+	// false the containers are only stopped, leaving them to be inspected and removed
+	// manually.
+	Remove bool `default:"true" json:"remove" yaml:"remove"`
+}
+
 // RegistryConfiguration defines the authentication credentials for a given
 // Docker registry.
 type RegistryConfiguration struct {
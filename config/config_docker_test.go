@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func TestValidateStopChain(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("validateStopChain", func() {
+		g.It("accepts the default and valid chains", func() {
+			g.Assert(DockerConfiguration{}.validateStopChain()).IsNil()
+			g.Assert(DockerConfiguration{StopChain: []StopStage{
+				{Type: StopStageCommand, Timeout: 30},
+				{Type: StopStageSignal, Signal: "sigint", Timeout: 10},
+				{Type: StopStageKill},
+			}}.validateStopChain()).IsNil()
+		})
+
+		g.It("rejects a kill stage before the final stage", func() {
+			err := DockerConfiguration{StopChain: []StopStage{{Type: StopStageKill}, {Type: StopStageCommand, Timeout: 30}}}.validateStopChain()
+			g.Assert(err == nil).IsFalse()
+		})
+
+		g.It("rejects unknown stage types and signals", func() {
+			g.Assert(DockerConfiguration{StopChain: []StopStage{{Type: "pray", Timeout: 30}}}.validateStopChain() == nil).IsFalse()
+			g.Assert(DockerConfiguration{StopChain: []StopStage{{Type: StopStageSignal, Signal: "SIGFOO", Timeout: 30}}}.validateStopChain() == nil).IsFalse()
+		})
+
+		g.It("requires a timeout for stages other than kill", func() {
+			g.Assert(DockerConfiguration{StopChain: []StopStage{{Type: StopStageCommand}}}.validateStopChain() == nil).IsFalse()
+		})
+	})
+}
+
+func TestValidateDevices(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("validateDevices", func() {
+		c := DockerConfiguration{Devices: []DockerDevice{{PathOnHost: "/dev/wings-does-not-exist"}}}
+
+		g.It("only requires devices to exist when device access is allowed", func() {
+			g.Assert(c.validateDevices(false)).IsNil()
+			g.Assert(c.validateDevices(true) == nil).IsFalse()
+		})
+
+		g.It("rejects relative paths regardless of device access", func() {
+			g.Assert(DockerConfiguration{Devices: []DockerDevice{{PathOnHost: "dev/null"}}}.validateDevices(false) == nil).IsFalse()
+		})
+	})
+}
+
+func TestParseNetworkRate(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("ParseNetworkRate", func() {
+		g.It("converts rates to bits per second", func() {
+			for v, want := range map[string]uint64{"": 0, "500kbit": 500_000, "100mbit": 100_000_000, "1gbit": 1_000_000_000, "10MBit": 10_000_000} {
+				rate, err := ParseNetworkRate(v)
+				g.Assert(err).IsNil()
+				g.Assert(rate).Equal(want)
+			}
+		})
+
+		g.It("rejects invalid and zero rates", func() {
+			for _, v := range []string{"100", "100mb", "1.5gbit", "-1kbit", "0mbit", "mbit", "99999999999999999999kbit"} {
+				_, err := ParseNetworkRate(v)
+				g.Assert(err == nil).IsFalse()
+			}
+		})
+	})
+
+	g.Describe("NetworkRates", func() {
+		c := DockerConfiguration{NetworkRateLimit: NetworkRateLimit{Egress: "10mbit", Ingress: "20mbit"}}
+
+		g.It("uses the server specific rates over the defaults", func() {
+			e, i, err := c.NetworkRates("1mbit", "")
+			g.Assert(err).IsNil()
+			g.Assert(e).Equal(uint64(1_000_000))
+			g.Assert(i).Equal(uint64(20_000_000))
+		})
+
+		g.It("returns an error for an invalid server specific rate", func() {
+			_, _, err := c.NetworkRates("", "fast")
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
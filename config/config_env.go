@@ -0,0 +1,167 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// EnvironmentPrefix is the prefix used for environment variables that override
+// values in the configuration file.
+const EnvironmentPrefix = "WINGS"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ApplyEnvironmentOverrides overrides values in the configuration using environment
+// variables. The name of the variable for a field is the EnvironmentPrefix followed
+// by the YAML path to the field, with each segment separated by an underscore and
+// converted to uppercase. For example "debug" is overridden by WINGS_DEBUG, and
+// "system.disk_check_interval" is overridden by WINGS_SYSTEM_DISK_CHECK_INTERVAL.
+//
+// Strings, booleans, numbers and durations are supported, as are lists of strings
+// which are provided as a comma separated value. Durations may be provided either
+// as a Go duration string ("1m30s") or as a number of nanoseconds. Values set in
+// the environment always take precedence over those in the configuration file.
+//
+// Values, both in the configuration file and in the environment, are always used
+// literally. References to other environment variables such as "$HOME" are never
+// expanded, so secrets containing a "$" character do not need to be escaped.
+//
+// The values the overridden fields had before are remembered, and WriteToDisk
+// writes those instead so that the environment never ends up in the file.
+func (c *Configuration) ApplyEnvironmentOverrides() error {
+	c.envOverrides = nil
+	return applyEnvironment(reflect.ValueOf(c).Elem(), EnvironmentPrefix, nil, &c.envOverrides)
+}
+
+// envOverride is a field of the configuration that was set from an environment
+// variable.
+type envOverride struct {
+	// The index path of the field within the Configuration struct.
+	index []int
+	// The value of the field before the override was applied.
+	original reflect.Value
+	// The value set from the environment.
+	value reflect.Value
+}
+
+// restoreEnvironmentOverrides sets every field that was overridden from the
+// environment back to the value it had before, unless the field has since been
+// changed to something else.
+func (c *Configuration) restoreEnvironmentOverrides() {
+	v := reflect.ValueOf(c).Elem()
+	for _, o := range c.envOverrides {
+		f := v.FieldByIndex(o.index)
+		if reflect.DeepEqual(f.Interface(), o.value.Interface()) {
+			f.Set(o.original)
+		}
+	}
+}
+
+// applyEnvironment walks the fields of the struct value provided and sets any of
+// them that have a matching environment variable defined, recording each field
+// that was set in overrides.
+func applyEnvironment(v reflect.Value, prefix string, index []int, overrides *[]envOverride) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+
+		fv := v.Field(i)
+		fi := append(append([]int{}, index...), i)
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnvironment(fv, key, fi, overrides); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		original := reflect.New(fv.Type()).Elem()
+		original.Set(fv)
+		if err := setFromEnvironment(fv, value); err != nil {
+			return errors.WrapIff(err, "config: failed to parse environment variable %s", key)
+		}
+		set := reflect.New(fv.Type()).Elem()
+		set.Set(fv)
+		*overrides = append(*overrides, envOverride{index: fi, original: original, value: set})
+	}
+	return nil
+}
+
+// setFromEnvironment parses the environment value into the field provided based
+// on the type of the field.
+func setFromEnvironment(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			n, nerr := strconv.ParseInt(value, 10, 64)
+			if nerr != nil {
+				return err
+			}
+			d = time.Duration(n)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return errors.Errorf("unsupported list type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return errors.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/franela/goblin"
+	"gopkg.in/yaml.v2"
+)
+
+func TestEnvironmentOverrides(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("WriteToDisk", func() {
+		read := func(p string) *Configuration {
+			b, err := os.ReadFile(p)
+			g.Assert(err).IsNil()
+			var c Configuration
+			g.Assert(yaml.Unmarshal(b, &c)).IsNil()
+			return &c
+		}
+
+		g.It("writes the file values of overridden fields", func() {
+			t.Setenv("WINGS_SYSTEM_DATA", "/srv/env")
+			p := filepath.Join(t.TempDir(), "config.yml")
+			c, err := NewAtPath(p)
+			g.Assert(err).IsNil()
+			c.System.Data = "/srv/file"
+
+			g.Assert(c.ApplyEnvironmentOverrides()).IsNil()
+			g.Assert(c.System.Data).Equal("/srv/env")
+			g.Assert(WriteToDisk(c)).IsNil()
+			g.Assert(read(p).System.Data).Equal("/srv/file")
+			g.Assert(c.System.Data).Equal("/srv/env")
+		})
+
+		g.It("writes overridden fields that were changed afterwards", func() {
+			t.Setenv("WINGS_SYSTEM_DATA", "/srv/env")
+			p := filepath.Join(t.TempDir(), "config.yml")
+			c, err := NewAtPath(p)
+			g.Assert(err).IsNil()
+
+			g.Assert(c.ApplyEnvironmentOverrides()).IsNil()
+			c.System.Data = "/srv/changed"
+			g.Assert(WriteToDisk(c)).IsNil()
+			g.Assert(read(p).System.Data).Equal("/srv/changed")
+		})
+	})
+}
@@ -2,8 +2,10 @@ package environment
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 
+	"github.com/apex/log"
 	"github.com/docker/go-connections/nat"
 
 	"github.com/pterodactyl/wings/config"
@@ -38,7 +40,19 @@ type Allocations struct {
 func (a *Allocations) Bindings() nat.PortMap {
 	out := nat.PortMap{}
 
-	for ip, ports := range a.Mappings {
+	for host, ports := range a.Mappings {
+		// Allocations may be assigned using a hostname, which must be resolved to
+		// the address that the ports are bound to.
+		ip := host
+		if ip != "" && net.ParseIP(ip) == nil {
+			ips, err := ResolveAllocation(host)
+			if err != nil {
+				log.WithField("host", host).WithField("error", err).Error("failed to resolve allocation, skipping its ports")
+				continue
+			}
+			ip = ips[0].String()
+		}
+
 		for _, port := range ports {
 			// Skip over invalid ports.
 			if port < 1 || port > 65535 {
@@ -2,6 +2,8 @@ package environment
 
 import (
 	"sync"
+
+	"github.com/pterodactyl/wings/config"
 )
 
 type Settings struct {
@@ -9,6 +11,35 @@ type Settings struct {
 	Allocations Allocations
 	Limits      Limits
 	Labels      map[string]string
+	NetworkMode string
+	// StopGracePeriod is the number of seconds to wait for the container to stop before
+	// it is killed. If 0 the default from the configuration is used.
+	StopGracePeriod int
+	// Tmpfs are the tmpfs mounts for the container, overriding the defaults from
+	// the configuration for the same paths.
+	Tmpfs map[string]string
+	// ReadonlyRootfs overrides whether the root filesystem of the container is read-only.
+	// If nil the default from the configuration is used.
+	ReadonlyRootfs *bool
+	// Init overrides whether an init process is run as PID 1 in the container. If nil
+	// the default from the configuration is used.
+	Init *bool
+	// ShmSize overrides the size of /dev/shm in the container. If empty the default
+	// from the configuration is used.
+	ShmSize string
+	// Privileged requests that the container is run in privileged mode. This is only
+	// honored if privileged containers are allowed by the configuration.
+	Privileged bool
+	// NetworkEgress and NetworkIngress override the bandwidth limits of the container.
+	// If empty the defaults from the configuration are used.
+	NetworkEgress  string
+	NetworkIngress string
+	// IoLimits override the disk IO limits of the container for the devices they are
+	// set for.
+	IoLimits []config.DockerIoLimit
+	// GroupAdd overrides the additional groups of the process in the container. If
+	// empty the groups from the configuration are used.
+	GroupAdd []string
 }
 
 // Defines the actual configuration struct for the environment with all of the settings
@@ -77,6 +108,93 @@ func (c *Configuration) Labels() map[string]string {
 	return c.settings.Labels
 }
 
+// NetworkMode returns the network mode assigned to this instance, if any.
+func (c *Configuration) NetworkMode() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.NetworkMode
+}
+
+// StopGracePeriod returns the stop grace period in seconds assigned to this
+// instance, or 0 if the default should be used.
+func (c *Configuration) StopGracePeriod() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.StopGracePeriod
+}
+
+// Tmpfs returns the tmpfs mounts assigned to this instance.
+func (c *Configuration) Tmpfs() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.Tmpfs
+}
+
+// ReadonlyRootfs returns whether the root filesystem of this instance should be
+// read-only, or nil if the default should be used.
+func (c *Configuration) ReadonlyRootfs() *bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.ReadonlyRootfs
+}
+
+// Init returns whether an init process should be run as PID 1 in this instance,
+// or nil if the default should be used.
+func (c *Configuration) Init() *bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.Init
+}
+
+// ShmSize returns the size of /dev/shm for this instance, or an empty string if
+// the default should be used.
+func (c *Configuration) ShmSize() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.ShmSize
+}
+
+// Privileged returns whether this instance has requested a privileged container.
+func (c *Configuration) Privileged() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.Privileged
+}
+
+// NetworkRateLimit returns the egress and ingress bandwidth limits assigned to
+// this instance, either of which is empty if the default should be used.
+func (c *Configuration) NetworkRateLimit() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.NetworkEgress, c.settings.NetworkIngress
+}
+
+// IoLimits returns the disk IO limits assigned to this instance that override the
+// defaults from the configuration.
+func (c *Configuration) IoLimits() []config.DockerIoLimit {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.IoLimits
+}
+
+// GroupAdd returns the additional groups assigned to this instance, or nil if the
+// default should be used.
+func (c *Configuration) GroupAdd() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.GroupAdd
+}
+
 // Returns the environment variables associated with this instance.
 func (c *Configuration) EnvironmentVariables() []string {
 	c.mu.RLock()
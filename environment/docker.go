@@ -2,12 +2,20 @@ package environment
 
 import (
 	"context"
+	"net"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
+	"github.com/cenkalti/backoff/v4"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 
@@ -17,6 +25,14 @@ import (
 var (
 	_conce  sync.Once
 	_client *client.Client
+
+	// initUnsupported is set when the Docker daemon does not have an init binary
+	// that can be used as PID 1 in containers.
+	initUnsupported atomic.Bool
+
+	// usernsRemapped is set when the Docker daemon has user namespace remapping
+	// enabled and it is not disabled for server containers.
+	usernsRemapped atomic.Bool
 )
 
 // Docker returns a docker client to be used throughout the codebase. Once a
@@ -25,11 +41,158 @@ var (
 func Docker() (*client.Client, error) {
 	var err error
 	_conce.Do(func() {
-		_client, err = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+		opts = append(opts, hostOpts(config.Get().Docker)...)
+		_client, err = client.NewClientWithOpts(opts...)
 	})
 	return _client, errors.Wrap(err, "environment/docker: could not create client")
 }
 
+// hostOpts returns the client options required to connect to the Docker host
+// defined in the configuration. If no host is defined no options are returned
+// and the client falls back to the environment or the default socket.
+func hostOpts(cfg config.DockerConfiguration) []client.Opt {
+	if cfg.Host == "" {
+		return nil
+	}
+	u, err := url.Parse(cfg.Host)
+	if err != nil {
+		return []client.Opt{client.WithHost(cfg.Host)}
+	}
+	switch u.Scheme {
+	case "ssh":
+		// Docker does not support SSH connections natively, instead we tunnel the HTTP
+		// connection through "docker system dial-stdio" running on the remote host. The
+		// host used for the requests is a placeholder and is never resolved.
+		return []client.Opt{
+			client.WithHost("http://docker.example.com"),
+			client.WithDialContext(func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialSSH(ctx, u)
+			}),
+		}
+	case "tcp":
+		opts := []client.Opt{client.WithHost(cfg.Host)}
+		if cfg.TLS.CAFile != "" || cfg.TLS.CertFile != "" {
+			opts = append(opts, client.WithTLSClientConfig(cfg.TLS.CAFile, cfg.TLS.CertFile, cfg.TLS.KeyFile))
+		}
+		return opts
+	default:
+		return []client.Opt{client.WithHost(cfg.Host)}
+	}
+}
+
+// WaitForDocker attempts to connect to the Docker daemon, retrying with an
+// exponential backoff up to the configured number of times if the daemon cannot
+// be reached. This prevents Wings from failing to boot when the Docker daemon is
+// started slightly after it.
+func WaitForDocker(ctx context.Context) error {
+	cli, err := Docker()
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get().Docker
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = time.Duration(cfg.ConnectRetryDelay) * time.Second
+	b.Multiplier = 2
+	b.RandomizationFactor = 0
+	b.MaxElapsedTime = 0
+
+	var attempt int
+	err = backoff.RetryNotify(func() error {
+		_, err := cli.Ping(ctx)
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(b, uint64(cfg.ConnectRetries)), ctx), func(err error, d time.Duration) {
+		attempt++
+		log.WithFields(log.Fields{"attempt": attempt, "max_attempts": cfg.ConnectRetries, "retry_in": d, "error": err}).
+			Warn("failed to connect to docker daemon, retrying...")
+	})
+	return errors.Wrap(err, "environment/docker: could not connect to docker daemon")
+}
+
+var reconnect struct {
+	mu      sync.Mutex
+	pending *reconnectAttempt
+}
+
+// reconnectAttempt is a single attempt to reconnect to the Docker daemon that
+// is shared between all the callers waiting on it.
+type reconnectAttempt struct {
+	done chan struct{}
+	err  error
+}
+
+// AwaitDockerReconnect waits for the Docker daemon to become reachable again
+// after the connection to it has been lost, retrying with an exponential backoff
+// up to the configured maximum wait. Concurrent callers share a single attempt,
+// so the loss and recovery of the daemon are only logged once no matter how many
+// servers were affected.
+func AwaitDockerReconnect(ctx context.Context) error {
+	reconnect.mu.Lock()
+	a := reconnect.pending
+	if a == nil {
+		a = &reconnectAttempt{done: make(chan struct{})}
+		reconnect.pending = a
+		go func() {
+			a.err = waitForReconnect(ctx)
+			reconnect.mu.Lock()
+			reconnect.pending = nil
+			reconnect.mu.Unlock()
+			close(a.done)
+		}()
+	}
+	reconnect.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.done:
+		return a.err
+	}
+}
+
+func waitForReconnect(ctx context.Context) error {
+	cli, err := Docker()
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get().Docker.Reconnect
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = time.Duration(cfg.InitialInterval) * time.Second
+	b.MaxInterval = time.Duration(cfg.MaxInterval) * time.Second
+	b.MaxElapsedTime = time.Duration(cfg.MaxWait) * time.Second
+	b.Multiplier = 2
+
+	start := time.Now()
+	log.WithField("max_wait", b.MaxElapsedTime).Warn("lost connection to docker daemon, waiting for it to return before updating server states...")
+	err = backoff.RetryNotify(func() error {
+		_, err := cli.Ping(ctx)
+		return err
+	}, backoff.WithContext(b, ctx), func(err error, d time.Duration) {
+		log.WithFields(log.Fields{"retry_in": d, "error": err}).Debug("docker daemon is still unavailable, retrying...")
+	})
+	if err != nil {
+		log.WithField("error", err).Error("docker daemon did not return in time, affected servers will be marked as offline")
+		return errors.Wrap(err, "environment/docker: could not reconnect to docker daemon")
+	}
+	log.WithField("duration", time.Since(start).Round(time.Second)).Info("reconnected to docker daemon")
+	return nil
+}
+
+// DockerInitSupported returns whether the Docker daemon is able to run an init
+// process as PID 1 in containers. This is assumed to be the case until the daemon
+// has been checked by ConfigureDocker.
+func DockerInitSupported() bool {
+	return !initUnsupported.Load()
+}
+
+// UsernsRemapped returns whether server containers are run with user namespace
+// remapping by the Docker daemon, as detected by ConfigureDocker.
+func UsernsRemapped() bool {
+	return usernsRemapped.Load()
+}
+
 // ConfigureDocker configures the required network for the docker environment.
 func ConfigureDocker(ctx context.Context) error {
 	// Ensure the required docker network exists on the system.
@@ -51,6 +214,49 @@ func ConfigureDocker(ctx context.Context) error {
 		}
 	}
 
+	// Warn when the daemon is remapping user namespaces, since the ownership of server
+	// files will not match the user inside containers unless the system user has the
+	// remapped IDs.
+	info, err := cli.Info(ctx)
+	if err != nil {
+		// Without the daemon information the host cannot be checked, which must not
+		// let Wings boot when it is configured to refuse unsupported hosts.
+		if sys := config.Get().System; sys.StorageDriverAction == config.StorageDriverActionRefuse || sys.KernelFeatureAction == config.KernelFeatureActionRefuse {
+			return errors.WrapIf(err, "environment/docker: failed to retrieve docker daemon information to check the storage driver and kernel features")
+		}
+		log.WithField("error", err).Warn("failed to retrieve docker daemon information, skipping the storage driver and kernel feature checks")
+	} else {
+		// Some storage drivers perform poorly or do not support the disk quotas used
+		// for servers, so check the driver against the configured allowlist.
+		if sys := config.Get().System; !sys.StorageDriverAllowed(info.Driver) {
+			if sys.StorageDriverAction == config.StorageDriverActionRefuse {
+				return errors.Errorf("environment/docker: docker is using the \"%s\" storage driver which is not allowed by system.allowed_storage_drivers", info.Driver)
+			}
+			log.WithField("driver", info.Driver).Warn("docker is using a storage driver that is not recommended, servers may perform poorly or exceed their disk limits")
+		}
+
+		if err := checkKernelFeatures(info); err != nil {
+			return err
+		}
+
+		if config.Get().Docker.UsernsMode == "" {
+			for _, opt := range info.SecurityOptions {
+				if strings.Contains(opt, "name=userns") {
+					usernsRemapped.Store(true)
+					log.Warn("docker daemon has user namespace remapping enabled, server files will only be writable if the system user has the remapped IDs")
+					break
+				}
+			}
+		}
+
+		// The daemon reports the version of its init binary when it is able to run it,
+		// without one containers cannot be created with an init process.
+		initUnsupported.Store(info.InitCommit.ID == "" || info.InitCommit.ID == "N/A")
+		if initUnsupported.Load() && config.Get().Docker.Init {
+			log.WithField("init_binary", info.InitBinary).Warn("docker.init is enabled but the docker daemon does not have an init binary available, containers will be created without an init process")
+		}
+	}
+
 	config.Update(func(c *config.Configuration) {
 		c.Docker.Network.Driver = resource.Driver
 		switch c.Docker.Network.Driver {
@@ -105,3 +311,145 @@ func createDockerNetwork(ctx context.Context, cli *client.Client) error {
 	}
 	return nil
 }
+
+// PruneImages removes images from the system that are not used by any container,
+// were created more than minAge ago, and are not referenced by any of the images
+// in keep. Egg images do not carry any label identifying them, so only images that
+// Wings pulled itself are considered, which leaves the images of other Docker users
+// untouched. Images pulled before the pulled images file existed are never pruned.
+// The number of bytes reclaimed is returned.
+func PruneImages(ctx context.Context, minAge time.Duration, keep []string) (int64, error) {
+	cli, err := Docker()
+	if err != nil {
+		return 0, err
+	}
+
+	images, err := cli.ImageList(ctx, types.ImageListOptions{ContainerCount: true})
+	if err != nil {
+		return 0, errors.Wrap(err, "environment/docker: failed to list images")
+	}
+
+	pulledImagesMu.Lock()
+	defer pulledImagesMu.Unlock()
+	pulled, err := readPulledImages()
+	if err != nil {
+		return 0, err
+	}
+
+	keepers := make(map[string]struct{}, len(keep))
+	for _, k := range keep {
+		keepers[normalizeImageReference(k)] = struct{}{}
+	}
+
+	var reclaimed int64
+	cutoff := time.Now().Add(-minAge)
+	for _, img := range images {
+		// A negative count means the daemon did not count the containers using the
+		// image, in which case it is never removed.
+		if _, ok := pulled[img.ID]; !ok {
+			continue
+		}
+		if img.Containers != 0 || time.Unix(img.Created, 0).After(cutoff) {
+			continue
+		}
+		var referenced bool
+		for _, t := range img.RepoTags {
+			if _, ok := keepers[t]; ok {
+				referenced = true
+				break
+			}
+		}
+		if referenced {
+			continue
+		}
+
+		if _, err := cli.ImageRemove(ctx, img.ID, types.ImageRemoveOptions{PruneChildren: true}); err != nil {
+			log.WithField("image", img.ID).WithField("error", err).Warn("failed to prune unused docker image")
+			continue
+		}
+		log.WithField("image", img.ID).WithField("tags", img.RepoTags).Debug("pruned unused docker image")
+		reclaimed += img.Size
+		delete(pulled, img.ID)
+	}
+
+	// Forget any images that no longer exist, including those removed above.
+	present := make(map[string]struct{}, len(images))
+	for _, img := range images {
+		present[img.ID] = struct{}{}
+	}
+	for id := range pulled {
+		if _, ok := present[id]; !ok {
+			delete(pulled, id)
+		}
+	}
+	if err := writePulledImages(pulled); err != nil {
+		log.WithField("error", err).Warn("failed to update pulled docker images file")
+	}
+
+	return reclaimed, nil
+}
+
+// ServerContainers returns all the server process containers on the system that
+// were created by Wings, including those that are not running.
+func ServerContainers(ctx context.Context) ([]types.Container, error) {
+	cli, err := Docker()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "Service=Pterodactyl"), filters.Arg("label", "ContainerType=server_process")),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "environment/docker: failed to list server containers")
+	}
+	return containers, nil
+}
+
+// InstallerContainers returns all the installation containers on the system that
+// were created by Wings, including those that are not running.
+func InstallerContainers(ctx context.Context) ([]types.Container, error) {
+	cli, err := Docker()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "Service=Pterodactyl"), filters.Arg("label", "ContainerType=server_installer")),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "environment/docker: failed to list installer containers")
+	}
+	return containers, nil
+}
+
+// RemoveOrphanedContainer stops the container provided, allowing it the default
+// stop grace period, and then removes it if remove is true.
+func RemoveOrphanedContainer(ctx context.Context, id string, remove bool) error {
+	cli, err := Docker()
+	if err != nil {
+		return err
+	}
+	timeout := config.Get().Docker.ContainerStopTimeout(0)
+	if err := cli.ContainerStop(ctx, id, container.StopOptions{Timeout: &timeout}); err != nil && !client.IsErrNotFound(err) {
+		return errors.Wrap(err, "environment/docker: failed to stop orphaned container")
+	}
+	if !remove {
+		return nil
+	}
+	if err := cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{RemoveVolumes: true}); err != nil && !client.IsErrNotFound(err) {
+		return errors.Wrap(err, "environment/docker: failed to remove orphaned container")
+	}
+	return nil
+}
+
+// normalizeImageReference returns the image reference in the same format that
+// Docker reports repository tags in, stripping the local image prefix used by
+// Wings and appending the implicit "latest" tag if no tag is present.
+func normalizeImageReference(image string) string {
+	image = strings.TrimPrefix(image, "~")
+	if i := strings.LastIndex(image, ":"); i == -1 || i < strings.LastIndex(image, "/") {
+		image += ":latest"
+	}
+	return image
+}
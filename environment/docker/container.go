@@ -11,14 +11,15 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
-	"github.com/buger/jsonparser"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
+	"golang.org/x/sys/unix"
 
 	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/internal/metrics"
 	"github.com/pterodactyl/wings/system"
 )
 
@@ -70,9 +71,12 @@ func (e *Environment) Attach(ctx context.Context) error {
 		pollCtx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 		defer e.stream.Close()
+		var reattached bool
 		defer func() {
-			e.SetState(environment.ProcessOfflineState)
-			e.SetStream(nil)
+			if !reattached {
+				e.SetState(environment.ProcessOfflineState)
+				e.SetStream(nil)
+			}
 		}()
 
 		go func() {
@@ -91,13 +95,55 @@ func (e *Environment) Attach(ctx context.Context) error {
 			e.logCallback(v)
 		}); err != nil && err != io.EOF {
 			log.WithField("error", err).WithField("container_id", e.Id).Warn("error processing scanner line in console output")
-			return
 		}
+
+		// The stream may have ended because the Docker daemon went away rather than
+		// because the container stopped, in which case the container may still be
+		// running once the daemon returns.
+		reattached = e.reattachAfterDaemonLoss()
 	}()
 
 	return nil
 }
 
+// reattachAfterDaemonLoss checks if the console stream for the container ended
+// because the connection to the Docker daemon was lost. If so, it waits for the
+// daemon to return and re-attaches to the container if it is still running,
+// returning true. If the daemon is reachable, or the container is no longer
+// running, false is returned and the server should be considered offline.
+func (e *Environment) reattachAfterDaemonLoss() bool {
+	if !config.Get().Docker.Reconnect.Enabled {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	_, err := e.client.Ping(ctx)
+	cancel()
+	if err == nil {
+		return false
+	}
+
+	e.log().WithField("error", err).Warn("console stream ended after losing connection to docker daemon, waiting to confirm container state...")
+	if err := environment.AwaitDockerReconnect(context.Background()); err != nil {
+		return false
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	c, err := e.ContainerInspect(ctx)
+	if err != nil || !c.State.Running {
+		e.log().Info("container is no longer running after docker daemon reconnect")
+		return false
+	}
+
+	e.SetStream(nil)
+	if err := e.Attach(ctx); err != nil {
+		e.log().WithField("error", err).Error("failed to re-attach to container after docker daemon reconnect")
+		return false
+	}
+	e.log().Info("re-attached to running container after docker daemon reconnect")
+	return true
+}
+
 // InSituUpdate performs an in-place update of the Docker container's resource
 // limits without actually making any changes to the operational state of the
 // container. This allows memory, cpu, and IO limitations to be adjusted on the
@@ -135,6 +181,7 @@ func (e *Environment) InSituUpdate() error {
 // currently available for it. If the container already exists it will be
 // returned.
 func (e *Environment) Create() error {
+	defer metrics.ObserveDockerOperation("create", time.Now())
 	ctx := context.Background()
 
 	// If the container already exists don't hit the user with an error, just return
@@ -153,6 +200,17 @@ func (e *Environment) Create() error {
 
 	cfg := config.Get()
 	a := e.Configuration.Allocations()
+	for _, ports := range a.Mappings {
+		for _, port := range ports {
+			// Invalid ports are skipped when creating the port bindings, so ignore them here.
+			if port < 1 || port > 65535 {
+				continue
+			}
+			if !cfg.System.PortRange.IsPortAllowed(port) {
+				return errors.Errorf("environment/docker: port %d assigned to server is outside of the allowed range %d-%d", port, cfg.System.PortRange.Start, cfg.System.PortRange.End)
+			}
+		}
+	}
 	evs := e.Configuration.EnvironmentVariables()
 	for i, v := range evs {
 		// Convert 127.0.0.1 to the pterodactyl0 network interface if the environment is Docker
@@ -172,8 +230,19 @@ func (e *Environment) Create() error {
 	labels["Service"] = "Pterodactyl"
 	labels["ContainerType"] = "server_process"
 
+	hostname, err := cfg.Docker.ContainerHostname(e.Id)
+	if err != nil {
+		return err
+	}
+
+	// Only override the stop timeout of the container when a grace period has been
+	// configured, otherwise the Docker default is used.
+	var stopTimeout *int
+	if t := cfg.Docker.ContainerStopTimeout(e.Configuration.StopGracePeriod()); t >= 0 {
+		stopTimeout = &t
+	}
 	conf := &container.Config{
-		Hostname:     e.Id,
+		Hostname:     hostname,
 		Domainname:   cfg.Docker.Domainname,
 		AttachStdin:  true,
 		AttachStdout: true,
@@ -184,6 +253,7 @@ func (e *Environment) Create() error {
 		Image:        strings.TrimPrefix(e.meta.Image, "~"),
 		Env:          e.Configuration.EnvironmentVariables(),
 		Labels:       labels,
+		StopTimeout:  stopTimeout,
 	}
 
 	// Set the user running the container properly depending on what mode we are operating in.
@@ -193,10 +263,21 @@ func (e *Environment) Create() error {
 		conf.User = strconv.Itoa(cfg.System.User.Uid) + ":" + strconv.Itoa(cfg.System.User.Gid)
 	}
 
-	networkMode := container.NetworkMode(cfg.Docker.Network.Mode)
+	networkMode := container.NetworkMode(cfg.Docker.ContainerNetworkMode())
+	if mode := e.Configuration.NetworkMode(); mode != "" {
+		if err := config.ValidateNetworkMode(mode, cfg.System.AllowHostNetwork); err != nil {
+			return errors.WrapIf(err, "environment/docker: invalid network mode assigned to server")
+		}
+		networkMode = container.NetworkMode(mode)
+	}
 	if a.ForceOutgoingIP {
 		e.log().Debug("environment/docker: forcing outgoing IP address")
-		networkName := "ip-" + strings.ReplaceAll(strings.ReplaceAll(a.DefaultMapping.Ip, ".", "-"), ":", "-")
+		ip, err := environment.ResolveOutgoingIPv4(a.DefaultMapping.Ip)
+		if err != nil {
+			return err
+		}
+		outgoingIp := ip.String()
+		networkName := "ip-" + strings.ReplaceAll(outgoingIp, ".", "-")
 		networkMode = container.NetworkMode(networkName)
 
 		if _, err := e.client.NetworkInspect(ctx, networkName, types.NetworkInspectOptions{}); err != nil {
@@ -214,7 +295,7 @@ func (e *Environment) Create() error {
 				Options: map[string]string{
 					"encryption": "false",
 					"com.docker.network.bridge.default_bridge": "false",
-					"com.docker.network.host_ipv4":             a.DefaultMapping.Ip,
+					"com.docker.network.host_ipv4":             outgoingIp,
 				},
 			}); err != nil {
 				return err
@@ -222,18 +303,74 @@ func (e *Environment) Create() error {
 		}
 	}
 
+	tmpfs := cfg.Docker.ContainerTmpfs(e.Configuration.Tmpfs())
+	tmpfsSize, err := config.ValidateTmpfs(tmpfs)
+	if err != nil {
+		return errors.WrapIf(err, "environment/docker: invalid tmpfs mounts assigned to server")
+	}
+	var si unix.Sysinfo_t
+	if err := unix.Sysinfo(&si); err == nil {
+		if mem := int64(si.Totalram) * int64(si.Unit); tmpfsSize > mem {
+			return errors.Errorf("environment/docker: combined size of tmpfs mounts (%d bytes) exceeds host memory (%d bytes)", tmpfsSize, mem)
+		}
+	}
+
+	readonly := cfg.Docker.ReadonlyRootfs
+	if v := e.Configuration.ReadonlyRootfs(); v != nil {
+		readonly = *v
+	}
+	shmSize, err := cfg.Docker.ShmSizeBytes(e.Configuration.ShmSize())
+	if err != nil {
+		return errors.WrapIf(err, "environment/docker: invalid shm size assigned to server")
+	}
+	if _, _, err := cfg.Docker.NetworkRates(e.Configuration.NetworkRateLimit()); err != nil {
+		return errors.WrapIf(err, "environment/docker: invalid network rate limit assigned to server")
+	}
+	ioLimits, err := cfg.Docker.IoThrottle(e.Configuration.IoLimits())
+	if err != nil {
+		return errors.WrapIf(err, "environment/docker: invalid io limits assigned to server")
+	}
+	groupAdd, err := cfg.Docker.ContainerGroupAdd(e.Configuration.GroupAdd())
+	if err != nil {
+		return errors.WrapIf(err, "environment/docker: invalid additional groups assigned to server")
+	}
+	if err := unix.Sysinfo(&si); err == nil {
+		if mem := int64(si.Totalram) * int64(si.Unit); shmSize > mem/2 {
+			e.log().WithField("shm_size", shmSize).Warn("shm size of container is more than half of the memory on this system")
+		}
+	}
+
+	// Init is left unset unless it was configured so that the daemon's default-init
+	// setting is respected.
+	var useInit *bool
+	if cfg.Docker.Init {
+		v := true
+		useInit = &v
+	}
+	if v := e.Configuration.Init(); v != nil {
+		useInit = v
+	}
+	if useInit != nil && *useInit && !environment.DockerInitSupported() {
+		e.log().Warn("docker daemon does not have an init binary available, creating container without an init process")
+		useInit = nil
+	}
+	mounts := e.convertMounts()
+	if readonly {
+		if err := validateWritablePaths(mounts, tmpfs); err != nil {
+			return err
+		}
+	}
+
 	hostConf := &container.HostConfig{
 		PortBindings: a.DockerBindings(),
 
 		// Configure the mounts for this container. First mount the server data directory
 		// into the container as an r/w bind.
-		Mounts: e.convertMounts(),
+		Mounts: mounts,
 
 		// Configure the /tmp folder mapping in containers. This is necessary for some
 		// games that need to make use of it for downloads and other installation processes.
-		Tmpfs: map[string]string{
-			"/tmp": "rw,exec,nosuid,size=" + strconv.Itoa(int(cfg.Docker.TmpfsSize)) + "M",
-		},
+		Tmpfs: tmpfs,
 
 		// Define resource limits for the container based on the data passed through
 		// from the Panel.
@@ -248,13 +385,45 @@ func (e *Environment) Create() error {
 		LogConfig: cfg.Docker.ContainerLogConfig(),
 
 		SecurityOpt:    []string{"no-new-privileges"},
-		ReadonlyRootfs: true,
+		ReadonlyRootfs: readonly,
+		Init:           useInit,
+		ShmSize:        shmSize,
 		CapDrop: []string{
 			"setpcap", "mknod", "audit_write", "net_raw", "dac_override",
 			"fowner", "fsetid", "net_bind_service", "sys_chroot", "setfcap",
 		},
 		NetworkMode: networkMode,
 		UsernsMode:  container.UsernsMode(cfg.Docker.UsernsMode),
+		GroupAdd:    groupAdd,
+	}
+
+	// Docker refuses to publish ports, or to set the hostname or DNS servers, of a
+	// container without a network stack of its own, so those are left to the host or
+	// to the container whose network stack is shared.
+	if networkMode.IsHost() || networkMode.IsNone() || networkMode.IsContainer() {
+		e.log().WithField("network_mode", networkMode).Debug("not publishing ports or setting hostname and dns for container without its own network stack")
+		conf.Hostname = ""
+		conf.Domainname = ""
+		conf.ExposedPorts = nil
+		hostConf.PortBindings = nil
+		hostConf.DNS = nil
+	}
+
+	hostConf.BlkioDeviceReadBps = ioLimits.ReadBps
+	hostConf.BlkioDeviceWriteBps = ioLimits.WriteBps
+	hostConf.BlkioDeviceReadIOps = ioLimits.ReadIops
+	hostConf.BlkioDeviceWriteIOps = ioLimits.WriteIops
+
+	if cfg.System.AllowDeviceAccess {
+		hostConf.Devices, hostConf.DeviceRequests = cfg.Docker.ContainerDevices()
+	}
+
+	if e.Configuration.Privileged() {
+		if !cfg.System.AllowPrivilegedContainers {
+			return errors.New("environment/docker: server requests a privileged container but privileged containers are not allowed")
+		}
+		e.log().Warn("creating privileged container for server, this gives it full access to the host system")
+		hostConf.Privileged = true
 	}
 
 	if _, err := e.client.ContainerCreate(ctx, conf, hostConf, nil, nil, e.Id); err != nil {
@@ -267,6 +436,7 @@ func (e *Environment) Create() error {
 // Destroy will remove the Docker container from the server. If the container
 // is currently running it will be forcibly stopped by Docker.
 func (e *Environment) Destroy() error {
+	defer metrics.ObserveDockerOperation("destroy", time.Now())
 	// We set it to stopping than offline to prevent crash detection from being triggered.
 	e.SetState(environment.ProcessStoppingState)
 
@@ -382,7 +552,9 @@ func (e *Environment) ensureImageExists(image string) error {
 		imagePullOptions.RegistryAuth = b64
 	}
 
-	out, err := e.client.ImagePull(ctx, image, imagePullOptions)
+	err := environment.PullImage(ctx, e.client, image, imagePullOptions, func(status string) {
+		e.Events().Publish(environment.DockerImagePullStatus, status)
+	})
 	if err != nil {
 		images, ierr := e.client.ImageList(ctx, types.ImageListOptions{})
 		if ierr != nil {
@@ -411,28 +583,35 @@ func (e *Environment) ensureImageExists(image string) error {
 
 		return errors.Wrapf(err, "environment/docker: failed to pull \"%s\" image for server", image)
 	}
-	defer out.Close()
-
-	log.WithField("image", image).Debug("pulling docker image... this could take a bit of time")
 
-	// I'm not sure what the best approach here is, but this will block execution until the image
-	// is done being pulled, which is what we need.
-	scanner := bufio.NewScanner(out)
-
-	for scanner.Scan() {
-		b := scanner.Bytes()
-		status, _ := jsonparser.GetString(b, "status")
-		progress, _ := jsonparser.GetString(b, "progress")
+	return nil
+}
 
-		e.Events().Publish(environment.DockerImagePullStatus, status+" "+progress)
+// validateWritablePaths checks that the paths a server needs to be able to write
+// to are writable when the root filesystem of its container is read-only, which
+// are the server data directory and /tmp.
+func validateWritablePaths(mounts []mount.Mount, tmpfs map[string]string) error {
+	var data bool
+	for _, m := range mounts {
+		if m.Target == "/home/container" {
+			if m.ReadOnly {
+				return errors.New("environment/docker: server data mount must be writable when the root filesystem is read-only")
+			}
+			data = true
+		}
 	}
-
-	if err := scanner.Err(); err != nil {
-		return err
+	if !data {
+		return errors.New("environment/docker: server data mount is missing for container with a read-only root filesystem")
+	}
+	opts, ok := tmpfs["/tmp"]
+	if !ok {
+		return errors.New("environment/docker: /tmp must be a tmpfs mount when the root filesystem is read-only")
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "ro" {
+			return errors.New("environment/docker: /tmp tmpfs mount must be writable when the root filesystem is read-only")
+		}
 	}
-
-	log.WithField("image", image).Debug("completed docker image pull")
-
 	return nil
 }
 
@@ -440,12 +619,16 @@ func (e *Environment) convertMounts() []mount.Mount {
 	var out []mount.Mount
 
 	for _, m := range e.Configuration.Mounts() {
-		out = append(out, mount.Mount{
+		bind := mount.Mount{
 			Type:     mount.TypeBind,
 			Source:   m.Source,
 			Target:   m.Target,
 			ReadOnly: m.ReadOnly,
-		})
+		}
+		if m.Propagation != "" {
+			bind.BindOptions = &mount.BindOptions{Propagation: mount.Propagation(m.Propagation)}
+		}
+		out = append(out, bind)
 	}
 
 	return out
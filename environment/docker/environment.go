@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 
 	"emperror.dev/errors"
@@ -189,6 +190,19 @@ func (e *Environment) SetImage(i string) {
 	e.meta.Image = i
 }
 
+// ImageChanged checks if the image configured for the environment differs from
+// the image that the existing container was created with.
+func (e *Environment) ImageChanged(ctx context.Context) (bool, error) {
+	c, err := e.ContainerInspect(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return c.Config.Image != strings.TrimPrefix(e.meta.Image, "~"), nil
+}
+
 func (e *Environment) State() string {
 	return e.st.Load()
 }
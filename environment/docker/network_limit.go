@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// applyNetworkRateLimit applies the bandwidth limits assigned to the container
+// using tc within the network namespace of the running container. Egress traffic
+// is shaped using a token bucket filter, and ingress traffic exceeding the limit
+// is dropped by a policer. Nothing is done if the container is unlimited or does
+// not have a network stack of its own.
+func (e *Environment) applyNetworkRateLimit(ctx context.Context) error {
+	egress, ingress, err := config.Get().Docker.NetworkRates(e.Configuration.NetworkRateLimit())
+	if err != nil {
+		return err
+	}
+	if egress == 0 && ingress == 0 {
+		return nil
+	}
+
+	c, err := e.ContainerInspect(ctx)
+	if err != nil {
+		return errors.WrapIf(err, "environment/docker: failed to inspect container")
+	}
+	if nm := c.HostConfig.NetworkMode; nm.IsHost() || nm.IsNone() || nm.IsContainer() {
+		e.log().WithField("network_mode", nm).Warn("not applying network rate limit to container without its own network stack")
+		return nil
+	}
+	if c.State == nil || c.State.Pid == 0 {
+		return errors.New("environment/docker: container is not running")
+	}
+	pid := strconv.Itoa(c.State.Pid)
+
+	var cmds [][]string
+	if egress > 0 {
+		cmds = append(cmds, []string{"qdisc", "add", "dev", "eth0", "root", "tbf", "rate", rateArg(egress), "burst", burstArg(egress), "latency", "50ms"})
+	}
+	if ingress > 0 {
+		cmds = append(cmds,
+			[]string{"qdisc", "add", "dev", "eth0", "handle", "ffff:", "ingress"},
+			[]string{"filter", "add", "dev", "eth0", "parent", "ffff:", "protocol", "all", "prio", "1", "u32", "match", "u32", "0", "0", "police", "rate", rateArg(ingress), "burst", burstArg(ingress), "drop", "flowid", ":1"},
+		)
+	}
+	for _, args := range cmds {
+		out, err := exec.CommandContext(ctx, "nsenter", append([]string{"-t", pid, "-n", "tc"}, args...)...).CombinedOutput()
+		if err != nil {
+			return errors.Wrapf(err, "environment/docker: failed to run tc %s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+		}
+	}
+	e.log().WithField("egress_bps", egress).WithField("ingress_bps", ingress).Debug("applied network rate limit to container")
+	return nil
+}
+
+// rateArg returns the tc argument for a rate in bits per second.
+func rateArg(bps uint64) string {
+	return strconv.FormatUint(bps, 10) + "bit"
+}
+
+// burstArg returns the tc burst argument for a rate in bits per second, which
+// allows for 100ms of traffic at the full rate, with a minimum of 32KiB so that
+// the limit is still usable at low rates.
+func burstArg(bps uint64) string {
+	burst := bps / 8 / 10
+	if burst < 32*1024 {
+		burst = 32 * 1024
+	}
+	return strconv.FormatUint(burst, 10) + "b"
+}
@@ -0,0 +1,27 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func TestNetworkRateArgs(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("rateArg", func() {
+		g.It("formats the rate in bits", func() {
+			g.Assert(rateArg(100_000_000)).Equal("100000000bit")
+		})
+	})
+
+	g.Describe("burstArg", func() {
+		g.It("allows 100ms of traffic at the full rate", func() {
+			g.Assert(burstArg(100_000_000)).Equal("1250000b")
+		})
+
+		g.It("never returns less than 32KiB", func() {
+			g.Assert(burstArg(500_000)).Equal("32768b")
+		})
+	})
+}
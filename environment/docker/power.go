@@ -12,7 +12,9 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/internal/metrics"
 	"github.com/pterodactyl/wings/remote"
 )
 
@@ -50,6 +52,7 @@ func (e *Environment) OnBeforeStart(ctx context.Context) error {
 // listeners for the console. If a container does not exist, or needs to be
 // rebuilt that will happen in the call to OnBeforeStart().
 func (e *Environment) Start(ctx context.Context) error {
+	defer metrics.ObserveDockerOperation("start", time.Now())
 	sawError := false
 
 	// If sawError is set to true there was an error somewhere in the pipeline that
@@ -125,6 +128,19 @@ func (e *Environment) Start(ctx context.Context) error {
 		return errors.WrapIf(err, "environment/docker: failed to start container")
 	}
 
+	if err := e.applyNetworkRateLimit(actx); err != nil {
+		if !config.Get().Docker.NetworkRateLimit.FailOpen {
+			// The server must not run without its limits, so the container is killed
+			// again before the start is reported as failed.
+			e.SetState(environment.ProcessStoppingState)
+			if kerr := e.SignalContainer(context.Background(), "SIGKILL"); kerr != nil {
+				e.log().WithField("error", kerr).Error("failed to kill container after failing to apply network rate limit")
+			}
+			return errors.WrapIf(err, "environment/docker: failed to apply network rate limit to container, check that tc and nsenter are installed and the required kernel modules are available")
+		}
+		e.log().WithField("error", err).Error("failed to apply network rate limit to container, continuing without it since docker.network_rate_limit.fail_open is enabled")
+	}
+
 	// No errors, good to continue through.
 	sawError = false
 	return nil
@@ -138,6 +154,7 @@ func (e *Environment) Start(ctx context.Context) error {
 // since this will return as soon as the command is sent, rather than waiting
 // for the process to be completed stopped.
 func (e *Environment) Stop(ctx context.Context) error {
+	defer metrics.ObserveDockerOperation("stop", time.Now())
 	e.mu.RLock()
 	s := e.meta.Stop
 	e.mu.RUnlock()
@@ -186,10 +203,10 @@ func (e *Environment) Stop(ctx context.Context) error {
 	// attempt to stop the container using the default stop signal, SIGTERM, unless
 	// another signal was specified in the Dockerfile
 	//
-	// Using a negative timeout here will allow the container to stop gracefully,
-	// rather than forcefully terminating it.  Value is in seconds, but -1 is
-	// treated as indefinitely.
-	timeout := -1
+	// The container is given the configured stop grace period to stop gracefully before
+	// Docker forcefully terminates it. Value is in seconds, and -1 waits for the container
+	// to stop if no grace period has been configured.
+	timeout := config.Get().Docker.ContainerStopTimeout(e.Configuration.StopGracePeriod())
 	if err := e.client.ContainerStop(ctx, e.Id, container.StopOptions{Timeout: &timeout}); err != nil {
 		// If the container does not exist just mark the process as stopped and return without
 		// an error.
@@ -204,74 +221,115 @@ func (e *Environment) Stop(ctx context.Context) error {
 	return nil
 }
 
-// WaitForStop attempts to gracefully stop a server using the defined stop
-// command. If the server does not stop after seconds have passed, an error will
-// be returned, or the instance will be terminated forcefully depending on the
-// value of the second argument.
-//
-// Calls to Environment.Terminate() in this function use the context passed
-// through since we don't want to prevent termination of the server instance
-// just because the context.WithTimeout() has expired.
+// WaitForStop attempts to gracefully stop a server using the configured stop
+// chain. If terminate is false only the first stage of the chain is used, and an
+// error is returned if the server does not stop before the duration has passed.
+// Otherwise each stage is used in turn until the server stops, with every stage
+// sharing the same deadline, and the server is killed once the chain or the
+// duration is exhausted.
 func (e *Environment) WaitForStop(ctx context.Context, duration time.Duration, terminate bool) error {
-	tctx, cancel := context.WithTimeout(context.Background(), duration)
+	return e.runStopChain(ctx, config.Get().Docker.StopStages(), duration, terminate, e.runStopStage, func() error {
+		return e.Terminate(context.Background(), "SIGKILL")
+	})
+}
+
+// runStopChain runs the stages of a stop chain in order using run, until one of
+// them stops the server. All the stages share a single deadline of duration. When
+// terminate is true the server is killed using kill if it is still running once
+// the stages or the deadline are exhausted, even if the chain has no kill stage.
+func (e *Environment) runStopChain(ctx context.Context, stages []config.StopStage, duration time.Duration, terminate bool, run func(context.Context, config.StopStage) (bool, error), kill func() error) error {
+	// Without termination only the first stage is used, and an error is returned if
+	// the server does not stop in time.
+	if !terminate {
+		stages = stages[:1]
+	}
+
+	deadline := time.Now().Add(duration)
+	dctx, cancel := context.WithDeadline(ctx, deadline)
 	defer cancel()
 
-	// If the parent context is canceled, abort the timed context for termination.
-	go func() {
-		select {
-		case <-ctx.Done():
-			cancel()
-		case <-tctx.Done():
-			// When the timed context is canceled, terminate this routine since we no longer
-			// need to worry about the parent routine being canceled.
-			break
+	for i, stage := range stages {
+		if stage.Type == config.StopStageKill {
+			return kill()
+		}
+
+		timeout := time.Duration(stage.Timeout) * time.Second
+		if remaining := time.Until(deadline); timeout <= 0 || timeout > remaining {
+			timeout = remaining
+		}
+		// We pass through the timed context for the stop action so that if one of the
+		// internal docker calls fails to ever finish before we've exhausted the time limit
+		// the resources get cleaned up, and the execution is stopped.
+		sctx, scancel := context.WithTimeout(dctx, timeout)
+		stopped, err := run(sctx, stage)
+		scancel()
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			if !terminate {
+				return err
+			}
+			e.log().WithField("stage", stage.Type).WithField("error", err).Warn("error while stopping container using stop stage")
+		}
+		if stopped {
+			e.log().WithField("stage", stage.Type).WithField("signal", stage.Signal).Info("container stopped using stop stage")
+			return nil
 		}
-	}()
 
-	doTermination := func(s string) error {
-		e.log().WithField("step", s).WithField("duration", duration).Warn("container stop did not complete in time, terminating process...")
-		return e.Terminate(ctx, "SIGKILL")
+		// If the parent context is canceled there is no time left to move through the
+		// remaining stages, so kill the process straight away.
+		if ctx.Err() != nil {
+			if terminate {
+				e.log().WithField("stage", stage.Type).Warn("container stop was canceled, terminating process...")
+				return kill()
+			}
+			return ctx.Err()
+		}
+		if !terminate {
+			return errors.WrapIf(context.DeadlineExceeded, "environment/docker: error waiting on container to enter \"not-running\" state")
+		}
+		if dctx.Err() != nil {
+			break
+		}
+		if i < len(stages)-1 {
+			e.log().WithField("stage", stage.Type).WithField("duration", timeout).Warn("container stop did not complete in time, moving on to the next stop stage...")
+		}
 	}
 
-	// We pass through the timed context for this stop action so that if one of the
-	// internal docker calls fails to ever finish before we've exhausted the time limit
-	// the resources get cleaned up, and the exection is stopped.
-	if err := e.Stop(tctx); err != nil {
-		if terminate && errors.Is(err, context.DeadlineExceeded) {
-			return doTermination("stop")
+	e.log().WithField("duration", duration).Warn("container did not stop using the stop stages, terminating process...")
+	return kill()
+}
+
+// runStopStage attempts to stop the container using a single stage of the stop
+// chain, returning true if the container stopped before the context is done.
+func (e *Environment) runStopStage(ctx context.Context, stage config.StopStage) (bool, error) {
+	var err error
+	switch stage.Type {
+	case config.StopStageSignal:
+		signal := strings.ToUpper(stage.Signal)
+		if signal == "" {
+			signal = "SIGTERM"
 		}
-		return err
+		err = e.SignalContainer(ctx, signal)
+	default:
+		err = e.Stop(ctx)
+	}
+	if err != nil {
+		return false, err
 	}
 
-	// Block the return of this function until the container as been marked as no
-	// longer running. If this wait does not end by the time seconds have passed,
-	// attempt to terminate the container, or return an error.
-	ok, errChan := e.client.ContainerWait(tctx, e.Id, container.WaitConditionNotRunning)
+	// Block until the container has been marked as no longer running, or the
+	// timeout for this stage has passed.
+	ok, errChan := e.client.ContainerWait(ctx, e.Id, container.WaitConditionNotRunning)
 	select {
-	case <-ctx.Done():
-		if err := ctx.Err(); err != nil {
-			if terminate {
-				return doTermination("parent-context")
-			}
-			return err
-		}
 	case err := <-errChan:
-		// If the error stems from the container not existing there is no point in wasting
-		// CPU time to then try and terminate it.
+		// If the error stems from the container not existing there is no point in
+		// moving on to the next stage.
 		if err == nil || client.IsErrNotFound(err) {
-			return nil
+			return true, nil
 		}
-		if terminate {
-			if !errors.Is(err, context.DeadlineExceeded) {
-				e.log().WithField("error", err).Warn("error while waiting for container stop; terminating process")
-			}
-			return doTermination("wait")
-		}
-		return errors.WrapIf(err, "environment/docker: error waiting on container to enter \"not-running\" state")
+		return false, errors.WrapIf(err, "environment/docker: error waiting on container to enter \"not-running\" state")
 	case <-ok:
+		return true, nil
 	}
-
-	return nil
 }
 
 // Sends the specified signal to the container in an attempt to stop it.
@@ -311,6 +369,7 @@ func (e *Environment) SignalContainer(ctx context.Context, signal string) error
 // Terminate forcefully terminates the container using the signal provided.
 // then sets its state to stopped.
 func (e *Environment) Terminate(ctx context.Context, signal string) error {
+	defer metrics.ObserveDockerOperation("terminate", time.Now())
 
 	// Send the signal to the container to kill it
 	if err := e.SignalContainer(ctx, signal); err != nil {
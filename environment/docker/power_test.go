@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestRunStopChain(t *testing.T) {
+	g := goblin.Goblin(t)
+	e := &Environment{Id: "test"}
+
+	// never returns a stop stage that waits until its context is done without the
+	// server stopping, recording the stages that were run.
+	never := func(ran *[]string) func(context.Context, config.StopStage) (bool, error) {
+		return func(ctx context.Context, s config.StopStage) (bool, error) {
+			*ran = append(*ran, s.Type)
+			<-ctx.Done()
+			return false, ctx.Err()
+		}
+	}
+
+	g.Describe("runStopChain", func() {
+		g.It("stops at the first stage that stops the server", func() {
+			var ran []string
+			killed := false
+			stages := []config.StopStage{{Type: config.StopStageCommand, Timeout: 5}, {Type: config.StopStageKill}}
+			err := e.runStopChain(context.Background(), stages, time.Second, true, func(_ context.Context, s config.StopStage) (bool, error) {
+				ran = append(ran, s.Type)
+				return true, nil
+			}, func() error {
+				killed = true
+				return nil
+			})
+			g.Assert(err).IsNil()
+			g.Assert(ran).Equal([]string{config.StopStageCommand})
+			g.Assert(killed).IsFalse()
+		})
+
+		g.It("shares a single deadline between the stages", func() {
+			var ran []string
+			killed := false
+			stages := []config.StopStage{
+				{Type: config.StopStageCommand, Timeout: 600},
+				{Type: config.StopStageSignal, Timeout: 600},
+				{Type: config.StopStageKill},
+			}
+			start := time.Now()
+			err := e.runStopChain(context.Background(), stages, 50*time.Millisecond, true, never(&ran), func() error {
+				killed = true
+				return nil
+			})
+			g.Assert(err).IsNil()
+			g.Assert(killed).IsTrue()
+			g.Assert(ran).Equal([]string{config.StopStageCommand})
+			g.Assert(time.Since(start) < time.Second).IsTrue()
+		})
+
+		g.It("kills the server when terminating a chain without a kill stage", func() {
+			var ran []string
+			killed := false
+			stages := []config.StopStage{{Type: config.StopStageCommand, Timeout: 1}, {Type: config.StopStageSignal, Timeout: 1}}
+			err := e.runStopChain(context.Background(), stages, time.Minute, true, func(_ context.Context, s config.StopStage) (bool, error) {
+				ran = append(ran, s.Type)
+				return false, nil
+			}, func() error {
+				killed = true
+				return nil
+			})
+			g.Assert(err).IsNil()
+			g.Assert(ran).Equal([]string{config.StopStageCommand, config.StopStageSignal})
+			g.Assert(killed).IsTrue()
+		})
+
+		g.It("only uses the first stage and returns an error without terminating", func() {
+			var ran []string
+			killed := false
+			stages := []config.StopStage{{Type: config.StopStageCommand, Timeout: 600}, {Type: config.StopStageKill}}
+			err := e.runStopChain(context.Background(), stages, 20*time.Millisecond, false, never(&ran), func() error {
+				killed = true
+				return nil
+			})
+			g.Assert(err == nil).IsFalse()
+			g.Assert(ran).Equal([]string{config.StopStageCommand})
+			g.Assert(killed).IsFalse()
+		})
+	})
+}
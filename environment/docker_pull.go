@@ -0,0 +1,161 @@
+package environment
+
+import (
+	"bufio"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/buger/jsonparser"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// imagePullTimeout is the maximum amount of time a single image pull may take,
+// not including the time spent waiting for other pulls to complete.
+const imagePullTimeout = time.Minute * 15
+
+var (
+	pullsMu sync.Mutex
+	pulls   = make(map[string]*imagePull)
+
+	pullSlotsOnce sync.Once
+	pullSlots     chan struct{}
+
+	pullsQueued atomic.Int64
+	pullsActive atomic.Int64
+)
+
+// imagePull is a pull of an image that is in progress, which any number of
+// callers needing the same image can wait on.
+type imagePull struct {
+	done chan struct{}
+	err  error
+
+	mu          sync.Mutex
+	watchers    map[uint64]func(status string)
+	nextWatcher uint64
+}
+
+// ImagePulls returns the number of image pulls that are currently in progress,
+// and the number that are queued waiting for another pull to complete.
+func ImagePulls() (active int64, queued int64) {
+	return pullsActive.Load(), pullsQueued.Load()
+}
+
+// PullImage pulls an image from its registry, calling onStatus with each status
+// update received from Docker. If the same image is already being pulled the
+// existing pull is waited on rather than starting another one, and at most
+// docker.max_concurrent_pulls different images are pulled at the same time with
+// any others being queued until a pull completes.
+//
+// The pull continues in the background if the context is canceled, so that any
+// other callers waiting on it are not affected, but onStatus is no longer called.
+func PullImage(ctx context.Context, c *client.Client, image string, opts types.ImagePullOptions, onStatus func(status string)) error {
+	pullsMu.Lock()
+	p, ok := pulls[image]
+	if !ok {
+		p = &imagePull{done: make(chan struct{}), watchers: make(map[uint64]func(status string))}
+		pulls[image] = p
+		go p.run(c, image, opts)
+	}
+	var watcher uint64
+	if onStatus != nil {
+		watcher = p.watch(onStatus)
+	}
+	pullsMu.Unlock()
+
+	if ok {
+		log.WithField("image", image).Debug("waiting on pull of docker image that is already in progress")
+	}
+	select {
+	case <-p.done:
+		return p.err
+	case <-ctx.Done():
+		// Stop passing status updates to a caller that is no longer waiting.
+		if onStatus != nil {
+			p.unwatch(watcher)
+		}
+		return ctx.Err()
+	}
+}
+
+// watch adds a function that is called with each status update of the pull,
+// returning an identifier that can be passed to unwatch.
+func (p *imagePull) watch(fn func(status string)) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := p.nextWatcher
+	p.nextWatcher++
+	p.watchers[id] = fn
+	return id
+}
+
+// unwatch removes a function added with watch.
+func (p *imagePull) unwatch(id uint64) {
+	p.mu.Lock()
+	delete(p.watchers, id)
+	p.mu.Unlock()
+}
+
+func (p *imagePull) run(c *client.Client, image string, opts types.ImagePullOptions) {
+	defer func() {
+		pullsMu.Lock()
+		delete(pulls, image)
+		pullsMu.Unlock()
+		close(p.done)
+	}()
+
+	pullSlotsOnce.Do(func() {
+		n := config.Get().Docker.MaxConcurrentPulls
+		if n < 1 {
+			n = 1
+		}
+		pullSlots = make(chan struct{}, n)
+	})
+	pullsQueued.Add(1)
+	pullSlots <- struct{}{}
+	pullsQueued.Add(-1)
+	pullsActive.Add(1)
+	defer func() {
+		pullsActive.Add(-1)
+		<-pullSlots
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), imagePullTimeout)
+	defer cancel()
+
+	out, err := c.ImagePull(ctx, image, opts)
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer out.Close()
+
+	log.WithField("image", image).Debug("pulling docker image... this could take a bit of time")
+
+	// Block until the image is done being pulled, passing the status updates on to
+	// everything waiting on the pull.
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		b := scanner.Bytes()
+		status, _ := jsonparser.GetString(b, "status")
+		progress, _ := jsonparser.GetString(b, "progress")
+
+		p.mu.Lock()
+		for _, w := range p.watchers {
+			w(status + " " + progress)
+		}
+		p.mu.Unlock()
+	}
+	p.err = scanner.Err()
+	if p.err == nil {
+		recordPulledImage(ctx, c, image)
+	}
+
+	log.WithField("image", image).Debug("completed docker image pull")
+}
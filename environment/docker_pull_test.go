@@ -0,0 +1,117 @@
+package environment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestPullImage(t *testing.T) {
+	g := Goblin(t)
+
+	// fakeDocker returns a client for a Docker daemon that counts the image pulls it
+	// receives and does not finish a pull until release is closed.
+	fakeDocker := func(pulled *atomic.Int64, release chan struct{}) *client.Client {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/images/create"):
+				pulled.Add(1)
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+				<-release
+				_, _ = w.Write([]byte(`{"status":"Downloaded"}` + "\n"))
+			case strings.HasSuffix(r.URL.Path, "/json"):
+				_, _ = w.Write([]byte(`{"Id":"sha256:abc"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(srv.Close)
+		c, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithHTTPClient(srv.Client()), client.WithVersion("1.44"))
+		if err != nil {
+			panic(err)
+		}
+		return c
+	}
+
+	// watchers returns the number of callers receiving status updates for the pull
+	// of the image.
+	watchers := func(image string) int {
+		pullsMu.Lock()
+		defer pullsMu.Unlock()
+		p, ok := pulls[image]
+		if !ok {
+			return 0
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.watchers)
+	}
+
+	g.Describe("PullImage", func() {
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{AuthenticationToken: "abc", System: config.SystemConfiguration{RootDirectory: t.TempDir()}})
+		})
+
+		g.It("pulls an image once for concurrent callers", func() {
+			var pulled atomic.Int64
+			release := make(chan struct{})
+			c := fakeDocker(&pulled, release)
+
+			var wg sync.WaitGroup
+			var statuses atomic.Int64
+			errs := make(chan error, 5)
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- PullImage(context.Background(), c, "example:one", types.ImagePullOptions{}, func(string) { statuses.Add(1) })
+				}()
+			}
+			for watchers("example:one") < 5 {
+				time.Sleep(time.Millisecond)
+			}
+			close(release)
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				g.Assert(err).IsNil()
+			}
+			g.Assert(pulled.Load()).Equal(int64(1))
+			g.Assert(statuses.Load()).Equal(int64(5))
+		})
+
+		g.It("stops sending status updates to callers that gave up", func() {
+			var pulled atomic.Int64
+			release := make(chan struct{})
+			c := fakeDocker(&pulled, release)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				done <- PullImage(ctx, c, "example:two", types.ImagePullOptions{}, func(string) {})
+			}()
+			for watchers("example:two") < 1 {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+			g.Assert(<-done).Equal(context.Canceled)
+			g.Assert(watchers("example:two")).Equal(0)
+
+			close(release)
+			g.Assert(PullImage(context.Background(), c, "example:two", types.ImagePullOptions{}, nil)).IsNil()
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package environment
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// sshConn is a net.Conn that tunnels a connection to a remote Docker daemon
+// through the standard input and output of "docker system dial-stdio" executed
+// over SSH on the remote host. The SSH binary on the system is used so that the
+// user's SSH configuration and keys are respected.
+type sshConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	once   sync.Once
+}
+
+var _ net.Conn = (*sshConn)(nil)
+
+// dialSSH opens a new connection to the Docker daemon running on the host in the
+// provided "ssh://[user@]host[:port]" URL.
+func dialSSH(ctx context.Context, u *url.URL) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	args := []string{"-o", "ConnectTimeout=30", "-o", "BatchMode=yes"}
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if p := u.Port(); p != "" {
+		args = append(args, "-p", p)
+	}
+	args = append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+
+	// The command must outlive the context of the request that caused the dial, so
+	// it is not created using exec.CommandContext.
+	cmd := exec.Command("ssh", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "environment/docker: failed to open ssh stdin")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "environment/docker: failed to open ssh stdout")
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "environment/docker: failed to start ssh connection to docker host")
+	}
+	return &sshConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+func (c *sshConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *sshConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+// Close closes the connection and terminates the underlying SSH process.
+func (c *sshConn) Close() error {
+	c.once.Do(func() {
+		_ = c.stdin.Close()
+		_ = c.cmd.Process.Kill()
+		_ = c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr {
+	return &net.UnixAddr{Name: "ssh", Net: "unix"}
+}
+
+func (c *sshConn) RemoteAddr() net.Addr {
+	return &net.UnixAddr{Name: "ssh", Net: "unix"}
+}
+
+// Deadlines are not supported on the connection since it is backed by the pipes
+// of a process, these are no-ops to satisfy the net.Conn interface.
+func (c *sshConn) SetDeadline(_ time.Time) error      { return nil }
+func (c *sshConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c *sshConn) SetWriteDeadline(_ time.Time) error { return nil }
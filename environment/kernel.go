@@ -0,0 +1,102 @@
+package environment
+
+import (
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/docker/docker/api/types/system"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// KernelFeature is the result of checking the host for a kernel feature that is
+// used by Wings, as reported by the Docker daemon.
+type KernelFeature struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	// Required is true if the feature is needed by the current configuration, in
+	// which case RequiredBy is the configuration option that needs it.
+	Required   bool   `json:"required"`
+	RequiredBy string `json:"required_by,omitempty"`
+}
+
+var (
+	kernelFeaturesMu sync.RWMutex
+	kernelFeatures   []KernelFeature
+)
+
+// KernelFeatures returns the results of the most recent check of the kernel
+// features of the host, or nil if the host has not been checked.
+func KernelFeatures() []KernelFeature {
+	kernelFeaturesMu.RLock()
+	defer kernelFeaturesMu.RUnlock()
+	out := make([]KernelFeature, len(kernelFeatures))
+	copy(out, kernelFeatures)
+	return out
+}
+
+// probeKernelFeatures determines which of the kernel features used by Wings are
+// available on the host, and which of them are required by the configuration.
+func probeKernelFeatures(info system.Info) []KernelFeature {
+	cfg := config.Get()
+
+	var userns, seccomp bool
+	for _, opt := range info.SecurityOptions {
+		userns = userns || strings.Contains(opt, "name=userns")
+		seccomp = seccomp || strings.Contains(opt, "name=seccomp")
+	}
+
+	feature := func(name string, available bool, requiredBy string) KernelFeature {
+		return KernelFeature{Name: name, Available: available, Required: requiredBy != "", RequiredBy: requiredBy}
+	}
+	requiredIf := func(b bool, option string) string {
+		if b {
+			return option
+		}
+		return ""
+	}
+
+	// Swap limits are needed unless the swap assigned by the Panel is used as-is,
+	// since a docker.memory_swap of 0 disables swap by limiting it to nothing.
+	mode := cfg.System.CpuLimitMode
+	return []KernelFeature{
+		feature("memory_limit", info.MemoryLimit, "server memory limits"),
+		feature("swap_limit", info.SwapLimit, requiredIf(cfg.Docker.MemorySwap != -1, "docker.memory_swap")),
+		feature("memory_swappiness", info.CgroupVersion != "2", requiredIf(cfg.Docker.MemorySwappiness >= 0, "docker.memory_swappiness")),
+		feature("cpu_cfs_quota", info.CPUCfsQuota, requiredIf(mode != config.CpuLimitModeSoft, "system.cpu_limit_mode")),
+		feature("cpu_shares", info.CPUShares, requiredIf(mode != config.CpuLimitModeHard || cfg.Docker.DefaultCpuShares > 0, "system.cpu_limit_mode or docker.default_cpu_shares")),
+		feature("cpuset", info.CPUSet, ""),
+		feature("pids_limit", info.PidsLimit, requiredIf(cfg.Docker.ContainerPidLimit > 0, "docker.container_pid_limit")),
+		feature("oom_kill_disable", info.OomKillDisable, ""),
+		feature("ipv4_forwarding", info.IPv4Forwarding, "server networking"),
+		feature("seccomp", seccomp, ""),
+		feature("userns", userns, requiredIf(cfg.System.OwnershipNormalization.UsernsOffset > 0, "system.ownership_normalization.userns_offset")),
+	}
+}
+
+// checkKernelFeatures probes the kernel features of the host and stores the
+// results. If a feature that is required by the configuration is missing an
+// error is returned when the configuration refuses to boot in that case, and
+// a warning is logged otherwise.
+func checkKernelFeatures(info system.Info) error {
+	features := probeKernelFeatures(info)
+	kernelFeaturesMu.Lock()
+	kernelFeatures = features
+	kernelFeaturesMu.Unlock()
+
+	var missing []string
+	for _, f := range features {
+		if !f.Required || f.Available {
+			continue
+		}
+		missing = append(missing, f.Name)
+		log.WithField("feature", f.Name).WithField("required_by", f.RequiredBy).WithField("kernel", info.KernelVersion).
+			Warn("a kernel feature required by the configuration is not available on this host, the related limits will not be applied to servers")
+	}
+	if len(missing) > 0 && config.Get().System.KernelFeatureAction == config.KernelFeatureActionRefuse {
+		return errors.Errorf("environment/docker: the kernel features %s are required by the configuration but are not available on this host", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package environment
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/docker/docker/client"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// pulledImagesMu guards the file tracking the images pulled by Wings.
+var pulledImagesMu sync.Mutex
+
+// pulledImagesPath returns the location of the file that tracks the IDs of the
+// images pulled by Wings, which are the only images that are ever pruned.
+func pulledImagesPath() string {
+	return filepath.Join(config.Get().System.RootDirectory, "pulled_images.json")
+}
+
+// recordPulledImage records the ID of an image that was pulled by Wings so that it
+// can be pruned once it is no longer used. The ID is recorded rather than the name
+// so that the image is still recognized once its tag moves to a newer image.
+func recordPulledImage(ctx context.Context, c *client.Client, image string) {
+	img, _, err := c.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		log.WithField("image", image).WithField("error", err).Warn("failed to inspect pulled docker image")
+		return
+	}
+
+	pulledImagesMu.Lock()
+	defer pulledImagesMu.Unlock()
+	ids, err := readPulledImages()
+	if err == nil {
+		ids[img.ID] = struct{}{}
+		err = writePulledImages(ids)
+	}
+	if err != nil {
+		log.WithField("image", image).WithField("error", err).Warn("failed to record pulled docker image")
+	}
+}
+
+// readPulledImages returns the IDs of the images pulled by Wings. This must be
+// called while holding pulledImagesMu.
+func readPulledImages() (map[string]struct{}, error) {
+	ids := make(map[string]struct{})
+	b, err := os.ReadFile(pulledImagesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ids, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, errors.Wrap(err, "environment: failed to parse pulled images file")
+	}
+	for _, id := range list {
+		ids[id] = struct{}{}
+	}
+	return ids, nil
+}
+
+// writePulledImages replaces the IDs of the images pulled by Wings. This must be
+// called while holding pulledImagesMu.
+func writePulledImages(ids map[string]struct{}) error {
+	list := make([]string, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+	b, err := json.Marshal(list)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	p := pulledImagesPath()
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(f.Name(), p))
+}
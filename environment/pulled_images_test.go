@@ -0,0 +1,37 @@
+package environment
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestPulledImages(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("pulled images", func() {
+		g.BeforeEach(func() {
+			dir, err := os.MkdirTemp("", "wings-pulled")
+			if err != nil {
+				panic(err)
+			}
+			config.Set(&config.Configuration{AuthenticationToken: "abc", System: config.SystemConfiguration{RootDirectory: dir}})
+		})
+
+		g.It("returns no images when nothing was recorded", func() {
+			ids, err := readPulledImages()
+			g.Assert(err).IsNil()
+			g.Assert(len(ids)).Equal(0)
+		})
+
+		g.It("reads back the images that were written", func() {
+			g.Assert(writePulledImages(map[string]struct{}{"sha256:b": {}, "sha256:a": {}})).IsNil()
+			ids, err := readPulledImages()
+			g.Assert(err).IsNil()
+			g.Assert(ids).Equal(map[string]struct{}{"sha256:a": {}, "sha256:b": {}})
+		})
+	})
+}
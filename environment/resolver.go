@@ -0,0 +1,105 @@
+package environment
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+type resolvedAllocation struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+var (
+	resolvedMu          sync.Mutex
+	resolvedAllocations = make(map[string]resolvedAllocation)
+	// resolverServer is used to rotate through the configured DNS servers.
+	resolverServer atomic.Uint64
+)
+
+// ResolveAllocation returns the IP addresses of the host of an allocation, ordered
+// so that the preferred address family comes first. IP addresses are returned as
+// they are, and hostnames are resolved using the allocation resolver configuration
+// and cached for its TTL.
+func ResolveAllocation(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	cfg := config.Get().Docker.AllocationResolver
+	if cfg.CacheTtl > 0 {
+		resolvedMu.Lock()
+		r, ok := resolvedAllocations[host]
+		resolvedMu.Unlock()
+		if ok && time.Now().Before(r.expires) {
+			return r.ips, nil
+		}
+	}
+
+	resolver := net.DefaultResolver
+	if servers := cfg.ServerAddresses(); len(servers) > 0 {
+		var d net.Dialer
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return d.DialContext(ctx, network, servers[resolverServer.Add(1)%uint64(len(servers))])
+			},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	ips, err := resolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "environment: failed to resolve allocation host \"%s\"", host)
+	}
+	if len(ips) == 0 {
+		return nil, errors.Errorf("environment: allocation host \"%s\" did not resolve to any addresses", host)
+	}
+	if cfg.PreferredFamily != config.AllocationFamilyAny {
+		v4 := cfg.PreferredFamily == config.AllocationFamilyIPv4
+		sort.SliceStable(ips, func(i, j int) bool {
+			return (ips[i].To4() != nil) == v4 && (ips[j].To4() != nil) != v4
+		})
+	}
+
+	if cfg.CacheTtl > 0 {
+		resolvedMu.Lock()
+		resolvedAllocations[host] = resolvedAllocation{ips: ips, expires: time.Now().Add(time.Duration(cfg.CacheTtl) * time.Second)}
+		resolvedMu.Unlock()
+	}
+	return ips, nil
+}
+
+// ResolveOutgoingIPv4 returns the IPv4 address of the host of an allocation, which
+// is used as the source address of the traffic leaving a container when its outgoing
+// IP is forced. Docker only supports setting an IPv4 source address for a network, so
+// an error is returned if the host has no IPv4 address.
+func ResolveOutgoingIPv4(host string) (net.IP, error) {
+	ips, err := ResolveAllocation(host)
+	if err != nil {
+		return nil, err
+	}
+	if ip := firstIPv4(ips); ip != nil {
+		return ip, nil
+	}
+	return nil, errors.Errorf("environment: allocation host \"%s\" has no IPv4 address to force as the outgoing IP", host)
+}
+
+// firstIPv4 returns the first IPv4 address in ips, or nil if there is none.
+func firstIPv4(ips []net.IP) net.IP {
+	for _, ip := range ips {
+		if v4 := ip.To4(); v4 != nil {
+			return v4
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package environment
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestResolveOutgoingIPv4(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("ResolveOutgoingIPv4", func() {
+		g.It("returns an IPv4 allocation as it is", func() {
+			ip, err := ResolveOutgoingIPv4("192.0.2.10")
+			g.Assert(err).IsNil()
+			g.Assert(ip.String()).Equal("192.0.2.10")
+		})
+
+		g.It("rejects an IPv6 allocation", func() {
+			_, err := ResolveOutgoingIPv4("2001:db8::10")
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+
+	g.Describe("firstIPv4", func() {
+		g.It("skips IPv6 addresses", func() {
+			ips := []net.IP{net.ParseIP("2001:db8::10"), net.ParseIP("192.0.2.10"), net.ParseIP("192.0.2.11")}
+			g.Assert(firstIPv4(ips).String()).Equal("192.0.2.10")
+		})
+
+		g.It("returns nil when there is no IPv4 address", func() {
+			g.Assert(firstIPv4([]net.IP{net.ParseIP("2001:db8::10")}) == nil).IsTrue()
+		})
+	})
+}
@@ -29,6 +29,10 @@ type Mount struct {
 	// Whether the directory is being mounted as read-only. It is up to the environment to
 	// handle this value correctly and ensure security expectations are met with its usage.
 	ReadOnly bool `json:"read_only"`
+
+	// Propagation is the bind propagation of the mount, such as "rshared". If empty the
+	// default of the environment is used.
+	Propagation string `json:"propagation,omitempty"`
 }
 
 // Limits is the build settings for a given server that impact docker container
@@ -41,10 +45,19 @@ type Limits struct {
 	// The amount of additional swap space to be provided to a container instance.
 	Swap int64 `json:"swap"`
 
+	// The tendency of the kernel to swap out the memory of the container, between 0
+	// and 100. If unset the value defined in the configuration is used.
+	Swappiness *int64 `json:"memory_swappiness,omitempty"`
+
 	// The relative weight for IO operations in a container. This is relative to other
 	// containers on the system and should be a value between 10 and 1000.
 	IoWeight uint16 `json:"io_weight"`
 
+	// The relative weight for CPU time in a container when the host is under contention.
+	// This should be a value of at least 2, or 0 to use the default defined in the
+	// configuration.
+	CpuShares int64 `json:"cpu_shares"`
+
 	// The percentage of CPU that this instance is allowed to consume relative to
 	// the host. A value of 200% represents complete utilization of two cores. This
 	// should be a value between 1 and THREAD_COUNT * 100.
@@ -85,12 +98,31 @@ func (l Limits) BoundedMemoryLimit() int64 {
 // ConvertedSwap returns the amount of swap available as a total in bytes. This
 // is returned as the amount of memory available to the server initially, PLUS
 // the amount of additional swap to include which is the format used by Docker.
+// The swap assigned to the server is capped by the maximum in the configuration.
 func (l Limits) ConvertedSwap() int64 {
-	if l.Swap < 0 {
+	swap := l.Swap
+	if max := config.Get().Docker.MemorySwap; max >= 0 && (swap < 0 || swap > max) {
+		swap = max
+	}
+	if swap < 0 {
 		return -1
 	}
 
-	return (l.Swap * 1024 * 1024) + l.BoundedMemoryLimit()
+	return (swap * 1024 * 1024) + l.BoundedMemoryLimit()
+}
+
+// ConvertedSwappiness returns the swappiness for the container, falling back to
+// the value defined in the configuration if the server does not have a valid value
+// assigned. A nil value leaves the swappiness unset.
+func (l Limits) ConvertedSwappiness() *int64 {
+	if l.Swappiness != nil && *l.Swappiness >= 0 && *l.Swappiness <= 100 {
+		v := *l.Swappiness
+		return &v
+	}
+	if v := config.Get().Docker.MemorySwappiness; v >= 0 {
+		return &v
+	}
+	return nil
 }
 
 // ProcessLimit returns the process limit for a container. This is currently
@@ -99,6 +131,61 @@ func (l Limits) ProcessLimit() int64 {
 	return config.Get().Docker.ContainerPidLimit
 }
 
+// ConvertedCpuShares returns the CPU shares for the container, falling back to the
+// default defined in the configuration if the server does not have a valid value
+// assigned. A value of 0 leaves the shares unset.
+func (l Limits) ConvertedCpuShares() int64 {
+	if l.CpuShares >= 2 {
+		return l.CpuShares
+	}
+	return config.Get().Docker.DefaultCpuShares
+}
+
+// ConvertedIoWeight returns the block IO weight for the container, falling back to
+// the default defined in the configuration if the server does not have a valid
+// value assigned. A value of 0 leaves the weight unset.
+func (l Limits) ConvertedIoWeight() uint16 {
+	if l.IoWeight >= 10 && l.IoWeight <= 1000 {
+		return l.IoWeight
+	}
+	return config.Get().Docker.BlkioWeight
+}
+
+// PriorityToCpuShares converts a priority value from the Panel into CPU shares. A
+// priority of 100 is equal to the Docker default of 1024 shares, so a server with a
+// priority of 200 receives twice as much CPU time under contention as one with the
+// default priority. The result is never less than the Docker minimum of 2.
+func PriorityToCpuShares(priority int) int64 {
+	shares := int64(priority) * 1024 / 100
+	if shares < 2 {
+		return 2
+	}
+	return shares
+}
+
+// CpuLimitToShares converts the CPU limit of a server into CPU shares for the soft
+// CPU limit mode. A limit of 100% (one thread) is equal to the Docker default of 1024
+// shares. The result is never less than the Docker minimum of 2.
+func CpuLimitToShares(limit int64) int64 {
+	shares := limit * 1024 / 100
+	if shares < 2 {
+		return 2
+	}
+	return shares
+}
+
+// PriorityToBlkioWeight converts a priority value from the Panel between 1 and 100
+// into a block IO weight, clamped to the range of 10 to 1000 accepted by Docker.
+func PriorityToBlkioWeight(priority int) uint16 {
+	weight := priority * 10
+	if weight < 10 {
+		return 10
+	} else if weight > 1000 {
+		return 1000
+	}
+	return uint16(weight)
+}
+
 // AsContainerResources returns the available resources for a container in a format
 // that Docker understands.
 func (l Limits) AsContainerResources() container.Resources {
@@ -107,7 +194,9 @@ func (l Limits) AsContainerResources() container.Resources {
 		Memory:            l.BoundedMemoryLimit(),
 		MemoryReservation: l.MemoryLimit * 1024 * 1024,
 		MemorySwap:        l.ConvertedSwap(),
-		BlkioWeight:       l.IoWeight,
+		MemorySwappiness:  l.ConvertedSwappiness(),
+		BlkioWeight:       l.ConvertedIoWeight(),
+		CPUShares:         l.ConvertedCpuShares(),
 		OomKillDisable:    &l.OOMDisabled,
 		PidsLimit:         &pids,
 	}
@@ -117,9 +206,19 @@ func (l Limits) AsContainerResources() container.Resources {
 	//
 	// @see https://github.com/pterodactyl/panel/issues/3988
 	if l.CpuLimit > 0 {
-		resources.CPUQuota = l.CpuLimit * 1_000
-		resources.CPUPeriod = 100_000
-		resources.CPUShares = 1024
+		mode := config.Get().System.CpuLimitMode
+		// A soft limit gives the server CPU shares in proportion to its limit. Shares
+		// assigned to the server itself or by docker.default_cpu_shares take precedence.
+		if (mode == config.CpuLimitModeSoft || mode == config.CpuLimitModeBoth) && resources.CPUShares == 0 {
+			resources.CPUShares = CpuLimitToShares(l.CpuLimit)
+		}
+		if mode != config.CpuLimitModeSoft {
+			resources.CPUQuota = l.CpuLimit * 1_000
+			resources.CPUPeriod = 100_000
+			if resources.CPUShares == 0 {
+				resources.CPUShares = 1024
+			}
+		}
 	}
 
 	// Similar to above, don't set the specific assigned CPUs if we didn't actually limit
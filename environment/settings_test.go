@@ -0,0 +1,42 @@
+package environment
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestAsContainerResources(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Limits.AsContainerResources", func() {
+		set := func(mode string, defaultShares int64) {
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System:              config.SystemConfiguration{CpuLimitMode: mode},
+				Docker:              config.DockerConfiguration{DefaultCpuShares: defaultShares},
+			})
+		}
+
+		g.It("derives CPU shares from the limit in soft mode", func() {
+			set(config.CpuLimitModeSoft, 0)
+			r := Limits{CpuLimit: 200}.AsContainerResources()
+			g.Assert(r.CPUShares).Equal(int64(2048))
+			g.Assert(r.CPUQuota).Equal(int64(0))
+		})
+
+		g.It("keeps the shares assigned to the server", func() {
+			set(config.CpuLimitModeBoth, 0)
+			r := Limits{CpuLimit: 200, CpuShares: 512}.AsContainerResources()
+			g.Assert(r.CPUShares).Equal(int64(512))
+			g.Assert(r.CPUQuota).Equal(int64(200_000))
+		})
+
+		g.It("keeps the default shares from the configuration", func() {
+			set(config.CpuLimitModeSoft, 256)
+			g.Assert(Limits{CpuLimit: 200}.AsContainerResources().CPUShares).Equal(int64(256))
+		})
+	})
+}
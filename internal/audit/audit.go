@@ -0,0 +1,170 @@
+// Package audit implements the audit log of file operations that are performed
+// on servers through the API and the SFTP server.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/logforward"
+	"github.com/pterodactyl/wings/internal/shipper"
+)
+
+const (
+	SourceApi  = "api"
+	SourceSftp = "sftp"
+
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// maxBatchSize is the maximum number of records written at once.
+const maxBatchSize = 100
+
+// Record is a single file operation in the audit log.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Source is where the operation came from, either "api" or "sftp".
+	Source string `json:"source"`
+	// User is the UUID of the user that performed the operation. Requests made by
+	// the Panel on behalf of a user do not include the user.
+	User      string `json:"user,omitempty"`
+	Server    string `json:"server"`
+	IP        string `json:"ip,omitempty"`
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	// Target is the destination of operations such as renames.
+	Target string `json:"target,omitempty"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// queue holds the records waiting to be written to the audit log.
+var queue shipper.Shipper[Record]
+
+// Log queues a record to be written to the audit log. The result of the record is
+// set from the error passed in. Records of operations that are not being recorded
+// are discarded, as are records that arrive while the queue is full.
+//
+// Records are also sent to the "audit" log forwarding stream. This happens even
+// when the audit log itself is disabled, so the audit log can be shipped to a log
+// collector without also being written by Wings.
+func Log(r Record, err error) {
+	cfg := config.Get().System.Audit
+	if !cfg.Includes(r.Operation) {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+	r.Result = ResultSuccess
+	if err != nil {
+		r.Result = ResultFailure
+		r.Error = err.Error()
+	}
+	logforward.Forward(logforward.Entry{
+		Time:    r.Time,
+		Stream:  logforward.StreamAudit,
+		Server:  r.Server,
+		Message: r.Operation + " " + r.Path,
+		Fields:  log.Fields{"source": r.Source, "user": r.User, "ip": r.IP, "target": r.Target, "result": r.Result, "error": r.Error},
+	})
+	if !cfg.Enabled || !queue.Running() {
+		return
+	}
+	if !queue.Send(r) {
+		log.WithField("subsystem", "audit").WithField("operation", r.Operation).Warn("audit log queue is full, dropping record")
+	}
+}
+
+// Start opens the configured audit log destination and begins writing records to
+// it in the background until the context is canceled. If the audit log is not
+// enabled this is a no-op.
+func Start(ctx context.Context) error {
+	cfg := config.Get().System.Audit
+	if !cfg.Enabled {
+		return nil
+	}
+	s, err := newSink(cfg)
+	if err != nil {
+		return err
+	}
+	queue.Start(ctx, s, shipper.Options{
+		BufferSize:    cfg.BufferSize,
+		BatchSize:     maxBatchSize,
+		FlushInterval: time.Second,
+		OnError: func(n int, err error) {
+			log.WithField("subsystem", "audit").WithField("records", n).WithField("error", err).Error("failed to write records to audit log")
+		},
+	})
+	return nil
+}
+
+func newSink(cfg config.AuditConfiguration) (shipper.Sink[Record], error) {
+	switch cfg.Destination {
+	case config.AuditDestinationWebhook:
+		return &webhookSink{url: cfg.WebhookUrl, client: &http.Client{Timeout: time.Second * 10}}, nil
+	case config.AuditDestinationSyslog:
+		s, err := shipper.NewSyslogSink[Record](cfg.SyslogNetwork, cfg.SyslogAddress, "wings-audit")
+		if err != nil {
+			return nil, errors.Wrap(err, "audit: failed to connect to syslog")
+		}
+		return s, nil
+	default:
+		p := cfg.File
+		if p == "" {
+			p = filepath.Join(config.Get().System.LogDirectory, "audit.log")
+		}
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, errors.Wrap(err, "audit: failed to open audit log file")
+		}
+		return &fileSink{f: f}, nil
+	}
+}
+
+type fileSink struct {
+	f *os.File
+}
+
+func (s *fileSink) Write(records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	_, err := s.f.Write(buf.Bytes())
+	return errors.WithStack(err)
+}
+
+func (s *fileSink) Close() error {
+	return s.f.Close()
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) Write(records []Record) error {
+	b, err := json.Marshal(records)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.Wrap(shipper.Post(s.client, s.url, "application/json", b), "audit: failed to send records to webhook")
+}
+
+func (s *webhookSink) Close() error {
+	return nil
+}
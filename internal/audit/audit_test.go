@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func TestFileSink(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("fileSink", func() {
+		g.It("appends each record as a line of JSON", func() {
+			p := filepath.Join(t.TempDir(), "audit.log")
+			f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+			g.Assert(err).IsNil()
+			s := &fileSink{f: f}
+			g.Assert(s.Write([]Record{{Operation: "write", Path: "/a"}, {Operation: "delete", Path: "/b"}})).IsNil()
+			g.Assert(s.Close()).IsNil()
+
+			b, err := os.ReadFile(p)
+			g.Assert(err).IsNil()
+			lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+			g.Assert(len(lines)).Equal(2)
+			g.Assert(strings.Contains(lines[1], `"operation":"delete"`)).IsTrue()
+		})
+	})
+}
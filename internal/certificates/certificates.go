@@ -0,0 +1,152 @@
+// Package certificates implements the certificate store used by the API when it
+// is serving TLS, selecting a certificate based on the hostname requested by the
+// client and reloading certificates when their files change on disk.
+package certificates
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// entry is a single certificate and key pair loaded from the disk.
+type entry struct {
+	certFile string
+	keyFile  string
+	modified time.Time
+	cert     *tls.Certificate
+}
+
+// Store holds the default certificate for the API along with any additional
+// certificates that are served for specific hostnames.
+type Store struct {
+	mu    sync.RWMutex
+	def   *entry
+	hosts map[string]*entry
+}
+
+// New returns a store containing the default certificate and key pair and any
+// hostname specific certificates provided. An error is returned if any of the
+// certificates cannot be loaded.
+func New(certFile, keyFile string, certs []config.SslCertificate) (*Store, error) {
+	s := &Store{
+		def:   &entry{certFile: certFile, keyFile: keyFile},
+		hosts: make(map[string]*entry, len(certs)),
+	}
+	if err := s.def.load(); err != nil {
+		return nil, err
+	}
+	for _, c := range certs {
+		e := &entry{certFile: c.CertificateFile, keyFile: c.KeyFile}
+		if err := e.load(); err != nil {
+			return nil, err
+		}
+		s.hosts[strings.ToLower(c.Hostname)] = e
+	}
+	return s, nil
+}
+
+// GetCertificate returns the certificate for the hostname requested by the
+// client, falling back to the default certificate. It is intended to be used
+// as the GetCertificate callback of a tls.Config.
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if e, ok := s.hosts[name]; ok {
+		return e.cert, nil
+	}
+	// Check for a wildcard certificate matching the first level of the hostname.
+	if i := strings.Index(name, "."); i > 0 {
+		if e, ok := s.hosts["*"+name[i:]]; ok {
+			return e.cert, nil
+		}
+	}
+	return s.def.cert, nil
+}
+
+// Watch checks the certificate files for changes every interval until the
+// context is canceled, reloading any certificates that have changed. If a
+// certificate fails to load the previous certificate continues to be served.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reload()
+		}
+	}
+}
+
+// reload reloads each of the certificates that have been modified since they
+// were last loaded.
+func (s *Store) reload() {
+	s.mu.RLock()
+	entries := make([]*entry, 0, len(s.hosts)+1)
+	entries = append(entries, s.def)
+	for _, e := range s.hosts {
+		entries = append(entries, e)
+	}
+	s.mu.RUnlock()
+
+	for _, e := range entries {
+		s.mu.RLock()
+		changed := e.changed()
+		s.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		l := log.WithField("cert", e.certFile).WithField("key", e.keyFile)
+		n := &entry{certFile: e.certFile, keyFile: e.keyFile}
+		if err := n.load(); err != nil {
+			l.WithField("error", err).Warn("failed to reload modified TLS certificate, continuing to use the previous certificate")
+			continue
+		}
+		s.mu.Lock()
+		e.cert, e.modified = n.cert, n.modified
+		s.mu.Unlock()
+		l.Info("reloaded modified TLS certificate")
+	}
+}
+
+// load loads the certificate and key pair from the disk.
+func (e *entry) load() error {
+	modified := e.lastModified()
+	cert, err := tls.LoadX509KeyPair(e.certFile, e.keyFile)
+	if err != nil {
+		return errors.Wrapf(err, "certificates: failed to load certificate %s", e.certFile)
+	}
+	e.cert, e.modified = &cert, modified
+	return nil
+}
+
+// changed returns true if either the certificate or key file has been modified
+// since the pair was last loaded.
+func (e *entry) changed() bool {
+	return e.lastModified().After(e.modified)
+}
+
+// lastModified returns the most recent modification time of the certificate
+// and key files.
+func (e *entry) lastModified() time.Time {
+	var t time.Time
+	for _, f := range []string{e.certFile, e.keyFile} {
+		if st, err := os.Stat(f); err == nil && st.ModTime().After(t) {
+			t = st.ModTime()
+		}
+	}
+	return t
+}
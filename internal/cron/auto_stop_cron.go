@@ -0,0 +1,40 @@
+package cron
+
+import (
+	"context"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+type autoStopCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+}
+
+// Run executes the auto-stop cron, checking every server on the node for
+// activity and stopping those that have been idle for too long. Servers are
+// checked in parallel since stopping a server can take some time.
+func (ac *autoStopCron) Run(ctx context.Context) error {
+	if !ac.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer ac.mu.Store(false)
+
+	var wg sync.WaitGroup
+	for _, s := range ac.manager.All() {
+		wg.Add(1)
+		go func(s *server.Server) {
+			defer wg.Done()
+			if err := s.AutoStopIfIdle(ctx); err != nil {
+				log.WithField("subsystem", "cron").WithField("cron", "auto_stop").WithField("server", s.ID()).WithField("error", err).Error("failed to auto-stop idle server")
+			}
+		}(s)
+	}
+	wg.Wait()
+	return nil
+}
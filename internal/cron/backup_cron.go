@@ -0,0 +1,77 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/gammazero/workerpool"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/server/backup"
+	"github.com/pterodactyl/wings/system"
+)
+
+type backupCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+	max     int
+}
+
+// Run executes the scheduled backup cron. Servers that are suspended, or that are
+// currently being installed, transferred, or restored are skipped since their files
+// are not in a consistent state. At most max servers are backed up at once. Once a
+// server has been backed up its older scheduled backups are pruned according to the
+// configured retention.
+func (bc *backupCron) Run(ctx context.Context) error {
+	if !bc.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer bc.mu.Store(false)
+
+	servers := bc.manager.Filter(func(s *server.Server) bool {
+		return !s.IsSuspended() && !s.IsInstalling() && !s.IsTransferring() && !s.IsRestoring()
+	})
+
+	schedule := config.Get().System.Backups.Schedule
+	maxAge := time.Duration(schedule.MaxAgeDays) * 24 * time.Hour
+
+	l := log.WithField("subsystem", "cron").WithField("cron", "backup")
+	l.WithField("servers", len(servers)).Info("starting scheduled backup run")
+
+	pool := workerpool.New(bc.max)
+	for _, s := range servers {
+		s := s
+		pool.Submit(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			start := time.Now()
+			b := backup.NewScheduled(bc.manager.Client(), s.ID(), start)
+			ad, err := s.ScheduledBackup(b)
+			if err != nil {
+				l.WithField("server", s.ID()).WithField("error", err).Error("failed to create scheduled backup")
+				return
+			}
+			l.WithFields(log.Fields{
+				"server":   s.ID(),
+				"backup":   b.Identifier(),
+				"size":     system.FormatBytes(ad.Size),
+				"duration": time.Since(start).String(),
+			}).Info("created scheduled backup")
+
+			removed, err := backup.PruneScheduled(bc.manager.Client(), s.ID(), schedule.Keep, maxAge, time.Now())
+			if err != nil {
+				l.WithField("server", s.ID()).WithField("error", err).Warn("failed to prune scheduled backups")
+			} else if len(removed) > 0 {
+				l.WithField("server", s.ID()).WithField("removed", removed).Info("pruned scheduled backups")
+			}
+		})
+	}
+	pool.StopWait()
+
+	l.Info("finished scheduled backup run")
+	return nil
+}
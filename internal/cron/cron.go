@@ -69,5 +69,179 @@ func Scheduler(ctx context.Context, m *server.Manager) (*gocron.Scheduler, error
 		}
 	})
 
+	if prune := config.Get().Docker.ImagePruning; prune.Enabled {
+		images := imagePruneCron{
+			mu:      system.NewAtomicBool(false),
+			manager: m,
+			minAge:  time.Duration(prune.MinAge) * time.Second,
+		}
+
+		_, _ = s.Tag("image_prune").Every(time.Duration(prune.Interval) * time.Second).Do(func() {
+			l.WithField("cron", "image_prune").Debug("pruning unused docker images")
+			if err := images.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "image_prune").Warn("image pruning process is already running, skipping...")
+				} else {
+					l.WithField("cron", "image_prune").WithField("error", err).Error("image pruning process failed to execute")
+				}
+			}
+		})
+	}
+
+	if orphaned := config.Get().Docker.OrphanedContainers; orphaned.Enabled {
+		orphans := orphanCron{
+			mu:      system.NewAtomicBool(false),
+			manager: m,
+			remove:  orphaned.Remove,
+		}
+
+		_, _ = s.Tag("orphaned_containers").Every(time.Duration(orphaned.Interval) * time.Second).Do(func() {
+			l.WithField("cron", "orphaned_containers").Debug("checking for orphaned server containers")
+			if err := orphans.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "orphaned_containers").Warn("orphaned container process is already running, skipping...")
+				} else {
+					l.WithField("cron", "orphaned_containers").WithField("error", err).Error("orphaned container process failed to execute")
+				}
+			}
+		})
+	}
+
+	if sys := config.Get().System; sys.AutoRecoverStuckInstalls {
+		installs := installRecoveryCron{
+			mu:      system.NewAtomicBool(false),
+			manager: m,
+			timeout: time.Duration(sys.InstallTimeout) * time.Second,
+		}
+
+		_, _ = s.Tag("stuck_installs").Every(time.Duration(sys.StuckInstallCheckInterval) * time.Second).Do(func() {
+			l.WithField("cron", "stuck_installs").Debug("checking for stuck server installations")
+			if err := installs.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "stuck_installs").Warn("stuck installation process is already running, skipping...")
+				} else {
+					l.WithField("cron", "stuck_installs").WithField("error", err).Error("stuck installation process failed to execute")
+				}
+			}
+		})
+	}
+
+	if ownership := config.Get().System.OwnershipNormalization; ownership.Periodic() {
+		owners := ownershipCron{
+			mu:      system.NewAtomicBool(false),
+			manager: m,
+		}
+
+		_, _ = s.Tag("ownership").Every(time.Duration(ownership.Interval) * time.Second).Do(func() {
+			l.WithField("cron", "ownership").Debug("normalizing ownership of server files")
+			if err := owners.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "ownership").Warn("ownership normalization process is already running, skipping...")
+				} else {
+					l.WithField("cron", "ownership").WithField("error", err).Error("ownership normalization process failed to execute")
+				}
+			}
+		})
+	}
+
+	if reconcile := config.Get().System.StateReconciliation; reconcile.Enabled {
+		rc := reconcileCron{
+			mu:      system.NewAtomicBool(false),
+			manager: m,
+		}
+
+		_, _ = s.Tag("reconcile").Every(time.Duration(reconcile.Interval) * time.Second).Do(func() {
+			l.WithField("cron", "reconcile").Debug("reconciling server states with their containers")
+			if err := rc.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "reconcile").Warn("state reconciliation process is already running, skipping...")
+				} else {
+					l.WithField("cron", "reconcile").WithField("error", err).Error("state reconciliation process failed to execute")
+				}
+			}
+		})
+	}
+
+	if interval := config.Get().System.ClockSkew.Interval; interval > 0 {
+		client := m.Client()
+
+		_, _ = s.Tag("clock_skew").Every(time.Duration(interval) * time.Second).WaitForSchedule().Do(func() {
+			l.WithField("cron", "clock_skew").Debug("checking the clock of this node against the panel")
+			if _, err := client.MeasureClockSkew(ctx); err != nil {
+				l.WithField("cron", "clock_skew").WithField("error", err).Warn("failed to check the clock of this node against the panel")
+			}
+		})
+	}
+
+	if autoStop := config.Get().System.AutoStop; autoStop.Enabled {
+		idle := autoStopCron{
+			mu:      system.NewAtomicBool(false),
+			manager: m,
+		}
+
+		_, _ = s.Tag("auto_stop").Every(time.Duration(autoStop.CheckInterval) * time.Second).Do(func() {
+			l.WithField("cron", "auto_stop").Debug("checking servers for activity")
+			if err := idle.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "auto_stop").Warn("auto-stop process is already running, skipping...")
+				} else {
+					l.WithField("cron", "auto_stop").WithField("error", err).Error("auto-stop process failed to execute")
+				}
+			}
+		})
+	}
+
+	if config.Get().System.ServerLogRetention.Enabled {
+		logs := logRetentionCron{
+			mu:      system.NewAtomicBool(false),
+			manager: m,
+		}
+
+		// Log files are pruned on the same cadence as disk usage is checked, falling
+		// back to the default interval if disk checking is disabled.
+		every := config.Get().System.DiskCheckInterval
+		if every <= 0 {
+			every = 150
+		}
+		_, _ = s.Tag("log_retention").Every(time.Duration(every) * time.Second).Do(func() {
+			l.WithField("cron", "log_retention").Debug("pruning server log files")
+			if err := logs.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "log_retention").Warn("log retention process is already running, skipping...")
+				} else {
+					l.WithField("cron", "log_retention").WithField("error", err).Error("log retention process failed to execute")
+				}
+			}
+		})
+	}
+
+	if schedule := config.Get().System.Backups.Schedule; schedule.Enabled {
+		backups := backupCron{
+			mu:      system.NewAtomicBool(false),
+			manager: m,
+			max:     schedule.MaxConcurrent,
+		}
+
+		var job *gocron.Scheduler
+		if schedule.CronExpression != "" {
+			l.WithField("expression", schedule.CronExpression).Info("configuring scheduled backups")
+			job = s.Tag("backup").Cron(schedule.CronExpression)
+		} else {
+			l.WithField("interval", time.Duration(schedule.Interval)*time.Second).Info("configuring scheduled backups")
+			job = s.Tag("backup").Every(time.Duration(schedule.Interval) * time.Second)
+		}
+		// Don't run a full backup of every server the moment Wings boots, wait for the
+		// first scheduled run instead.
+		_, _ = job.WaitForSchedule().Do(func() {
+			if err := backups.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "backup").Warn("scheduled backup process is already running, skipping...")
+				} else {
+					l.WithField("cron", "backup").WithField("error", err).Error("scheduled backup process failed to execute")
+				}
+			}
+		})
+	}
+
 	return s, nil
 }
@@ -0,0 +1,45 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+type imagePruneCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+	minAge  time.Duration
+}
+
+// Run executes the image pruning cron. Any image that is currently assigned to
+// a server on this node is kept, even if no container is using it at the moment,
+// so that stopped servers do not need to pull their image again when started.
+func (ic *imagePruneCron) Run(ctx context.Context) error {
+	if !ic.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer ic.mu.Store(false)
+
+	var keep []string
+	for _, s := range ic.manager.All() {
+		if image := s.Config().Container.Image; image != "" {
+			keep = append(keep, image)
+		}
+	}
+
+	reclaimed, err := environment.PruneImages(ctx, ic.minAge, keep)
+	if err != nil {
+		return err
+	}
+	if reclaimed > 0 {
+		log.WithField("subsystem", "cron").WithField("reclaimed", system.FormatBytes(reclaimed)).Info("pruned unused docker images")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package cron
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+type installRecoveryCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+	timeout time.Duration
+}
+
+// Run executes the stuck installation cron. Any installation container that is
+// not being tracked by a running installation process is removed once it has
+// exited, or once it has been running for longer than the install timeout. The
+// result of the installation is then reported to the Panel so that the server
+// leaves the installing state.
+func (ic *installRecoveryCron) Run(ctx context.Context) error {
+	if !ic.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer ic.mu.Store(false)
+
+	containers, err := environment.InstallerContainers(ctx)
+	if err != nil {
+		return err
+	}
+	cli, err := environment.Docker()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if len(c.Names) == 0 {
+			continue
+		}
+		uuid := strings.TrimSuffix(strings.TrimPrefix(c.Names[0], "/"), "_installer")
+		s, ok := ic.manager.Get(uuid)
+		// Installations that are being run by this process enforce the install timeout
+		// themselves, so there is nothing to recover.
+		if ok && s.IsInstalling() {
+			continue
+		}
+
+		running := c.State == "running"
+		age := time.Since(time.Unix(c.Created, 0))
+		if running && (ic.timeout == 0 || age < ic.timeout) {
+			continue
+		}
+
+		l := log.WithField("subsystem", "cron").WithField("cron", "stuck_installs").WithField("container", c.ID).WithField("server", uuid)
+
+		// An installation container that exited on its own is reported using its exit
+		// code, one that is still running has exceeded the timeout and has failed.
+		successful := false
+		if !running {
+			if inspect, err := cli.ContainerInspect(ctx, c.ID); err != nil {
+				l.WithField("error", err).Warn("failed to inspect installation container, reporting the installation as failed")
+			} else if inspect.State != nil {
+				successful = inspect.State.ExitCode == 0
+			}
+		}
+
+		if err := environment.RemoveOrphanedContainer(ctx, c.ID, true); err != nil {
+			l.WithField("error", err).Error("failed to remove stuck installation container")
+			continue
+		}
+		if !ok {
+			l.Info("removed installation container for server that does not exist on this node")
+			continue
+		}
+
+		if err := s.SyncInstallState(successful, false); err != nil {
+			l.WithField("error", err).Error("failed to notify panel of recovered installation state")
+			continue
+		}
+		s.Events().Publish(server.InstallCompletedEvent, "")
+		l.WithField("running_for", age.Round(time.Second)).WithField("was_running", running).WithField("successful", successful).Info("recovered stuck server installation")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+type logRetentionCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+}
+
+// Run executes the log retention cron, pruning the log files of every server
+// on the node according to the configured limits. A failure for one server is
+// logged and does not prevent the others from being pruned.
+func (lc *logRetentionCron) Run(ctx context.Context) error {
+	if !lc.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer lc.mu.Store(false)
+
+	r := config.Get().System.ServerLogRetention
+	maxSize := r.MaxSizeMB * 1024 * 1024
+	maxAge := time.Duration(r.MaxAgeDays) * 24 * time.Hour
+
+	for _, s := range lc.manager.All() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		res, err := s.Filesystem().PruneLogs(r.Directories, maxSize, maxAge, time.Now())
+		l := log.WithField("subsystem", "cron").WithField("cron", "log_retention").WithField("server", s.ID())
+		if err != nil {
+			l.WithField("error", err).Warn("failed to prune server log files")
+			continue
+		}
+		if len(res.Removed) > 0 || len(res.Rotated) > 0 {
+			l.WithField("removed", res.Removed).WithField("rotated", res.Rotated).Info("pruned server log files")
+		}
+	}
+	return nil
+}
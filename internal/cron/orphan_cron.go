@@ -0,0 +1,68 @@
+package cron
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+type orphanCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+	remove  bool
+}
+
+// Run executes the orphaned container cron. Any server container on the system
+// that does not belong to a server on this node is checked against the Panel,
+// and only if the Panel confirms that the server no longer exists is the
+// container stopped and removed.
+func (oc *orphanCron) Run(ctx context.Context) error {
+	if !oc.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer oc.mu.Store(false)
+
+	containers, err := environment.ServerContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if len(c.Names) == 0 {
+			continue
+		}
+		uuid := strings.TrimPrefix(c.Names[0], "/")
+		if _, ok := oc.manager.Get(uuid); ok {
+			continue
+		}
+
+		l := log.WithField("subsystem", "cron").WithField("cron", "orphaned_containers").WithField("container", c.ID).WithField("server", uuid)
+		if _, err := oc.manager.Client().GetServerConfiguration(ctx, uuid); err == nil {
+			l.Debug("container does not belong to a server on this node but the server exists on the Panel, skipping")
+			continue
+		} else if rerr := remote.AsRequestError(err); rerr == nil || rerr.StatusCode() != http.StatusNotFound {
+			l.WithField("error", err).Warn("failed to confirm with the Panel that the server for the container was deleted, skipping")
+			continue
+		}
+
+		l.WithField("state", c.State).WithField("remove", oc.remove).Info("stopping container for server that no longer exists on the Panel")
+		if err := environment.RemoveOrphanedContainer(ctx, c.ID, oc.remove); err != nil {
+			l.WithField("error", err).Error("failed to clean up orphaned server container")
+			continue
+		}
+		if oc.remove {
+			l.Info("removed orphaned server container")
+		} else {
+			l.Info("stopped orphaned server container")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package cron
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+type ownershipCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+}
+
+// Run executes the ownership normalization cron. Servers that are running, or
+// that are being installed, transferred or restored, are skipped since their
+// files are still being written.
+func (oc *ownershipCron) Run(ctx context.Context) error {
+	if !oc.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer oc.mu.Store(false)
+
+	for _, s := range oc.manager.All() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.IsRunning() || s.IsInstalling() || s.IsTransferring() || s.IsRestoring() {
+			continue
+		}
+		if err := s.NormalizeOwnership(); err != nil {
+			log.WithField("subsystem", "cron").WithField("cron", "ownership").WithField("server", s.ID()).WithField("error", err).Warn("failed to normalize ownership of server files")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package cron
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+type reconcileCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+}
+
+// Run executes the state reconciliation cron, repairing each server whose recorded
+// state does not match the state of its container.
+func (rc *reconcileCron) Run(ctx context.Context) error {
+	if !rc.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer rc.mu.Store(false)
+
+	policy := config.Get().System.StateReconciliation.Policy
+	for _, s := range rc.manager.All() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.Reconcile(ctx, policy); err != nil {
+			log.WithField("subsystem", "cron").WithField("cron", "reconcile").WithField("server", s.ID()).WithField("error", err).Warn("failed to reconcile server state")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Package logforward ships the Wings log, the console output of servers and the
+// audit log to an external log collector.
+package logforward
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/metrics"
+	"github.com/pterodactyl/wings/internal/shipper"
+)
+
+const (
+	StreamDaemon  = "daemon"
+	StreamConsole = "console"
+	StreamAudit   = "audit"
+)
+
+// subsystem is the value of the "subsystem" field on log entries written by this
+// package, which are never forwarded so that failing to reach the collector does
+// not produce more entries to send to it.
+const subsystem = "logforward"
+
+// Entry is a single log entry that is forwarded to the collector.
+type Entry struct {
+	Time    time.Time  `json:"time"`
+	Stream  string     `json:"stream"`
+	Level   string     `json:"level,omitempty"`
+	Server  string     `json:"server,omitempty"`
+	Message string     `json:"message"`
+	Fields  log.Fields `json:"fields,omitempty"`
+}
+
+// entries holds the entries waiting to be sent to the collector.
+var entries shipper.Shipper[Entry]
+
+// Forward queues an entry to be sent to the collector. If forwarding is disabled,
+// the stream of the entry is not forwarded, or the queue is full the entry is
+// discarded. This never blocks.
+func Forward(e Entry) {
+	if !entries.Running() || !config.Get().System.LogForwarding.Forwards(e.Stream) {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	entries.Send(e)
+}
+
+// Dropped returns the number of entries that have been discarded because the
+// queue was full or because they could not be sent to the collector.
+func Dropped() int64 {
+	return entries.Dropped()
+}
+
+// Handler is a log handler that forwards the entries written to the Wings log.
+type Handler struct{}
+
+// HandleLog implements log.Handler.
+func (Handler) HandleLog(e *log.Entry) error {
+	if e.Fields.Get("subsystem") == subsystem {
+		return nil
+	}
+	entry := Entry{Time: e.Timestamp.UTC(), Stream: StreamDaemon, Level: e.Level.String(), Message: e.Message}
+	if len(e.Fields) > 0 {
+		entry.Fields = make(log.Fields, len(e.Fields))
+		for k, v := range e.Fields {
+			// Errors do not encode to JSON in a useful form.
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			entry.Fields[k] = v
+		}
+	}
+	Forward(entry)
+	return nil
+}
+
+// Start connects to the configured collector and begins sending entries to it in
+// the background until the context is canceled. If log forwarding is not enabled
+// this is a no-op.
+func Start(ctx context.Context) error {
+	cfg := config.Get().System.LogForwarding
+	if !cfg.Enabled {
+		return nil
+	}
+	s, err := newSink(cfg)
+	if err != nil {
+		return err
+	}
+	metrics.Register(func(w *metrics.Writer) {
+		w.Counter("wings_log_forwarding_dropped_total", "The number of log entries that were dropped because the forwarding queue was full.", metrics.Sample{Value: float64(Dropped())})
+	})
+	entries.Start(ctx, s, shipper.Options{
+		BufferSize:    cfg.BufferSize,
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: time.Duration(cfg.FlushInterval) * time.Second,
+		OnError: func(n int, err error) {
+			log.WithField("subsystem", subsystem).WithField("entries", n).WithField("error", err).Warn("failed to forward log entries to collector")
+		},
+	})
+	return nil
+}
+
+func newSink(cfg config.LogForwardingConfiguration) (shipper.Sink[Entry], error) {
+	client := &http.Client{Timeout: time.Second * 10}
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	switch cfg.Destination {
+	case config.LogForwardingElasticsearch:
+		return &elasticsearchSink{url: endpoint + "/_bulk", index: cfg.Index, client: client}, nil
+	case config.LogForwardingSyslog:
+		s, err := shipper.NewSyslogSink[Entry](cfg.SyslogNetwork, cfg.SyslogAddress, "wings")
+		if err != nil {
+			return nil, errors.Wrap(err, "logforward: failed to connect to syslog")
+		}
+		return s, nil
+	default:
+		return &lokiSink{url: endpoint + "/loki/api/v1/push", client: client}, nil
+	}
+}
+
+// post sends a request body to a collector and checks that it was accepted.
+func post(client *http.Client, url, contentType string, body []byte) error {
+	return errors.Wrap(shipper.Post(client, url, contentType, body), "logforward: failed to send entries to collector")
+}
+
+type lokiSink struct {
+	url    string
+	client *http.Client
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// encode groups the entries into Loki streams labelled by the log stream and
+// server, with each line being the JSON encoded entry.
+func (s *lokiSink) encode(entries []Entry) ([]byte, error) {
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, e := range entries {
+		key := e.Stream + "/" + e.Server
+		st, ok := streams[key]
+		if !ok {
+			labels := map[string]string{"job": "wings", "stream": e.Stream}
+			if e.Server != "" {
+				labels["server"] = e.Server
+			}
+			st = &lokiStream{Stream: labels}
+			streams[key] = st
+			order = append(order, key)
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), string(line)})
+	}
+	out := make([]*lokiStream, len(order))
+	for i, k := range order {
+		out[i] = streams[k]
+	}
+	b, err := json.Marshal(map[string]interface{}{"streams": out})
+	return b, errors.WithStack(err)
+}
+
+func (s *lokiSink) Write(entries []Entry) error {
+	b, err := s.encode(entries)
+	if err != nil {
+		return err
+	}
+	return post(s.client, s.url, "application/json", b)
+}
+
+func (s *lokiSink) Close() error {
+	return nil
+}
+
+type elasticsearchSink struct {
+	url    string
+	index  string
+	client *http.Client
+}
+
+// encode returns the entries as the body of a bulk index request.
+func (s *elasticsearchSink) encode(entries []Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	action := map[string]map[string]string{"index": {"_index": s.index}}
+	for _, e := range entries {
+		if err := enc.Encode(action); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := enc.Encode(e); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *elasticsearchSink) Write(entries []Entry) error {
+	b, err := s.encode(entries)
+	if err != nil {
+		return err
+	}
+	return post(s.client, s.url, "application/x-ndjson", b)
+}
+
+func (s *elasticsearchSink) Close() error {
+	return nil
+}
@@ -0,0 +1,49 @@
+package logforward
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/goccy/go-json"
+)
+
+func TestSinks(t *testing.T) {
+	g := goblin.Goblin(t)
+	now := time.Unix(1700000000, 0).UTC()
+	entries := []Entry{
+		{Time: now, Stream: StreamDaemon, Level: "info", Message: "booting"},
+		{Time: now, Stream: StreamConsole, Server: "abc", Message: "Done!"},
+		{Time: now, Stream: StreamConsole, Server: "abc", Message: "Saving..."},
+	}
+
+	g.Describe("lokiSink", func() {
+		g.It("groups entries into streams by stream and server", func() {
+			b, err := (&lokiSink{}).encode(entries)
+			g.Assert(err).IsNil()
+
+			var body struct {
+				Streams []lokiStream `json:"streams"`
+			}
+			g.Assert(json.Unmarshal(b, &body)).IsNil()
+			g.Assert(len(body.Streams)).Equal(2)
+			g.Assert(body.Streams[0].Stream).Equal(map[string]string{"job": "wings", "stream": "daemon"})
+			g.Assert(body.Streams[1].Stream["server"]).Equal("abc")
+			g.Assert(len(body.Streams[1].Values)).Equal(2)
+			g.Assert(body.Streams[1].Values[0][0]).Equal("1700000000000000000")
+		})
+	})
+
+	g.Describe("elasticsearchSink", func() {
+		g.It("writes an index action before each entry", func() {
+			b, err := (&elasticsearchSink{index: "wings"}).encode(entries)
+			g.Assert(err).IsNil()
+
+			lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+			g.Assert(len(lines)).Equal(6)
+			g.Assert(lines[0]).Equal(`{"index":{"_index":"wings"}}`)
+			g.Assert(strings.Contains(lines[1], `"message":"booting"`)).IsTrue()
+		})
+	})
+}
@@ -0,0 +1,107 @@
+// Package masking replaces the values of secrets, such as the passwords and API
+// keys in the environment variables of servers, in text that leaves Wings through
+// its logs.
+package masking
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apex/log"
+)
+
+// Mask is the text that secrets are replaced with.
+const Mask = "***"
+
+// minLength is the length a value must be to be masked. Shorter values, such as
+// "1" or "on", would mask unrelated text everywhere.
+const minLength = 4
+
+var (
+	mu       sync.Mutex
+	secrets  = make(map[string][]string)
+	replacer atomic.Pointer[strings.Replacer]
+)
+
+// Set registers the secret values of a source, such as the UUID of a server,
+// replacing any that were previously registered for it.
+func Set(source string, values []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(values) == 0 {
+		delete(secrets, source)
+	} else {
+		secrets[source] = values
+	}
+	rebuild()
+}
+
+// Remove removes all the secret values registered for a source.
+func Remove(source string) {
+	Set(source, nil)
+}
+
+// rebuild creates the replacer for the registered secrets. Longer values are
+// placed first so that a secret containing another is masked in full.
+func rebuild() {
+	seen := make(map[string]bool)
+	var values []string
+	for _, vs := range secrets {
+		for _, v := range vs {
+			if len(v) >= minLength && !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+	if len(values) == 0 {
+		replacer.Store(nil)
+		return
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	pairs := make([]string, 0, len(values)*2)
+	for _, v := range values {
+		pairs = append(pairs, v, Mask)
+	}
+	replacer.Store(strings.NewReplacer(pairs...))
+}
+
+// String returns the string provided with every registered secret replaced.
+func String(s string) string {
+	if r := replacer.Load(); r != nil {
+		return r.Replace(s)
+	}
+	return s
+}
+
+// Handler is a log.Handler that masks the registered secrets in the message and
+// fields of each entry before passing it to the wrapped handler.
+type Handler struct {
+	Handler log.Handler
+}
+
+// HandleLog implements log.Handler.
+func (h Handler) HandleLog(e *log.Entry) error {
+	if replacer.Load() == nil {
+		return h.Handler.HandleLog(e)
+	}
+	masked := *e
+	masked.Message = String(e.Message)
+	if len(e.Fields) > 0 {
+		masked.Fields = make(log.Fields, len(e.Fields))
+		for k, v := range e.Fields {
+			switch t := v.(type) {
+			case string:
+				v = String(t)
+			case error:
+				v = String(t.Error())
+			}
+			masked.Fields[k] = v
+		}
+	}
+	return h.Handler.HandleLog(&masked)
+}
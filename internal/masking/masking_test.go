@@ -0,0 +1,60 @@
+package masking
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/franela/goblin"
+)
+
+type memoryHandler struct {
+	entries []*log.Entry
+}
+
+func (h *memoryHandler) HandleLog(e *log.Entry) error {
+	h.entries = append(h.entries, e)
+	return nil
+}
+
+func TestMasking(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("String", func() {
+		g.AfterEach(func() {
+			Remove("a")
+			Remove("b")
+		})
+
+		g.It("replaces the registered secrets", func() {
+			Set("a", []string{"hunter22", "abc"})
+			Set("b", []string{"hunter2"})
+			g.Assert(String("rcon password is hunter22, hunter2 and abc")).Equal("rcon password is ***, *** and abc")
+		})
+
+		g.It("stops masking the secrets of a removed source", func() {
+			Set("a", []string{"hunter22"})
+			Remove("a")
+			g.Assert(String("hunter22")).Equal("hunter22")
+		})
+	})
+
+	g.Describe("Handler", func() {
+		g.It("masks the message and fields of entries", func() {
+			Set("a", []string{"s3cr3t-key"})
+			defer Remove("a")
+
+			mem := &memoryHandler{}
+			e := &log.Entry{
+				Message: "using s3cr3t-key",
+				Fields:  log.Fields{"env": "KEY=s3cr3t-key", "error": errors.New("bad key s3cr3t-key")},
+			}
+			g.Assert(Handler{Handler: mem}.HandleLog(e)).IsNil()
+
+			g.Assert(mem.entries[0].Message).Equal("using ***")
+			g.Assert(mem.entries[0].Fields["env"]).Equal("KEY=***")
+			g.Assert(mem.entries[0].Fields["error"]).Equal("bad key ***")
+			g.Assert(e.Message).Equal("using s3cr3t-key")
+		})
+	})
+}
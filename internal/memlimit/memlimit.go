@@ -0,0 +1,113 @@
+// Package memlimit limits the memory used by the Wings process itself, so that
+// a leak or a burst of large requests cannot exhaust the memory of the host and
+// take every server on the node down with it.
+package memlimit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+)
+
+// pressureThreshold is the fraction of the soft limit at which Wings considers
+// itself to be under memory pressure.
+const pressureThreshold = 0.9
+
+var pressure atomic.Bool
+
+// UnderPressure returns whether the memory used by Wings is approaching the
+// soft limit, in which case new memory intensive work should be rejected.
+func UnderPressure() bool {
+	return pressure.Load()
+}
+
+// Configure sets the soft memory limit of the Go runtime, which makes the
+// garbage collector work harder as it is approached, and the hard limit of the
+// cgroup that Wings is running in if one is provided. Limits are in bytes.
+func Configure(soft, hard int64) error {
+	if soft > 0 {
+		debug.SetMemoryLimit(soft)
+	}
+	if hard > 0 {
+		p, err := cgroupLimitPath()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, []byte(strconv.FormatInt(hard, 10)), 0o644); err != nil {
+			return errors.Wrap(err, "memlimit: failed to set cgroup memory limit")
+		}
+	}
+	return nil
+}
+
+// cgroupLimitPath returns the path of the file that sets the memory limit for
+// the cgroup of this process, for either cgroup v2 or v1.
+func cgroupLimitPath() (string, error) {
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", errors.Wrap(err, "memlimit: failed to read cgroup of process")
+	}
+	var v1 string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return filepath.Join("/sys/fs/cgroup", parts[2], "memory.max"), nil
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if c == "memory" {
+				v1 = filepath.Join("/sys/fs/cgroup/memory", parts[2], "memory.limit_in_bytes")
+			}
+		}
+	}
+	if v1 == "" {
+		return "", errors.New("memlimit: process is not in a memory cgroup")
+	}
+	return v1, nil
+}
+
+// Monitor periodically checks the memory used by Wings against the soft limit
+// until the context is canceled. When the usage crosses the pressure threshold
+// memory that has been freed is returned to the operating system and
+// UnderPressure reports true until the usage falls again.
+func Monitor(ctx context.Context, soft int64, interval time.Duration) {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		metrics.Read(samples)
+		used := int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+		under := float64(used) >= float64(soft)*pressureThreshold
+		if under == pressure.Load() {
+			continue
+		}
+		pressure.Store(under)
+		l := log.WithField("used_mb", used/1024/1024).WithField("limit_mb", soft/1024/1024)
+		if under {
+			l.Warn("wings is approaching its memory limit, rejecting new uploads and remote downloads until memory is freed")
+			debug.FreeOSMemory()
+		} else {
+			l.Info("wings memory usage has recovered, accepting new uploads and remote downloads")
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package metrics implements a small Prometheus exposition endpoint for Wings,
+// covering the node, its servers, and the operations Wings performs against the
+// Docker daemon and the SFTP server.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Labels are the labels attached to a single sample of a metric.
+type Labels map[string]string
+
+// Sample is a single value of a metric.
+type Sample struct {
+	Labels Labels
+	Value  float64
+}
+
+// Collector writes metrics to the writer each time the endpoint is scraped.
+type Collector func(w *Writer)
+
+var (
+	mu         sync.RWMutex
+	collectors []Collector
+
+	sftpSessions int64
+
+	throttleMu       sync.Mutex
+	throttleTriggers = make(map[string]uint64)
+
+	dockerMu  sync.Mutex
+	dockerOps = make(map[string]*summary)
+)
+
+type summary struct {
+	count uint64
+	sum   float64
+}
+
+// Register adds a collector that is called every time metrics are scraped.
+func Register(c Collector) {
+	mu.Lock()
+	defer mu.Unlock()
+	collectors = append(collectors, c)
+}
+
+// ConsoleThrottled records that the console output of a server was throttled.
+func ConsoleThrottled(server string) {
+	throttleMu.Lock()
+	defer throttleMu.Unlock()
+	throttleTriggers[server]++
+}
+
+// RemoveServer removes the metrics tracked for a server, which should be called
+// once the server is removed from the node.
+func RemoveServer(server string) {
+	throttleMu.Lock()
+	defer throttleMu.Unlock()
+	delete(throttleTriggers, server)
+}
+
+// SftpSessionOpened records that an SFTP session has been opened, the returned
+// function must be called once the session is closed.
+func SftpSessionOpened() func() {
+	atomic.AddInt64(&sftpSessions, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			atomic.AddInt64(&sftpSessions, -1)
+		})
+	}
+}
+
+// ObserveDockerOperation records the time taken by an operation against the
+// Docker daemon that was started at the given time. This is intended to be
+// deferred at the start of the operation.
+func ObserveDockerOperation(operation string, start time.Time) {
+	d := time.Since(start).Seconds()
+
+	dockerMu.Lock()
+	defer dockerMu.Unlock()
+	s, ok := dockerOps[operation]
+	if !ok {
+		s = &summary{}
+		dockerOps[operation] = s
+	}
+	s.count++
+	s.sum += d
+}
+
+// Handler returns a http.Handler that serves all of the metrics in the
+// Prometheus text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w := NewWriter(rw)
+		collect(w)
+		_ = w.Flush()
+	})
+}
+
+// collect writes the metrics tracked by this package followed by those of the
+// registered collectors.
+func collect(w *Writer) {
+	w.Gauge("wings_sftp_sessions", "The number of open SFTP sessions.", Sample{Value: float64(atomic.LoadInt64(&sftpSessions))})
+
+	throttleMu.Lock()
+	throttled := make([]Sample, 0, len(throttleTriggers))
+	for server, n := range throttleTriggers {
+		throttled = append(throttled, Sample{Labels: Labels{"server": server}, Value: float64(n)})
+	}
+	throttleMu.Unlock()
+	w.Counter("wings_console_throttle_triggers_total", "The number of times the console output of a server has been throttled.", throttled...)
+
+	dockerMu.Lock()
+	counts := make([]Sample, 0, len(dockerOps))
+	sums := make([]Sample, 0, len(dockerOps))
+	for op, s := range dockerOps {
+		counts = append(counts, Sample{Labels: Labels{"operation": op}, Value: float64(s.count)})
+		sums = append(sums, Sample{Labels: Labels{"operation": op}, Value: s.sum})
+	}
+	dockerMu.Unlock()
+	w.Summary("wings_docker_operation_duration_seconds", "The time taken by operations against the Docker daemon.", counts, sums)
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, c := range collectors {
+		c(w)
+	}
+}
+
+// Writer writes metrics in the Prometheus text exposition format.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter returns a new Writer around w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Flush writes any buffered metrics to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Gauge writes a metric with a value that can go up and down.
+func (w *Writer) Gauge(name, help string, samples ...Sample) {
+	w.metric(name, help, "gauge", samples)
+}
+
+// Counter writes a metric with a value that only increases.
+func (w *Writer) Counter(name, help string, samples ...Sample) {
+	w.metric(name, help, "counter", samples)
+}
+
+// Summary writes a summary metric from the count and sum of its observations,
+// the samples of both must be in the same order.
+func (w *Writer) Summary(name, help string, counts, sums []Sample) {
+	w.header(name, help, "summary")
+	w.samples(name+"_count", counts)
+	w.samples(name+"_sum", sums)
+}
+
+func (w *Writer) metric(name, help, typ string, samples []Sample) {
+	w.header(name, help, typ)
+	w.samples(name, samples)
+}
+
+func (w *Writer) header(name, help, typ string) {
+	fmt.Fprintf(w.w, "# HELP %s %s\n# TYPE %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help), name, typ)
+}
+
+func (w *Writer) samples(name string, samples []Sample) {
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Labels.String() < samples[j].Labels.String()
+	})
+	for _, s := range samples {
+		fmt.Fprintf(w.w, "%s%s %s\n", name, s.Labels.String(), formatValue(s.Value))
+	}
+}
+
+// String returns the labels in the exposition format, sorted by name.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(r.Replace(l[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
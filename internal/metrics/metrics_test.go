@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/internal/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("Writer", func() {
+		g.It("writes samples sorted by their labels", func() {
+			var b bytes.Buffer
+			w := metrics.NewWriter(&b)
+			w.Gauge("test_metric", "A test metric.",
+				metrics.Sample{Labels: metrics.Labels{"server": "b"}, Value: 2},
+				metrics.Sample{Labels: metrics.Labels{"server": "a"}, Value: 1.5},
+			)
+			g.Assert(w.Flush()).IsNil()
+			g.Assert(b.String()).Equal("# HELP test_metric A test metric.\n# TYPE test_metric gauge\ntest_metric{server=\"a\"} 1.5\ntest_metric{server=\"b\"} 2\n")
+		})
+
+		g.It("escapes label values", func() {
+			g.Assert(metrics.Labels{"b": "x\"y", "a": "z\\"}.String()).Equal(`{a="z\\",b="x\"y"}`)
+		})
+	})
+
+	g.Describe("Handler", func() {
+		g.It("includes the built-in and registered metrics", func() {
+			metrics.ConsoleThrottled("server-uuid")
+			closed := metrics.SftpSessionOpened()
+			metrics.ObserveDockerOperation("start", time.Now())
+			metrics.Register(func(w *metrics.Writer) {
+				w.Counter("registered_total", "A registered metric.", metrics.Sample{Value: 3})
+			})
+
+			rec := httptest.NewRecorder()
+			metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			body := rec.Body.String()
+			g.Assert(strings.Contains(body, "wings_sftp_sessions 1\n")).IsTrue()
+			g.Assert(strings.Contains(body, "wings_console_throttle_triggers_total{server=\"server-uuid\"} 1\n")).IsTrue()
+			g.Assert(strings.Contains(body, "wings_docker_operation_duration_seconds_count{operation=\"start\"} 1\n")).IsTrue()
+			g.Assert(strings.Contains(body, "registered_total 3\n")).IsTrue()
+
+			closed()
+			closed()
+			rec = httptest.NewRecorder()
+			metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			g.Assert(strings.Contains(rec.Body.String(), "wings_sftp_sessions 0\n")).IsTrue()
+		})
+
+		g.It("drops the metrics of removed servers", func() {
+			metrics.ConsoleThrottled("removed-uuid")
+			metrics.RemoveServer("removed-uuid")
+
+			rec := httptest.NewRecorder()
+			metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			g.Assert(strings.Contains(rec.Body.String(), "removed-uuid")).IsFalse()
+		})
+	})
+}
@@ -0,0 +1,109 @@
+// Package readiness implements the dependency checks that are run when Wings
+// boots, before it begins accepting connections over the API and SFTP server.
+package readiness
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// Gate is a single dependency that must be available before Wings is ready.
+type Gate struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// GateResult is the outcome of the last time a gate was checked.
+type GateResult struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Error    string `json:"error,omitempty"`
+	Attempts int    `json:"attempts"`
+}
+
+// Result is the outcome of waiting for all the gates to pass.
+type Result struct {
+	// Ready is true if all gates passed before the maximum wait elapsed.
+	Ready bool `json:"ready"`
+	// Degraded is true if Wings continued booting without all gates passing.
+	Degraded  bool         `json:"degraded"`
+	Gates     []GateResult `json:"gates"`
+	StartedAt time.Time    `json:"started_at"`
+	Duration  string       `json:"duration"`
+}
+
+var (
+	mu   sync.RWMutex
+	last *Result
+)
+
+// Last returns the result of the most recent call to Wait, or nil if Wait has
+// not yet completed.
+func Last() *Result {
+	mu.RLock()
+	defer mu.RUnlock()
+	return last
+}
+
+// Wait checks each of the gates provided until they have all passed, retrying the
+// failing gates every interval until maxWait has elapsed. If the gates do not all
+// pass in time a warning is logged and a degraded result is returned rather than
+// an error, allowing Wings to continue booting.
+func Wait(ctx context.Context, maxWait, interval time.Duration, gates ...Gate) *Result {
+	start := time.Now()
+	res := &Result{StartedAt: start, Gates: make([]GateResult, len(gates))}
+	for i, g := range gates {
+		res.Gates[i].Name = g.Name
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	for {
+		pending := 0
+		for i, g := range gates {
+			r := &res.Gates[i]
+			if r.Passed {
+				continue
+			}
+			r.Attempts++
+			if err := g.Check(ctx); err != nil {
+				r.Error = err.Error()
+				pending++
+				log.WithField("gate", g.Name).WithField("error", err).Debug("readiness gate has not passed yet")
+				continue
+			}
+			r.Passed = true
+			r.Error = ""
+		}
+		if pending == 0 {
+			res.Ready = true
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+			continue
+		}
+		break
+	}
+
+	res.Degraded = !res.Ready
+	res.Duration = time.Since(start).String()
+	for _, r := range res.Gates {
+		l := log.WithField("gate", r.Name).WithField("attempts", r.Attempts)
+		if r.Passed {
+			l.Info("readiness gate passed")
+		} else {
+			l.WithField("error", r.Error).Warn("readiness gate did not pass in time, continuing in degraded mode")
+		}
+	}
+
+	mu.Lock()
+	last = res
+	mu.Unlock()
+	return res
+}
@@ -0,0 +1,181 @@
+// Package shipper queues items and writes them to a destination in batches from a
+// background goroutine, so that the code producing them is never blocked by the
+// destination. It is shared by the audit log and log forwarding.
+package shipper
+
+import (
+	"bytes"
+	"context"
+	"log/syslog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/goccy/go-json"
+)
+
+// Sink writes a batch of items to a destination.
+type Sink[T any] interface {
+	Write(items []T) error
+	Close() error
+}
+
+// Options defines how items are queued and batched.
+type Options struct {
+	// BufferSize is the number of items that may be queued before further items
+	// are dropped.
+	BufferSize int
+	// BatchSize is the maximum number of items written to the sink at once.
+	BatchSize int
+	// FlushInterval is the maximum amount of time an item is queued for before it
+	// is written to the sink.
+	FlushInterval time.Duration
+	// OnError is called with the number of items in a batch and the error when the
+	// batch could not be written to the sink.
+	OnError func(n int, err error)
+}
+
+// Shipper queues items and writes them to a sink in batches. Items sent before
+// Start is called are discarded.
+type Shipper[T any] struct {
+	mu      sync.Mutex
+	queue   chan T
+	dropped atomic.Int64
+}
+
+// Start begins writing the items that are sent to the sink in the background until
+// the context is canceled, at which point any items still queued are written and
+// the sink is closed.
+func (s *Shipper[T]) Start(ctx context.Context, sink Sink[T], o Options) {
+	q := make(chan T, o.BufferSize)
+	s.mu.Lock()
+	s.queue = q
+	s.mu.Unlock()
+	go s.run(ctx, sink, q, o)
+}
+
+// Running returns true if Start has been called.
+func (s *Shipper[T]) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queue != nil
+}
+
+// Send queues an item to be written to the sink, returning false if the item was
+// discarded because the queue is full or the shipper is not running. This never
+// blocks.
+func (s *Shipper[T]) Send(v T) bool {
+	s.mu.Lock()
+	q := s.queue
+	s.mu.Unlock()
+	if q == nil {
+		return false
+	}
+	select {
+	case q <- v:
+		return true
+	default:
+		s.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns the number of items that have been discarded because the queue
+// was full or because they could not be written to the sink.
+func (s *Shipper[T]) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// run writes queued items to the sink in batches. A batch is written once it is
+// full, or once the flush interval passes if there are any items waiting.
+func (s *Shipper[T]) run(ctx context.Context, sink Sink[T], q chan T, o Options) {
+	defer sink.Close()
+	t := time.NewTicker(o.FlushInterval)
+	defer t.Stop()
+	batch := make([]T, 0, o.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := sink.Write(batch); err != nil {
+			s.dropped.Add(int64(len(batch)))
+			if o.OnError != nil {
+				o.OnError(len(batch), err)
+			}
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			// Write anything that is still waiting before exiting.
+			for {
+				select {
+				case v := <-q:
+					batch = append(batch, v)
+					if len(batch) == o.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case v := <-q:
+			batch = append(batch, v)
+			if len(batch) == o.BatchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		}
+	}
+}
+
+// Post sends a request body to an HTTP destination and checks that it was
+// accepted.
+func Post(client *http.Client, url, contentType string, body []byte) error {
+	res, err := client.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// SyslogSink writes each item to syslog as JSON.
+type SyslogSink[T any] struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog server at the address provided, using the
+// local syslog server if the network is empty.
+func NewSyslogSink[T any](network, address, tag string) (*SyslogSink[T], error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &SyslogSink[T]{w: w}, nil
+}
+
+func (s *SyslogSink[T]) Write(items []T) error {
+	for _, v := range items {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := s.w.Info(string(b)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink[T]) Close() error {
+	return s.w.Close()
+}
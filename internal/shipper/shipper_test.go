@@ -0,0 +1,78 @@
+package shipper
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/franela/goblin"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	batches [][]int
+	err     error
+	closed  bool
+}
+
+func (s *memorySink) Write(items []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]int{}, items...))
+	return s.err
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestShipper(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("Shipper", func() {
+		g.It("discards items until it is started", func() {
+			var s Shipper[int]
+			g.Assert(s.Running()).IsFalse()
+			g.Assert(s.Send(1)).IsFalse()
+			g.Assert(s.Dropped()).Equal(int64(0))
+		})
+
+		g.It("writes queued items in batches before exiting", func() {
+			s := &Shipper[int]{}
+			sink := &memorySink{}
+			q := make(chan int, 25)
+			for i := 0; i < 25; i++ {
+				q <- i
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			s.run(ctx, sink, q, Options{BatchSize: 10, FlushInterval: time.Second})
+
+			g.Assert(len(sink.batches)).Equal(3)
+			g.Assert(len(sink.batches[0])).Equal(10)
+			g.Assert(len(sink.batches[2])).Equal(5)
+			g.Assert(sink.closed).IsTrue()
+		})
+
+		g.It("counts items that could not be written as dropped", func() {
+			s := &Shipper[int]{}
+			sink := &memorySink{err: errors.New("unavailable")}
+			q := make(chan int, 5)
+			for i := 0; i < 5; i++ {
+				q <- i
+			}
+
+			var failed int
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			s.run(ctx, sink, q, Options{BatchSize: 10, FlushInterval: time.Second, OnError: func(n int, _ error) { failed += n }})
+
+			g.Assert(failed).Equal(5)
+			g.Assert(s.Dropped()).Equal(int64(5))
+		})
+	})
+}
@@ -0,0 +1,158 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// ErrCircuitOpen is returned when a request is not sent to the Panel because too
+// many of the previous requests to it failed.
+const ErrCircuitOpen = errors.Sentinel("remote: circuit breaker is open, not sending request to the panel")
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreakerState is the state of the circuit breaker around requests to the
+// Panel.
+type CircuitBreakerState struct {
+	// State is "closed" while requests are sent normally, "open" while requests are
+	// refused, and "half_open" once the open duration has passed and a limited number
+	// of requests are sent to probe whether the Panel has recovered.
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at"`
+	Trips    uint64     `json:"trips"`
+}
+
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	duration  time.Duration
+	probes    int
+	state     string
+	failures  int
+	openedAt  time.Time
+	inflight  int
+	trips     uint64
+}
+
+// WithCircuitBreaker stops requests from being sent to the Panel once the number
+// of consecutive failed requests reaches the configured threshold, giving it time
+// to recover rather than retrying against it.
+func WithCircuitBreaker(cfg config.CircuitBreaker) ClientOption {
+	return func(c *client) {
+		if !cfg.Enabled {
+			c.breaker = nil
+			return
+		}
+		c.breaker = &breaker{
+			threshold: cfg.FailureThreshold,
+			duration:  time.Duration(cfg.OpenDuration) * time.Second,
+			probes:    cfg.HalfOpenProbes,
+			state:     CircuitClosed,
+		}
+	}
+}
+
+// allow returns ErrCircuitOpen if a request should not be sent to the Panel. Once
+// the open duration has passed the breaker is moved to half-open, and only the
+// configured number of probes may be in flight at once.
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.duration {
+		b.state = CircuitHalfOpen
+		b.inflight = 0
+	}
+	switch b.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.inflight >= b.probes {
+			return ErrCircuitOpen
+		}
+		b.inflight++
+	}
+	return nil
+}
+
+// record updates the breaker using the result of a request that was allowed. Only
+// requests that could not reach the Panel, or that it failed with a 5XX response,
+// count as failures. Canceled requests are not counted either way.
+func (b *breaker) record(res *Response, err error) {
+	if b == nil {
+		return
+	}
+	failed := err != nil || res.StatusCode >= 500
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if errors.Is(err, context.Canceled) {
+		if b.state == CircuitHalfOpen {
+			b.inflight--
+		}
+		return
+	}
+	if b.state == CircuitHalfOpen {
+		b.inflight--
+		if failed {
+			b.trip()
+		} else {
+			log.Info("remote: panel requests succeeded again, closing circuit breaker")
+			b.state = CircuitClosed
+			b.failures = 0
+		}
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitClosed && b.failures >= b.threshold {
+		log.WithField("failures", b.failures).WithField("open_duration", b.duration).
+			Warn("remote: too many failed requests to the panel, opening circuit breaker")
+		b.trip()
+	}
+}
+
+// trip opens the breaker. The caller must hold the lock.
+func (b *breaker) trip() {
+	b.state = CircuitOpen
+	b.openedAt = time.Now()
+	b.inflight = 0
+	b.trips++
+}
+
+// snapshot returns the current state of the breaker.
+func (b *breaker) snapshot() *CircuitBreakerState {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := &CircuitBreakerState{State: b.state, Failures: b.failures, Trips: b.trips}
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.duration {
+		out.State = CircuitHalfOpen
+	}
+	if !b.openedAt.IsZero() {
+		t := b.openedAt
+		out.OpenedAt = &t
+	}
+	return out
+}
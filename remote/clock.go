@@ -0,0 +1,106 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// ErrClockSkew is returned when an operation that depends on the time is refused
+// because the clock of this node differs too much from the clock of the Panel.
+const ErrClockSkew = errors.Sentinel("remote: clock differs from the panel by more than the configured threshold")
+
+// ClockSkew is the difference between the clock of the Panel and the clock of this
+// node, measured using the Date header of responses from the Panel.
+type ClockSkew struct {
+	// Skew is the number of seconds the clock of this node is behind the clock of the
+	// Panel, or negative if it is ahead. This is only accurate to about a second since
+	// the Date header does not include fractions of a second.
+	Skew float64 `json:"skew"`
+	// Threshold is the configured number of seconds the clocks may differ by, and
+	// Exceeded is true if the last measurement was beyond it.
+	Threshold  int        `json:"threshold"`
+	Exceeded   bool       `json:"exceeded"`
+	MeasuredAt *time.Time `json:"measured_at"`
+}
+
+var clock struct {
+	mu         sync.RWMutex
+	skew       time.Duration
+	measuredAt time.Time
+}
+
+// recordClockSkew measures the clock skew using the Date header of a response
+// from the Panel, comparing it to the time halfway between sending the request
+// and receiving the response.
+func recordClockSkew(sent time.Time, received time.Time, res *http.Response) {
+	if res == nil {
+		return
+	}
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// The Date header is truncated to the second, so the Panel generated it at some
+	// point during that second.
+	panel := date.Add(time.Millisecond * 500)
+	local := sent.Add(received.Sub(sent) / 2)
+
+	clock.mu.Lock()
+	clock.skew = panel.Sub(local)
+	clock.measuredAt = received
+	clock.mu.Unlock()
+}
+
+// CurrentClockSkew returns the most recent measurement of the clock skew between
+// this node and the Panel.
+func CurrentClockSkew() ClockSkew {
+	clock.mu.RLock()
+	defer clock.mu.RUnlock()
+
+	threshold := config.Get().System.ClockSkew.Threshold
+	out := ClockSkew{Threshold: threshold, Skew: clock.skew.Round(time.Millisecond).Seconds()}
+	if !clock.measuredAt.IsZero() {
+		t := clock.measuredAt
+		out.MeasuredAt = &t
+		out.Exceeded = clock.skew.Abs() > time.Duration(threshold)*time.Second
+	}
+	return out
+}
+
+// CheckClockSkew returns ErrClockSkew if the clock of this node differs from the
+// clock of the Panel by more than the threshold and the configuration refuses
+// time sensitive operations in that case. Operations are only refused while the
+// measurement is recent, within twice the check interval, so that a node is not
+// locked out by a measurement that may no longer be true once its clock is fixed.
+func CheckClockSkew() error {
+	cfg := config.Get().System.ClockSkew
+	if cfg.Action != config.ClockSkewActionRefuse {
+		return nil
+	}
+	s := CurrentClockSkew()
+	if !s.Exceeded || time.Since(*s.MeasuredAt) > 2*time.Duration(cfg.Interval)*time.Second {
+		return nil
+	}
+	return ErrClockSkew
+}
+
+// MeasureClockSkew makes a request to the Panel to measure the clock skew between
+// this node and the Panel, logging a warning if it exceeds the threshold.
+func (c *client) MeasureClockSkew(ctx context.Context) (ClockSkew, error) {
+	if _, _, err := c.getServersPaged(ctx, 1, 1); err != nil {
+		return ClockSkew{}, errors.WrapIf(err, "remote: failed to measure clock skew")
+	}
+	s := CurrentClockSkew()
+	if s.Exceeded {
+		log.WithField("skew", s.Skew).WithField("threshold", s.Threshold).
+			Warn("the clock of this node differs from the clock of the panel, tokens issued by the panel may be rejected; check that time synchronization (NTP) is working on both")
+	}
+	return s, nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	"github.com/goccy/go-json"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/system"
 )
 
@@ -33,6 +34,8 @@ type Client interface {
 	SetTransferStatus(ctx context.Context, uuid string, successful bool) error
 	ValidateSftpCredentials(ctx context.Context, request SftpAuthRequest) (SftpAuthResponse, error)
 	SendActivityLogs(ctx context.Context, activity []models.Activity) error
+	Connectivity() Connectivity
+	MeasureClockSkew(ctx context.Context) (ClockSkew, error)
 }
 
 type client struct {
@@ -41,6 +44,8 @@ type client struct {
 	tokenId     string
 	token       string
 	maxAttempts int
+	conn        *connectivity
+	breaker     *breaker
 }
 
 // New returns a new HTTP request client that is used for making authenticated
@@ -52,6 +57,7 @@ func New(base string, opts ...ClientOption) Client {
 			Timeout: time.Second * 15,
 		},
 		maxAttempts: 0,
+		conn:        &connectivity{behavior: config.PanelOfflineQueue, size: 100},
 	}
 	for _, opt := range opts {
 		opt(&c)
@@ -117,7 +123,11 @@ func (c *client) requestOnce(ctx context.Context, method, path string, body io.R
 
 	debugLogRequest(req)
 
+	sent := time.Now()
 	res, err := c.httpClient.Do(req)
+	if err == nil {
+		recordClockSkew(sent, time.Now(), res)
+	}
 	return &Response{res}, err
 }
 
@@ -125,7 +135,8 @@ func (c *client) requestOnce(ctx context.Context, method, path string, body io.R
 // encountered with the request it will be retried using an exponential backoff.
 // If the error returned from the Panel is due to API throttling or because there
 // are invalid authentication credentials provided the request will _not_ be
-// retried by the backoff.
+// retried by the backoff. Attempts are also stopped once the circuit breaker is
+// open, in which case ErrCircuitOpen is returned.
 //
 // This function automatically appends the path to the current client endpoint
 // and adds the required authentication headers to the request that is being
@@ -143,7 +154,13 @@ func (c *client) request(ctx context.Context, method, path string, body *bytes.B
 				return backoff.Permanent(errors.Wrap(err, "http: failed to copy body buffer"))
 			}
 		}
+		// Refuse to send the request while the circuit breaker is open, rather than
+		// adding to the load of a Panel that is already failing.
+		if err := c.breaker.allow(); err != nil {
+			return backoff.Permanent(err)
+		}
 		r, err := c.requestOnce(ctx, method, path, &b, opts...)
+		c.breaker.record(r, err)
 		if err != nil {
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 				return backoff.Permanent(err)
@@ -164,6 +181,11 @@ func (c *client) request(ctx context.Context, method, path string, body *bytes.B
 		}
 		return nil
 	}, c.backoff(ctx))
+	if v, ok := err.(*backoff.PermanentError); ok {
+		c.recordResult(v.Unwrap())
+	} else {
+		c.recordResult(err)
+	}
 	if err != nil {
 		if v, ok := err.(*backoff.PermanentError); ok {
 			return nil, v.Unwrap()
@@ -2,11 +2,16 @@ package remote
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
 )
 
 func createTestClient(h http.HandlerFunc) (*client, *httptest.Server) {
@@ -92,3 +97,176 @@ func TestPost(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, r)
 }
+
+func TestOfflineBehavior(t *testing.T) {
+	offlineRetryInterval = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	var paths, bodies []string
+	down := true
+	c, _ := createTestClient(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, string(b))
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	// Updates are queued while the Panel is unreachable and sent in order afterwards.
+	WithOfflineBehavior(config.PanelOfflineQueue, 10)(c)
+	assert.NoError(t, c.SetArchiveStatus(context.Background(), "a", false))
+	assert.NoError(t, c.SetArchiveStatus(context.Background(), "a", true))
+	assert.False(t, c.Connectivity().Online)
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	assert.Eventually(t, func() bool { return c.Connectivity().Pending == 0 }, time.Second, 5*time.Millisecond)
+	assert.True(t, c.Connectivity().Online)
+	mu.Lock()
+	assert.Equal(t, []string{"/servers/a/archive", "/servers/a/archive"}, paths)
+	assert.Equal(t, []string{`{"successful":false}`, `{"successful":true}`}, bodies)
+	bodies, down = nil, true
+	mu.Unlock()
+
+	// Only the latest update for a resource is kept when continuing, although an
+	// update that was already being sent may still reach the Panel first.
+	WithOfflineBehavior(config.PanelOfflineContinue, 10)(c)
+	assert.NoError(t, c.SetArchiveStatus(context.Background(), "a", false))
+	assert.NoError(t, c.SetArchiveStatus(context.Background(), "a", true))
+	assert.Equal(t, 1, c.Connectivity().Pending)
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	assert.Eventually(t, func() bool { return c.Connectivity().Pending == 0 }, time.Second, 5*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, `{"successful":true}`, bodies[len(bodies)-1])
+	down = true
+	mu.Unlock()
+
+	// Updates fail immediately once the Panel is known to be unreachable when rejecting.
+	WithOfflineBehavior(config.PanelOfflineReject, 10)(c)
+	assert.Error(t, c.SetArchiveStatus(context.Background(), "a", true))
+	assert.ErrorIs(t, c.SetArchiveStatus(context.Background(), "a", true), ErrPanelUnreachable)
+	assert.Equal(t, 0, c.Connectivity().Pending)
+}
+
+func TestOfflineQueueLimits(t *testing.T) {
+	offlineRetryInterval = 10 * time.Millisecond
+	defer func(n int) { offlineMaxAttempts = n }(offlineMaxAttempts)
+	offlineMaxAttempts = 3
+
+	var mu sync.Mutex
+	var bodies []string
+	status := http.StatusInternalServerError
+	c, _ := createTestClient(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		rw.WriteHeader(status)
+	})
+	WithOfflineBehavior(config.PanelOfflineQueue, 10)(c)
+
+	// Errors from the Panel itself are returned rather than queued.
+	assert.Error(t, c.SetTransferStatus(context.Background(), "a", true))
+	assert.Equal(t, 0, c.Connectivity().Pending)
+	mu.Lock()
+	// The transfer status is sent in the path, with the same empty body as ever.
+	assert.Equal(t, "null", bodies[0])
+	status = http.StatusServiceUnavailable
+	mu.Unlock()
+
+	// Updates that can never be sent are dropped after the maximum attempts.
+	assert.NoError(t, c.SetTransferStatus(context.Background(), "a", false))
+	assert.Equal(t, 1, c.Connectivity().Pending)
+	assert.Eventually(t, func() bool { return c.Connectivity().Pending == 0 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, uint64(1), c.Connectivity().Dropped)
+	mu.Lock()
+	for _, b := range bodies {
+		assert.Equal(t, "null", b)
+	}
+	mu.Unlock()
+}
+
+func TestClockSkew(t *testing.T) {
+	config.Set(&config.Configuration{AuthenticationToken: "test", System: config.SystemConfiguration{ClockSkew: config.ClockSkewCheck{Threshold: 30, Interval: 60, Action: config.ClockSkewActionRefuse}}})
+
+	offset := time.Duration(0)
+	c, _ := createTestClient(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Date", time.Now().Add(offset).UTC().Format(http.TimeFormat))
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	// A clock within the threshold of the Panel is allowed.
+	_, err := c.requestOnce(context.Background(), "", "/test", nil)
+	assert.NoError(t, err)
+	s := CurrentClockSkew()
+	assert.NotNil(t, s.MeasuredAt)
+	assert.False(t, s.Exceeded)
+	assert.InDelta(t, 0, s.Skew, 2)
+	assert.NoError(t, CheckClockSkew())
+
+	// A node whose clock is behind the Panel by more than the threshold is refused.
+	offset = time.Minute
+	_, err = c.requestOnce(context.Background(), "", "/test", nil)
+	assert.NoError(t, err)
+	s = CurrentClockSkew()
+	assert.True(t, s.Exceeded)
+	assert.InDelta(t, 60, s.Skew, 2)
+	assert.ErrorIs(t, CheckClockSkew(), ErrClockSkew)
+
+	// A measurement older than twice the interval no longer refuses tokens.
+	clock.mu.Lock()
+	clock.measuredAt = time.Now().Add(-121 * time.Second)
+	clock.mu.Unlock()
+	assert.True(t, CurrentClockSkew().Exceeded)
+	assert.NoError(t, CheckClockSkew())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	status := http.StatusBadGateway
+	c, _ := createTestClient(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		rw.WriteHeader(status)
+	})
+	WithCircuitBreaker(config.CircuitBreaker{Enabled: true, FailureThreshold: 2, OpenDuration: 1, HalfOpenProbes: 1})(c)
+	c.breaker.duration = 20 * time.Millisecond
+
+	// The breaker opens once the threshold is reached, which stops the retries of
+	// the request, and refuses further requests without sending them to the Panel.
+	_, err := c.Get(context.Background(), "/test", nil)
+	assert.Error(t, err)
+	_, err = c.Get(context.Background(), "/test", nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, CircuitOpen, c.breaker.snapshot().State)
+	mu.Lock()
+	assert.Equal(t, 2, calls)
+	mu.Unlock()
+
+	// A failed probe opens the breaker again.
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, c.breaker.snapshot().State)
+	_, err = c.Get(context.Background(), "/test", nil)
+	assert.Error(t, err)
+	assert.Equal(t, CircuitOpen, c.breaker.snapshot().State)
+
+	// A successful probe closes the breaker, and client errors do not count as failures.
+	mu.Lock()
+	status = http.StatusNotFound
+	mu.Unlock()
+	time.Sleep(30 * time.Millisecond)
+	_, err = c.Get(context.Background(), "/test", nil)
+	assert.Error(t, err)
+	assert.Equal(t, CircuitClosed, c.breaker.snapshot().State)
+	assert.Equal(t, 0, c.breaker.snapshot().Failures)
+	assert.GreaterOrEqual(t, c.breaker.snapshot().Trips, uint64(2))
+}
@@ -0,0 +1,294 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// ErrPanelUnreachable is returned when a status update is rejected because the
+// Panel could not be reached the last time a request was made to it.
+const ErrPanelUnreachable = errors.Sentinel("remote: panel is unreachable")
+
+// offlineRetryInterval is the amount of time to wait between attempts to send
+// pending status updates to the Panel while it is unreachable.
+var offlineRetryInterval = 30 * time.Second
+
+// offlineMaxAttempts is the number of times a pending status update is sent to
+// the Panel before it is dropped, so that a single update cannot block the rest
+// of the queue forever.
+var offlineMaxAttempts = 120
+
+// Connectivity is the state of the connection between Wings and the Panel.
+type Connectivity struct {
+	// Online is false if the last request to the Panel failed because it could
+	// not be reached.
+	Online          bool       `json:"online"`
+	OfflineBehavior string     `json:"offline_behavior"`
+	LastSuccess     *time.Time `json:"last_success"`
+	LastFailure     *time.Time `json:"last_failure"`
+	LastError       string     `json:"last_error,omitempty"`
+
+	// Pending is the number of status updates waiting to be sent to the Panel, and
+	// Dropped is the number that were discarded because the queue was full.
+	Pending int    `json:"pending"`
+	Dropped uint64 `json:"dropped"`
+
+	// CircuitBreaker is the state of the circuit breaker around requests to the
+	// Panel, or nil if it is disabled.
+	CircuitBreaker *CircuitBreakerState `json:"circuit_breaker"`
+}
+
+// pendingUpdate is a status update that could not be sent to the Panel.
+type pendingUpdate struct {
+	id       uint64
+	key      string
+	path     string
+	body     json.RawMessage
+	queuedAt time.Time
+	attempts int
+}
+
+type connectivity struct {
+	mu          sync.Mutex
+	behavior    string
+	size        int
+	offline     bool
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+	pending     []pendingUpdate
+	nextId      uint64
+	dropped     uint64
+	flushing    bool
+}
+
+// WithOfflineBehavior sets how status updates are handled while the Panel cannot
+// be reached, and the maximum number of updates that are kept to be sent later.
+func WithOfflineBehavior(behavior string, size int) ClientOption {
+	return func(c *client) {
+		c.conn = &connectivity{behavior: behavior, size: size}
+	}
+}
+
+// Connectivity returns the current state of the connection to the Panel.
+func (c *client) Connectivity() Connectivity {
+	if c.conn == nil {
+		return Connectivity{Online: true}
+	}
+	c.conn.mu.Lock()
+	defer c.conn.mu.Unlock()
+
+	out := Connectivity{
+		Online:          !c.conn.offline,
+		OfflineBehavior: c.conn.behavior,
+		LastError:       c.conn.lastError,
+		Pending:         len(c.conn.pending),
+		Dropped:         c.conn.dropped,
+		CircuitBreaker:  c.breaker.snapshot(),
+	}
+	if !c.conn.lastSuccess.IsZero() {
+		t := c.conn.lastSuccess
+		out.LastSuccess = &t
+	}
+	if !c.conn.lastFailure.IsZero() {
+		t := c.conn.lastFailure
+		out.LastFailure = &t
+	}
+	return out
+}
+
+// isUnreachable returns whether the error from a request indicates that the
+// Panel could not be reached, rather than the Panel rejecting the request. Only
+// transport errors and the responses of a proxy in front of an unavailable Panel
+// count, other 5XX responses are the Panel failing to handle the request.
+func isUnreachable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if rerr := AsRequestError(err); rerr != nil {
+		if rerr.response == nil {
+			return true
+		}
+		switch rerr.StatusCode() {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// recordResult updates the connectivity state using the result of a request to
+// the Panel. Once the Panel is reachable again any pending status updates are
+// sent to it.
+func (c *client) recordResult(err error) {
+	if c.conn == nil {
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	unreachable := isUnreachable(err)
+
+	c.conn.mu.Lock()
+	wasOffline := c.conn.offline
+	c.conn.offline = unreachable
+	if unreachable {
+		c.conn.lastFailure = time.Now()
+		c.conn.lastError = err.Error()
+	} else {
+		c.conn.lastSuccess = time.Now()
+	}
+	c.conn.mu.Unlock()
+
+	if unreachable && !wasOffline {
+		log.WithField("error", err).WithField("offline_behavior", c.conn.behavior).Warn("remote: lost connection to the Panel")
+	} else if !unreachable && wasOffline {
+		log.Info("remote: connection to the Panel has been restored")
+		c.startFlush()
+	}
+}
+
+// postState sends a status update for the given resource to the Panel. If the
+// Panel cannot be reached the update is handled according to the configured
+// offline behavior:
+//
+// "queue" -> the update is kept and sent once the Panel is reachable, along with
+// any other updates, in the order they were made.
+// "reject" -> an error is returned, without waiting for the request to time out
+// if the Panel was already unreachable.
+// "continue" -> only the latest update for each resource is kept and sent once
+// the Panel is reachable, so that the Panel is reconciled with the state of the
+// node rather than replaying every change.
+func (c *client) postState(ctx context.Context, key, path string, data interface{}) error {
+	if c.conn == nil {
+		return c.postOnce(ctx, path, data)
+	}
+
+	c.conn.mu.Lock()
+	behavior, offline := c.conn.behavior, c.conn.offline
+	c.conn.mu.Unlock()
+	if behavior == config.PanelOfflineReject && offline {
+		return errors.WithStack(ErrPanelUnreachable)
+	}
+
+	err := c.postOnce(ctx, path, data)
+	if err == nil || behavior == config.PanelOfflineReject || !isUnreachable(err) {
+		return err
+	}
+
+	b, merr := json.Marshal(data)
+	if merr != nil {
+		return err
+	}
+	c.enqueue(key, path, b)
+	log.WithField("error", err).WithField("path", path).WithField("offline_behavior", behavior).Warn("remote: panel is unreachable, status update will be sent once it is reachable again")
+	return nil
+}
+
+// postOnce makes a POST request and closes the response.
+func (c *client) postOnce(ctx context.Context, path string, data interface{}) error {
+	resp, err := c.Post(ctx, path, data)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// enqueue adds a status update to the pending queue, dropping the oldest update
+// when the queue is full.
+func (c *client) enqueue(key, path string, body json.RawMessage) {
+	c.conn.mu.Lock()
+	if c.conn.behavior == config.PanelOfflineContinue {
+		for i, u := range c.conn.pending {
+			if u.key == key {
+				c.conn.pending = append(c.conn.pending[:i], c.conn.pending[i+1:]...)
+				break
+			}
+		}
+	}
+	if c.conn.size > 0 && len(c.conn.pending) >= c.conn.size {
+		log.WithField("path", c.conn.pending[0].path).WithField("queued_at", c.conn.pending[0].queuedAt).Warn("remote: pending status update queue is full, dropping oldest update")
+		c.conn.pending = c.conn.pending[1:]
+		c.conn.dropped++
+	}
+	c.conn.nextId++
+	c.conn.pending = append(c.conn.pending, pendingUpdate{id: c.conn.nextId, key: key, path: path, body: body, queuedAt: time.Now()})
+	c.conn.mu.Unlock()
+
+	c.startFlush()
+}
+
+// startFlush starts sending pending status updates to the Panel in the
+// background, unless that is already happening.
+func (c *client) startFlush() {
+	c.conn.mu.Lock()
+	if c.conn.flushing || len(c.conn.pending) == 0 {
+		c.conn.mu.Unlock()
+		return
+	}
+	c.conn.flushing = true
+	c.conn.mu.Unlock()
+
+	go c.flush()
+}
+
+// flush sends pending status updates to the Panel in order, waiting between
+// attempts while the Panel is unreachable. Updates rejected by the Panel, or that
+// could not be sent after the maximum number of attempts, are logged and
+// discarded.
+func (c *client) flush() {
+	for {
+		c.conn.mu.Lock()
+		if len(c.conn.pending) == 0 {
+			c.conn.flushing = false
+			c.conn.mu.Unlock()
+			return
+		}
+		u := c.conn.pending[0]
+		c.conn.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := c.postOnce(ctx, u.path, u.body)
+		cancel()
+		if isUnreachable(err) {
+			u.attempts++
+			if u.attempts < offlineMaxAttempts {
+				c.conn.mu.Lock()
+				for i, p := range c.conn.pending {
+					if p.id == u.id {
+						c.conn.pending[i].attempts = u.attempts
+						break
+					}
+				}
+				c.conn.mu.Unlock()
+				time.Sleep(offlineRetryInterval)
+				continue
+			}
+			log.WithField("error", err).WithField("path", u.path).WithField("attempts", u.attempts).Error("remote: failed to send pending status update after maximum attempts, discarding")
+		} else if err != nil {
+			log.WithField("error", err).WithField("path", u.path).Error("remote: panel rejected pending status update, discarding")
+		}
+
+		c.conn.mu.Lock()
+		for i, p := range c.conn.pending {
+			if p.id == u.id {
+				c.conn.pending = append(c.conn.pending[:i], c.conn.pending[i+1:]...)
+				if u.attempts >= offlineMaxAttempts {
+					c.conn.dropped++
+				}
+				break
+			}
+		}
+		c.conn.mu.Unlock()
+	}
+}
@@ -93,21 +93,11 @@ func (c *client) GetInstallationScript(ctx context.Context, uuid string) (Instal
 }
 
 func (c *client) SetInstallationStatus(ctx context.Context, uuid string, data InstallStatusRequest) error {
-	resp, err := c.Post(ctx, fmt.Sprintf("/servers/%s/install", uuid), data)
-	if err != nil {
-		return err
-	}
-	_ = resp.Body.Close()
-	return nil
+	return c.postState(ctx, "install:"+uuid, fmt.Sprintf("/servers/%s/install", uuid), data)
 }
 
 func (c *client) SetArchiveStatus(ctx context.Context, uuid string, successful bool) error {
-	resp, err := c.Post(ctx, fmt.Sprintf("/servers/%s/archive", uuid), d{"successful": successful})
-	if err != nil {
-		return err
-	}
-	_ = resp.Body.Close()
-	return nil
+	return c.postState(ctx, "archive:"+uuid, fmt.Sprintf("/servers/%s/archive", uuid), d{"successful": successful})
 }
 
 func (c *client) SetTransferStatus(ctx context.Context, uuid string, successful bool) error {
@@ -115,12 +105,7 @@ func (c *client) SetTransferStatus(ctx context.Context, uuid string, successful
 	if successful {
 		state = "success"
 	}
-	resp, err := c.Post(ctx, fmt.Sprintf("/servers/%s/transfer/%s", uuid, state), nil)
-	if err != nil {
-		return err
-	}
-	_ = resp.Body.Close()
-	return nil
+	return c.postState(ctx, "transfer:"+uuid, fmt.Sprintf("/servers/%s/transfer/%s", uuid, state), nil)
 }
 
 // ValidateSftpCredentials makes a request to determine if the username and
@@ -160,24 +145,14 @@ func (c *client) GetBackupRemoteUploadURLs(ctx context.Context, backup string, s
 }
 
 func (c *client) SetBackupStatus(ctx context.Context, backup string, data BackupRequest) error {
-	resp, err := c.Post(ctx, fmt.Sprintf("/backups/%s", backup), data)
-	if err != nil {
-		return err
-	}
-	_ = resp.Body.Close()
-	return nil
+	return c.postState(ctx, "backup:"+backup, fmt.Sprintf("/backups/%s", backup), data)
 }
 
 // SendRestorationStatus triggers a request to the Panel to notify it that a
 // restoration has been completed and the server should be marked as being
 // activated again.
 func (c *client) SendRestorationStatus(ctx context.Context, backup string, successful bool) error {
-	resp, err := c.Post(ctx, fmt.Sprintf("/backups/%s/restore", backup), d{"successful": successful})
-	if err != nil {
-		return err
-	}
-	_ = resp.Body.Close()
-	return nil
+	return c.postState(ctx, "restore:"+backup, fmt.Sprintf("/backups/%s/restore", backup), d{"successful": successful})
 }
 
 // SendActivityLogs sends activity logs back to the Panel for processing.
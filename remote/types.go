@@ -166,6 +166,7 @@ type BackupRequest struct {
 	Checksum     string       `json:"checksum"`
 	ChecksumType string       `json:"checksum_type"`
 	Size         int64        `json:"size"`
+	Format       string       `json:"format"`
 	Successful   bool         `json:"successful"`
 	Parts        []BackupPart `json:"parts"`
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/server"
 )
 
@@ -51,18 +52,26 @@ func init() {
 				return c, nil // 如果IP在白名单中，直接返回，允许请求通过
 			}
 		}
-		for _, block := range internalRanges {
-			if !block.Contains(ip) {
-				continue
+		cfg := config.Get().Api.RemoteDownload
+		if cfg.BlockPrivateNetworks {
+			for _, block := range internalRanges {
+				if !block.Contains(ip) {
+					continue
+				}
+				return c, errors.WithStack(ErrInternalResolution)
+			}
+		}
+		for _, block := range cfg.DeniedNetworks() {
+			if block.Contains(ip) {
+				return c, errors.WithStack(ErrInternalResolution)
 			}
-			return c, errors.WithStack(ErrInternalResolution)
 		}
 		return c, nil
 	}
 
+	// The timeout for a download is configurable, and is applied to the context of
+	// each request in Download.Execute.
 	client = &http.Client{
-		Timeout: time.Hour * 12,
-
 		Transport: trnspt,
 
 		// Disallow any redirect on an HTTP call. This is a security requirement: do not modify
@@ -95,7 +104,9 @@ var instance = &Downloader{
 	serverCache: make(map[string][]string),
 }
 
-// Internal IP ranges that should be blocked if the resource requested resolves within.
+// Internal IP ranges that should be blocked if the resource requested resolves within,
+// unless api.remote_download.block_private_networks is disabled. Loopback and link-local
+// addresses are always blocked.
 var internalRanges = []*net.IPNet{
 	mustParseCIDR("127.0.0.1/8"),
 	mustParseCIDR("10.0.0.0/8"),
@@ -111,6 +122,8 @@ const (
 	ErrInternalResolution = errors.Sentinel("downloader: destination resolves to internal network location")
 	ErrInvalidIPAddress   = errors.Sentinel("downloader: invalid IP address")
 	ErrDownloadFailed     = errors.Sentinel("downloader: download request failed")
+	ErrSchemeNotAllowed   = errors.Sentinel("downloader: URL scheme is not allowed")
+	ErrDownloadTooLarge   = errors.Sentinel("downloader: file is larger than the maximum download size")
 )
 
 type Counter struct {
@@ -189,7 +202,13 @@ func (dl Download) MarshalJSON() ([]byte, error) {
 // Execute executes a given download for the server and begins writing the file to the disk. Once
 // completed the download will be removed from the cache.
 func (dl *Download) Execute() error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Hour*12)
+	cfg := config.Get().Api.RemoteDownload
+	if !cfg.SchemeAllowed(dl.req.URL.Scheme) {
+		dl.Cancel()
+		return errors.WithStack(ErrSchemeNotAllowed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout)*time.Second)
 	dl.cancelFunc = &cancel
 	defer dl.Cancel()
 
@@ -214,6 +233,9 @@ func (dl *Download) Execute() error {
 	if res.ContentLength < 1 {
 		return errors.New("downloader: request is missing ContentLength")
 	}
+	if cfg.MaxSize > 0 && res.ContentLength > cfg.MaxSize*1024*1024 {
+		return errors.WithStack(ErrDownloadTooLarge)
+	}
 
 	if dl.req.UseHeader {
 		if contentDisposition := res.Header.Get("Content-Disposition"); contentDisposition != "" {
@@ -242,7 +264,7 @@ func (dl *Download) Execute() error {
 	// Write the file while tracking the progress, Write will check that the
 	// size of the file won't exceed the disk limit.
 	r := io.TeeReader(res.Body, dl.counter(res.ContentLength))
-	if err := dl.server.Filesystem().Write(p, r, res.ContentLength, 0o644); err != nil {
+	if err := dl.server.Filesystem().WriteStaged(p, r, res.ContentLength, 0o644); err != nil {
 		return errors.WrapIf(err, "downloader: failed to write file to server directory")
 	}
 	return nil
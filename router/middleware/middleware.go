@@ -12,6 +12,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/memlimit"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/server"
 )
@@ -92,15 +93,24 @@ func CaptureErrors() gin.HandlerFunc {
 // the requests.
 func SetAccessControlHeaders() gin.HandlerFunc {
 	cfg := config.Get()
-	origins := cfg.AllowedOrigins
+	origins := append(append([]string{}, cfg.AllowedOrigins...), cfg.Api.Cors.AllowedOrigins...)
 	location := cfg.PanelLocation
 	allowPrivateNetwork := cfg.AllowCORSPrivateNetwork
+	allowCredentials := cfg.Api.Cors.AllowCredentials
+	methods := strings.Join(cfg.Api.Cors.AllowedMethods, ", ")
+	headers := strings.Join(cfg.Api.Cors.AllowedHeaders, ", ")
 
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", location)
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PATCH, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Accept, Accept-Encoding, Authorization, Cache-Control, Content-Type, Content-Length, Origin, X-Real-IP, X-CSRF-Token")
+		if allowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if methods != "" {
+			c.Header("Access-Control-Allow-Methods", methods)
+		}
+		if headers != "" {
+			c.Header("Access-Control-Allow-Headers", headers)
+		}
 
 		// CORS for Private Networks (RFC1918)
 		// @see https://developer.chrome.com/blog/private-network-access-update/?utm_source=devtools
@@ -187,6 +197,41 @@ func RequireAuthorization() gin.HandlerFunc {
 	}
 }
 
+// LimitRequestBody limits the size of request bodies based on the endpoint being
+// requested. Requests that declare a body larger than the limit are rejected with
+// a 413 before any of the body is read, and bodies without a declared length are
+// cut off once they reach the limit.
+func LimitRequestBody() gin.HandlerFunc {
+	cfg := config.Get().Api
+	limits := map[string]int64{
+		// Uploads are limited per file by the upload handler, and transfers stream an
+		// archive of the entire server.
+		"POST /upload/file":                     0,
+		"POST /api/transfers":                   0,
+		"POST /api/servers/:server/files/write": cfg.UploadLimit,
+	}
+	for k, v := range cfg.RequestBodyLimits {
+		limits[k] = v
+	}
+	return func(c *gin.Context) {
+		limit, ok := limits[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			limit = cfg.MaxRequestBody
+		}
+		if limit > 0 && c.Request.Body != nil {
+			max := limit * 1024 * 1024
+			if c.Request.ContentLength > max {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error": "The request body exceeds the maximum size allowed for this endpoint.",
+				})
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		}
+		c.Next()
+	}
+}
+
 // RemoteDownloadEnabled checks if remote downloads are enabled for this instance
 // and if not aborts the request.
 func RemoteDownloadEnabled() gin.HandlerFunc {
@@ -200,6 +245,37 @@ func RemoteDownloadEnabled() gin.HandlerFunc {
 	}
 }
 
+// RejectSuspended aborts requests for a server that is suspended. This must be used
+// after ServerExists so that the server is present in the request context.
+func RejectSuspended() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ExtractServer(c).IsSuspended() {
+			AbortSuspended(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortSuspended aborts the request with an error explaining that the server it is
+// for is suspended.
+func AbortSuspended(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "This server is suspended, its files cannot be accessed until it is unsuspended."})
+}
+
+// RejectUnderMemoryPressure aborts requests for memory intensive work while the
+// Wings process is approaching its memory limit.
+func RejectUnderMemoryPressure() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if memlimit.UnderPressure() {
+			c.Header("Retry-After", "30")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Wings is low on memory, please try again shortly."})
+			return
+		}
+		c.Next()
+	}
+}
+
 // ExtractLogger pulls the logger out of the request context and returns it. By
 // default this will include the request ID, but may also include the server ID
 // if that middleware has been used in the chain by the time it is called.
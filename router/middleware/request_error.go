@@ -131,6 +131,9 @@ func (re *RequestError) asFilesystemError() (int, string) {
 	if filesystem.IsErrorCode(err, filesystem.ErrCodeDiskSpace) || strings.Contains(err.Error(), "filesystem: not enough disk space") {
 		return http.StatusBadRequest, "There is not enough disk space available to perform that action."
 	}
+	if filesystem.IsErrorCode(err, filesystem.ErrCodeTooManyFiles) {
+		return http.StatusBadRequest, "Cannot perform that action: the server contains too many files."
+	}
 	if strings.HasSuffix(err.Error(), "file name too long") {
 		return http.StatusBadRequest, "Cannot perform that action: file name is too long."
 	}
@@ -22,7 +22,7 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 		return nil
 	}
 	router.Use(middleware.AttachRequestID(), middleware.CaptureErrors(), middleware.SetAccessControlHeaders())
-	router.Use(middleware.AttachServerManager(m), middleware.AttachApiClient(client))
+	router.Use(middleware.AttachServerManager(m), middleware.AttachApiClient(client), middleware.LimitRequestBody())
 	// @todo log this into a different file so you can setup IP blocking for abusive requests and such.
 	// This should still dump requests in debug mode since it does help with understanding the request
 	// lifecycle and quickly seeing what was called leading to the logs. However, it isn't feasible to mix
@@ -42,7 +42,7 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	// These routes use signed URLs to validate access to the resource being requested.
 	router.GET("/download/backup", getDownloadBackup)
 	router.GET("/download/file", getDownloadFile)
-	router.POST("/upload/file", postServerUploadFiles)
+	router.POST("/upload/file", middleware.RejectUnderMemoryPressure(), postServerUploadFiles)
 
 	// This route is special it sits above all the other requests because we are
 	// using a JWT to authorize access to it, therefore it needs to be publicly
@@ -52,6 +52,7 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	// This request is called by another daemon when a server is going to be transferred out.
 	// This request does not need the AuthorizationMiddleware as the panel should never call it
 	// and requests are authenticated through a JWT the panel issues to the other daemon.
+	router.GET("/api/transfers", getTransfers)
 	router.POST("/api/transfers", postTransfers)
 
 	// All the routes beyond this mount will use an authorization middleware
@@ -59,8 +60,14 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	protected := router.Use(middleware.RequireAuthorization())
 	protected.POST("/api/update", postUpdateConfiguration)
 	protected.GET("/api/system", getSystemInformation)
+	protected.GET("/api/system/readiness", getSystemReadiness)
+	protected.GET("/api/system/kernel-features", getSystemKernelFeatures)
+	protected.GET("/api/system/summary", getSystemSummary)
+	protected.GET("/api/system/panel", getSystemPanelConnectivity)
+	protected.GET("/api/system/clock-skew", getSystemClockSkew)
 	protected.GET("/api/servers", getAllServers)
 	protected.POST("/api/servers", postCreateServer)
+	protected.POST("/api/servers/power", postServersPower)
 	protected.DELETE("/api/transfers/:server", deleteTransfer)
 
 	// These are server specific routes, and require that the request be authorized, and
@@ -85,6 +92,7 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 		server.DELETE("/transfer", deleteServerTransfer)
 
 		files := server.Group("/files")
+		files.Use(middleware.RejectSuspended())
 		{
 			files.GET("/contents", getServerFileContents)
 			files.GET("/list-directory", getServerListDirectory)
@@ -98,7 +106,7 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 			files.POST("/chmod", postServerChmodFile)
 
 			files.GET("/pull", middleware.RemoteDownloadEnabled(), getServerPullingFiles)
-			files.POST("/pull", middleware.RemoteDownloadEnabled(), postServerPullRemoteFile)
+			files.POST("/pull", middleware.RemoteDownloadEnabled(), middleware.RejectUnderMemoryPressure(), postServerPullRemoteFile)
 			files.DELETE("/pull/:download", middleware.RemoteDownloadEnabled(), deleteServerPullRemoteFile)
 		}
 
@@ -88,6 +88,10 @@ func getDownloadFile(c *gin.Context) {
 		})
 		return
 	}
+	if s.IsSuspended() {
+		middleware.AbortSuspended(c)
+		return
+	}
 
 	f, st, err := s.Filesystem().File(token.FilePath)
 	if err != nil {
@@ -89,7 +89,7 @@ func postServerPower(c *gin.Context) {
 		if data.WaitSeconds < 0 || data.WaitSeconds > 300 {
 			data.WaitSeconds = 30
 		}
-		if err := s.HandlePowerAction(data.Action, data.WaitSeconds); err != nil {
+		if err := s.HandlePowerActionFrom(server.PowerTriggerPanel, data.Action, data.WaitSeconds); err != nil {
 			if errors.Is(err, context.DeadlineExceeded) {
 				s.Log().WithField("action", data.Action).WithField("error", err).Warn("could not process server power action")
 			} else if errors.Is(err, server.ErrIsRunning) {
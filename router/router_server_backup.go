@@ -1,9 +1,9 @@
 package router
 
 import (
+	"io"
 	"net/http"
 	"os"
-	"strings"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
@@ -130,35 +130,57 @@ func postServerRestoreBackup(c *gin.Context) {
 	// Since this is not a local backup we need to stream the archive and then
 	// parse over the contents as we go in order to restore it to the server.
 	httpClient := http.Client{}
-	logger.Info("downloading backup from remote location...")
-	// TODO: this will hang if there is an issue. We can't use c.Request.Context() (or really any)
-	//  since it will be canceled when the request is closed which happens quickly since we push
-	//  this into the background.
-	//
-	// For now I'm just using the server context so at least the request is canceled if
-	// the server gets deleted.
-	req, err := http.NewRequestWithContext(s.Context(), http.MethodGet, data.DownloadUrl, nil)
-	if err != nil {
-		middleware.CaptureAndAbort(c, err)
-		return
+	download := func() (*http.Response, error) {
+		// TODO: this will hang if there is an issue. We can't use c.Request.Context() (or really any)
+		//  since it will be canceled when the request is closed which happens quickly since we push
+		//  this into the background.
+		//
+		// For now I'm just using the server context so at least the request is canceled if
+		// the server gets deleted.
+		req, err := http.NewRequestWithContext(s.Context(), http.MethodGet, data.DownloadUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		// Don't allow content types that we know are going to give us problems.
+		if !backup.IsContentType(res.Header.Get("Content-Type")) {
+			_ = res.Body.Close()
+			return nil, errUnsupportedBackupType{contentType: res.Header.Get("Content-Type")}
+		}
+		return res, nil
 	}
-	res, err := httpClient.Do(req)
+
+	// Check that the backup can be downloaded before accepting the request so that
+	// an unreachable link or unsupported archive is reported straight away. The
+	// download is then started again once the restoration is able to run, rather
+	// than leaving the response idle while waiting for other restorations.
+	logger.Info("downloading backup from remote location...")
+	res, err := download()
 	if err != nil {
+		var cerr errUnsupportedBackupType
+		if errors.As(err, &cerr) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": cerr.Error()})
+			return
+		}
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
-	// Don't allow content types that we know are going to give us problems.
-	if res.Header.Get("Content-Type") == "" || !strings.Contains("application/x-gzip application/gzip", res.Header.Get("Content-Type")) {
-		_ = res.Body.Close()
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "The provided backup link is not a supported content type. \"" + res.Header.Get("Content-Type") + "\" is not application/x-gzip.",
-		})
-		return
+	_ = res.Body.Close()
+
+	open := func() (io.ReadCloser, error) {
+		res, err := download()
+		if err != nil {
+			return nil, err
+		}
+		return res.Body, nil
 	}
 
 	go func(s *server.Server, uuid string, logger *log.Entry) {
 		logger.Info("starting restoration process for server backup using S3 driver")
-		if err := s.RestoreBackup(backup.NewS3(client, uuid, ""), res.Body); err != nil {
+		if err := s.RestoreBackup(backup.NewS3(client, uuid, ""), open); err != nil {
 			logger.WithField("error", errors.WithStack(err)).Error("failed to restore remote S3 backup to server")
 		}
 		s.Events().Publish(server.DaemonMessageEvent, "Completed server restoration from S3 backup.")
@@ -171,6 +193,16 @@ func postServerRestoreBackup(c *gin.Context) {
 	c.Status(http.StatusAccepted)
 }
 
+// errUnsupportedBackupType is returned when the link to a remote backup responds
+// with a content type that is not a supported archive.
+type errUnsupportedBackupType struct {
+	contentType string
+}
+
+func (e errUnsupportedBackupType) Error() string {
+	return "The provided backup link is not a supported content type. \"" + e.contentType + "\" is not a gzip, zip or zstd archive."
+}
+
 // deleteServerBackup deletes a local backup of a server. If the backup is not
 // found on the machine just return a 404 error. The service calling this
 // endpoint can make its own decisions as to how it wants to handle that
@@ -19,6 +19,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/audit"
 	"github.com/pterodactyl/wings/internal/models"
 	"github.com/pterodactyl/wings/router/downloader"
 	"github.com/pterodactyl/wings/router/middleware"
@@ -27,11 +28,25 @@ import (
 	"github.com/pterodactyl/wings/server/filesystem"
 )
 
+// auditFile records a file operation performed through the API in the audit log.
+// These requests are made by the Panel, so the user is not known.
+func auditFile(c *gin.Context, s *server.Server, operation, p, target string, err error) {
+	audit.Log(audit.Record{
+		Source:    audit.SourceApi,
+		Server:    s.ID(),
+		IP:        c.ClientIP(),
+		Operation: operation,
+		Path:      p,
+		Target:    target,
+	}, err)
+}
+
 // getServerFileContents returns the contents of a file on the server.
 func getServerFileContents(c *gin.Context) {
 	s := middleware.ExtractServer(c)
 	p := strings.TrimLeft(c.Query("file"), "/")
 	f, st, err := s.Filesystem().File(p)
+	auditFile(c, s, "read", p, "", err)
 	if err != nil {
 		middleware.CaptureAndAbort(c, err)
 		return
@@ -127,7 +142,9 @@ func putServerRenameFiles(c *gin.Context) {
 				if err := fs.IsIgnored(pf, pt); err != nil {
 					return err
 				}
-				if err := fs.Rename(pf, pt); err != nil {
+				err := fs.Rename(pf, pt)
+				auditFile(c, s, "rename", pf, pt, err)
+				if err != nil {
 					// Return nil if the error is an is not exists.
 					if errors.Is(err, os.ErrNotExist) {
 						s.Log().WithField("error", err).
@@ -174,7 +191,9 @@ func postServerCopyFile(c *gin.Context) {
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
-	if err := s.Filesystem().Copy(data.Location); err != nil {
+	err := s.Filesystem().Copy(data.Location)
+	auditFile(c, s, "copy", data.Location, "", err)
+	if err != nil {
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
@@ -214,7 +233,9 @@ func postServerDeleteFiles(c *gin.Context) {
 			case <-ctx.Done():
 				return ctx.Err()
 			default:
-				return s.Filesystem().Delete(pi)
+				err := s.Filesystem().Delete(pi)
+				auditFile(c, s, "delete", pi, "", err)
+				return err
 			}
 		})
 	}
@@ -247,7 +268,9 @@ func postServerWriteFile(c *gin.Context) {
 		return
 	}
 
-	if err := s.Filesystem().Write(f, c.Request.Body, c.Request.ContentLength, 0o644); err != nil {
+	err := s.Filesystem().Write(f, c.Request.Body, c.Request.ContentLength, 0o644)
+	auditFile(c, s, "write", f, "", err)
+	if err != nil {
 		if filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory) {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error": "无法写入文件，名称与现有目录的名称存在冲突。",
@@ -306,6 +329,13 @@ func postServerPullRemoteFile(c *gin.Context) {
 		return
 	}
 
+	if !config.Get().Api.RemoteDownload.SchemeAllowed(u.Scheme) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "不允许从使用 \"" + u.Scheme + "\" 协议的 URL 下载文件。",
+		})
+		return
+	}
+
 	if err := s.Filesystem().HasSpaceErr(true); err != nil {
 		middleware.CaptureAndAbort(c, err)
 		return
@@ -325,9 +355,14 @@ func postServerPullRemoteFile(c *gin.Context) {
 		UseHeader: data.UseHeader,
 	})
 
+	// The download may continue after the request has completed, so the address of
+	// the client is read now.
+	ip := c.ClientIP()
 	download := func() error {
 		s.Log().WithField("download_id", dl.Identifier).WithField("url", u.String()).Info("starting pull of remote file to disk")
-		if err := dl.Execute(); err != nil {
+		err := dl.Execute()
+		audit.Log(audit.Record{Source: audit.SourceApi, Server: s.ID(), IP: ip, Operation: "pull", Path: dl.Path()}, err)
+		if err != nil {
 			s.Log().WithField("download_id", dl.Identifier).WithField("error", err).Error("failed to pull remote file")
 			return err
 		} else {
@@ -380,7 +415,9 @@ func postServerCreateDirectory(c *gin.Context) {
 		return
 	}
 
-	if err := s.Filesystem().CreateDirectory(data.Name, data.Path); err != nil {
+	err := s.Filesystem().CreateDirectory(data.Name, data.Path)
+	auditFile(c, s, "create_directory", path.Join(data.Path, data.Name), "", err)
+	if err != nil {
 		if err.Error() == "not a directory" {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error": "Part of the path being created is not a directory (ENOTDIR).",
@@ -422,6 +459,11 @@ func postServerCompressFiles(c *gin.Context) {
 	}
 
 	f, err := s.Filesystem().CompressFiles(data.RootPath, data.Files)
+	var archive string
+	if err == nil {
+		archive = path.Join(data.RootPath, f.Name())
+	}
+	auditFile(c, s, "compress", data.RootPath, archive, err)
 	if err != nil {
 		middleware.CaptureAndAbort(c, err)
 		return
@@ -460,7 +502,14 @@ func postServerDecompressFiles(c *gin.Context) {
 	}
 
 	lg.Info("starting file decompression")
-	if err := s.Filesystem().DecompressFile(context.Background(), data.RootPath, data.File); err != nil {
+	err = s.Filesystem().DecompressFile(context.Background(), data.RootPath, data.File)
+	auditFile(c, s, "decompress", path.Join(data.RootPath, data.File), data.RootPath, err)
+	if err != nil {
+		if filesystem.IsErrorCode(err, filesystem.ErrCodeArchiveLimits) {
+			lg.WithField("error", err).Warn("failed to decompress file: archive exceeds limits")
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The archive exceeds the extraction limits configured for this node."})
+			return
+		}
 		// If the file is busy for some reason just return a nicer error to the user since there is not
 		// much we specifically can do. They'll need to stop the running server process in order to overwrite
 		// a file like this.
@@ -508,6 +557,8 @@ func postServerChmodFile(c *gin.Context) {
 
 	// Loop over the array of files passed in and perform the move or rename action against each.
 	for _, p := range data.Files {
+		pf := path.Join(data.Root, p.File)
+
 		g.Go(func() error {
 			select {
 			case <-ctx.Done():
@@ -518,7 +569,9 @@ func postServerChmodFile(c *gin.Context) {
 					return errInvalidFileMode
 				}
 
-				if err := s.Filesystem().Chmod(path.Join(data.Root, p.File), os.FileMode(mode)); err != nil {
+				err = s.Filesystem().Chmod(pf, os.FileMode(mode))
+				auditFile(c, s, "chmod", pf, "", err)
+				if err != nil {
 					// Return nil if the error is an is not exists.
 					// NOTE: os.IsNotExist() does not work if the error is wrapped.
 					if errors.Is(err, os.ErrNotExist) {
@@ -564,6 +617,10 @@ func postServerUploadFiles(c *gin.Context) {
 		})
 		return
 	}
+	if s.IsSuspended() {
+		middleware.AbortSuspended(c)
+		return
+	}
 
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -583,6 +640,15 @@ func postServerUploadFiles(c *gin.Context) {
 
 	directory := c.Query("directory")
 
+	// Large files may be uploaded in chunks, in which case every request contains a
+	// single chunk of the file, the offset of that chunk within the file, and the
+	// total size of the file. Each chunk is limited to the upload limit, and the
+	// assembled file to the maximum assembled upload size.
+	if c.Query("total_size") != "" {
+		postServerUploadFileChunk(c, s, token, directory, headers)
+		return
+	}
+
 	maxFileSize := config.Get().Api.UploadLimit
 	maxFileSizeBytes := maxFileSize * 1024 * 1024
 	var totalSize int64
@@ -599,7 +665,9 @@ func postServerUploadFiles(c *gin.Context) {
 	for _, header := range headers {
 		// We run this in a different method so I can use defer without any of
 		// the consequences caused by calling it in a loop.
-		if err := handleFileUpload(filepath.Join(directory, header.Filename), s, header); err != nil {
+		err := handleFileUpload(filepath.Join(directory, header.Filename), s, header)
+		auditUpload(c, s, token, filepath.Join(directory, header.Filename), err)
+		if err != nil {
 			middleware.CaptureAndAbort(c, err)
 			return
 		} else {
@@ -611,6 +679,65 @@ func postServerUploadFiles(c *gin.Context) {
 	}
 }
 
+// postServerUploadFileChunk handles a request containing a single chunk of a
+// file that is being uploaded in chunks.
+func postServerUploadFileChunk(c *gin.Context, s *server.Server, token tokens.UploadPayload, directory string, headers []*multipart.FileHeader) {
+	offset, oerr := strconv.ParseInt(c.Query("offset"), 10, 64)
+	total, terr := strconv.ParseInt(c.Query("total_size"), 10, 64)
+	if oerr != nil || terr != nil || offset < 0 || total < 1 || len(headers) != 1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Chunked uploads must contain a single file with a valid offset and total_size.",
+		})
+		return
+	}
+
+	header := headers[0]
+	cfg := config.Get().Api
+	if header.Size > cfg.UploadLimit*1024*1024 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The chunk of " + header.Filename + " is larger than the maximum file upload size of " + strconv.FormatInt(cfg.UploadLimit, 10) + " MB.",
+		})
+		return
+	}
+
+	p := filepath.Join(directory, header.Filename)
+	if err := s.Filesystem().IsIgnored(p); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	done, err := uploads.write(s, p, offset, total, cfg.MaxAssembledUploadSizeBytes(), header)
+	if err != nil || done {
+		auditUpload(c, s, token, p, err)
+	}
+	if err != nil {
+		var cerr *chunkedUploadError
+		if errors.As(err, &cerr) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": cerr.Error()})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if done {
+		s.SaveActivity(s.NewRequestActivity(token.UserUuid, c.ClientIP()), server.ActivityFileUploaded, models.ActivityMeta{
+			"file":      header.Filename,
+			"directory": filepath.Clean(directory),
+		})
+	}
+}
+
+// auditUpload records a file uploaded by a user in the audit log.
+func auditUpload(c *gin.Context, s *server.Server, token tokens.UploadPayload, p string, err error) {
+	audit.Log(audit.Record{
+		Source:    audit.SourceApi,
+		User:      token.UserUuid,
+		Server:    s.ID(),
+		IP:        c.ClientIP(),
+		Operation: "upload",
+		Path:      p,
+	}, err)
+}
+
 func handleFileUpload(p string, s *server.Server, header *multipart.FileHeader) error {
 	file, err := header.Open()
 	if err != nil {
@@ -622,7 +749,7 @@ func handleFileUpload(p string, s *server.Server, header *multipart.FileHeader)
 		return err
 	}
 
-	if err := s.Filesystem().Write(p, file, header.Size, 0o644); err != nil {
+	if err := s.Filesystem().WriteStaged(p, file, header.Size, 0o644); err != nil {
 		return err
 	}
 	return nil
@@ -11,7 +11,6 @@ import (
 
 	"github.com/pterodactyl/wings/environment"
 	"github.com/pterodactyl/wings/router/middleware"
-	"github.com/pterodactyl/wings/server"
 	"github.com/pterodactyl/wings/server/installer"
 	"github.com/pterodactyl/wings/server/transfer"
 )
@@ -44,18 +43,6 @@ func postServerTransfer(c *gin.Context) {
 
 	manager := middleware.ExtractManager(c)
 
-	notifyPanelOfFailure := func() {
-		if err := manager.Client().SetTransferStatus(context.Background(), s.ID(), false); err != nil {
-			s.Log().WithField("subsystem", "transfer").
-				WithField("status", false).
-				WithError(err).
-				Error("failed to set transfer status")
-		}
-
-		s.Events().Publish(server.TransferStatusEvent, "failure")
-		s.SetTransferring(false)
-	}
-
 	// Block the server from starting while we are transferring it.
 	s.SetTransferring(true)
 
@@ -80,26 +67,7 @@ func postServerTransfer(c *gin.Context) {
 	go func() {
 		defer transfer.Outgoing().Remove(trnsfr)
 
-		if _, err := trnsfr.PushArchiveToTarget(data.URL, data.Token); err != nil {
-			notifyPanelOfFailure()
-
-			if err == context.Canceled {
-				trnsfr.Log().Debug("canceled")
-				trnsfr.SendMessage("Canceled.")
-				return
-			}
-
-			trnsfr.Log().WithError(err).Error("failed to push archive to target")
-			return
-		}
-
-		// DO NOT NOTIFY THE PANEL OF SUCCESS HERE. The only node that should send
-		// a success status is the destination node.  When we send a failure status,
-		// the panel will automatically cancel the transfer and attempt to reset
-		// the server state on the destination node, we just need to make sure
-		// we clean up our statuses for failure.
-
-		trnsfr.Log().Debug("transfer complete")
+		trnsfr.Push(manager.Client(), data.URL, data.Token)
 	}()
 
 	c.Status(http.StatusAccepted)
@@ -4,12 +4,15 @@ import (
 	"context"
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/goccy/go-json"
 	ws "github.com/gorilla/websocket"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/router/middleware"
 	"github.com/pterodactyl/wings/router/websocket"
+	"github.com/pterodactyl/wings/system"
 )
 
 var expectedCloseCodes = []int{
@@ -38,6 +41,9 @@ func getServerWebsocket(c *gin.Context) {
 		return
 	}
 	defer handler.Connection.Close()
+	// The connection takes a slot on the server once it authenticates, which
+	// is freed again when it is closed.
+	defer handler.ReleaseSlot()
 
 	// Track this open connection on the server so that we can close them all programmatically
 	// if the server is deleted.
@@ -49,6 +55,15 @@ func getServerWebsocket(c *gin.Context) {
 		handler.Logger().Debug("closing connection to server websocket")
 	}()
 
+	// Connections are closed once they reach the maximum session lifetime, if there
+	// is one, regardless of whether their token has been refreshed.
+	var lifetime <-chan time.Time
+	if d := config.Get().Api.Websocket.MaxSessionLifetime; d > 0 {
+		t := time.NewTimer(time.Duration(d) * time.Second)
+		defer t.Stop()
+		lifetime = t.C
+	}
+
 	// If the server is deleted we need to send a close message to the connected client
 	// so that they disconnect since there will be no more events sent along. Listen for
 	// the request context being closed to break this loop, otherwise this routine will
@@ -57,23 +72,39 @@ func getServerWebsocket(c *gin.Context) {
 		select {
 		case <-ctx.Done():
 			break
+		case <-lifetime:
+			handler.Logger().Debug("closing websocket connection: maximum session lifetime reached")
+			_ = handler.Connection.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, "session lifetime exceeded"), time.Now().Add(time.Second*5))
+			_ = handler.Connection.Close()
+			break
 		case <-s.Context().Done():
 			_ = handler.Connection.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseGoingAway, "server deleted"), time.Now().Add(time.Second*5))
 			break
 		}
 	}()
 
+	// Limit the number of messages a single client is able to send to us each second,
+	// a client exceeding this is almost certainly misbehaving and is disconnected.
+	rate := system.NewRate(config.Get().Api.Websocket.MaxMessagesPerSecond, time.Second)
 	for {
 		j := websocket.Message{}
 
 		_, p, err := handler.Connection.ReadMessage()
 		if err != nil {
-			if ws.IsUnexpectedCloseError(err, expectedCloseCodes...) {
+			if errors.Is(err, ws.ErrReadLimit) {
+				handler.Logger().Warn("closing websocket connection: client sent a message exceeding the size limit")
+			} else if ws.IsUnexpectedCloseError(err, expectedCloseCodes...) {
 				handler.Logger().WithField("error", err).Warn("error handling websocket message for server")
 			}
 			break
 		}
 
+		if !rate.Try() {
+			handler.Logger().Warn("closing websocket connection: client exceeded the message rate limit")
+			_ = handler.Connection.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.ClosePolicyViolation, "message rate limit exceeded"), time.Now().Add(time.Second*5))
+			break
+		}
+
 		// Discard and JSON parse errors into the void and don't continue processing this
 		// specific socket request. If we did a break here the client would get disconnected
 		// from the socket, which is NOT what we want to do.
@@ -10,6 +10,9 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/internal/readiness"
+	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/router/middleware"
 	"github.com/pterodactyl/wings/server"
 	"github.com/pterodactyl/wings/server/installer"
@@ -18,7 +21,13 @@ import (
 
 // Returns information about the system that wings is running on.
 func getSystemInformation(c *gin.Context) {
-	i, err := system.GetSystemInformation()
+	cli, err := environment.Docker()
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	i, err := system.GetSystemInformation(cli)
 	if err != nil {
 		middleware.CaptureAndAbort(c, err)
 		return
@@ -44,6 +53,40 @@ func getSystemInformation(c *gin.Context) {
 	})
 }
 
+// Returns a summary of the capacity of the node and the resources committed to the
+// servers on it.
+func getSystemSummary(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.ExtractManager(c).NodeSummary())
+}
+
+// Returns the state of the connection between this node and the Panel, including
+// any status updates waiting to be sent while the Panel is unreachable.
+func getSystemPanelConnectivity(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.ExtractApiClient(c).Connectivity())
+}
+
+// Returns the most recent measurement of the difference between the clock of this
+// node and the clock of the Panel.
+func getSystemClockSkew(c *gin.Context) {
+	c.JSON(http.StatusOK, remote.CurrentClockSkew())
+}
+
+// Returns the results of the readiness checks that were run when Wings booted. If
+// the checks are disabled an empty ready result is returned.
+func getSystemReadiness(c *gin.Context) {
+	r := readiness.Last()
+	if r == nil {
+		r = &readiness.Result{Ready: true, Gates: []readiness.GateResult{}}
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// Returns the kernel features of the host that were checked when Wings booted,
+// and whether each of them is required by the configuration.
+func getSystemKernelFeatures(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"features": environment.KernelFeatures()})
+}
+
 // Returns all the servers that are registered and configured correctly on
 // this wings instance.
 func getAllServers(c *gin.Context) {
@@ -55,6 +98,68 @@ func getAllServers(c *gin.Context) {
 	c.JSON(http.StatusOK, out)
 }
 
+// Applies a power action to many servers at once. The actions are queued and
+// processed in the background with the configured concurrency and delay, rather
+// than all at the same time. Suspended servers are not started or restarted.
+func postServersPower(c *gin.Context) {
+	manager := middleware.ExtractManager(c)
+
+	var data struct {
+		Servers     []string           `json:"servers"`
+		Action      server.PowerAction `json:"action"`
+		WaitSeconds int                `json:"wait_seconds"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if !data.Action.IsValid() {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "The power action provided was not valid, should be one of \"stop\", \"start\", \"restart\", \"kill\"",
+		})
+		return
+	}
+	if len(data.Servers) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "At least one server must be provided.",
+		})
+		return
+	}
+	if data.WaitSeconds < 0 || data.WaitSeconds > 300 {
+		data.WaitSeconds = 30
+	}
+
+	servers := make([]*server.Server, 0, len(data.Servers))
+	missing := make([]string, 0)
+	suspended := make([]string, 0)
+	for _, uuid := range data.Servers {
+		s, ok := manager.Get(uuid)
+		if !ok {
+			missing = append(missing, uuid)
+			continue
+		}
+		if (data.Action == server.PowerActionStart || data.Action == server.PowerActionRestart) && s.IsSuspended() {
+			suspended = append(suspended, uuid)
+			continue
+		}
+		servers = append(servers, s)
+	}
+	if len(missing) > 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error":   "The requested servers do not exist on this instance.",
+			"servers": missing,
+		})
+		return
+	}
+
+	manager.HandleBulkPowerAction(servers, data.Action, data.WaitSeconds)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"queued":    len(servers),
+		"suspended": suspended,
+	})
+}
+
 // Creates a new server on the wings daemon and begins the installation process
 // for it.
 func postCreateServer(c *gin.Context) {
@@ -65,6 +170,13 @@ func postCreateServer(c *gin.Context) {
 		return
 	}
 
+	if config.Get().System.StrictServerUuids && manager.Find(func(s *server.Server) bool { return strings.EqualFold(s.ID(), details.UUID) }) != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "A server with that UUID already exists on this node.",
+		})
+		return
+	}
+
 	install, err := installer.New(c.Request.Context(), manager, details)
 	if err != nil {
 		if installer.IsValidationError(err) {
@@ -98,7 +210,7 @@ func postCreateServer(c *gin.Context) {
 
 		if i.StartOnCompletion {
 			log.WithField("server_id", i.Server().ID()).Debug("starting server after successful installation")
-			if err := i.Server().HandlePowerAction(server.PowerActionStart, 30); err != nil {
+			if err := i.Server().HandlePowerActionFrom(server.PowerTriggerInstall, server.PowerActionStart, 30); err != nil {
 				if errors.Is(err, context.DeadlineExceeded) {
 					log.WithFields(log.Fields{"server_id": i.Server().ID(), "action": "start"}).Warn("could not acquire a lock while attempting to perform a power action")
 				} else {
@@ -132,6 +244,16 @@ func postUpdateConfiguration(c *gin.Context) {
 		return
 	}
 
+	// Values set in the environment always take precedence over anything sent by the Panel.
+	if err := cfg.ApplyEnvironmentOverrides(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Keep the SSL certificates the same since the Panel will send through Lets Encrypt
 	// default locations. However, if we picked a different location manually we don't
 	// want to override that.
@@ -4,20 +4,24 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/router/middleware"
 	"github.com/pterodactyl/wings/router/tokens"
 	"github.com/pterodactyl/wings/server"
@@ -25,20 +29,90 @@ import (
 	"github.com/pterodactyl/wings/server/transfer"
 )
 
-// postTransfers .
-func postTransfers(c *gin.Context) {
+// parseTransferToken parses the transfer token issued by the Panel from the
+// request, aborting the request if it is missing or invalid.
+func parseTransferToken(c *gin.Context) (tokens.TransferPayload, bool) {
+	token := tokens.TransferPayload{}
 	auth := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
 	if len(auth) != 2 || auth[0] != "Bearer" {
 		c.Header("WWW-Authenticate", "Bearer")
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 			"error": "The required authorization heads were not present in the request.",
 		})
-		return
+		return token, false
 	}
 
-	token := tokens.TransferPayload{}
 	if err := tokens.ParseToken([]byte(auth[1]), &token); err != nil {
 		middleware.CaptureAndAbort(c, err)
+		return token, false
+	}
+	return token, true
+}
+
+// isTrustedProxy returns true if the address provided is one of the configured
+// trusted proxies.
+func isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, p := range config.Get().Api.TrustedProxies {
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			if n.Contains(ip) {
+				return true
+			}
+		} else if pip := net.ParseIP(p); pip != nil && pip.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// receivedOverTls returns true if the request was received over TLS, either
+// directly or by a trusted proxy in front of this node.
+func receivedOverTls(c *gin.Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	return c.GetHeader("X-Forwarded-Proto") == "https" && isTrustedProxy(net.ParseIP(c.RemoteIP()))
+}
+
+// getTransfers returns the transfers this node is able to receive, which is used
+// by the source node to choose the compression of the archive it sends.
+func getTransfers(c *gin.Context) {
+	if _, ok := parseTransferToken(c); !ok {
+		return
+	}
+	c.JSON(http.StatusOK, transfer.Capabilities{Compression: transfer.SupportedCompression})
+}
+
+// postTransfers .
+func postTransfers(c *gin.Context) {
+	token, ok := parseTransferToken(c)
+	if !ok {
+		return
+	}
+
+	// Check the source node against the configured handshake token and allowlist,
+	// in addition to the token issued by the Panel.
+	tcfg := config.Get().System.Transfers
+	if tcfg.HandshakeToken != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Wings-Transfer-Token")), []byte(tcfg.HandshakeToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "The transfer handshake token provided is not valid.",
+		})
+		return
+	}
+	if !tcfg.IsNodeAllowed(net.ParseIP(c.ClientIP()), c.GetHeader("X-Wings-Node")) {
+		log.WithFields(log.Fields{"ip": c.ClientIP(), "node": c.GetHeader("X-Wings-Node")}).Warn("rejected incoming server transfer from a node that is not allowed")
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "This node does not accept server transfers from the requesting node.",
+		})
+		return
+	}
+
+	if tcfg.RequireTls && !receivedOverTls(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "This node only accepts server transfers over HTTPS.",
+		})
 		return
 	}
 
@@ -54,6 +128,12 @@ func postTransfers(c *gin.Context) {
 		ctx    context.Context
 		cancel context.CancelFunc
 	)
+	checkpoint := &transfer.Checkpoint{
+		Server:    u.String(),
+		Direction: transfer.DirectionIncoming,
+		Status:    transfer.StatusProcessing,
+		StartedAt: time.Now(),
+	}
 	trnsfr := transfer.Incoming().Get(u.String())
 	if trnsfr == nil {
 		// TODO: should this use the request context?
@@ -81,18 +161,42 @@ func postTransfers(c *gin.Context) {
 		trnsfr.Server = i.Server()
 		transfer.Incoming().Add(trnsfr)
 	} else {
+		checkpoint = nil
 		ctx, cancel = context.WithCancel(trnsfr.Context())
 		defer cancel()
 	}
 
+	if checkpoint != nil {
+		if err := transfer.SaveCheckpoint(*checkpoint); err != nil {
+			trnsfr.Log().WithError(err).Warn("failed to save transfer checkpoint")
+		}
+	}
+
 	// Any errors past this point (until the transfer is complete) will abort
 	// the transfer.
 
-	successful := false
+	// interrupted is set if the request body could not be read, which happens when
+	// the source node is stopped during the transfer.
+	successful, interrupted := false, false
 	defer func(ctx context.Context, trnsfr *transfer.Transfer) {
 		// Remove the transfer from the list of incoming transfers.
 		transfer.Incoming().Remove(trnsfr)
 
+		// Keep the partial server data around while waiting for the source node to
+		// send the server again.
+		if !successful && interrupted && checkpoint != nil && config.Get().System.Transfers.Resumes() {
+			// The container is created again along with the server once the transfer
+			// is resumed.
+			if err := trnsfr.Server.Environment.Destroy(); err != nil {
+				trnsfr.Log().WithError(err).Warn("failed to remove container of partially transferred server")
+			}
+			manager.Remove(func(match *server.Server) bool {
+				return match.ID() == trnsfr.Server.ID()
+			})
+			transfer.AwaitResume(manager, *checkpoint)
+			return
+		}
+
 		if !successful {
 			trnsfr.Server.Events().Publish(server.TransferStatusEvent, "failure")
 			manager.Remove(func(match *server.Server) bool {
@@ -117,6 +221,7 @@ func postTransfers(c *gin.Context) {
 			return
 		}
 
+		transfer.RemoveCheckpoint(transfer.DirectionIncoming, trnsfr.Server.ID())
 		trnsfr.Server.SetTransferring(false)
 		trnsfr.Server.Events().Publish(server.TransferStatusEvent, "success")
 	}(ctx, trnsfr)
@@ -157,6 +262,7 @@ out:
 				break out
 			}
 			if err != nil {
+				interrupted = true
 				middleware.CaptureAndAbort(c, err)
 				return
 			}
@@ -171,8 +277,21 @@ out:
 					return
 				}
 
+				// The name of the archive identifies the compression used by the source
+				// node. Nodes that do not support configurable compression always send
+				// a gzip compressed archive.
+				name := p.FileName()
+				switch {
+				case name == "":
+					name = "archive.tar.gz"
+				case !strings.HasSuffix(name, ".tar") && !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".tar.zst"):
+					middleware.CaptureAndAbort(c, fmt.Errorf("unsupported transfer archive \"%s\", expected a tar, tar.gz, or tar.zst archive", name))
+					return
+				}
+
 				tee := io.TeeReader(p, h)
-				if err := trnsfr.Server.Filesystem().ExtractStreamUnsafe(ctx, "/", tee); err != nil {
+				if err := trnsfr.Server.Filesystem().ExtractStreamUnsafe(ctx, "/", name, tee); err != nil {
+					interrupted = errors.Is(err, io.ErrUnexpectedEOF)
 					middleware.CaptureAndAbort(c, err)
 					return
 				}
@@ -240,6 +359,15 @@ out:
 		return
 	}
 
+	// Record that the transfer only needs to be reported to the Panel, so that it
+	// is not rolled back if Wings is stopped before the Panel is notified.
+	if checkpoint != nil {
+		checkpoint.Status = transfer.StatusCompleted
+		if err := transfer.SaveCheckpoint(*checkpoint); err != nil {
+			trnsfr.Log().WithError(err).Warn("failed to save transfer checkpoint")
+		}
+	}
+
 	// Changing this causes us to notify the panel about a successful transfer,
 	// rather than failing the transfer like we do by default.
 	successful = true
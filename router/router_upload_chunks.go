@@ -0,0 +1,179 @@
+package router
+
+import (
+	"mime/multipart"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+// Files that are too large to be uploaded in a single request can be uploaded in
+// chunks, with each chunk sent as its own upload request:
+//
+//   - The request contains a single file holding the chunk, along with the offset
+//     of the chunk within the file and the total size of the file in the "offset"
+//     and "total_size" query parameters.
+//   - Chunks must be sent one at a time and in order, each starting where the
+//     previous chunk ended. A chunk at offset 0 starts the upload over, discarding
+//     anything received before.
+//   - A chunk that does not continue the upload in progress, or whose total size
+//     differs from the one the upload was started with, is rejected and the upload
+//     in progress is left untouched so that the correct chunk can be sent.
+//   - Each chunk is limited to api.upload_limit, and the total size of the file to
+//     the maximum assembled upload size. A total size over the maximum, or a chunk
+//     extending past the total size, also discards the upload in progress.
+//   - Chunks are written to a hidden ".<name>.upload" file next to the destination,
+//     which is moved into place once the final chunk is received.
+//   - An upload that has not received a chunk for chunkedUploadTimeout is treated
+//     as abandoned, and its partial file is removed when the next chunk of any
+//     upload is received.
+//
+// Rejected chunks are answered with a 400 response containing the reason.
+
+// chunkedUploadTimeout is how long a partially uploaded file is kept without
+// another chunk being received before it is removed.
+const chunkedUploadTimeout = time.Hour
+
+// chunkedUploadError is returned when a chunk is rejected because of a problem
+// with the request, the message of which is returned to the client.
+type chunkedUploadError struct {
+	message string
+}
+
+func (e *chunkedUploadError) Error() string {
+	return e.message
+}
+
+// chunkedUpload tracks a file that is being uploaded in chunks. The chunks are
+// appended to a hidden partial file next to the destination, which is renamed
+// to the destination once the final chunk is received.
+type chunkedUpload struct {
+	s       uploadTarget
+	partial string
+	total   int64
+	size    int64
+	busy    bool
+	updated time.Time
+}
+
+// uploadTarget is the server that a file is uploaded to, which is implemented by
+// server.Server.
+type uploadTarget interface {
+	ID() string
+	Filesystem() *filesystem.Filesystem
+}
+
+type chunkedUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*chunkedUpload
+}
+
+var uploads = &chunkedUploads{uploads: make(map[string]*chunkedUpload)}
+
+// partialUploadPath returns the path that the chunks of a file are written to
+// until the upload is complete.
+func partialUploadPath(p string) string {
+	return filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".upload")
+}
+
+// write writes a chunk of the file at p, starting at offset, where total is the
+// size of the assembled file. Chunks must be received in order, and a chunk at
+// offset 0 starts the upload over. True is returned once the final chunk has
+// been written and the file has been moved into place. If the chunk would cause
+// the file to exceed max bytes the partial upload is removed.
+func (u *chunkedUploads) write(s uploadTarget, p string, offset, total, max int64, header *multipart.FileHeader) (bool, error) {
+	u.prune()
+
+	key := s.ID() + ":" + filepath.Clean(p)
+	if total > max {
+		u.discard(key)
+		return false, &chunkedUploadError{message: "File " + header.Filename + " is larger than the maximum file upload size of " + strconv.FormatInt(max/1024/1024, 10) + " MB."}
+	}
+
+	u.mu.Lock()
+	e := u.uploads[key]
+	if e != nil && e.busy {
+		u.mu.Unlock()
+		return false, &chunkedUploadError{message: "Another chunk of " + header.Filename + " is currently being uploaded."}
+	}
+	if offset == 0 {
+		e = &chunkedUpload{s: s, partial: partialUploadPath(p), total: total}
+		u.uploads[key] = e
+	} else if e == nil || e.size != offset || e.total != total {
+		u.mu.Unlock()
+		return false, &chunkedUploadError{message: "The chunk of " + header.Filename + " does not continue the upload in progress."}
+	}
+	if offset+header.Size > total {
+		u.mu.Unlock()
+		u.discard(key)
+		return false, &chunkedUploadError{message: "The chunks of " + header.Filename + " are larger than the size of the file."}
+	}
+	e.busy = true
+	u.mu.Unlock()
+
+	err := writeChunk(s, e.partial, header, offset == 0)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	e.busy = false
+	e.updated = time.Now()
+	if err != nil {
+		u.remove(key)
+		return false, err
+	}
+	e.size += header.Size
+	if e.size < e.total {
+		return false, nil
+	}
+	delete(u.uploads, key)
+	if err := s.Filesystem().Rename(e.partial, p); err != nil {
+		_ = s.Filesystem().Delete(e.partial)
+		return false, err
+	}
+	return true, nil
+}
+
+func writeChunk(s uploadTarget, p string, header *multipart.FileHeader, truncate bool) error {
+	f, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Filesystem().Append(p, f, header.Size, truncate)
+}
+
+// discard removes a partial upload, if there is one that is not currently
+// being written to.
+func (u *chunkedUploads) discard(key string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if e, ok := u.uploads[key]; ok && !e.busy {
+		u.remove(key)
+	}
+}
+
+// prune removes partial uploads that have not received a chunk recently.
+func (u *chunkedUploads) prune() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for k, e := range u.uploads {
+		if !e.busy && time.Since(e.updated) > chunkedUploadTimeout {
+			u.remove(k)
+		}
+	}
+}
+
+// remove deletes a partial upload and its file. The lock must be held by the
+// caller.
+func (u *chunkedUploads) remove(key string) {
+	e := u.uploads[key]
+	delete(u.uploads, key)
+	if err := e.s.Filesystem().Delete(e.partial); err != nil {
+		log.WithField("server", e.s.ID()).WithField("path", e.partial).WithField("error", err).Warn("failed to remove partial upload")
+	}
+}
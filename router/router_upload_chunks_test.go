@@ -0,0 +1,159 @@
+package router
+
+import (
+	"bytes"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+type testUploadTarget struct {
+	fs *filesystem.Filesystem
+}
+
+func (t *testUploadTarget) ID() string {
+	return "test"
+}
+
+func (t *testUploadTarget) Filesystem() *filesystem.Filesystem {
+	return t.fs
+}
+
+// chunk returns a file header for a chunk containing the data provided, parsed
+// from a multipart form in the same way as an upload request.
+func chunk(data string) *multipart.FileHeader {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	f, err := w.CreateFormFile("files", "file.txt")
+	if err != nil {
+		panic(err)
+	}
+	_, _ = f.Write([]byte(data))
+	_ = w.Close()
+	form, err := multipart.NewReader(&b, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		panic(err)
+	}
+	return form.File["files"][0]
+}
+
+func TestChunkedUploads(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	var root string
+	var s *testUploadTarget
+	var u *chunkedUploads
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(root, name))
+		g.Assert(err).IsNil()
+		return string(b)
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+	isChunkError := func(err error) bool {
+		var cerr *chunkedUploadError
+		return errors.As(err, &cerr)
+	}
+
+	g.Describe("chunkedUploads.write", func() {
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{AuthenticationToken: "abc"})
+			root = t.TempDir()
+			fs, err := filesystem.New(root, 0, []string{})
+			if err != nil {
+				panic(err)
+			}
+			s = &testUploadTarget{fs: fs}
+			u = &chunkedUploads{uploads: make(map[string]*chunkedUpload)}
+		})
+
+		g.It("assembles the chunks and moves the file into place", func() {
+			done, err := u.write(s, "file.txt", 0, 10, 100, chunk("hello"))
+			g.Assert(err).IsNil()
+			g.Assert(done).IsFalse()
+			g.Assert(exists("file.txt")).IsFalse()
+			g.Assert(read(".file.txt.upload")).Equal("hello")
+
+			done, err = u.write(s, "file.txt", 5, 10, 100, chunk("world"))
+			g.Assert(err).IsNil()
+			g.Assert(done).IsTrue()
+			g.Assert(read("file.txt")).Equal("helloworld")
+			g.Assert(exists(".file.txt.upload")).IsFalse()
+		})
+
+		g.It("rejects out of order chunks without losing the upload", func() {
+			_, err := u.write(s, "file.txt", 0, 15, 100, chunk("hello"))
+			g.Assert(err).IsNil()
+
+			_, err = u.write(s, "file.txt", 10, 15, 100, chunk("again"))
+			g.Assert(isChunkError(err)).IsTrue()
+			_, err = u.write(s, "other.txt", 5, 15, 100, chunk("world"))
+			g.Assert(isChunkError(err)).IsTrue()
+
+			_, err = u.write(s, "file.txt", 5, 15, 100, chunk("world"))
+			g.Assert(err).IsNil()
+			done, err := u.write(s, "file.txt", 10, 15, 100, chunk("again"))
+			g.Assert(err).IsNil()
+			g.Assert(done).IsTrue()
+			g.Assert(read("file.txt")).Equal("helloworldagain")
+		})
+
+		g.It("starts the upload over from a chunk at offset 0", func() {
+			_, err := u.write(s, "file.txt", 0, 10, 100, chunk("aaaaa"))
+			g.Assert(err).IsNil()
+			_, err = u.write(s, "file.txt", 0, 10, 100, chunk("hello"))
+			g.Assert(err).IsNil()
+			done, err := u.write(s, "file.txt", 5, 10, 100, chunk("world"))
+			g.Assert(err).IsNil()
+			g.Assert(done).IsTrue()
+			g.Assert(read("file.txt")).Equal("helloworld")
+		})
+
+		g.It("rejects a chunk with a different total size", func() {
+			_, err := u.write(s, "file.txt", 0, 10, 100, chunk("hello"))
+			g.Assert(err).IsNil()
+			_, err = u.write(s, "file.txt", 5, 12, 100, chunk("world"))
+			g.Assert(isChunkError(err)).IsTrue()
+			g.Assert(read(".file.txt.upload")).Equal("hello")
+		})
+
+		g.It("discards the upload when the chunks exceed the total size", func() {
+			_, err := u.write(s, "file.txt", 0, 8, 100, chunk("hello"))
+			g.Assert(err).IsNil()
+			_, err = u.write(s, "file.txt", 5, 8, 100, chunk("world"))
+			g.Assert(isChunkError(err)).IsTrue()
+			g.Assert(exists(".file.txt.upload")).IsFalse()
+			g.Assert(len(u.uploads)).Equal(0)
+		})
+
+		g.It("rejects files larger than the maximum size", func() {
+			_, err := u.write(s, "file.txt", 0, 101, 100, chunk("hello"))
+			g.Assert(isChunkError(err)).IsTrue()
+			g.Assert(exists(".file.txt.upload")).IsFalse()
+		})
+
+		g.It("removes abandoned partial uploads", func() {
+			_, err := u.write(s, "file.txt", 0, 10, 100, chunk("hello"))
+			g.Assert(err).IsNil()
+			_, err = u.write(s, "recent.txt", 0, 10, 100, chunk("hello"))
+			g.Assert(err).IsNil()
+			u.uploads["test:file.txt"].updated = time.Now().Add(-chunkedUploadTimeout - time.Minute)
+
+			u.prune()
+			g.Assert(exists(".file.txt.upload")).IsFalse()
+			g.Assert(exists(".recent.txt.upload")).IsTrue()
+			_, err = u.write(s, "file.txt", 5, 10, 100, chunk("world"))
+			g.Assert(isChunkError(err)).IsTrue()
+		})
+	})
+}
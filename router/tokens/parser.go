@@ -6,6 +6,7 @@ import (
 	"github.com/gbrlsnchs/jwt/v3"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
 )
 
 type TokenData interface {
@@ -16,14 +17,40 @@ type TokenData interface {
 // parsed data. This function DOES NOT validate that the token is valid for the connected
 // server, nor does it ensure that the user providing the token is able to actually do things.
 //
-// This simply returns a parsed token.
+// This simply returns a parsed token. If the clock of this node was recently measured to
+// differ too much from the Panel and the configuration refuses tokens in that case, the
+// token is rejected without being parsed.
 func ParseToken(token []byte, data TokenData) error {
-	verifyOptions := jwt.ValidatePayload(
-		data.GetPayload(),
-		jwt.ExpirationTimeValidator(time.Now()),
-	)
+	if err := remote.CheckClockSkew(); err != nil {
+		return err
+	}
+	verifyOptions := jwt.ValidatePayload(data.GetPayload(), timeValidators()...)
 
 	_, err := jwt.Verify(token, config.GetJwtAlgorithm(), &data, verifyOptions)
 
 	return err
 }
+
+// ValidateTime checks that the expiration and not-before times of the payload are
+// valid, allowing for the clock skew defined in the configuration. Like ParseToken, this
+// refuses the token while a recent measurement of the clock skew exceeds the threshold.
+func ValidateTime(p *jwt.Payload) error {
+	if err := remote.CheckClockSkew(); err != nil {
+		return err
+	}
+	for _, v := range timeValidators() {
+		if err := v(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func timeValidators() []jwt.Validator {
+	now := time.Now()
+	skew := time.Duration(config.Get().System.JwtClockSkew) * time.Second
+	return []jwt.Validator{
+		jwt.ExpirationTimeValidator(now.Add(-skew)),
+		jwt.NotBeforeValidator(now.Add(skew)),
+	}
+}
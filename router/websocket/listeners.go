@@ -7,7 +7,9 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/goccy/go-json"
+	"github.com/gorilla/websocket"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/events"
 	"github.com/pterodactyl/wings/system"
 
@@ -43,7 +45,12 @@ func (h *Handler) registerListenerEvents(ctx context.Context) {
 // until the token has expired. If we are within 3 minutes of the token expiring,
 // send a notice over the socket that it is expiring soon. If it has expired,
 // send that notice as well.
+//
+// If token refresh is disabled the client is not told that the token is expiring
+// since it cannot replace it, and the connection is closed once it has expired.
 func (h *Handler) listenForExpiration(ctx context.Context) {
+	refresh := config.Get().Api.Websocket.TokenRefresh
+
 	// Make a ticker and completion channel that is used to continuously poll the
 	// JWT stored in the session to send events to the socket when it is expiring.
 	ticker := time.NewTicker(time.Second * 30)
@@ -58,7 +65,12 @@ func (h *Handler) listenForExpiration(ctx context.Context) {
 			if jwt != nil {
 				if jwt.ExpirationTime.Unix()-time.Now().Unix() <= 0 {
 					_ = h.SendJson(Message{Event: TokenExpiredEvent})
-				} else if jwt.ExpirationTime.Unix()-time.Now().Unix() <= 60 {
+					if !refresh {
+						_ = h.Connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "token expired"), time.Now().Add(time.Second*5))
+						_ = h.Connection.Close()
+						return
+					}
+				} else if refresh && jwt.ExpirationTime.Unix()-time.Now().Unix() <= 60 {
 					_ = h.SendJson(Message{Event: TokenExpiringEvent})
 				}
 			}
@@ -23,6 +23,7 @@ import (
 	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
 	"github.com/pterodactyl/wings/environment/docker"
+	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/router/tokens"
 	"github.com/pterodactyl/wings/server"
 )
@@ -46,6 +47,12 @@ type Handler struct {
 	server       *server.Server
 	ra           server.RequestActivity
 	uuid         uuid.UUID
+
+	// slotMu guards slot, which is whether the connection holds one of the
+	// connection slots of the server. A slot is only taken once the connection
+	// has authenticated.
+	slotMu sync.Mutex
+	slot   bool
 }
 
 var (
@@ -53,6 +60,8 @@ var (
 	ErrJwtNoConnectPerm = errors.New("jwt: missing connect permission")
 	ErrJwtUuidMismatch  = errors.New("jwt: server uuid mismatch")
 	ErrJwtOnDenylist    = errors.New("jwt: created too far in past (denylist)")
+	ErrJwtUserMismatch  = errors.New("jwt: user uuid mismatch")
+	ErrJwtNoRefresh     = errors.New("jwt: token refresh is disabled")
 )
 
 func IsJwtError(err error) bool {
@@ -60,6 +69,9 @@ func IsJwtError(err error) bool {
 		errors.Is(err, ErrJwtNoConnectPerm) ||
 		errors.Is(err, ErrJwtUuidMismatch) ||
 		errors.Is(err, ErrJwtOnDenylist) ||
+		errors.Is(err, ErrJwtUserMismatch) ||
+		errors.Is(err, ErrJwtNoRefresh) ||
+		errors.Is(err, remote.ErrClockSkew) ||
 		errors.Is(err, jwt.ErrExpValidation)
 }
 
@@ -104,6 +116,9 @@ func GetHandler(s *server.Server, w http.ResponseWriter, r *http.Request, c *gin
 	if err != nil {
 		return nil, err
 	}
+	// Any message larger than this limit will cause the connection to be closed
+	// with a "message too big" close code when it is read.
+	conn.SetReadLimit(config.Get().Api.Websocket.MaxMessageBytes)
 
 	u, err := uuid.NewRandom()
 	if err != nil {
@@ -119,6 +134,29 @@ func GetHandler(s *server.Server, w http.ResponseWriter, r *http.Request, c *gin
 	}, nil
 }
 
+// acquireSlot reserves a connection slot on the server for this connection if it
+// does not already hold one, returning false if the server has too many open
+// connections.
+func (h *Handler) acquireSlot() bool {
+	h.slotMu.Lock()
+	defer h.slotMu.Unlock()
+	if !h.slot {
+		h.slot = h.server.Websockets().Acquire(config.Get().Api.Websocket.MaxConnectionsPerServer)
+	}
+	return h.slot
+}
+
+// ReleaseSlot frees the connection slot held by this connection, if any. This
+// should be called once the connection is closed.
+func (h *Handler) ReleaseSlot() {
+	h.slotMu.Lock()
+	defer h.slotMu.Unlock()
+	if h.slot {
+		h.server.Websockets().Release()
+		h.slot = false
+	}
+}
+
 func (h *Handler) Uuid() uuid.UUID {
 	return h.uuid
 }
@@ -200,7 +238,7 @@ func (h *Handler) TokenValid() error {
 		return ErrJwtNotPresent
 	}
 
-	if err := jwt.ExpirationTimeValidator(time.Now())(&j.Payload); err != nil {
+	if err := tokens.ValidateTime(&j.Payload); err != nil {
 		return err
 	}
 
@@ -275,6 +313,21 @@ func (h *Handler) setJwt(token *tokens.WebsocketPayload) {
 	h.Unlock()
 }
 
+// validateRefresh checks that the token provided can replace the current token of
+// the connection.
+func (h *Handler) validateRefresh(current *tokens.WebsocketPayload, token *tokens.WebsocketPayload) error {
+	if !config.Get().Api.Websocket.TokenRefresh {
+		return ErrJwtNoRefresh
+	}
+	if token.GetServerUuid() != h.server.ID() {
+		return ErrJwtUuidMismatch
+	}
+	if token.UserUUID != current.UserUUID {
+		return ErrJwtUserMismatch
+	}
+	return nil
+}
+
 // HandleInbound handles an inbound socket request and route it to the proper action.
 func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
 	if m.Event != AuthenticationEvent {
@@ -295,8 +348,24 @@ func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
 				return err
 			}
 
-			// Check if the user has previously authenticated successfully.
-			newConnection := h.GetJwt() == nil
+			// Check if the user has previously authenticated successfully. If so the client
+			// is refreshing its token, which must be for the same server and user as the
+			// token it is replacing.
+			current := h.GetJwt()
+			newConnection := current == nil
+			if !newConnection {
+				if err := h.validateRefresh(current, token); err != nil {
+					return err
+				}
+			}
+
+			// Only authenticated connections count towards the connection limit of the
+			// server, so that unauthenticated clients cannot use up every slot.
+			if newConnection && !h.acquireSlot() {
+				h.Logger().Warn("closing websocket connection: too many open console connections for server")
+				_ = h.Connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too many open console connections"), time.Now().Add(time.Second*5))
+				return h.Connection.Close()
+			}
 
 			// Previously there was a HasPermission(PermissionConnect) check around this,
 			// however NewTokenPayload will return an error if it doesn't have the connect
@@ -360,7 +429,7 @@ func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
 				}
 			}
 
-			err := h.server.HandlePowerAction(action)
+			err := h.server.HandlePowerActionFrom(server.PowerTriggerUser, action)
 			if errors.Is(err, system.ErrLockerLocked) {
 				m, _ := h.GetErrorMessage("当前正在为此服务器处理另一个电源操作，请稍后重试")
 
@@ -386,15 +455,20 @@ func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
 				return nil
 			}
 
-			logs, err := h.server.Environment.Readlog(config.Get().System.WebsocketLogCount)
-			if err != nil {
-				return err
+			var logs []string
+			if history := h.server.ConsoleHistory(); history != nil {
+				logs = history.Lines()
+			} else {
+				var err error
+				if logs, err = h.server.Environment.Readlog(config.Get().System.WebsocketLogCount); err != nil {
+					return err
+				}
 			}
 
 			for _, line := range logs {
 				_ = h.SendJson(Message{
 					Event: server.ConsoleOutputEvent,
-					Args:  []string{line},
+					Args:  []string{string(server.SanitizeConsoleOutput([]byte(line)))},
 				})
 			}
 
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/environment/docker"
+)
+
+// ActivityProbe detects activity on a server so that servers which are idle can
+// be stopped automatically.
+type ActivityProbe interface {
+	// Active returns whether there has been any activity on the server since the
+	// last time it was checked.
+	Active(ctx context.Context, s *Server) (bool, error)
+}
+
+// activityProbes are the probes that can be selected in the configuration.
+var activityProbes = map[string]ActivityProbe{
+	config.AutoStopProbeConnections: connectionsProbe{},
+	config.AutoStopProbeNetwork:     networkProbe{},
+	config.AutoStopProbeLogPattern:  logPatternProbe{},
+}
+
+// autoStopState tracks the activity of a server for automatically stopping it
+// once it has been idle.
+type autoStopState struct {
+	mu         sync.Mutex
+	lastActive time.Time
+	lastRx     uint64
+	logMatched atomic.Bool
+	wake       net.Listener
+}
+
+// AutoStopIfIdle checks the server for activity using the configured probe and
+// stops it if it has been idle for longer than the idle timeout. If the server
+// is stopped and wake on connection is enabled, the server is started again the
+// next time a connection is made to its default allocation.
+func (s *Server) AutoStopIfIdle(ctx context.Context) error {
+	cfg := config.Get().System.AutoStop
+	if s.IsSuspended() || s.IsInstalling() || s.IsTransferring() || s.IsRestoring() {
+		return nil
+	}
+
+	st := &s.autoStop
+	if s.Environment.State() != environment.ProcessRunningState {
+		// Start timing from the first check after the server is running again.
+		st.mu.Lock()
+		st.lastActive = time.Time{}
+		st.mu.Unlock()
+		return nil
+	}
+
+	probe, ok := activityProbes[cfg.Probe]
+	if !ok {
+		return errors.Errorf("server: unknown activity probe \"%s\"", cfg.Probe)
+	}
+	active, err := probe.Active(ctx, s)
+	if err != nil {
+		// Never stop a server because its activity could not be determined.
+		s.Log().WithField("error", err).Debug("failed to probe server for activity, assuming it is active")
+		active = true
+	}
+
+	now := time.Now()
+	st.mu.Lock()
+	if active || st.lastActive.IsZero() {
+		st.lastActive = now
+		st.mu.Unlock()
+		return nil
+	}
+	idle := now.Sub(st.lastActive)
+	st.mu.Unlock()
+	if idle < time.Duration(cfg.IdleTimeout)*time.Second {
+		return nil
+	}
+
+	s.Log().WithField("idle", idle.Round(time.Second)).WithField("probe", cfg.Probe).Info("auto-stopping server after being idle")
+	s.PublishConsoleOutputFromDaemon("服务器空闲时间过长，正在自动停止...")
+	if err := s.HandlePowerActionFrom(PowerTriggerAutoStop, PowerActionStop, 30); err != nil {
+		return errors.WithMessage(err, "server: failed to auto-stop idle server")
+	}
+	if cfg.WakeOnConnection {
+		s.listenForWake()
+	}
+	return nil
+}
+
+// observeAutoStopOutput records a line of console output as activity if it
+// matches the pattern of the log pattern probe.
+func (s *Server) observeAutoStopOutput(v []byte) {
+	cfg := config.Get().System.AutoStop
+	if !cfg.Enabled || cfg.Probe != config.AutoStopProbeLogPattern {
+		return
+	}
+	if re := autoStopPattern(cfg.LogPattern); re != nil && re.Match(v) {
+		s.autoStop.logMatched.Store(true)
+	}
+}
+
+var autoStopPatterns sync.Map
+
+// autoStopPattern returns the compiled log pattern, or nil if it is invalid.
+func autoStopPattern(pattern string) *regexp.Regexp {
+	if v, ok := autoStopPatterns.Load(pattern); ok {
+		return v.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	autoStopPatterns.Store(pattern, re)
+	return re
+}
+
+// listenForWake listens for TCP connections on the default allocation of the
+// server, which is free while the server is stopped, and starts the server when
+// one is made.
+func (s *Server) listenForWake() {
+	a := s.Config().Allocations.DefaultMapping
+	addr := net.JoinHostPort(a.Ip, strconv.Itoa(a.Port))
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		s.Log().WithField("address", addr).WithField("error", err).Warn("failed to listen for connections to wake idle server")
+		return
+	}
+	s.autoStop.mu.Lock()
+	s.autoStop.wake = l
+	s.autoStop.mu.Unlock()
+	s.Log().WithField("address", addr).Info("listening for connections to wake idle server")
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-s.Context().Done():
+			s.closeWakeListener()
+		case <-done:
+		}
+	}()
+	go func() {
+		defer close(done)
+		conn, err := l.Accept()
+		if err != nil {
+			// The listener was closed because the server was started some other way.
+			return
+		}
+		_ = conn.Close()
+		s.closeWakeListener()
+
+		s.Log().WithField("remote_addr", conn.RemoteAddr().String()).Info("auto-starting idle server after incoming connection")
+		if err := s.HandlePowerActionFrom(PowerTriggerWake, PowerActionStart, 30); err != nil {
+			s.Log().WithField("error", err).Error("failed to auto-start idle server")
+		}
+	}()
+}
+
+// closeWakeListener stops listening for connections to wake the server, which
+// must happen before the server is started so that its ports can be bound.
+func (s *Server) closeWakeListener() {
+	s.autoStop.mu.Lock()
+	defer s.autoStop.mu.Unlock()
+	if s.autoStop.wake != nil {
+		_ = s.autoStop.wake.Close()
+		s.autoStop.wake = nil
+	}
+}
+
+// connectionsProbe considers a server active if there are any established TCP
+// connections to its ports within the network namespace of its container.
+type connectionsProbe struct{}
+
+func (connectionsProbe) Active(ctx context.Context, s *Server) (bool, error) {
+	e, ok := s.Environment.(*docker.Environment)
+	if !ok {
+		return true, nil
+	}
+	c, err := e.ContainerInspect(ctx)
+	if err != nil {
+		return false, err
+	}
+	if c.State == nil || c.State.Pid == 0 {
+		return false, errors.New("server: container does not have a running process")
+	}
+
+	ports := make(map[uint64]bool)
+	for _, p := range s.Config().Allocations.Mappings {
+		for _, port := range p {
+			ports[uint64(port)] = true
+		}
+	}
+	for _, name := range []string{"tcp", "tcp6"} {
+		n, err := establishedConnections("/proc/"+strconv.Itoa(c.State.Pid)+"/net/"+name, ports)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// establishedConnections returns the number of established connections in the
+// /proc/net/tcp formatted file that have one of the given local ports.
+func establishedConnections(p string, ports map[uint64]bool) (int, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var n int
+	sc := bufio.NewScanner(f)
+	sc.Scan() // Skip the header line.
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		// The state of the socket is in the fourth field, where "01" is TCP_ESTABLISHED.
+		if len(fields) < 4 || fields[3] != "01" {
+			continue
+		}
+		i := strings.LastIndexByte(fields[1], ':')
+		if i < 0 {
+			continue
+		}
+		if port, err := strconv.ParseUint(fields[1][i+1:], 16, 16); err == nil && ports[port] {
+			n++
+		}
+	}
+	return n, sc.Err()
+}
+
+// networkProbe considers a server active if its container has received enough
+// data since the last check.
+type networkProbe struct{}
+
+func (networkProbe) Active(_ context.Context, s *Server) (bool, error) {
+	rx := s.Proc().Network.RxBytes
+
+	s.autoStop.mu.Lock()
+	defer s.autoStop.mu.Unlock()
+	prev := s.autoStop.lastRx
+	s.autoStop.lastRx = rx
+	// The counter is reset when the container restarts.
+	if rx < prev {
+		return true, nil
+	}
+	return rx-prev >= config.Get().System.AutoStop.MinNetworkBytes, nil
+}
+
+// logPatternProbe considers a server active if a line of console output has
+// matched the configured pattern since the last check.
+type logPatternProbe struct{}
+
+func (logPatternProbe) Active(_ context.Context, s *Server) (bool, error) {
+	return s.autoStop.logMatched.Swap(false), nil
+}
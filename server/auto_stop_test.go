@@ -0,0 +1,32 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func TestEstablishedConnections(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("establishedConnections", func() {
+		g.It("counts established connections to the given ports", func() {
+			p := filepath.Join(t.TempDir(), "tcp")
+			data := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+				"   0: 00000000:63DD 00000000:0000 0A 00000000:00000000 00:00000000 00000000   988        0 1 1 0000000000000000 100 0 0 10 0\n" +
+				"   1: 0200A8C0:63DD 0100A8C0:D431 01 00000000:00000000 00:00000000 00000000   988        0 2 1 0000000000000000 20 4 30 10 -1\n" +
+				"   2: 0200A8C0:1F90 0100A8C0:D432 01 00000000:00000000 00:00000000 00000000   988        0 3 1 0000000000000000 20 4 30 10 -1\n"
+			g.Assert(os.WriteFile(p, []byte(data), 0o600)).IsNil()
+
+			n, err := establishedConnections(p, map[uint64]bool{25565: true})
+			g.Assert(err).IsNil()
+			g.Assert(n).Equal(1)
+
+			n, err = establishedConnections(p, map[uint64]bool{25566: true})
+			g.Assert(err).IsNil()
+			g.Assert(n).Equal(0)
+		})
+	})
+}
@@ -4,17 +4,67 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/docker/docker/client"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/server/backup"
 )
 
+var (
+	restoreSlotsOnce sync.Once
+	restoreSlots     chan struct{}
+
+	restoresQueued atomic.Int64
+	restoresActive atomic.Int64
+)
+
+// BackupRestores returns the number of backup restorations that are currently in
+// progress, and the number that are queued waiting for another restoration to
+// complete.
+func BackupRestores() (active int64, queued int64) {
+	return restoresActive.Load(), restoresQueued.Load()
+}
+
+// acquireRestoreSlot blocks until fewer than system.backups.max_concurrent_restores
+// backups are being restored, returning a function that must be called once the
+// restoration is complete.
+func (s *Server) acquireRestoreSlot() (func(), error) {
+	restoreSlotsOnce.Do(func() {
+		n := config.Get().System.Backups.MaxConcurrentRestores
+		if n < 1 {
+			n = 1
+		}
+		restoreSlots = make(chan struct{}, n)
+	})
+	restoresQueued.Add(1)
+	select {
+	case restoreSlots <- struct{}{}:
+	default:
+		s.Events().Publish(DaemonMessageEvent, "Waiting for other backup restorations on this node to complete...")
+		select {
+		case restoreSlots <- struct{}{}:
+		case <-s.Context().Done():
+			restoresQueued.Add(-1)
+			return nil, s.Context().Err()
+		}
+	}
+	restoresQueued.Add(-1)
+	restoresActive.Add(1)
+	return func() {
+		restoresActive.Add(-1)
+		<-restoreSlots
+	}, nil
+}
+
 // Notifies the panel of a backup's state and returns an error if one is encountered
 // while performing this action.
 func (s *Server) notifyPanelOfBackup(uuid string, ad *backup.ArchiveDetails, successful bool) error {
@@ -54,20 +104,60 @@ func (s *Server) getServerwideIgnoredFiles() (string, error) {
 	return string(b), nil
 }
 
-// Backup performs a server backup and then emits the event over the server
-// websocket. We let the actual backup system handle notifying the panel of the
-// status, but that won't emit a websocket event.
-func (s *Server) Backup(b backup.BackupInterface) error {
+// Returns the ignored files for a backup, falling back to the server-wide
+// .pteroignore file if the backup does not define any itself. The backup
+// exclusion patterns are placed first, so that the ignored files can include
+// an excluded file again.
+func (s *Server) backupIgnoredFiles(b backup.BackupInterface) string {
 	ignored := b.Ignored()
-	if b.Ignored() == "" {
-		if i, err := s.getServerwideIgnoredFiles(); err != nil {
+	if ignored == "" {
+		i, err := s.getServerwideIgnoredFiles()
+		if err != nil {
 			log.WithField("server", s.ID()).WithField("error", err).Warn("failed to get server-wide ignored files")
+		}
+		ignored = i
+	}
+	exclude := s.backupExclude()
+	if len(exclude) == 0 {
+		return ignored
+	}
+	return strings.Join(append(exclude, ignored), "\n")
+}
+
+// backupExclude returns the patterns of the files left out of backups of the
+// server, falling back to the defaults from the configuration if the server
+// does not define any or if they are not valid.
+func (s *Server) backupExclude() []string {
+	exclude := config.Get().System.Backups.Exclude
+	if override := s.Config().BackupExclude; len(override) > 0 {
+		if err := config.ValidateBackupExclude(override); err != nil {
+			s.Log().WithField("error", err).Warn("ignoring invalid backup exclusion patterns assigned to server")
 		} else {
-			ignored = i
+			exclude = override
 		}
 	}
+	out := make([]string, len(exclude))
+	copy(out, exclude)
+	return out
+}
 
-	ad, err := b.Generate(s.Context(), s.Filesystem(), ignored)
+// ScheduledBackup generates a backup that was triggered by the node itself rather
+// than by the Panel. Since the Panel has no record of these backups it is not
+// notified of their state, and the archive is pruned by the backup cron according
+// to the configured retention.
+func (s *Server) ScheduledBackup(b backup.BackupInterface) (*backup.ArchiveDetails, error) {
+	ad, err := b.Generate(s.Context(), s.Filesystem(), s.backupIgnoredFiles(b))
+	if err != nil {
+		return nil, errors.WrapIf(err, "backup: error while generating scheduled server backup")
+	}
+	return ad, nil
+}
+
+// Backup performs a server backup and then emits the event over the server
+// websocket. We let the actual backup system handle notifying the panel of the
+// status, but that won't emit a websocket event.
+func (s *Server) Backup(b backup.BackupInterface) error {
+	ad, err := b.Generate(s.Context(), s.Filesystem(), s.backupIgnoredFiles(b))
 	if err != nil {
 		if err := s.notifyPanelOfBackup(b.Identifier(), &backup.ArchiveDetails{}, false); err != nil {
 			s.Log().WithFields(log.Fields{
@@ -100,6 +190,10 @@ func (s *Server) Backup(b backup.BackupInterface) error {
 		s.Log().WithField("backup", b.Identifier()).Info("notified panel of successful backup state")
 	}
 
+	if ad.RemoteCopyError != "" {
+		s.PublishConsoleOutputFromDaemon("备份已创建，但无法复制到远程存储: " + ad.RemoteCopyError)
+	}
+
 	// Emit an event over the socket so we can update the backup in realtime on
 	// the frontend for the server.
 	s.Events().Publish(BackupCompletedEvent+":"+b.Identifier(), map[string]interface{}{
@@ -119,16 +213,13 @@ func (s *Server) Backup(b backup.BackupInterface) error {
 //
 // In addition to the websocket event an API call is triggered to notify the
 // Panel of the new state.
-func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser) (err error) {
+//
+// Remote backups pass an open function that starts the download of the backup,
+// which is only called once a restoration slot is available so that the download
+// does not sit idle while waiting for other restorations. Local backups pass nil.
+func (s *Server) RestoreBackup(b backup.BackupInterface, open func() (io.ReadCloser, error)) (err error) {
 	s.Config().SetSuspended(true)
-	// Local backups will not pass a reader through to this function, so check first
-	// to make sure it is a valid reader before trying to close it.
-	defer func() {
-		s.Config().SetSuspended(false)
-		if reader != nil {
-			_ = reader.Close()
-		}
-	}()
+	defer s.Config().SetSuspended(false)
 	// Send an API call to the Panel as soon as this function is done running so that
 	// the Panel is informed of the restoration status of this backup.
 	defer func() {
@@ -137,6 +228,22 @@ func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser) (
 		}
 	}()
 
+	// Limit the number of restorations running at once so that restoring many
+	// servers does not overwhelm the disk of the node.
+	release, err := s.acquireRestoreSlot()
+	if err != nil {
+		return errors.WrapIf(err, "server/backup: restore: failed to wait for other restorations")
+	}
+	defer release()
+
+	var reader io.ReadCloser
+	if open != nil {
+		if reader, err = open(); err != nil {
+			return errors.WrapIf(err, "server/backup: restore: failed to download backup")
+		}
+		defer reader.Close()
+	}
+
 	// Don't try to restore the server until we have completely stopped the running
 	// instance, otherwise you'll likely hit all types of write errors due to the
 	// server being suspended.
@@ -6,8 +6,10 @@ import (
 	"encoding/hex"
 	"io"
 	"io/fs"
+	"mime"
 	"os"
 	"path"
+	"strings"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
@@ -19,9 +21,35 @@ import (
 	"github.com/pterodactyl/wings/server/filesystem"
 )
 
-var format = archiver.CompressedArchive{
-	Compression: archiver.Gz{},
-	Archival:    archiver.Tar{},
+// formats are the archive formats that backups may have been created in. The
+// format of a backup is detected from its contents when it is restored, since
+// the configured format may have changed since the backup was created.
+var formats = []string{config.BackupFormatTarGz, config.BackupFormatZip, config.BackupFormatZstd}
+
+// contentTypes are the content types of each backup format, the first of which is
+// sent when uploading a backup. Any of them are accepted when downloading a backup
+// to restore it.
+var contentTypes = map[string][]string{
+	config.BackupFormatTarGz: {"application/x-gzip", "application/gzip"},
+	config.BackupFormatZip:   {"application/zip", "application/x-zip-compressed"},
+	config.BackupFormatZstd:  {"application/zstd"},
+}
+
+// IsContentType returns whether the content type is one of a supported backup
+// format. Any parameters of the content type are ignored.
+func IsContentType(v string) bool {
+	mt, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return false
+	}
+	for _, f := range formats {
+		for _, t := range contentTypes[f] {
+			if mt == t {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 type AdapterType string
@@ -90,9 +118,34 @@ func (b *Backup) Identifier() string {
 	return b.Uuid
 }
 
-// Path returns the path for this specific backup.
+// Path returns the path for this specific backup. If the backup already exists
+// on the disk its existing path is returned, otherwise the path is based on the
+// configured backup format.
 func (b *Backup) Path() string {
-	return path.Join(config.Get().System.BackupDirectory, b.Identifier()+".tar.gz")
+	dir := config.Get().System.BackupDirectory
+	for _, f := range formats {
+		p := path.Join(dir, b.Identifier()+filesystem.ArchiveExtension(f))
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return path.Join(dir, b.Identifier()+filesystem.ArchiveExtension(config.Get().System.Backups.Format))
+}
+
+// Format returns the archive format of this backup based on its path.
+func (b *Backup) Format() string {
+	p := b.Path()
+	for _, f := range formats {
+		if strings.HasSuffix(p, filesystem.ArchiveExtension(f)) {
+			return f
+		}
+	}
+	return config.BackupFormatTarGz
+}
+
+// ContentType returns the content type of this backup based on its format.
+func (b *Backup) ContentType() string {
+	return contentTypes[b.Format()][0]
 }
 
 // Size returns the size of the generated backup.
@@ -126,7 +179,7 @@ func (b *Backup) Checksum() ([]byte, error) {
 // Details returns both the checksum and size of the archive currently stored on
 // the disk to the caller.
 func (b *Backup) Details(ctx context.Context, parts []remote.BackupPart) (*ArchiveDetails, error) {
-	ad := ArchiveDetails{ChecksumType: "sha1", Parts: parts}
+	ad := ArchiveDetails{ChecksumType: "sha1", Format: b.Format(), Parts: parts}
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
@@ -171,7 +224,11 @@ type ArchiveDetails struct {
 	Checksum     string              `json:"checksum"`
 	ChecksumType string              `json:"checksum_type"`
 	Size         int64               `json:"size"`
+	Format       string              `json:"format"`
 	Parts        []remote.BackupPart `json:"parts"`
+	// RemoteCopyError is set if a local backup was created but could not be copied
+	// to the remote storage bucket. This is not sent to the Panel.
+	RemoteCopyError string `json:"-"`
 }
 
 // ToRequest returns a request object.
@@ -180,7 +237,52 @@ func (ad *ArchiveDetails) ToRequest(successful bool) remote.BackupRequest {
 		Checksum:     ad.Checksum,
 		ChecksumType: ad.ChecksumType,
 		Size:         ad.Size,
+		Format:       ad.Format,
 		Successful:   successful,
 		Parts:        ad.Parts,
 	}
 }
+
+// extractArchive detects the format of the archive in the reader provided and
+// calls the handler for each of the files within it.
+func extractArchive(ctx context.Context, r io.Reader, handler archiver.FileHandler) error {
+	format, input, err := archiver.Identify("", r)
+	if err != nil {
+		return errors.Wrap(err, "backup: failed to identify archive format")
+	}
+	if _, ok := format.(archiver.Zip); ok {
+		return extractZip(ctx, r, input, handler)
+	}
+	ex, ok := format.(archiver.Extractor)
+	if !ok {
+		return errors.Errorf("backup: archive format %s cannot be extracted", format.Name())
+	}
+	return ex.Extract(ctx, input, nil, handler)
+}
+
+// extractZip extracts a zip archive. Zip archives cannot be read as a stream, so
+// unless the original reader can be read from at any offset the archive is first
+// written to a temporary file in the backup directory.
+func extractZip(ctx context.Context, original io.Reader, input io.Reader, handler archiver.FileHandler) error {
+	if f, ok := original.(*os.File); ok {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return errors.WithStack(err)
+		}
+		return archiver.Zip{}.Extract(ctx, f, nil, handler)
+	}
+
+	f, err := os.CreateTemp(config.Get().System.BackupDirectory, ".restore-*.zip")
+	if err != nil {
+		return errors.Wrap(err, "backup: failed to create temporary file for zip archive")
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, input); err != nil {
+		return errors.Wrap(err, "backup: failed to write zip archive to temporary file")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	return archiver.Zip{}.Extract(ctx, f, nil, handler)
+}
@@ -47,9 +47,15 @@ func LocateLocal(client remote.Client, uuid string) (*LocalBackup, os.FileInfo,
 	return b, st, nil
 }
 
-// Remove removes a backup from the system.
+// Remove removes a backup from the system, and from the remote storage bucket if
+// one is configured.
 func (b *LocalBackup) Remove() error {
-	return os.Remove(b.Path())
+	p := b.Path()
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+	removeFromRemoteStorage(p)
+	return nil
 }
 
 // WithLogContext attaches additional context to the log output for this backup.
@@ -63,6 +69,7 @@ func (b *LocalBackup) Generate(ctx context.Context, fsys *filesystem.Filesystem,
 	a := &filesystem.Archive{
 		Filesystem: fsys,
 		Ignore:     ignore,
+		Format:     config.Get().System.Backups.Format,
 	}
 
 	b.log().WithField("path", b.Path()).Info("creating backup for server")
@@ -75,6 +82,13 @@ func (b *LocalBackup) Generate(ctx context.Context, fsys *filesystem.Filesystem,
 	if err != nil {
 		return nil, errors.WrapIf(err, "backup: failed to get archive details for local backup")
 	}
+
+	// The copy in remote storage is optional, so the local backup is kept and reported
+	// as successful even if it could not be copied.
+	if err := b.copyToRemoteStorage(ctx); err != nil {
+		b.log().WithField("error", err).Error("failed to copy backup to remote storage, the local backup has been kept")
+		ad.RemoteCopyError = err.Error()
+	}
 	return ad, nil
 }
 
@@ -93,7 +107,7 @@ func (b *LocalBackup) Restore(ctx context.Context, _ io.Reader, callback Restore
 	if writeLimit := int64(config.Get().System.Backups.WriteLimit * 1024 * 1024); writeLimit > 0 {
 		reader = ratelimit.Reader(f, ratelimit.NewBucketWithRate(float64(writeLimit), writeLimit))
 	}
-	if err := format.Extract(ctx, reader, nil, func(ctx context.Context, f archiver.File) error {
+	if err := extractArchive(ctx, reader, func(ctx context.Context, f archiver.File) error {
 		r, err := f.Open()
 		if err != nil {
 			return err
@@ -54,6 +54,7 @@ func (s *S3Backup) Generate(ctx context.Context, fsys *filesystem.Filesystem, ig
 	a := &filesystem.Archive{
 		Filesystem: fsys,
 		Ignore:     ignore,
+		Format:     config.Get().System.Backups.Format,
 	}
 
 	s.log().WithField("path", s.Path()).Info("creating backup for server")
@@ -62,13 +63,13 @@ func (s *S3Backup) Generate(ctx context.Context, fsys *filesystem.Filesystem, ig
 	}
 	s.log().Info("created backup successfully")
 
-	rc, err := os.Open(s.Path())
+	f, err := os.Open(s.Path())
 	if err != nil {
 		return nil, errors.Wrap(err, "backup: could not read archive from disk")
 	}
-	defer rc.Close()
+	defer f.Close()
 
-	parts, err := s.generateRemoteRequest(ctx, rc)
+	parts, err := s.generateRemoteRequest(ctx, f)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +80,8 @@ func (s *S3Backup) Generate(ctx context.Context, fsys *filesystem.Filesystem, ig
 	return ad, nil
 }
 
-// Restore will read from the provided reader assuming that it is a gzipped
-// tar reader. When a file is encountered in the archive the callback function
+// Restore will read from the provided reader, detecting the format of the archive
+// from its contents. When a file is encountered in the archive the callback function
 // will be triggered. If the callback returns an error the entire process is
 // stopped, otherwise this function will run until all files have been written.
 //
@@ -93,7 +94,7 @@ func (s *S3Backup) Restore(ctx context.Context, r io.Reader, callback RestoreCal
 	if writeLimit := int64(config.Get().System.Backups.WriteLimit * 1024 * 1024); writeLimit > 0 {
 		reader = ratelimit.Reader(r, ratelimit.NewBucketWithRate(float64(writeLimit), writeLimit))
 	}
-	if err := format.Extract(ctx, reader, nil, func(ctx context.Context, f archiver.File) error {
+	if err := extractArchive(ctx, reader, func(ctx context.Context, f archiver.File) error {
 		r, err := f.Open()
 		if err != nil {
 			return err
@@ -107,10 +108,9 @@ func (s *S3Backup) Restore(ctx context.Context, r io.Reader, callback RestoreCal
 	return nil
 }
 
-// Generates the remote S3 request and begins the upload.
-func (s *S3Backup) generateRemoteRequest(ctx context.Context, rc io.ReadCloser) ([]remote.BackupPart, error) {
-	defer rc.Close()
-
+// Generates the remote S3 request and begins the upload. Parts are uploaded in
+// parallel, up to the concurrency configured for the remote storage of backups.
+func (s *S3Backup) generateRemoteRequest(ctx context.Context, f *os.File) ([]remote.BackupPart, error) {
 	s.log().Debug("attempting to get size of backup...")
 	size, err := s.Backup.Size()
 	if err != nil {
@@ -126,8 +126,10 @@ func (s *S3Backup) generateRemoteRequest(ctx context.Context, rc io.ReadCloser)
 	s.log().Debug("got S3 upload urls from the Panel")
 	s.log().WithField("parts", len(urls.Parts)).Info("attempting to upload backup to s3 endpoint...")
 
-	uploader := newS3FileUploader(rc)
-	for i, part := range urls.Parts {
+	uploader := newS3FileUploader()
+	contentType := s.ContentType()
+	concurrency := config.Get().System.Backups.RemoteStorage.Concurrency
+	parts, err := uploadParts(ctx, concurrency, len(urls.Parts), func(ctx context.Context, i int) (string, error) {
 		// Get the size for the current part.
 		var partSize int64
 		if i+1 < len(urls.Parts) {
@@ -139,32 +141,29 @@ func (s *S3Backup) generateRemoteRequest(ctx context.Context, rc io.ReadCloser)
 		}
 
 		// Attempt to upload the part.
-		etag, err := uploader.uploadPart(ctx, part, partSize)
+		etag, err := uploader.uploadPart(ctx, urls.Parts[i], io.NewSectionReader(f, int64(i)*urls.PartSize, partSize), contentType)
 		if err != nil {
 			s.log().WithField("part_id", i+1).WithError(err).Warn("failed to upload part")
-			return nil, err
+			return "", err
 		}
-		uploader.uploadedParts = append(uploader.uploadedParts, remote.BackupPart{
-			ETag:       etag,
-			PartNumber: i + 1,
-		})
 		s.log().WithField("part_id", i+1).Info("successfully uploaded backup part")
+		return etag, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	s.log().WithField("parts", len(urls.Parts)).Info("backup has been successfully uploaded")
 
-	return uploader.uploadedParts, nil
+	return parts, nil
 }
 
 type s3FileUploader struct {
-	io.ReadCloser
-	client        *http.Client
-	uploadedParts []remote.BackupPart
+	client *http.Client
 }
 
 // newS3FileUploader returns a new file uploader instance.
-func newS3FileUploader(file io.ReadCloser) *s3FileUploader {
+func newS3FileUploader() *s3FileUploader {
 	return &s3FileUploader{
-		ReadCloser: file,
 		// We purposefully use a super high timeout on this request since we need to upload
 		// a 5GB file. This assumes at worst a 10Mbps connection for uploading. While technically
 		// you could go slower we're targeting mostly hosted servers that should have 100Mbps
@@ -185,24 +184,23 @@ func (fu *s3FileUploader) backoff(ctx context.Context) backoff.BackOffContext {
 
 // uploadPart attempts to upload a given S3 file part to the S3 system. If a
 // 5xx error is returned from the endpoint this will continue with an exponential
-// backoff to try and successfully upload the part.
+// backoff to try and successfully upload the part. The part is sent with the given
+// content type, which should match the format of the backup.
 //
 // Once uploaded the ETag is returned to the caller.
-func (fu *s3FileUploader) uploadPart(ctx context.Context, part string, size int64) (string, error) {
-	r, err := http.NewRequestWithContext(ctx, http.MethodPut, part, nil)
-	if err != nil {
-		return "", errors.Wrap(err, "backup: could not create request for S3")
-	}
-
-	r.ContentLength = size
-	r.Header.Add("Content-Length", strconv.Itoa(int(size)))
-	r.Header.Add("Content-Type", "application/x-gzip")
-
-	// Limit the reader to the size of the part.
-	r.Body = Reader{Reader: io.LimitReader(fu.ReadCloser, size)}
-
+func (fu *s3FileUploader) uploadPart(ctx context.Context, part string, body *io.SectionReader, contentType string) (string, error) {
 	var etag string
-	err = backoff.Retry(func() error {
+	err := backoff.Retry(func() error {
+		// Create the request on every attempt since the body of the previous attempt
+		// has already been read.
+		r, err := http.NewRequestWithContext(ctx, http.MethodPut, part, Reader{Reader: io.NewSectionReader(body, 0, body.Size())})
+		if err != nil {
+			return backoff.Permanent(errors.Wrap(err, "backup: could not create request for S3"))
+		}
+		r.ContentLength = body.Size()
+		r.Header.Add("Content-Length", strconv.Itoa(int(body.Size())))
+		r.Header.Add("Content-Type", contentType)
+
 		res, err := fu.client.Do(r)
 		if err != nil {
 			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
@@ -0,0 +1,25 @@
+package backup
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestIsContentType(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("IsContentType", func() {
+		g.It("accepts the content types of every backup format", func() {
+			for _, v := range []string{"application/x-gzip", "application/gzip", "application/zip", "application/zstd", "application/gzip; charset=binary"} {
+				g.Assert(IsContentType(v)).IsTrue()
+			}
+		})
+
+		g.It("rejects other content types", func() {
+			for _, v := range []string{"", "gzip", "text/html", "application/octet-stream"} {
+				g.Assert(IsContentType(v)).IsFalse()
+			}
+		})
+	})
+}
@@ -0,0 +1,406 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/cenkalti/backoff/v4"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+)
+
+// emptyPayloadHash is the SHA256 hash of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// maxUploadParts is the maximum number of parts S3 allows in a multipart upload.
+const maxUploadParts = 10000
+
+// uploadParts uploads count parts using the upload function provided, with up to
+// concurrency parts being uploaded at the same time. The parts are returned in
+// order along with the ETag returned for each of them. upload is called with the
+// zero based index of the part.
+func uploadParts(ctx context.Context, concurrency, count int, upload func(ctx context.Context, i int) (string, error)) ([]remote.BackupPart, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	parts := make([]remote.BackupPart, count)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for i := 0; i < count; i++ {
+		i := i
+		g.Go(func() error {
+			etag, err := upload(ctx, i)
+			if err != nil {
+				return err
+			}
+			parts[i] = remote.BackupPart{ETag: etag, PartNumber: i + 1}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// CheckRemoteStorage checks that the bucket configured for the remote storage of
+// backups can be accessed with the configured credentials. Nothing is checked if
+// backups are only stored locally.
+func CheckRemoteStorage(ctx context.Context) error {
+	cfg := config.Get().System.Backups.RemoteStorage
+	if cfg.Driver != config.BackupStorageS3 {
+		return nil
+	}
+	res, err := newS3Storage(cfg).do(ctx, http.MethodHead, "", nil, nil, emptyPayloadHash)
+	if err != nil {
+		return errors.WrapIf(err, "backup: failed to access remote storage bucket")
+	}
+	_ = res.Body.Close()
+	return nil
+}
+
+// remoteKey returns the name of the object a local backup is stored as in the
+// remote storage bucket.
+func remoteKey(cfg config.BackupRemoteStorage, p string) string {
+	return cfg.Prefix + filepath.Base(p)
+}
+
+// copyToRemoteStorage uploads the local backup to the remote storage bucket if
+// one is configured.
+func (b *LocalBackup) copyToRemoteStorage(ctx context.Context) error {
+	cfg := config.Get().System.Backups.RemoteStorage
+	if cfg.Driver != config.BackupStorageS3 {
+		return nil
+	}
+	f, err := os.Open(b.Path())
+	if err != nil {
+		return errors.Wrap(err, "backup: could not read archive from disk")
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "backup: could not read archive from disk")
+	}
+
+	key := remoteKey(cfg, f.Name())
+	b.log().WithField("bucket", cfg.Bucket).WithField("key", key).Info("copying backup to remote storage...")
+	if err := newS3Storage(cfg).upload(ctx, key, f, st.Size()); err != nil {
+		return err
+	}
+	b.log().WithField("bucket", cfg.Bucket).WithField("key", key).Info("copied backup to remote storage successfully")
+	return nil
+}
+
+// removeFromRemoteStorage removes the copy of a local backup from the remote
+// storage bucket if one is configured.
+func removeFromRemoteStorage(p string) {
+	cfg := config.Get().System.Backups.RemoteStorage
+	if cfg.Driver != config.BackupStorageS3 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	key := remoteKey(cfg, p)
+	res, err := newS3Storage(cfg).do(ctx, http.MethodDelete, key, nil, nil, emptyPayloadHash)
+	if err != nil {
+		log.WithField("bucket", cfg.Bucket).WithField("key", key).WithField("error", err).Warn("failed to remove backup from remote storage")
+		return
+	}
+	_ = res.Body.Close()
+}
+
+// s3Storage is a minimal client for the S3 API, implementing only what is needed
+// to store backups in a bucket.
+type s3Storage struct {
+	cfg    config.BackupRemoteStorage
+	client *http.Client
+	now    func() time.Time
+}
+
+func newS3Storage(cfg config.BackupRemoteStorage) *s3Storage {
+	return &s3Storage{
+		cfg: cfg,
+		// Individual parts are small enough that they should never take this long,
+		// this only exists to prevent a stalled connection from blocking forever.
+		client: &http.Client{Timeout: time.Hour},
+		now:    time.Now,
+	}
+}
+
+// upload uploads the contents of r to the object with the given key using a
+// multipart upload, uploading parts in parallel. The upload is aborted if any
+// part fails to upload.
+func (s *s3Storage) upload(ctx context.Context, key string, r io.ReaderAt, size int64) error {
+	partSize := s.cfg.PartSize * 1024 * 1024
+	count, err := partCount(size, partSize)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, emptyPayloadHash)
+	if err != nil {
+		return errors.WrapIf(err, "backup: failed to create multipart upload")
+	}
+	var initiated struct {
+		UploadId string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(res.Body).Decode(&initiated)
+	_ = res.Body.Close()
+	if err != nil || initiated.UploadId == "" {
+		return errors.New("backup: failed to create multipart upload: missing upload id in response")
+	}
+	id := url.Values{"uploadId": {initiated.UploadId}}
+
+	parts, err := uploadParts(ctx, s.cfg.Concurrency, count, func(ctx context.Context, i int) (string, error) {
+		body := io.NewSectionReader(r, int64(i)*partSize, partSize)
+		q := url.Values{"partNumber": {strconv.Itoa(i + 1)}, "uploadId": id["uploadId"]}
+		res, err := s.do(ctx, http.MethodPut, key, q, body, "UNSIGNED-PAYLOAD")
+		if err != nil {
+			return "", errors.WrapIff(err, "backup: failed to upload part %d", i+1)
+		}
+		_ = res.Body.Close()
+		return res.Header.Get("ETag"), nil
+	})
+	if err == nil {
+		err = s.complete(ctx, key, id, parts)
+	}
+	if err != nil {
+		// Abort the upload so that the parts that were uploaded do not continue to
+		// use space in the bucket.
+		actx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if res, aerr := s.do(actx, http.MethodDelete, key, id, nil, emptyPayloadHash); aerr == nil {
+			_ = res.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// partCount returns the number of parts an object of the given size is uploaded
+// in, returning an error if it would need more parts than S3 allows.
+func partCount(size, partSize int64) (int, error) {
+	count := (size + partSize - 1) / partSize
+	if count == 0 {
+		count = 1
+	}
+	if count > maxUploadParts {
+		return 0, errors.Errorf("backup: archive of %d bytes needs %d parts of %d MiB, more than the %d allowed by S3; increase system.backups.remote_storage.part_size", size, count, partSize/1024/1024, maxUploadParts)
+	}
+	return int(count), nil
+}
+
+// complete completes a multipart upload once all of its parts are uploaded.
+func (s *s3Storage) complete(ctx context.Context, key string, id url.Values, parts []remote.BackupPart) error {
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	body := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	for _, p := range parts {
+		body.Parts = append(body.Parts, part{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+	b, err := xml.Marshal(body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	h := sha256.Sum256(b)
+	res, err := s.do(ctx, http.MethodPost, key, id, io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b))), hex.EncodeToString(h[:]))
+	if err != nil {
+		return errors.WrapIf(err, "backup: failed to complete multipart upload")
+	}
+	defer res.Body.Close()
+	// S3 can return an error in the body of a successful response to this request
+	// if the upload fails after the response has started.
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		return errors.Errorf("backup: failed to complete multipart upload: %s", result.Code)
+	}
+	return nil
+}
+
+// do performs a signed request against the bucket, retrying requests that fail
+// with a server error. The body of the returned response must be closed by the
+// caller.
+func (s *s3Storage) do(ctx context.Context, method, key string, query url.Values, body *io.SectionReader, payloadHash string) (*http.Response, error) {
+	var res *http.Response
+	err := backoff.Retry(func() error {
+		r, err := s.newRequest(ctx, method, key, query, body, payloadHash)
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+		res, err = s.client.Do(r)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+				return backoff.Permanent(err)
+			}
+			return errors.Wrap(err, "backup: S3 HTTP request failed")
+		}
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
+			return nil
+		}
+		err = s3Error(res)
+		if res.StatusCode >= http.StatusInternalServerError {
+			return err
+		}
+		return backoff.Permanent(err)
+	}, s.backoff(ctx))
+	if err != nil {
+		if v, ok := err.(*backoff.PermanentError); ok {
+			return nil, v.Unwrap()
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// s3Error returns an error describing an unsuccessful response, including the
+// error code returned by S3 if there is one. The body of the response is closed.
+func s3Error(res *http.Response) error {
+	defer res.Body.Close()
+	var body struct {
+		Code string `xml:"Code"`
+	}
+	_ = xml.NewDecoder(io.LimitReader(res.Body, 4096)).Decode(&body)
+	if body.Code != "" {
+		return errors.New(fmt.Sprintf("backup: S3 request failed: [HTTP/%d] %s", res.StatusCode, body.Code))
+	}
+	return errors.New(fmt.Sprintf("backup: S3 request failed: [HTTP/%d] %s", res.StatusCode, res.Status))
+}
+
+func (s *s3Storage) backoff(ctx context.Context) backoff.BackOffContext {
+	b := backoff.NewExponentialBackOff()
+	b.Multiplier = 2
+	b.MaxElapsedTime = time.Minute
+
+	return backoff.WithContext(b, ctx)
+}
+
+// newRequest creates a request for the given object, or for the bucket itself if
+// the key is empty, signed using AWS Signature Version 4.
+func (s *s3Storage) newRequest(ctx context.Context, method, key string, query url.Values, body *io.SectionReader, payloadHash string) (*http.Request, error) {
+	u, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "backup: invalid S3 endpoint")
+	}
+	host := u.Host
+	p := strings.TrimSuffix(u.Path, "/")
+	if s.cfg.PathStyle {
+		p += "/" + s.cfg.Bucket
+	} else {
+		host = s.cfg.Bucket + "." + host
+	}
+	if key != "" || !s.cfg.PathStyle {
+		p += "/" + key
+	}
+	canonicalPath := uriEncode(p, false)
+	canonicalQuery := canonicalQueryString(query)
+
+	target := u.Scheme + "://" + host + canonicalPath
+	if canonicalQuery != "" {
+		target += "?" + canonicalQuery
+	}
+	var reader io.Reader
+	if body != nil {
+		// Read the body from the start for every request, since a previous attempt
+		// may have already read some of it.
+		reader = Reader{Reader: io.NewSectionReader(body, 0, body.Size())}
+	}
+	r, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "backup: could not create request for S3")
+	}
+	if body != nil {
+		r.ContentLength = body.Size()
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	r.Header.Set("X-Amz-Date", amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalPath,
+		canonicalQuery,
+		"host:" + host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	h := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(h[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	for _, v := range []string{s.cfg.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return r, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQueryString returns the query string of a request in the canonical
+// form used when signing it, with the parameters sorted by name.
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode encodes every byte of s other than the unreserved characters defined
+// by RFC 3986, and the slash unless encodeSlash is true, as required by AWS
+// Signature Version 4.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
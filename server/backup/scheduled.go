@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+// scheduledPrefix is the prefix of the identifier of every backup created by the
+// backup schedule, which keeps them apart from the backups tracked by the Panel.
+const scheduledPrefix = "scheduled-"
+
+// NewScheduled returns a local backup for a scheduled run of the given server. The
+// identifier embeds the server and the creation time so that the scheduled backups
+// of a server can be found again when pruning them.
+func NewScheduled(client remote.Client, server string, t time.Time) *LocalBackup {
+	return NewLocal(client, scheduledPrefix+server+"-"+strconv.FormatInt(t.Unix(), 10), "")
+}
+
+// scheduledBackup is a scheduled backup found on the disk.
+type scheduledBackup struct {
+	uuid    string
+	created time.Time
+}
+
+// PruneScheduled removes the scheduled backups of a server that are beyond the
+// keep count or older than maxAge, returning the identifiers of the backups that
+// were removed. A keep or maxAge of 0 disables that limit.
+func PruneScheduled(client remote.Client, server string, keep int, maxAge time.Duration, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(config.Get().System.BackupDirectory)
+	if err != nil {
+		return nil, err
+	}
+	var found []scheduledBackup
+	for _, e := range entries {
+		if b, ok := parseScheduled(e.Name(), server); ok && !e.IsDir() {
+			found = append(found, b)
+		}
+	}
+	var removed []string
+	for _, b := range expiredScheduled(found, keep, maxAge, now) {
+		if err := NewLocal(client, b.uuid, "").Remove(); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, b.uuid)
+	}
+	return removed, nil
+}
+
+// parseScheduled returns the scheduled backup for a file name in the backup
+// directory if it belongs to the given server.
+func parseScheduled(name string, server string) (scheduledBackup, bool) {
+	p := scheduledPrefix + server + "-"
+	if !strings.HasPrefix(name, p) {
+		return scheduledBackup{}, false
+	}
+	for _, f := range formats {
+		if ext := filesystem.ArchiveExtension(f); strings.HasSuffix(name, ext) {
+			id := strings.TrimSuffix(name, ext)
+			ts, err := strconv.ParseInt(strings.TrimPrefix(id, p), 10, 64)
+			if err != nil {
+				return scheduledBackup{}, false
+			}
+			return scheduledBackup{uuid: id, created: time.Unix(ts, 0)}, true
+		}
+	}
+	return scheduledBackup{}, false
+}
+
+// expiredScheduled returns the backups that fall outside of the retention limits,
+// keeping the newest ones.
+func expiredScheduled(backups []scheduledBackup, keep int, maxAge time.Duration, now time.Time) []scheduledBackup {
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].created.After(backups[j].created)
+	})
+	var expired []scheduledBackup
+	for i, b := range backups {
+		if (keep > 0 && i >= keep) || (maxAge > 0 && now.Sub(b.created) > maxAge) {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
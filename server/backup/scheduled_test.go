@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestScheduledRetention(t *testing.T) {
+	g := Goblin(t)
+	now := time.Unix(1700000000, 0)
+	day := 24 * time.Hour
+
+	g.Describe("parseScheduled", func() {
+		g.It("parses backups belonging to the server", func() {
+			b, ok := parseScheduled("scheduled-abc-1700000000.tar.gz", "abc")
+			g.Assert(ok).IsTrue()
+			g.Assert(b.uuid).Equal("scheduled-abc-1700000000")
+			g.Assert(b.created.Equal(now)).IsTrue()
+
+			_, ok = parseScheduled("scheduled-abc-1700000000.zip", "abc")
+			g.Assert(ok).IsTrue()
+		})
+
+		g.It("ignores other servers and Panel backups", func() {
+			_, ok := parseScheduled("scheduled-abcd-1700000000.tar.gz", "abc")
+			g.Assert(ok).IsFalse()
+			_, ok = parseScheduled("3f1e9a2c-0000-0000-0000-000000000000.tar.gz", "abc")
+			g.Assert(ok).IsFalse()
+			_, ok = parseScheduled("scheduled-abc-1700000000.txt", "abc")
+			g.Assert(ok).IsFalse()
+		})
+	})
+
+	g.Describe("expiredScheduled", func() {
+		backups := func() []scheduledBackup {
+			return []scheduledBackup{
+				{uuid: "b", created: now.Add(-2 * day)},
+				{uuid: "a", created: now.Add(-3 * day)},
+				{uuid: "d", created: now},
+				{uuid: "c", created: now.Add(-1 * day)},
+			}
+		}
+		ids := func(b []scheduledBackup) []string {
+			var out []string
+			for _, v := range b {
+				out = append(out, v.uuid)
+			}
+			return out
+		}
+
+		g.It("keeps the newest backups", func() {
+			g.Assert(ids(expiredScheduled(backups(), 2, 0, now))).Equal([]string{"b", "a"})
+		})
+
+		g.It("removes backups older than the max age", func() {
+			g.Assert(ids(expiredScheduled(backups(), 0, 36*time.Hour, now))).Equal([]string{"b", "a"})
+		})
+
+		g.It("removes nothing when no limits are set", func() {
+			g.Assert(len(expiredScheduled(backups(), 0, 0, now))).Equal(0)
+		})
+	})
+}
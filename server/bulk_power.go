@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// bulkPowerOrder returns the servers in the order a bulk power action should be
+// applied to them. Servers are started in the same order they are restored in when
+// Wings boots, highest boot priority first, and are stopped in the reverse order.
+// Servers with the same priority keep the order they were provided in.
+func bulkPowerOrder(b config.BootStartup, servers []*Server, action PowerAction) []*Server {
+	priorities := make(map[string]int, len(servers))
+	for _, s := range servers {
+		priorities[s.ID()] = b.Priority(s.ID(), s.Config().Labels)
+	}
+	starting := action == PowerActionStart || action == PowerActionRestart
+	out := make([]*Server, len(servers))
+	copy(out, servers)
+	sort.SliceStable(out, func(i, j int) bool {
+		if starting {
+			return priorities[out[i].ID()] > priorities[out[j].ID()]
+		}
+		return priorities[out[i].ID()] < priorities[out[j].ID()]
+	})
+	return out
+}
+
+// HandleBulkPowerAction applies a power action to each of the servers provided in
+// the background. No more than the configured number of actions are processed at
+// the same time, and at least the configured delay passes between beginning each
+// one, so that mass power actions sent by the Panel do not overwhelm the host.
+func (m *Manager) HandleBulkPowerAction(servers []*Server, action PowerAction, waitSeconds int) {
+	cfg := config.Get().System
+	servers = bulkPowerOrder(cfg.BootStartup, servers, action)
+	delay := time.Duration(cfg.BulkPowerDelay) * time.Second
+
+	l := log.WithField("action", action).WithField("servers", len(servers))
+	l.WithField("concurrency", cfg.BulkPowerConcurrency).WithField("delay", delay).Info("processing bulk power action")
+
+	go func() {
+		start := time.Now()
+		slots := make(chan struct{}, cfg.BulkPowerConcurrency)
+		done := make(chan struct{}, len(servers))
+		for i, s := range servers {
+			if i > 0 && delay > 0 {
+				time.Sleep(delay)
+			}
+			slots <- struct{}{}
+			go func(s *Server) {
+				defer func() {
+					<-slots
+					done <- struct{}{}
+				}()
+				if err := s.HandlePowerActionFrom(PowerTriggerPanel, action, waitSeconds); err != nil {
+					if errors.Is(err, context.DeadlineExceeded) {
+						s.Log().WithField("action", action).WithField("error", err).Warn("could not process server power action")
+					} else if !errors.Is(err, ErrIsRunning) {
+						s.Log().WithFields(log.Fields{"action": action, "wait_seconds": waitSeconds, "error": err}).
+							Error("encountered error processing a bulk server power action")
+					}
+				}
+			}(s)
+		}
+		for range servers {
+			<-done
+		}
+		l.WithField("duration", time.Since(start).Round(time.Second)).Info("finished processing bulk power action")
+	}()
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestBulkPowerOrder(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("bulkPowerOrder", func() {
+		b := config.BootStartup{Priorities: map[string]int{"b": 10, "tier=low": -5}}
+		servers := []*Server{
+			{cfg: Configuration{Uuid: "a"}},
+			{cfg: Configuration{Uuid: "b"}},
+			{cfg: Configuration{Uuid: "c", Labels: map[string]string{"tier": "low"}}},
+			{cfg: Configuration{Uuid: "d"}},
+		}
+		ids := func(out []*Server) []string {
+			var s []string
+			for _, v := range out {
+				s = append(s, v.ID())
+			}
+			return s
+		}
+
+		g.It("starts servers with the highest boot priority first", func() {
+			g.Assert(ids(bulkPowerOrder(b, servers, PowerActionStart))).Equal([]string{"b", "a", "d", "c"})
+			g.Assert(ids(bulkPowerOrder(b, servers, PowerActionRestart))).Equal([]string{"b", "a", "d", "c"})
+		})
+
+		g.It("stops servers with the lowest boot priority first", func() {
+			g.Assert(ids(bulkPowerOrder(b, servers, PowerActionStop))).Equal([]string{"c", "a", "d", "b"})
+		})
+
+		g.It("does not modify the provided slice", func() {
+			bulkPowerOrder(b, servers, PowerActionStart)
+			g.Assert(ids(servers)).Equal([]string{"a", "b", "c", "d"})
+		})
+	})
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"sync"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
 )
 
@@ -14,11 +15,19 @@ type EggConfiguration struct {
 	// or basically any type of access on the server by any user. This is NOT the same
 	// as a per-user denylist, this is defined at the Egg level.
 	FileDenylist []string `json:"file_denylist"`
+
+	// Variables are the variables defined by the Egg along with their validation rules,
+	// which are checked before the server is started.
+	Variables []EggVariable `json:"variables"`
 }
 
 type ConfigurationMeta struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	// Metadata is the additional metadata assigned to the server on the Panel. Only the
+	// fields listed in the metadata label configuration are applied to the container.
+	Metadata map[string]string `json:"metadata"`
 }
 
 type Configuration struct {
@@ -49,6 +58,50 @@ type Configuration struct {
 	// Labels is a map of container labels that should be applied to the running server process.
 	Labels map[string]string `json:"labels"`
 
+	// NetworkMode overrides the default network mode used for the server's container. If
+	// empty the default network mode defined in the Wings configuration is used.
+	NetworkMode string `json:"network_mode"`
+
+	// StopGracePeriod overrides the number of seconds Docker waits for the server's container
+	// to stop before killing it. This is capped by the maximum defined in the Wings configuration.
+	StopGracePeriod int `json:"stop_grace_period"`
+
+	// Tmpfs defines additional tmpfs mounts for the server's container, mapping the path in
+	// the container to the mount options. These override the defaults defined in the Wings
+	// configuration for the same paths.
+	Tmpfs map[string]string `json:"tmpfs"`
+
+	// ReadonlyRootfs overrides whether the root filesystem of the server's container is
+	// read-only. If not set the default defined in the Wings configuration is used.
+	ReadonlyRootfs *bool `json:"readonly_rootfs"`
+
+	// ShmSize overrides the size of /dev/shm in the server's container, for example "1g". If
+	// empty the default defined in the Wings configuration is used.
+	ShmSize string `json:"shm_size"`
+
+	// Init overrides whether an init process is run as PID 1 in the server's container to
+	// reap zombie processes. If not set the default defined in the Wings configuration is used.
+	Init *bool `json:"init"`
+
+	// NetworkEgressLimit and NetworkIngressLimit override the bandwidth limits of the
+	// server's container, for example "100mbit". If empty the defaults defined in the
+	// Wings configuration are used.
+	NetworkEgressLimit  string `json:"network_egress_limit"`
+	NetworkIngressLimit string `json:"network_ingress_limit"`
+
+	// IoLimits override the disk IO limits of the server's container for the devices
+	// they are set for. Devices without an override use the limits defined in the
+	// Wings configuration.
+	IoLimits []config.DockerIoLimit `json:"io_limits"`
+
+	// GroupAdd overrides the additional groups of the server process inside the
+	// container. If empty the groups defined in the Wings configuration are used.
+	GroupAdd []string `json:"group_add"`
+
+	// BackupExclude overrides the patterns of the files left out of backups of the
+	// server. If empty the patterns defined in the Wings configuration are used.
+	BackupExclude []string `json:"backup_exclude"`
+
 	Allocations           environment.Allocations `json:"allocations"`
 	Build                 environment.Limits      `json:"build"`
 	CrashDetectionEnabled bool                    `json:"crash_detection_enabled"`
@@ -58,6 +111,10 @@ type Configuration struct {
 	Container struct {
 		// Defines the Docker image that will be used for this server
 		Image string `json:"image,omitempty"`
+
+		// Privileged requests that the server's container is run in privileged mode. This
+		// is only honored if privileged containers are allowed in the Wings configuration.
+		Privileged bool `json:"privileged,omitempty"`
 	} `json:"container,omitempty"`
 }
 
@@ -80,6 +137,24 @@ func (s *Server) MemoryLimit() int64 {
 	return s.cfg.Build.MemoryLimit
 }
 
+// containerLabels returns the labels that should be applied to the server's
+// container, combining the labels assigned by the Panel with any of the server
+// metadata that is configured to be applied as a label.
+func (c *Configuration) containerLabels() map[string]string {
+	metadata := make(map[string]string, len(c.Meta.Metadata)+2)
+	for k, v := range c.Meta.Metadata {
+		metadata[k] = v
+	}
+	metadata["name"] = c.Meta.Name
+	metadata["description"] = c.Meta.Description
+
+	labels := config.Get().Docker.ContainerMetadataLabels(metadata)
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
 func (c *Configuration) GetUuid() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
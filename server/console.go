@@ -1,13 +1,17 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mitchellh/colorstring"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/metrics"
 	"github.com/pterodactyl/wings/system"
 )
 
@@ -29,6 +33,42 @@ func (s *Server) PublishConsoleOutputFromDaemon(data string) {
 	)
 }
 
+// SanitizeConsoleOutput handles any invalid UTF-8 in a line of console output using
+// the strategy defined in the configuration, since invalid UTF-8 cannot be sent to
+// clients over the websocket.
+func SanitizeConsoleOutput(b []byte) []byte {
+	if utf8.Valid(b) {
+		return b
+	}
+	return sanitizeUtf8(b, config.Get().System.ConsoleInvalidUtf8)
+}
+
+// sanitizeUtf8 replaces each run of invalid UTF-8 bytes with the replacement
+// character, removes them when mode is "strip", or replaces each invalid byte with
+// an escape sequence such as "\xff" when mode is "hex".
+func sanitizeUtf8(b []byte, mode string) []byte {
+	switch mode {
+	case config.ConsoleInvalidUtf8Strip:
+		return bytes.ToValidUTF8(b, nil)
+	case config.ConsoleInvalidUtf8Hex:
+		out := make([]byte, 0, len(b)+16)
+		for len(b) > 0 {
+			r, size := utf8.DecodeRune(b)
+			if r == utf8.RuneError && size == 1 {
+				// Invalid bytes are always at least 0x80, so are two hex digits.
+				out = append(out, '\\', 'x')
+				out = strconv.AppendUint(out, uint64(b[0]), 16)
+			} else {
+				out = append(out, b[:size]...)
+			}
+			b = b[size:]
+		}
+		return out
+	default:
+		return bytes.ToValidUTF8(b, []byte(string(utf8.RuneError)))
+	}
+}
+
 // Throttler returns the throttler instance for the server or creates a new one.
 func (s *Server) Throttler() *ConsoleThrottle {
 	s.throttleOnce.Do(func() {
@@ -36,15 +76,30 @@ func (s *Server) Throttler() *ConsoleThrottle {
 		period := time.Duration(throttles.Period) * time.Millisecond
 
 		s.throttler = newConsoleThrottle(throttles.Lines, period)
+		if throttles.Algorithm == config.ThrottleAlgorithmLeakyBucket {
+			s.throttler.limit = system.NewLeakyBucket(throttles.Lines, period, throttles.Burst)
+		}
 		s.throttler.strike = func() {
+			metrics.ConsoleThrottled(s.ID())
 			s.PublishConsoleOutputFromDaemon("服务器输出控制台数据的速度太快——正在限制...")
 		}
 	})
 	return s.throttler
 }
 
+// ConsoleHistory returns the buffer of recent console output for the server, or
+// nil if console output is not being retained in memory.
+func (s *Server) ConsoleHistory() *system.RingBuffer {
+	s.consoleHistoryOnce.Do(func() {
+		if n := config.Get().System.ConsoleBufferLines; n > 0 {
+			s.consoleHistory = system.NewRingBuffer(n)
+		}
+	})
+	return s.consoleHistory
+}
+
 type ConsoleThrottle struct {
-	limit  *system.Rate
+	limit  system.Limiter
 	lock   *system.Locker
 	strike func()
 }
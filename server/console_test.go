@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
 )
 
 func TestName(t *testing.T) {
@@ -60,3 +62,28 @@ func BenchmarkConsoleThrottle(b *testing.B) {
 		t.Allow()
 	}
 }
+
+func TestSanitizeUtf8(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("sanitizeUtf8", func() {
+		b := []byte("a\xff\xfeb\x01c")
+
+		g.It("replaces runs of invalid bytes with the replacement character", func() {
+			g.Assert(string(sanitizeUtf8(b, config.ConsoleInvalidUtf8Replace))).Equal("a�b\x01c")
+		})
+
+		g.It("strips invalid bytes", func() {
+			g.Assert(string(sanitizeUtf8(b, config.ConsoleInvalidUtf8Strip))).Equal("ab\x01c")
+		})
+
+		g.It("escapes each invalid byte", func() {
+			g.Assert(string(sanitizeUtf8(b, config.ConsoleInvalidUtf8Hex))).Equal(`a\xff\xfeb` + "\x01c")
+			g.Assert(string(sanitizeUtf8([]byte("\x80"), config.ConsoleInvalidUtf8Hex))).Equal(`\x80`)
+		})
+
+		g.It("keeps valid multi-byte characters", func() {
+			g.Assert(string(sanitizeUtf8([]byte("服务器\xff"), config.ConsoleInvalidUtf8Hex))).Equal(`服务器\xff`)
+		})
+	})
+}
@@ -87,5 +87,5 @@ func (s *Server) handleServerCrash() error {
 
 	s.crasher.SetLastCrash(time.Now())
 
-	return errors.Wrap(s.HandlePowerAction(PowerActionStart), "检测到崩溃后无法启动服务器")
+	return errors.Wrap(s.HandlePowerActionFrom(PowerTriggerCrash, PowerActionStart), "检测到崩溃后无法启动服务器")
 }
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/google/uuid"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/remote"
+)
+
+// findDuplicateServers returns the UUIDs, in lowercase, that are used by more than
+// one server. This is the case if the Panel returns the same UUID more than once,
+// or if the data directory or the server containers contain entries for a UUID
+// that differ only by case. Each UUID is mapped to a description of the entries
+// that conflict with each other.
+func findDuplicateServers(ctx context.Context, servers []remote.RawServerData) map[string][]string {
+	conflicts := make(map[string][]string)
+
+	panel := make([]string, len(servers))
+	for i, s := range servers {
+		panel[i] = s.Uuid
+	}
+	addDuplicateUuids(conflicts, "panel", panel)
+
+	if entries, err := os.ReadDir(config.Get().System.Data); err != nil {
+		log.WithField("error", err).Warn("failed to read data directory while checking for duplicate server uuids")
+	} else {
+		var dirs []string
+		for _, e := range entries {
+			dirs = append(dirs, e.Name())
+		}
+		addDuplicateUuids(conflicts, "data directory", dirs)
+	}
+
+	if containers, err := environment.ServerContainers(ctx); err != nil {
+		log.WithField("error", err).Warn("failed to list server containers while checking for duplicate server uuids")
+	} else {
+		var names []string
+		for _, c := range containers {
+			for _, n := range c.Names {
+				names = append(names, strings.TrimPrefix(n, "/"))
+			}
+		}
+		addDuplicateUuids(conflicts, "container", names)
+	}
+
+	return conflicts
+}
+
+// addDuplicateUuids adds any of the values that are a UUID appearing more than
+// once, ignoring case, to the conflicts along with the source they came from.
+// Values that are not a UUID are ignored.
+func addDuplicateUuids(conflicts map[string][]string, source string, values []string) {
+	seen := make(map[string][]string)
+	for _, v := range values {
+		if _, err := uuid.Parse(v); err != nil {
+			continue
+		}
+		k := strings.ToLower(v)
+		seen[k] = append(seen[k], v)
+	}
+	for k, matches := range seen {
+		if len(matches) < 2 {
+			continue
+		}
+		for _, m := range matches {
+			conflicts[k] = append(conflicts[k], source+": "+m)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestAddDuplicateUuids(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("addDuplicateUuids", func() {
+		g.It("finds uuids that appear more than once", func() {
+			conflicts := make(map[string][]string)
+			addDuplicateUuids(conflicts, "panel", []string{
+				"ca9d6ae4-7d4b-4a9e-a64f-1b1e0e6e5c11",
+				"1f2b3c4d-0000-4000-8000-000000000000",
+				"ca9d6ae4-7d4b-4a9e-a64f-1b1e0e6e5c11",
+			})
+
+			g.Assert(conflicts).Equal(map[string][]string{
+				"ca9d6ae4-7d4b-4a9e-a64f-1b1e0e6e5c11": {
+					"panel: ca9d6ae4-7d4b-4a9e-a64f-1b1e0e6e5c11",
+					"panel: ca9d6ae4-7d4b-4a9e-a64f-1b1e0e6e5c11",
+				},
+			})
+		})
+
+		g.It("finds uuids that differ only by case", func() {
+			conflicts := make(map[string][]string)
+			addDuplicateUuids(conflicts, "data directory", []string{
+				"CA9D6AE4-7D4B-4A9E-A64F-1B1E0E6E5C11",
+				"ca9d6ae4-7d4b-4a9e-a64f-1b1e0e6e5c11",
+			})
+
+			g.Assert(len(conflicts["ca9d6ae4-7d4b-4a9e-a64f-1b1e0e6e5c11"])).Equal(2)
+		})
+
+		g.It("ignores values that are not a uuid", func() {
+			conflicts := make(map[string][]string)
+			addDuplicateUuids(conflicts, "data directory", []string{".sftp", ".sftp", "states.json"})
+
+			g.Assert(len(conflicts)).Equal(0)
+		})
+	})
+}
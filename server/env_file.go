@@ -0,0 +1,48 @@
+package server
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// writeEnvFile writes the environment variables for the server to the configured
+// environment file in the server's root directory. The file is written through the
+// server filesystem so that the path cannot escape the root directory, and is only
+// readable by its owner.
+func (s *Server) writeEnvFile() error {
+	p := config.Get().System.EnvFilePath
+
+	vars := s.GetEnvironmentVariables()
+	sort.Strings(vars)
+
+	var buf bytes.Buffer
+	for _, v := range vars {
+		k, val, _ := strings.Cut(v, "=")
+		buf.WriteString(k + "=" + quoteEnvValue(val) + "\n")
+	}
+
+	if err := s.Filesystem().Write(p, &buf, int64(buf.Len()), 0o600); err != nil {
+		return errors.WrapIf(err, "server: failed to write environment file")
+	}
+	// The mode is only applied when the file is created, so make sure that an existing
+	// file is not left readable by other users.
+	if err := s.Filesystem().Chmod(p, 0o600); err != nil {
+		return errors.WrapIf(err, "server: failed to set environment file permissions")
+	}
+	return nil
+}
+
+// quoteEnvValue returns the value wrapped in double quotes if it contains any
+// characters that would otherwise be misinterpreted when the file is read.
+func quoteEnvValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\r\n\"'\\#$`") {
+		return strconv.Quote(v)
+	}
+	return v
+}
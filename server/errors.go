@@ -10,6 +10,7 @@ var (
 	ErrServerIsInstalling   = errors.New("server is currently installing")
 	ErrServerIsTransferring = errors.New("server is currently being transferred")
 	ErrServerIsRestoring    = errors.New("server is currently being restored")
+	ErrResourceOutOfBounds  = errors.New("server resource assignment is outside the bounds of this node")
 )
 
 type crashTooFrequent struct{}
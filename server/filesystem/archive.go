@@ -13,7 +13,6 @@ import (
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/juju/ratelimit"
-	"github.com/klauspost/pgzip"
 	ignore "github.com/sabhiram/go-gitignore"
 
 	"github.com/pterodactyl/wings/config"
@@ -30,31 +29,6 @@ var pool = sync.Pool{
 	},
 }
 
-// TarProgress .
-type TarProgress struct {
-	*tar.Writer
-	p *progress.Progress
-}
-
-// NewTarProgress .
-func NewTarProgress(w *tar.Writer, p *progress.Progress) *TarProgress {
-	if p != nil {
-		p.Writer = w
-	}
-	return &TarProgress{
-		Writer: w,
-		p:      p,
-	}
-}
-
-// Write .
-func (p *TarProgress) Write(v []byte) (int, error) {
-	if p.p == nil {
-		return p.Writer.Write(v)
-	}
-	return p.p.Write(v)
-}
-
 type Archive struct {
 	// Filesystem to create the archive with.
 	Filesystem *Filesystem
@@ -74,7 +48,15 @@ type Archive struct {
 	// Progress wraps the writer of the archive to pass through the progress tracker.
 	Progress *progress.Progress
 
-	w *TarProgress
+	// Format is the format of the archive, defaulting to a gzip compressed tar
+	// archive if unset.
+	Format string
+
+	// Level is the compression level used for the archive. If 0 the compression
+	// level from the backup configuration is used.
+	Level int
+
+	w archiveWriter
 }
 
 // Create creates an archive at dst with all the files defined in the
@@ -129,27 +111,26 @@ func (a *Archive) Stream(ctx context.Context, w io.Writer) error {
 		a.Files = files
 	}
 
-	// Choose which compression level to use based on the compression_level configuration option
-	var compressionLevel int
-	switch config.Get().System.Backups.CompressionLevel {
-	case "none":
-		compressionLevel = pgzip.NoCompression
-	case "best_compression":
-		compressionLevel = pgzip.BestCompression
-	default:
-		compressionLevel = pgzip.BestSpeed
+	format := a.Format
+	if format == "" {
+		format = config.BackupFormatTarGz
+	}
+	// Choose which compression level to use based on the compression_level configuration
+	// option, falling back to the fastest level if it is not valid for this format.
+	compressionLevel, err := config.Get().System.Backups.Level(format)
+	if err != nil {
+		compressionLevel = 1
+	}
+	if a.Level != 0 {
+		compressionLevel = a.Level
 	}
 
-	// Create a new gzip writer around the file.
-	gw, _ := pgzip.NewWriterLevel(w, compressionLevel)
-	_ = gw.SetConcurrency(1<<20, 1)
-	defer gw.Close()
-
-	// Create a new tar writer around the gzip writer.
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
-	a.w = NewTarProgress(tw, a.Progress)
+	// Create a new archive writer around the file.
+	aw, err := newArchiveWriter(w, format, compressionLevel)
+	if err != nil {
+		return err
+	}
+	a.w = aw
 
 	fs := a.Filesystem.unixFS
 
@@ -175,11 +156,12 @@ func (a *Archive) Stream(ctx context.Context, w io.Writer) error {
 	dirfd, name, closeFd, err := fs.SafePath(a.BaseDirectory)
 	defer closeFd()
 	if err != nil {
+		_ = aw.Close()
 		return err
 	}
 
 	// Recursively walk the base directory.
-	return fs.WalkDirat(dirfd, name, func(dirfd int, name, relative string, d ufs.DirEntry, err error) error {
+	err = fs.WalkDirat(dirfd, name, func(dirfd int, name, relative string, d ufs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -190,6 +172,10 @@ func (a *Archive) Stream(ctx context.Context, w io.Writer) error {
 			return callback(dirfd, name, relative, d)
 		}
 	})
+	if cerr := aw.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // Callback function used to determine if a given file should be included in the archive
@@ -304,7 +290,8 @@ func (a *Archive) addToArchive(dirfd int, name, relative string, entry ufs.DirEn
 	}
 
 	// Write the tar FileInfoHeader to the archive.
-	if err := a.w.WriteHeader(header); err != nil {
+	w, err := a.w.WriteHeader(header)
+	if err != nil {
 		return errors.WrapIff(err, "failed to write tar#FileInfoHeader for '%s'", name)
 	}
 
@@ -337,7 +324,12 @@ func (a *Archive) addToArchive(dirfd int, name, relative string, entry ufs.DirEn
 	defer f.Close()
 
 	// Copy the file's contents to the archive using our buffer.
-	if _, err := io.CopyBuffer(a.w, io.LimitReader(f, header.Size), buf); err != nil {
+	// Pass the contents through the progress tracker if there is one.
+	if a.Progress != nil {
+		a.Progress.Writer = w
+		w = a.Progress
+	}
+	if _, err := io.CopyBuffer(w, io.LimitReader(f, header.Size), buf); err != nil {
 		return errors.WrapIff(err, "failed to copy '%s' to archive", header.Name)
 	}
 	return nil
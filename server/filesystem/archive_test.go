@@ -11,6 +11,8 @@ import (
 
 	. "github.com/franela/goblin"
 	"github.com/mholt/archiver/v4"
+
+	"github.com/pterodactyl/wings/config"
 )
 
 func TestArchive_Stream(t *testing.T) {
@@ -84,6 +86,35 @@ func TestArchive_Stream(t *testing.T) {
 
 			g.Assert(files).Equal(expected)
 		})
+
+		for _, format := range []string{config.BackupFormatZip, config.BackupFormatZstd, ArchiveFormatTar} {
+			format := format
+			g.It("creates a "+format+" archive", func() {
+				g.Assert(fs.CreateDirectory("test", "/")).IsNil()
+
+				r := strings.NewReader("hello, world!\n")
+				g.Assert(fs.Write("test/file.txt", r, r.Size(), 0o644)).IsNil()
+				r = strings.NewReader("hello, world!\n")
+				g.Assert(fs.Write("test_file.txt", r, r.Size(), 0o644)).IsNil()
+
+				a := &Archive{Filesystem: fs, Format: format}
+				archivePath := filepath.Join(rfs.root, "archive"+ArchiveExtension(format))
+				g.Assert(a.Create(context.Background(), archivePath)).IsNil()
+
+				genericFs, err := archiver.FileSystem(context.Background(), archivePath)
+				g.Assert(err).IsNil()
+				var files []string
+				err = iofs.WalkDir(genericFs, ".", func(p string, d iofs.DirEntry, err error) error {
+					if err == nil && !d.IsDir() {
+						files = append(files, p)
+					}
+					return err
+				})
+				g.Assert(err).IsNil()
+				sort.Strings(files)
+				g.Assert(files).Equal([]string{"test/file.txt", "test_file.txt"})
+			})
+		}
 	})
 }
 
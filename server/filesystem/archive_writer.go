@@ -0,0 +1,130 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"io/fs"
+
+	"emperror.dev/errors"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// archiveWriter is implemented by each of the formats that an archive can be
+// written in.
+type archiveWriter interface {
+	// WriteHeader adds a new file described by the header to the archive and
+	// returns the writer that the contents of the file should be written to.
+	WriteHeader(header *tar.Header) (io.Writer, error)
+	// Close finishes writing the archive, flushing any buffered data.
+	Close() error
+}
+
+// ArchiveFormatTar is an uncompressed tar archive, which is only used when
+// transferring servers between nodes.
+const ArchiveFormatTar = "tar"
+
+// ArchiveExtension returns the file extension used for archives of the format
+// provided.
+func ArchiveExtension(format string) string {
+	switch format {
+	case config.BackupFormatZip:
+		return ".zip"
+	case config.BackupFormatZstd:
+		return ".tar.zst"
+	case ArchiveFormatTar:
+		return ".tar"
+	default:
+		return ".tar.gz"
+	}
+}
+
+// newArchiveWriter returns an archive writer for the format provided that
+// writes to w using the given compression level.
+func newArchiveWriter(w io.Writer, format string, level int) (archiveWriter, error) {
+	switch format {
+	case "", config.BackupFormatTarGz:
+		gw, err := pgzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		_ = gw.SetConcurrency(1<<20, 1)
+		return &tarArchiveWriter{tw: tar.NewWriter(gw), c: gw}, nil
+	case config.BackupFormatZstd:
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &tarArchiveWriter{tw: tar.NewWriter(zw), c: zw}, nil
+	case ArchiveFormatTar:
+		return &tarArchiveWriter{tw: tar.NewWriter(w)}, nil
+	case config.BackupFormatZip:
+		zw := zip.NewWriter(w)
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+		return &zipArchiveWriter{zw: zw, store: level == 0}, nil
+	}
+	return nil, errors.Errorf("filesystem: unsupported archive format \"%s\"", format)
+}
+
+// tarArchiveWriter writes a tar archive through a compressor, if there is one.
+type tarArchiveWriter struct {
+	tw *tar.Writer
+	c  io.Closer
+}
+
+func (t *tarArchiveWriter) WriteHeader(header *tar.Header) (io.Writer, error) {
+	if err := t.tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	return t.tw, nil
+}
+
+func (t *tarArchiveWriter) Close() error {
+	if t.c == nil {
+		return t.tw.Close()
+	}
+	if err := t.tw.Close(); err != nil {
+		_ = t.c.Close()
+		return err
+	}
+	return t.c.Close()
+}
+
+// zipArchiveWriter writes a zip archive. Symlinks are stored using the Unix
+// convention of writing the target of the link as the contents of the file.
+type zipArchiveWriter struct {
+	zw    *zip.Writer
+	store bool
+}
+
+func (z *zipArchiveWriter) WriteHeader(header *tar.Header) (io.Writer, error) {
+	fh := &zip.FileHeader{
+		Name:     header.Name,
+		Modified: header.ModTime,
+		Method:   zip.Deflate,
+	}
+	if z.store {
+		fh.Method = zip.Store
+	}
+	fh.SetMode(header.FileInfo().Mode())
+	w, err := z.zw.CreateHeader(fh)
+	if err != nil {
+		return nil, err
+	}
+	if header.FileInfo().Mode()&fs.ModeSymlink != 0 {
+		if _, err := io.WriteString(w, header.Linkname); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (z *zipArchiveWriter) Close() error {
+	return z.zw.Close()
+}
@@ -0,0 +1,54 @@
+package filesystem
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"golang.org/x/sys/unix"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// transientChownErrors are the errors returned when changing the ownership of a
+// file that may succeed if the operation is retried, generally because of a
+// temporary problem with the network storage the file is stored on.
+var transientChownErrors = []error{
+	unix.EIO,
+	unix.EAGAIN,
+	unix.EINTR,
+	unix.EBUSY,
+	unix.ETIMEDOUT,
+	unix.ESTALE,
+	unix.ENOLCK,
+	unix.ECONNRESET,
+	unix.EHOSTUNREACH,
+}
+
+// isTransientChownError returns true if the error returned when changing the
+// ownership of a file may not occur again if the operation is retried.
+func isTransientChownError(err error) bool {
+	for _, e := range transientChownErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// chownWithRetry calls the chown function, retrying it with an increasing backoff
+// according to the ownership retry configuration for as long as it fails with a
+// transient error. Any other error is returned immediately.
+func chownWithRetry(chown func() error) error {
+	cfg := config.Get().System.OwnershipRetry
+	backoff := time.Duration(cfg.Backoff) * time.Millisecond
+
+	err := chown()
+	for attempt := 1; attempt <= cfg.Attempts && err != nil && isTransientChownError(err); attempt++ {
+		log.WithField("error", err).WithField("attempt", attempt).Debug("retrying failed chown after transient error")
+		time.Sleep(backoff)
+		backoff *= 2
+		err = chown()
+	}
+	return err
+}
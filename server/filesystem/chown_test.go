@@ -0,0 +1,58 @@
+package filesystem
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+	"golang.org/x/sys/unix"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/ufs"
+)
+
+func TestChownWithRetry(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("chownWithRetry", func() {
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System: config.SystemConfiguration{
+					OwnershipRetry: config.OwnershipRetry{Attempts: 2, Backoff: 0},
+				},
+			})
+		})
+
+		g.It("retries transient errors", func() {
+			calls := 0
+			err := chownWithRetry(func() error {
+				if calls++; calls < 3 {
+					return &ufs.PathError{Op: "fchownat", Path: "test", Err: unix.EIO}
+				}
+				return nil
+			})
+			g.Assert(err).IsNil()
+			g.Assert(calls).Equal(3)
+		})
+
+		g.It("gives up after the configured number of attempts", func() {
+			calls := 0
+			err := chownWithRetry(func() error {
+				calls++
+				return unix.ESTALE
+			})
+			g.Assert(err).Equal(unix.ESTALE)
+			g.Assert(calls).Equal(3)
+		})
+
+		g.It("does not retry permanent errors", func() {
+			calls := 0
+			err := chownWithRetry(func() error {
+				calls++
+				return unix.EPERM
+			})
+			g.Assert(err).Equal(unix.EPERM)
+			g.Assert(calls).Equal(1)
+		})
+	})
+}
@@ -12,9 +12,11 @@ import (
 	"time"
 
 	"emperror.dev/errors"
+	"github.com/apex/log"
 	"github.com/klauspost/compress/zip"
 	"github.com/mholt/archiver/v4"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/internal/ufs"
 	"github.com/pterodactyl/wings/server/filesystem/archiverext"
 )
@@ -145,6 +147,10 @@ func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file strin
 		return err
 	}
 	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
 
 	// Identify the type of archive we are dealing with.
 	format, input, err := archiver.Identify(filepath.Base(file), f)
@@ -160,12 +166,15 @@ func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file strin
 		Directory: dir,
 		Format:    format,
 		Reader:    input,
+		Limiter:   newExtractLimiter(st.Size()),
 	})
 }
 
-// ExtractStreamUnsafe .
-func (fs *Filesystem) ExtractStreamUnsafe(ctx context.Context, dir string, r io.Reader) error {
-	format, input, err := archiver.Identify("archive.tar.gz", r)
+// ExtractStreamUnsafe extracts the archive read from r into the directory without
+// enforcing any extraction limits. The name of the archive is used to identify the
+// format of the archive.
+func (fs *Filesystem) ExtractStreamUnsafe(ctx context.Context, dir string, name string, r io.Reader) error {
+	format, input, err := archiver.Identify(name, r)
 	if err != nil {
 		if errors.Is(err, archiver.ErrNoMatch) {
 			return newFilesystemError(ErrCodeUnknownArchive, err)
@@ -188,6 +197,9 @@ type extractStreamOptions struct {
 	Format archiver.Format
 	// Reader for the archive.
 	Reader io.Reader
+	// Limiter enforces the configured extraction limits. If nil no limits are
+	// enforced, which is only the case for trusted archives such as transfers.
+	Limiter *extractLimiter
 }
 
 func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptions) error {
@@ -211,11 +223,18 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 			return nil
 		}
 
-		reader, err := de.OpenReader(opts.Reader)
+		rc, err := de.OpenReader(opts.Reader)
 		if err != nil {
 			return err
 		}
-		defer reader.Close()
+		defer rc.Close()
+		if err := opts.Limiter.addFile(); err != nil {
+			return err
+		}
+		reader := opts.Limiter.reader(rc)
+
+		_, serr := fs.unixFS.Lstat(p)
+		created := errors.Is(serr, ufs.ErrNotExist)
 
 		// Open the file for creation/writing
 		f, err := fs.unixFS.OpenFile(p, ufs.O_WRONLY|ufs.O_CREATE, 0o644)
@@ -250,6 +269,13 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 					break
 				}
 
+				// Remove the partially written file if it was created by this extraction
+				// and the archive exceeded the extraction limits.
+				if created && IsErrorCode(err, ErrCodeArchiveLimits) {
+					_ = f.Close()
+					_ = fs.Delete(p)
+				}
+
 				// Return any other
 				return err
 			}
@@ -258,8 +284,11 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 		return nil
 	}
 
-	// Decompress and extract archive
-	return ex.Extract(ctx, opts.Reader, nil, func(ctx context.Context, f archiver.File) error {
+	// Decompress and extract archive. Keep track of the files that did not exist
+	// before the extraction began so that they can be removed if the archive turns
+	// out to exceed the extraction limits.
+	var created []string
+	err := ex.Extract(ctx, opts.Reader, nil, func(ctx context.Context, f archiver.File) error {
 		if f.IsDir() {
 			return nil
 		}
@@ -268,12 +297,28 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 		if err := fs.IsIgnored(p); err != nil {
 			return nil
 		}
+		// Skip over any symlinks in the archive that are not permitted by the configured
+		// symlink policy.
+		if f.Mode()&iofs.ModeSymlink != 0 {
+			if err := fs.checkSymlinkTarget(f.LinkTarget, p); err != nil {
+				log.WithField("path", p).WithField("target", f.LinkTarget).Debug("skipping symlink in archive that is not permitted")
+				return nil
+			}
+		}
+		if err := opts.Limiter.addFile(); err != nil {
+			return err
+		}
 		r, err := f.Open()
 		if err != nil {
 			return err
 		}
 		defer r.Close()
-		if err := fs.Write(p, r, f.Size(), f.Mode()); err != nil {
+		if opts.Limiter != nil {
+			if _, err := fs.unixFS.Lstat(p); errors.Is(err, ufs.ErrNotExist) {
+				created = append(created, p)
+			}
+		}
+		if err := fs.Write(p, opts.Limiter.reader(r), f.Size(), f.Mode()); err != nil {
 			return wrapError(err, opts.FileName)
 		}
 		// Update the file modification time to the one set in the archive.
@@ -282,4 +327,81 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 		}
 		return nil
 	})
+	if IsErrorCode(err, ErrCodeArchiveLimits) {
+		for _, p := range created {
+			if derr := fs.Delete(p); derr != nil && !errors.Is(derr, ufs.ErrNotExist) {
+				log.WithField("path", p).WithField("error", derr).Warn("failed to remove file after archive exceeded extraction limits")
+			}
+		}
+	}
+	return err
+}
+
+// extractLimiter tracks the number of files and bytes extracted from an archive
+// and returns an error once any of the configured extraction limits have been
+// exceeded. A nil limiter enforces no limits.
+type extractLimiter struct {
+	maxBytes int64
+	maxFiles int
+	maxRatio float64
+	size     int64
+	files    int
+	written  int64
+}
+
+// newExtractLimiter returns a limiter using the configured extraction limits for
+// an archive of the given size.
+func newExtractLimiter(size int64) *extractLimiter {
+	cfg := config.Get().System.Extraction
+	return &extractLimiter{
+		maxBytes: cfg.MaxTotalBytes,
+		maxFiles: cfg.MaxFiles,
+		maxRatio: cfg.MaxCompressionRatio,
+		size:     size,
+	}
+}
+
+// addFile records that another file is being extracted from the archive.
+func (l *extractLimiter) addFile() error {
+	if l == nil {
+		return nil
+	}
+	if l.files++; l.maxFiles > 0 && l.files > l.maxFiles {
+		return newFilesystemError(ErrCodeArchiveLimits, fmt.Errorf("archive contains more than %d files", l.maxFiles))
+	}
+	return nil
+}
+
+// add records that n more bytes have been extracted from the archive.
+func (l *extractLimiter) add(n int) error {
+	l.written += int64(n)
+	if l.maxBytes > 0 && l.written > l.maxBytes {
+		return newFilesystemError(ErrCodeArchiveLimits, fmt.Errorf("extracted size exceeds %d bytes", l.maxBytes))
+	}
+	if l.maxRatio > 0 && l.size > 0 && float64(l.written) > l.maxRatio*float64(l.size) {
+		return newFilesystemError(ErrCodeArchiveLimits, fmt.Errorf("compression ratio exceeds %g", l.maxRatio))
+	}
+	return nil
+}
+
+// reader wraps the reader for a file in the archive so that the data read from
+// it is counted against the limits.
+func (l *extractLimiter) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{r: r, l: l}
+}
+
+type limitedReader struct {
+	r io.Reader
+	l *extractLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	if lerr := lr.l.add(n); lerr != nil {
+		return n, lerr
+	}
+	return n, err
 }
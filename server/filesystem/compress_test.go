@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
 )
 
 // Given an archive named test.{ext}, with the following file structure:
@@ -47,6 +49,30 @@ func TestFilesystem_DecompressFile(t *testing.T) {
 			})
 		}
 
+		g.It("aborts and cleans up when the archive exceeds the file limit", func() {
+			previous := config.Get().System.Extraction.MaxFiles
+			config.Update(func(c *config.Configuration) {
+				c.System.Extraction.MaxFiles = 1
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.System.Extraction.MaxFiles = previous
+			})
+
+			c, err := os.ReadFile("./testdata/test.tar")
+			g.Assert(err).IsNil()
+			err = rfs.CreateServerFile("./test.tar", c)
+			g.Assert(err).IsNil()
+
+			err = fs.DecompressFile(context.Background(), "/", "test.tar")
+			g.Assert(err).IsNotNil()
+			g.Assert(IsErrorCode(err, ErrCodeArchiveLimits)).IsTrue("err is not ErrCodeArchiveLimits")
+
+			_, err = rfs.StatServerFile("test/outside.txt")
+			g.Assert(err).IsNotNil()
+			_, err = rfs.StatServerFile("test/inside/finside.txt")
+			g.Assert(err).IsNotNil()
+		})
+
 		g.AfterEach(func() {
 			_ = fs.TruncateRootDirectory()
 		})
@@ -84,6 +84,25 @@ func (fs *Filesystem) HasSpaceAvailable(allowStaleValue bool) bool {
 	return size <= fs.MaxDisk()
 }
 
+// HasSpaceAvailableStatfs is the same as HasSpaceAvailable, however the used space of
+// the filesystem containing the server's root directory is compared to the limit
+// rather than the calculated size of the directory. This avoids walking the directory
+// but is only accurate when each server is stored on its own filesystem or has a
+// filesystem quota applied.
+func (fs *Filesystem) HasSpaceAvailableStatfs() bool {
+	if fs.MaxDisk() == 0 {
+		return true
+	}
+	var st unix.Statfs_t
+	if err := unix.Statfs(fs.Path(), &st); err != nil {
+		log.WithField("root", fs.Path()).WithField("error", err).Warn("failed to statfs root fs directory, falling back to calculated disk usage")
+		return fs.HasSpaceAvailable(true)
+	}
+	// Block counts are in units of the fragment size, which is not always the same as
+	// the preferred I/O size reported in Bsize.
+	return int64(st.Blocks-st.Bfree)*st.Frsize <= fs.MaxDisk()
+}
+
 // Returns the cached value for the amount of disk space used by the filesystem. Do not rely on this
 // function for critical logical checks. It should only be used in areas where the actual disk usage
 // does not need to be perfect, e.g. API responses for server resource usage.
@@ -146,7 +165,15 @@ func (fs *Filesystem) updateCachedDiskUsage() (int64, error) {
 	// will have effectively no impact), or there is nothing in the cache, in which case we need to
 	// grab the size of their data directory. This is a taxing operation, so we want to store it in
 	// the cache once we've gotten it.
-	size, err := fs.DirectorySize("/")
+	size, err := fs.directorySize("/", fs.maxFiles)
+	if errors.Is(err, errTooManyFiles) {
+		if !fs.tooManyFiles.Swap(true) {
+			log.WithField("root", fs.Path()).WithField("max_files", fs.maxFiles).Warn("server contains too many files, aborted disk usage calculation and using partial size")
+		}
+		err = nil
+	} else if err == nil {
+		fs.tooManyFiles.Store(false)
+	}
 
 	// Always cache the size, even if there is an error. We want to always return that value
 	// so that we don't cause an endless loop of determining the disk size if there is a temporary
@@ -158,8 +185,25 @@ func (fs *Filesystem) updateCachedDiskUsage() (int64, error) {
 	return size, err
 }
 
+// TooManyFiles returns true if the last disk usage calculation for the server was
+// aborted because it contained more files than allowed by the configuration.
+func (fs *Filesystem) TooManyFiles() bool {
+	return fs.tooManyFiles.Load()
+}
+
+// errTooManyFiles is returned by directorySize when the walk is aborted because
+// the maximum number of files was exceeded.
+var errTooManyFiles = errors.Sentinel("too many files")
+
 // DirectorySize calculates the size of a directory and its descendants.
 func (fs *Filesystem) DirectorySize(root string) (int64, error) {
+	return fs.directorySize(root, 0)
+}
+
+// directorySize calculates the size of a directory and its descendants, aborting
+// the walk with errTooManyFiles and returning the partial size if more than
+// maxFiles entries are encountered. A maxFiles value of 0 disables the limit.
+func (fs *Filesystem) directorySize(root string, maxFiles int64) (int64, error) {
 	dirfd, name, closeFd, err := fs.unixFS.SafePath(root)
 	defer closeFd()
 	if err != nil {
@@ -169,11 +213,17 @@ func (fs *Filesystem) DirectorySize(root string) (int64, error) {
 	var hardLinks []uint64
 
 	var size atomic.Int64
+	var files int64
 	err = fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, _ string, d ufs.DirEntry, err error) error {
 		if err != nil {
 			return errors.Wrap(err, "walkdirat err")
 		}
 
+		files++
+		if maxFiles > 0 && files > maxFiles {
+			return errTooManyFiles
+		}
+
 		// Only calculate the size of regular files.
 		if !d.Type().IsRegular() {
 			return nil
@@ -198,16 +248,53 @@ func (fs *Filesystem) DirectorySize(root string) (int64, error) {
 		size.Add(info.Size())
 		return nil
 	})
+	if errors.Is(err, errTooManyFiles) {
+		return size.Load(), err
+	}
 	return size.Load(), errors.WrapIf(err, "server/filesystem: directorysize: failed to walk directory")
 }
 
 func (fs *Filesystem) HasSpaceFor(size int64) error {
+	if fs.enforceMaxFiles && fs.tooManyFiles.Load() {
+		return newFilesystemError(ErrCodeTooManyFiles, nil)
+	}
 	if !fs.unixFS.CanFit(size) {
 		return newFilesystemError(ErrCodeDiskSpace, nil)
 	}
 	return nil
 }
 
+// trackedFile wraps a file opened for writing and adds any growth in the size of
+// the file to the disk usage of the Filesystem it belongs to. A write that would
+// cause the disk limit to be exceeded is refused before it is performed.
+type trackedFile struct {
+	ufs.File
+
+	mu   sync.Mutex
+	fs   *Filesystem
+	size int64
+}
+
+// WriteAt writes to the underlying file at the given offset, updating the disk
+// usage of the Filesystem with any growth in the file.
+func (tf *trackedFile) WriteAt(b []byte, off int64) (int, error) {
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+
+	if grow := off + int64(len(b)) - tf.size; grow > 0 {
+		if err := tf.fs.HasSpaceFor(grow); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := tf.File.WriteAt(b, off)
+	if end := off + int64(n); end > tf.size {
+		tf.fs.addDisk(end - tf.size)
+		tf.size = end
+	}
+	return n, err
+}
+
 // Updates the disk usage for the Filesystem instance.
 func (fs *Filesystem) addDisk(i int64) int64 {
 	return fs.unixFS.Add(i)
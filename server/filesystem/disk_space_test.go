@@ -0,0 +1,60 @@
+package filesystem
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_DiskCheckMaxFiles(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("updateCachedDiskUsage", func() {
+		g.BeforeEach(func() {
+			_ = fs.TruncateRootDirectory()
+			fs.maxFiles = 0
+			fs.enforceMaxFiles = false
+			fs.tooManyFiles.Store(false)
+			for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+				if err := rfs.CreateServerFileFromString(name, "hello"); err != nil {
+					panic(err)
+				}
+			}
+		})
+
+		g.It("calculates the full size when under the limit", func() {
+			fs.maxFiles = 10
+			size, err := fs.updateCachedDiskUsage()
+			g.Assert(err).IsNil()
+			g.Assert(size).Equal(int64(15))
+			g.Assert(fs.TooManyFiles()).IsFalse()
+		})
+
+		g.It("aborts and flags the server when over the limit", func() {
+			fs.maxFiles = 2
+			size, err := fs.updateCachedDiskUsage()
+			g.Assert(err).IsNil()
+			g.Assert(size < 15).IsTrue()
+			g.Assert(fs.TooManyFiles()).IsTrue()
+		})
+
+		g.It("clears the flag once the server is back under the limit", func() {
+			fs.tooManyFiles.Store(true)
+			fs.maxFiles = 10
+			_, err := fs.updateCachedDiskUsage()
+			g.Assert(err).IsNil()
+			g.Assert(fs.TooManyFiles()).IsFalse()
+		})
+
+		g.It("refuses writes when the limit is enforced", func() {
+			fs.maxFiles = 2
+			fs.enforceMaxFiles = true
+			_, err := fs.updateCachedDiskUsage()
+			g.Assert(err).IsNil()
+
+			err = fs.HasSpaceFor(1)
+			g.Assert(IsErrorCode(err, ErrCodeTooManyFiles)).IsTrue()
+		})
+	})
+}
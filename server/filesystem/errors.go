@@ -18,6 +18,9 @@ const (
 	ErrCodeUnknownArchive ErrorCode = "E_UNKNFMT"
 	ErrCodePathResolution ErrorCode = "E_BADPATH"
 	ErrCodeDenylistFile   ErrorCode = "E_DENYLIST"
+	ErrCodeSymlinkDenied  ErrorCode = "E_SYMLINK"
+	ErrCodeArchiveLimits  ErrorCode = "E_ARCHIVELIMIT"
+	ErrCodeTooManyFiles   ErrorCode = "E_TOOMANYFILES"
 	ErrCodeUnknownError   ErrorCode = "E_UNKNOWN"
 	ErrNotExist           ErrorCode = "E_NOTEXIST"
 )
@@ -70,6 +73,12 @@ func (e *Error) Error() string {
 			r = "<empty>"
 		}
 		return fmt.Sprintf("filesystem: server path [%s] resolves to a location outside the server root: %s", e.path, r)
+	case ErrCodeSymlinkDenied:
+		return fmt.Sprintf("filesystem: cannot perform action: [%s] is or traverses a symlink", e.path)
+	case ErrCodeArchiveLimits:
+		return fmt.Sprintf("filesystem: archive exceeds limits: %s", e.Unwrap())
+	case ErrCodeTooManyFiles:
+		return "filesystem: server contains too many files"
 	case ErrNotExist:
 		return "filesystem: does not exist"
 	case ErrCodeUnknownError:
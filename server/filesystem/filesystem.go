@@ -10,12 +10,14 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/gabriel-vasile/mimetype"
 	ignore "github.com/sabhiram/go-gitignore"
+	"golang.org/x/sys/unix"
 
 	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/internal/ufs"
@@ -28,6 +30,11 @@ type Filesystem struct {
 	lastLookupTime    *usageLookupTime
 	lookupInProgress  atomic.Bool
 	diskCheckInterval time.Duration
+	enforceQuota      bool
+	maxFiles          int64
+	enforceMaxFiles   bool
+	tooManyFiles      atomic.Bool
+	symlinks          string
 	denylist          *ignore.GitIgnore
 
 	isTest bool
@@ -48,6 +55,10 @@ func New(root string, size int64, denylist []string) (*Filesystem, error) {
 		unixFS: quota,
 
 		diskCheckInterval: time.Duration(config.Get().System.DiskCheckInterval),
+		enforceQuota:      config.Get().System.EnforceDiskQuotaOnWrite,
+		maxFiles:          config.Get().System.DiskCheckMaxFiles,
+		enforceMaxFiles:   config.Get().System.EnforceDiskCheckMaxFiles,
+		symlinks:          config.Get().System.FollowSymlinks,
 		lastLookupTime:    &usageLookupTime{},
 		denylist:          ignore.CompileIgnoreLines(denylist...),
 	}, nil
@@ -73,6 +84,9 @@ func (fs *Filesystem) ReadDirStat(path string) ([]ufs.FileInfo, error) {
 
 // File returns a reader for a file instance as well as the stat information.
 func (fs *Filesystem) File(p string) (ufs.File, Stat, error) {
+	if err := fs.checkSymlinks(p); err != nil {
+		return nil, Stat{}, err
+	}
 	f, err := fs.unixFS.Open(p)
 	if err != nil {
 		return nil, Stat{}, err
@@ -93,15 +107,53 @@ func (fs *Filesystem) UnixFS() *ufs.UnixFS {
 // already. If  it is present, the file is opened using the defaults which will truncate
 // the contents. The opened file is then returned to the caller.
 func (fs *Filesystem) Touch(p string, flag int) (ufs.File, error) {
+	if err := fs.checkSymlinks(p); err != nil {
+		return nil, err
+	}
 	return fs.unixFS.Touch(p, flag, 0o644)
 }
 
+// TouchTracked acts the same as Touch, however if disk quotas are enforced on
+// write the returned file tracks the data written to it against the disk usage
+// of the server, and refuses any write that would cause the server to exceed
+// its disk limit.
+func (fs *Filesystem) TouchTracked(p string, flag int) (ufs.File, error) {
+	if !fs.enforceQuota {
+		return fs.Touch(p, flag)
+	}
+	if err := fs.checkSymlinks(p); err != nil {
+		return nil, err
+	}
+
+	var currentSize int64
+	if st, err := fs.unixFS.Stat(p); err == nil {
+		currentSize = st.Size()
+	} else if !errors.Is(err, ufs.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err := fs.unixFS.Touch(p, flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// If the file was truncated when it was opened the previous contents no
+	// longer count against the disk usage of the server.
+	if flag&ufs.O_TRUNC != 0 {
+		fs.addDisk(-currentSize)
+		currentSize = 0
+	}
+	return &trackedFile{File: f, fs: fs, size: currentSize}, nil
+}
+
 // Writefile writes a file to the system. If the file does not already exist one
 // will be created. This will also properly recalculate the disk space used by
 // the server when writing new files or modifying existing ones.
 //
 // DEPRECATED: use `Write` instead.
 func (fs *Filesystem) Writefile(p string, r io.Reader) error {
+	if err := fs.checkSymlinks(p); err != nil {
+		return err
+	}
 	var currentSize int64
 	st, err := fs.unixFS.Stat(p)
 	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
@@ -138,6 +190,9 @@ func (fs *Filesystem) Writefile(p string, r io.Reader) error {
 }
 
 func (fs *Filesystem) Write(p string, r io.Reader, newSize int64, mode ufs.FileMode) error {
+	if err := fs.checkSymlinks(p); err != nil {
+		return err
+	}
 	var currentSize int64
 	st, err := fs.unixFS.Stat(p)
 	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
@@ -186,9 +241,58 @@ func (fs *Filesystem) Write(p string, r io.Reader, newSize int64, mode ufs.FileM
 	return err
 }
 
+// Append writes size bytes from the reader to the end of the file, creating the
+// file if it does not exist. If truncate is true any existing contents of the
+// file are removed first.
+func (fs *Filesystem) Append(p string, r io.Reader, size int64, truncate bool) error {
+	if err := fs.checkSymlinks(p); err != nil {
+		return err
+	}
+	var currentSize int64
+	st, err := fs.unixFS.Stat(p)
+	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
+		return errors.Wrap(err, "server/filesystem: append: failed to stat file")
+	} else if err == nil {
+		if st.IsDir() {
+			return errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: ""})
+		}
+		currentSize = st.Size()
+	}
+
+	flag := ufs.O_WRONLY | ufs.O_APPEND
+	if truncate {
+		flag |= ufs.O_TRUNC
+		if err := fs.HasSpaceFor(size - currentSize); err != nil {
+			return err
+		}
+	} else if err := fs.HasSpaceFor(size); err != nil {
+		return err
+	}
+
+	file, err := fs.unixFS.Touch(p, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if truncate {
+		fs.unixFS.Add(-currentSize)
+	}
+
+	n, err := io.Copy(file, io.LimitReader(r, size))
+	fs.unixFS.Add(n)
+
+	if err := fs.chownFile(p); err != nil {
+		return err
+	}
+	return err
+}
+
 // CreateDirectory creates a new directory (name) at a specified path (p) for
 // the server.
 func (fs *Filesystem) CreateDirectory(name string, p string) error {
+	if err := fs.checkSymlinks(filepath.Join(p, name)); err != nil {
+		return err
+	}
 	return fs.unixFS.MkdirAll(filepath.Join(p, name), 0o755)
 }
 
@@ -196,7 +300,40 @@ func (fs *Filesystem) Rename(oldpath, newpath string) error {
 	return fs.unixFS.Rename(oldpath, newpath)
 }
 
+// TreeDepth returns how many directories deep the deepest entry below p is nested,
+// counted from p itself. A file or an empty directory has a depth of 0. The walk
+// stops once the depth exceeds limit, since the caller only needs to know that it
+// was exceeded.
+func (fs *Filesystem) TreeDepth(p string, limit int) (int, error) {
+	dirfd, name, closeFd, err := fs.unixFS.SafePath(p)
+	defer closeFd()
+	if err != nil {
+		return 0, err
+	}
+
+	var depth int
+	err = fs.unixFS.WalkDirat(dirfd, name, func(_ int, _, relative string, _ ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d := strings.Count(strings.TrimPrefix(relative, name), "/"); d > depth {
+			depth = d
+		}
+		if depth > limit {
+			return ufs.SkipAll
+		}
+		return nil
+	})
+	return depth, err
+}
+
+// Symlink creates newpath as a symlink pointing to oldpath. Depending on the
+// configured symlink policy this may be refused entirely, or only permitted if
+// oldpath resolves within the server root.
 func (fs *Filesystem) Symlink(oldpath, newpath string) error {
+	if err := fs.checkSymlinkTarget(oldpath, newpath); err != nil {
+		return err
+	}
 	return fs.unixFS.Symlink(oldpath, newpath)
 }
 
@@ -207,7 +344,9 @@ func (fs *Filesystem) chownFile(name string) error {
 
 	uid := config.Get().System.User.Uid
 	gid := config.Get().System.User.Gid
-	return fs.unixFS.Lchown(name, uid, gid)
+	return chownWithRetry(func() error {
+		return fs.unixFS.Lchown(name, uid, gid)
+	})
 }
 
 // Chown recursively iterates over a file or directory and sets the permissions on all of the
@@ -229,7 +368,7 @@ func (fs *Filesystem) Chown(p string) error {
 	}
 
 	// Start by just chowning the initial path that we received.
-	if err := fs.unixFS.Lchownat(dirfd, name, uid, gid); err != nil {
+	if err := chownWithRetry(func() error { return fs.unixFS.Lchownat(dirfd, name, uid, gid) }); err != nil {
 		return errors.Wrap(err, "server/filesystem: chown: failed to chown path")
 	}
 
@@ -244,13 +383,22 @@ func (fs *Filesystem) Chown(p string) error {
 	// need to check if every individual path it touches is safe as the code
 	// doesn't traverse symlinks, is immune to symlink timing attacks, and
 	// gives us a dirfd and file name to make a direct syscall with.
+	//
+	// For very large directories periodically log the progress of the walk so
+	// that it is clear the process is not stuck.
+	var count int64
+	last := time.Now()
 	if err := fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, _ string, info ufs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if err := fs.unixFS.Lchownat(dirfd, name, uid, gid); err != nil {
+		if err := chownWithRetry(func() error { return fs.unixFS.Lchownat(dirfd, name, uid, gid) }); err != nil {
 			return err
 		}
+		if count++; count%1000 == 0 && time.Since(last) > 30*time.Second {
+			last = time.Now()
+			log.WithField("path", fs.Path()).WithField("files", count).Info("still setting file permissions for server directory")
+		}
 		return nil
 	}); err != nil {
 		return fmt.Errorf("server/filesystem: chown: failed to chown during walk function: %w", err)
@@ -258,6 +406,87 @@ func (fs *Filesystem) Chown(p string) error {
 	return nil
 }
 
+// MisownedPath returns the first of the server's root directory and the entries
+// directly within it that is not owned by the system user, or an empty string if
+// they all are. This is a quick check for a directory that has had its ownership
+// changed, for example by restoring it from a backup as root, and does not walk
+// the whole directory.
+func (fs *Filesystem) MisownedPath() (string, error) {
+	if fs.isTest {
+		return "", nil
+	}
+	uid := uint32(config.Get().System.User.Uid)
+	gid := uint32(config.Get().System.User.Gid)
+
+	st, err := os.Lstat(fs.Path())
+	if err != nil {
+		return "", err
+	}
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok && (sys.Uid != uid || sys.Gid != gid) {
+		return "/", nil
+	}
+
+	entries, err := fs.ReadDirStat("")
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if sys, ok := e.Sys().(*unix.Stat_t); ok && (sys.Uid != uid || sys.Gid != gid) {
+			return "/" + e.Name(), nil
+		}
+	}
+	return "", nil
+}
+
+// NormalizeOwnership changes the owner of every file in the server's root directory
+// that is not owned by the given uid and gid, returning the number of files that
+// were changed. Unlike Chown, files that already have the correct owner are left
+// untouched.
+func (fs *Filesystem) NormalizeOwnership(uid, gid int) (int64, error) {
+	if fs.isTest {
+		return 0, nil
+	}
+
+	dirfd, name, closeFd, err := fs.unixFS.SafePath("/")
+	defer closeFd()
+	if err != nil {
+		return 0, err
+	}
+
+	var changed int64
+	misowned := func(dirfd int, name string) bool {
+		st, err := fs.unixFS.Lstatat(dirfd, name)
+		if err != nil {
+			return false
+		}
+		sys, ok := st.Sys().(*unix.Stat_t)
+		return ok && (sys.Uid != uint32(uid) || sys.Gid != uint32(gid))
+	}
+	chown := func(dirfd int, name string) error {
+		if !misowned(dirfd, name) {
+			return nil
+		}
+		if err := chownWithRetry(func() error { return fs.unixFS.Lchownat(dirfd, name, uid, gid) }); err != nil {
+			return err
+		}
+		changed++
+		return nil
+	}
+
+	if err := chown(dirfd, name); err != nil {
+		return changed, errors.Wrap(err, "server/filesystem: normalize ownership: failed to chown root directory")
+	}
+	if err := fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, _ string, _ ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return chown(dirfd, name)
+	}); err != nil {
+		return changed, fmt.Errorf("server/filesystem: normalize ownership: failed during walk function: %w", err)
+	}
+	return changed, nil
+}
+
 func (fs *Filesystem) Chmod(path string, mode ufs.FileMode) error {
 	return fs.unixFS.Chmod(path, mode)
 }
@@ -353,7 +582,8 @@ func (fs *Filesystem) Copy(p string) error {
 	fs.unixFS.Add(n)
 
 	if !fs.isTest {
-		if err := fs.unixFS.Lchownat(dirfd, newName, config.Get().System.User.Uid, config.Get().System.User.Gid); err != nil {
+		uid, gid := config.Get().System.User.Uid, config.Get().System.User.Gid
+		if err := chownWithRetry(func() error { return fs.unixFS.Lchownat(dirfd, newName, uid, gid) }); err != nil {
 			return err
 		}
 	}
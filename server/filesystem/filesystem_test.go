@@ -203,6 +203,50 @@ func TestFilesystem_Writefile(t *testing.T) {
 	})
 }
 
+func TestFilesystem_TouchTracked(t *testing.T) {
+	g := Goblin(t)
+	fs, _ := NewFs()
+	fs.enforceQuota = true
+
+	g.Describe("TouchTracked", func() {
+		g.It("tracks data written to the file in the disk usage", func() {
+			f, err := fs.TouchTracked("test.txt", ufs.O_RDWR|ufs.O_TRUNC)
+			g.Assert(err).IsNil()
+			defer f.Close()
+
+			_, err = f.WriteAt([]byte("test file content"), 0)
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(int64(17))
+
+			// Overwriting existing data should not count against the usage again.
+			_, err = f.WriteAt([]byte("TEST"), 0)
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(int64(17))
+		})
+
+		g.It("refuses writes that exceed the disk limits", func() {
+			fs.SetDiskLimit(1024)
+
+			f, err := fs.TouchTracked("test.txt", ufs.O_RDWR|ufs.O_TRUNC)
+			g.Assert(err).IsNil()
+			defer f.Close()
+
+			_, err = f.WriteAt(make([]byte, 1000), 0)
+			g.Assert(err).IsNil()
+
+			_, err = f.WriteAt(make([]byte, 25), 1000)
+			g.Assert(err).IsNotNil()
+			g.Assert(IsErrorCode(err, ErrCodeDiskSpace)).IsTrue()
+			g.Assert(fs.CachedUsage()).Equal(int64(1000))
+		})
+
+		g.AfterEach(func() {
+			fs.SetDiskLimit(0)
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
+
 func TestFilesystem_CreateDirectory(t *testing.T) {
 	g := Goblin(t)
 	fs, rfs := NewFs()
@@ -343,6 +387,53 @@ func TestFilesystem_Rename(t *testing.T) {
 	})
 }
 
+func TestFilesystem_TreeDepth(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("TreeDepth", func() {
+		g.BeforeEach(func() {
+			_ = fs.TruncateRootDirectory()
+			if err := os.MkdirAll(filepath.Join(rfs.root, "server", "a/b/c"), 0o755); err != nil {
+				panic(err)
+			}
+			if err := rfs.CreateServerFileFromString("a/b/c/file.txt", "x"); err != nil {
+				panic(err)
+			}
+			if err := rfs.CreateServerFileFromString("a/file.txt", "x"); err != nil {
+				panic(err)
+			}
+		})
+
+		g.It("returns the depth of the deepest entry", func() {
+			d, err := fs.TreeDepth("/a", 64)
+			g.Assert(err).IsNil()
+			g.Assert(d).Equal(3)
+
+			d, err = fs.TreeDepth("a/b", 64)
+			g.Assert(err).IsNil()
+			g.Assert(d).Equal(2)
+		})
+
+		g.It("returns 0 for a file", func() {
+			d, err := fs.TreeDepth("/a/file.txt", 64)
+			g.Assert(err).IsNil()
+			g.Assert(d).Equal(0)
+		})
+
+		g.It("stops once the limit is exceeded", func() {
+			d, err := fs.TreeDepth("/a", 1)
+			g.Assert(err).IsNil()
+			g.Assert(d > 1).IsTrue()
+		})
+
+		g.It("returns an error if the path does not exist", func() {
+			_, err := fs.TreeDepth("/missing", 64)
+			g.Assert(errors.Is(err, ufs.ErrNotExist)).IsTrue()
+		})
+	})
+}
+
 func TestFilesystem_Copy(t *testing.T) {
 	g := Goblin(t)
 	fs, rfs := NewFs()
@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/internal/ufs"
+)
+
+// activeLogWindow is how recently a log file must have been written to for it
+// to be considered in use by the server process.
+const activeLogWindow = 5 * time.Minute
+
+// LogRetentionResult is the outcome of pruning the log files of a server.
+type LogRetentionResult struct {
+	Removed []string
+	Rotated []string
+}
+
+type logFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// PruneLogs removes the log files in the given directories that were last
+// written more than maxAge ago, and then removes the oldest log files until the
+// combined size of those remaining is no more than maxSize bytes. A limit of 0
+// disables that check.
+//
+// Files that are still being written to are never removed or truncated since
+// the server process holds them open. If one of those alone puts the server over
+// the size limit it is rotated instead, by renaming it so that the server starts
+// a new file the next time it opens its log, and the rotated file is pruned on a
+// later run once it is no longer written to.
+func (fs *Filesystem) PruneLogs(dirs []string, maxSize int64, maxAge time.Duration, now time.Time) (LogRetentionResult, error) {
+	var res LogRetentionResult
+	var files []logFile
+	for _, dir := range dirs {
+		entries, err := fs.ReadDirStat(dir)
+		if err != nil {
+			if errors.Is(err, ufs.ErrNotExist) {
+				continue
+			}
+			return res, errors.WrapIf(err, "filesystem: failed to read log directory")
+		}
+		for _, e := range entries {
+			if !e.Mode().IsRegular() || !strings.Contains(e.Name(), ".log") {
+				continue
+			}
+			files = append(files, logFile{path: path.Join(dir, e.Name()), size: e.Size(), modTime: e.ModTime()})
+		}
+	}
+
+	// Oldest files first, so they are the first to be removed when over the size limit.
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var total int64
+	kept := files[:0]
+	for _, f := range files {
+		if maxAge > 0 && now.Sub(f.modTime) > maxAge {
+			if err := fs.Delete(f.path); err != nil {
+				return res, errors.WrapIf(err, "filesystem: failed to remove expired log file")
+			}
+			res.Removed = append(res.Removed, f.path)
+			continue
+		}
+		total += f.size
+		kept = append(kept, f)
+	}
+
+	if maxSize <= 0 {
+		return res, nil
+	}
+	for _, f := range kept {
+		if total <= maxSize {
+			break
+		}
+		if now.Sub(f.modTime) < activeLogWindow {
+			// Only rotate the file the server writes to, not one that has already been
+			// rotated and is still held open by the server process.
+			if f.size > maxSize && strings.HasSuffix(f.path, ".log") {
+				rotated := f.path + "." + strconv.FormatInt(now.Unix(), 10)
+				if err := fs.Rename(f.path, rotated); err != nil {
+					return res, errors.WrapIf(err, "filesystem: failed to rotate log file")
+				}
+				res.Rotated = append(res.Rotated, f.path)
+			}
+			continue
+		}
+		if err := fs.Delete(f.path); err != nil {
+			return res, errors.WrapIf(err, "filesystem: failed to remove log file")
+		}
+		res.Removed = append(res.Removed, f.path)
+		total -= f.size
+	}
+	return res, nil
+}
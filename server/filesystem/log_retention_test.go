@@ -0,0 +1,86 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_PruneLogs(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+	now := time.Now()
+
+	create := func(name string, size int, age time.Duration) {
+		if err := rfs.CreateServerFileFromString(name, strings.Repeat("a", size)); err != nil {
+			panic(err)
+		}
+		mtime := now.Add(-age)
+		if err := os.Chtimes(filepath.Join(rfs.root, "server", name), mtime, mtime); err != nil {
+			panic(err)
+		}
+	}
+	exists := func(name string) bool {
+		_, err := rfs.StatServerFile(name)
+		return err == nil
+	}
+
+	g.Describe("PruneLogs", func() {
+		g.BeforeEach(func() {
+			_ = fs.TruncateRootDirectory()
+			if err := os.Mkdir(filepath.Join(rfs.root, "server", "logs"), 0o755); err != nil {
+				panic(err)
+			}
+		})
+
+		g.It("ignores missing directories", func() {
+			res, err := fs.PruneLogs([]string{"missing"}, 10, time.Hour, now)
+			g.Assert(err).IsNil()
+			g.Assert(len(res.Removed)).Equal(0)
+		})
+
+		g.It("removes log files older than the maximum age", func() {
+			create("logs/old.log.gz", 10, 48*time.Hour)
+			create("logs/latest.log", 10, time.Minute)
+			create("logs/notes.txt", 10, 48*time.Hour)
+
+			res, err := fs.PruneLogs([]string{"logs"}, 0, 24*time.Hour, now)
+			g.Assert(err).IsNil()
+			g.Assert(res.Removed).Equal([]string{"logs/old.log.gz"})
+			g.Assert(exists("logs/old.log.gz")).IsFalse()
+			g.Assert(exists("logs/latest.log")).IsTrue()
+			g.Assert(exists("logs/notes.txt")).IsTrue()
+		})
+
+		g.It("removes the oldest log files when over the maximum size", func() {
+			create("logs/a.log.gz", 10, 3*time.Hour)
+			create("logs/b.log.gz", 10, 2*time.Hour)
+			create("logs/latest.log", 10, time.Minute)
+
+			res, err := fs.PruneLogs([]string{"logs"}, 20, 0, now)
+			g.Assert(err).IsNil()
+			g.Assert(res.Removed).Equal([]string{"logs/a.log.gz"})
+			g.Assert(exists("logs/b.log.gz")).IsTrue()
+			g.Assert(exists("logs/latest.log")).IsTrue()
+		})
+
+		g.It("rotates an active log file instead of removing it", func() {
+			create("logs/latest.log", 30, time.Minute)
+
+			res, err := fs.PruneLogs([]string{"logs"}, 20, 0, now)
+			g.Assert(err).IsNil()
+			g.Assert(len(res.Removed)).Equal(0)
+			g.Assert(res.Rotated).Equal([]string{"logs/latest.log"})
+			g.Assert(exists("logs/latest.log")).IsFalse()
+
+			st, err := rfs.StatServerFile("logs/latest.log." + strconv.FormatInt(now.Unix(), 10))
+			g.Assert(err).IsNil()
+			g.Assert(st.Size()).Equal(int64(30))
+		})
+	})
+}
@@ -1,12 +1,19 @@
 package filesystem
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 
 	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/internal/ufs"
 )
 
+// The maximum number of symlinks that will be followed when resolving a path,
+// matching the limit used by the Linux kernel.
+const maxSymlinks = 40
+
 // Checks if the given file or path is in the server's file denylist. If so, an Error
 // is returned, otherwise nil is returned.
 func (fs *Filesystem) IsIgnored(paths ...string) error {
@@ -41,3 +48,109 @@ func (fs *Filesystem) unsafeFilePath(p string) string {
 func (fs *Filesystem) unsafeIsInDataDirectory(p string) bool {
 	return strings.HasPrefix(strings.TrimSuffix(p, "/")+"/", strings.TrimSuffix(fs.Path(), "/")+"/")
 }
+
+// SafeResolve resolves the path p, which is relative to serverRoot, following any
+// symlinks along the way and returns the resulting absolute path. Components of
+// the path that do not exist yet are resolved lexically. An error is returned if
+// the resolved path is not within serverRoot.
+func SafeResolve(serverRoot, p string) (string, error) {
+	base, err := filepath.EvalSymlinks(serverRoot)
+	if err != nil {
+		return "", errors.Wrap(err, "server/filesystem: failed to resolve server root")
+	}
+	resolved, err := resolvePath(filepath.Join(base, p))
+	if err != nil {
+		return "", err
+	}
+	if resolved != base && !strings.HasPrefix(resolved, base+"/") {
+		return "", errors.WithStack(&Error{code: ErrCodePathResolution, path: p, resolved: resolved, err: ufs.ErrBadPathResolution})
+	}
+	return resolved, nil
+}
+
+// resolvePath resolves all the symlinks in the absolute path p one component at
+// a time. Unlike filepath.EvalSymlinks this does not fail if the path, or the
+// target of a symlink within it, does not exist.
+func resolvePath(p string) (string, error) {
+	resolved := "/"
+	parts := strings.Split(p, "/")
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		st, err := os.Lstat(next)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", errors.WithStack(err)
+		}
+		if err != nil || st.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", errors.Errorf("server/filesystem: too many levels of symbolic links resolving %s", p)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	return resolved, nil
+}
+
+// checkSymlinks enforces the configured symlink policy for a path within the
+// server root. When symlinks are contained or allowed this is a no-op, since
+// the underlying filesystem never resolves a path outside the server root.
+func (fs *Filesystem) checkSymlinks(p string) error {
+	if fs.symlinks != "deny" {
+		return nil
+	}
+	resolved, err := SafeResolve(fs.Path(), p)
+	if err != nil {
+		return err
+	}
+	base, err := filepath.EvalSymlinks(fs.Path())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// If the resolved path differs from the lexical one at least one symlink was
+	// traversed while resolving it.
+	if resolved != filepath.Join(base, p) {
+		return errors.WithStack(&Error{code: ErrCodeSymlinkDenied, path: p, resolved: resolved})
+	}
+	return nil
+}
+
+// checkSymlinkTarget enforces the configured symlink policy for a new symlink at
+// newpath pointing to oldpath.
+func (fs *Filesystem) checkSymlinkTarget(oldpath, newpath string) error {
+	switch fs.symlinks {
+	case "allow":
+		return nil
+	case "deny":
+		return errors.WithStack(&Error{code: ErrCodeSymlinkDenied, path: newpath})
+	}
+	target := oldpath
+	if filepath.IsAbs(target) {
+		rel, err := filepath.Rel(fs.Path(), target)
+		if err != nil {
+			return errors.WithStack(&Error{code: ErrCodePathResolution, path: oldpath, resolved: target, err: ufs.ErrBadPathResolution})
+		}
+		target = rel
+	} else {
+		target = filepath.Join(filepath.Dir(strings.TrimPrefix(newpath, "/")), target)
+	}
+	_, err := SafeResolve(fs.Path(), target)
+	return err
+}
@@ -168,3 +168,79 @@ func TestFilesystem_Blocks_Symlinks(t *testing.T) {
 
 	_ = fs.TruncateRootDirectory()
 }
+
+func TestFilesystem_SafeResolve(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("SafeResolve", func() {
+		g.BeforeEach(func() {
+			_ = fs.TruncateRootDirectory()
+			fs.symlinks = "contain"
+		})
+
+		g.It("resolves a path within the root", func() {
+			_ = rfs.CreateServerFileFromString("test.txt", "content")
+			base, _ := filepath.EvalSymlinks(fs.Path())
+
+			p, err := SafeResolve(fs.Path(), "test.txt")
+			g.Assert(err).IsNil()
+			g.Assert(p).Equal(filepath.Join(base, "test.txt"))
+		})
+
+		g.It("resolves a symlink that points within the root", func() {
+			_ = rfs.CreateServerFileFromString("test.txt", "content")
+			_ = os.Symlink("test.txt", filepath.Join(rfs.root, "server", "link.txt"))
+			base, _ := filepath.EvalSymlinks(fs.Path())
+
+			p, err := SafeResolve(fs.Path(), "link.txt")
+			g.Assert(err).IsNil()
+			g.Assert(p).Equal(filepath.Join(base, "test.txt"))
+		})
+
+		g.It("rejects a dangling symlink that points outside the root", func() {
+			_ = os.Symlink("../../does_not_exist", filepath.Join(rfs.root, "server", "link.txt"))
+
+			_, err := SafeResolve(fs.Path(), "link.txt/foo")
+			g.Assert(err).IsNotNil()
+			g.Assert(errors.Is(err, ufs.ErrBadPathResolution)).IsTrue("err is not ErrBadPathResolution")
+		})
+
+		g.It("rejects a path that traverses outside the root", func() {
+			_, err := SafeResolve(fs.Path(), "../malicious.txt")
+			g.Assert(err).IsNotNil()
+		})
+
+		g.It("does not allow creating a symlink pointing outside the root", func() {
+			err := fs.Symlink("../../malicious.txt", "foo/link.txt")
+			g.Assert(err).IsNotNil()
+			g.Assert(errors.Is(err, ufs.ErrBadPathResolution)).IsTrue("err is not ErrBadPathResolution")
+
+			err = fs.Symlink(filepath.Join(rfs.root, "malicious.txt"), "link.txt")
+			g.Assert(err).IsNotNil()
+		})
+
+		g.It("allows creating a symlink pointing within the root", func() {
+			err := fs.Symlink("test.txt", "link.txt")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("denies traversing symlinks when configured to", func() {
+			fs.symlinks = "deny"
+			_ = rfs.CreateServerFileFromString("test.txt", "content")
+			_ = os.Symlink("test.txt", filepath.Join(rfs.root, "server", "link.txt"))
+
+			_, _, err := fs.File("link.txt")
+			g.Assert(err).IsNotNil()
+			var fserr *Error
+			g.Assert(errors.As(err, &fserr)).IsTrue()
+			g.Assert(fserr.Code()).Equal(ErrCodeSymlinkDenied)
+
+			f, _, err := fs.File("test.txt")
+			g.Assert(err).IsNil()
+			_ = f.Close()
+
+			g.Assert(fs.Symlink("test.txt", "other.txt")).IsNotNil()
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package filesystem
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/ufs"
+)
+
+// WriteStaged writes the file at the given path in the same way as Write, except
+// that when an upload staging directory is configured the contents are first
+// written to a temporary file within it, and only moved into the server directory
+// once the entire file has been written. This means that a partially written file
+// never replaces an existing file.
+func (fs *Filesystem) WriteStaged(p string, r io.Reader, newSize int64, mode ufs.FileMode) error {
+	dir := config.Get().System.UploadStagingDir
+	if dir == "" {
+		return fs.Write(p, r, newSize, mode)
+	}
+
+	if err := fs.checkSymlinks(p); err != nil {
+		return err
+	}
+	var currentSize int64
+	st, err := fs.unixFS.Stat(p)
+	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
+		return errors.Wrap(err, "server/filesystem: writestaged: failed to stat file")
+	} else if err == nil {
+		if st.IsDir() {
+			return errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: ""})
+		}
+		currentSize = st.Size()
+	}
+	if err := fs.HasSpaceFor(newSize - currentSize); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		return errors.Wrap(err, "server/filesystem: writestaged: failed to create staging file")
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(r, newSize))
+	if err == nil {
+		err = tmp.Chmod(os.FileMode(mode))
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "server/filesystem: writestaged: failed to write staging file")
+	}
+
+	if err := fs.moveIntoPlace(tmp.Name(), p); err != nil {
+		return err
+	}
+	fs.unixFS.Add(n - currentSize)
+
+	return fs.chownFile(p)
+}
+
+// moveIntoPlace moves the file at src, which is outside the server directory, to
+// the path p within it, replacing any existing file. If src is on a different
+// filesystem it is copied next to the destination and then renamed into place so
+// that the destination is still replaced atomically.
+func (fs *Filesystem) moveIntoPlace(src string, p string) error {
+	if err := fs.unixFS.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	dirfd, name, closeFd, err := fs.unixFS.SafePath(p)
+	defer closeFd()
+	if err != nil {
+		return err
+	}
+
+	err = unix.Renameat(unix.AT_FDCWD, src, dirfd, name)
+	if err == nil || !errors.Is(err, unix.EXDEV) {
+		return errors.WrapIf(err, "server/filesystem: writestaged: failed to move staging file")
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := "." + name + ".staged"
+	out, err := fs.unixFS.OpenFileat(dirfd, tmp, ufs.O_WRONLY|ufs.O_CREATE|ufs.O_TRUNC, ufs.FileMode(st.Mode().Perm()))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = unix.Renameat(dirfd, tmp, dirfd, name)
+	}
+	if err != nil {
+		_ = unix.Unlinkat(dirfd, tmp, 0)
+		return errors.Wrap(err, "server/filesystem: writestaged: failed to copy staging file")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package filesystem
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestFilesystem_WriteStaged(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+	staging := filepath.Join(rfs.root, "staging")
+
+	g.Describe("WriteStaged", func() {
+		g.BeforeEach(func() {
+			_ = fs.TruncateRootDirectory()
+			_ = os.RemoveAll(staging)
+			if err := os.Mkdir(staging, 0o755); err != nil {
+				panic(err)
+			}
+			config.Update(func(c *config.Configuration) {
+				c.System.UploadStagingDir = staging
+			})
+		})
+
+		g.AfterEach(func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.UploadStagingDir = ""
+			})
+		})
+
+		g.It("moves the staged file into the server directory", func() {
+			err := fs.WriteStaged("nested/test.txt", bytes.NewReader([]byte("hello")), 5, 0o644)
+			g.Assert(err).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server", "nested", "test.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("hello")
+			g.Assert(fs.CachedUsage()).Equal(int64(5))
+
+			entries, err := os.ReadDir(staging)
+			g.Assert(err).IsNil()
+			g.Assert(len(entries)).Equal(0)
+		})
+
+		g.It("replaces an existing file", func() {
+			err := rfs.CreateServerFileFromString("test.txt", "previous contents")
+			g.Assert(err).IsNil()
+
+			err = fs.WriteStaged("test.txt", bytes.NewReader([]byte("new")), 3, 0o644)
+			g.Assert(err).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server", "test.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("new")
+		})
+
+		g.It("writes in place when no staging directory is configured", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.UploadStagingDir = ""
+			})
+
+			err := fs.WriteStaged("test.txt", bytes.NewReader([]byte("hello")), 5, 0o644)
+			g.Assert(err).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server", "test.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("hello")
+		})
+	})
+}
@@ -0,0 +1,67 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/internal/ufs"
+)
+
+// matchesLockPattern returns whether the path provided, relative to the root of
+// the server, matches any of the patterns. A pattern without a slash is matched
+// against the name of the file in any directory.
+func matchesLockPattern(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		target := p
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(p)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveStaleLocks removes the regular files in the server's data directory that
+// match any of the glob patterns provided, returning the paths of the files that
+// were removed. This must only be called while the server process is not running,
+// since the lock files of a running server are not stale.
+func (fs *Filesystem) RemoveStaleLocks(patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	dirfd, name, closeFd, err := fs.unixFS.SafePath("/")
+	defer closeFd()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	if err := fs.unixFS.WalkDirat(dirfd, name, func(_ int, _, relative string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if p := path.Clean(relative); matchesLockPattern(patterns, p) {
+			matches = append(matches, p)
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.WrapIf(err, "filesystem: failed to walk data directory for stale lock files")
+	}
+
+	removed := make([]string, 0, len(matches))
+	for _, p := range matches {
+		if err := fs.unixFS.Remove(p); err != nil && !errors.Is(err, ufs.ErrNotExist) {
+			return removed, errors.WrapIf(err, "filesystem: failed to remove stale lock file")
+		}
+		removed = append(removed, p)
+	}
+	return removed, nil
+}
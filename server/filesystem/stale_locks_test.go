@@ -0,0 +1,60 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_RemoveStaleLocks(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	exists := func(name string) bool {
+		_, err := rfs.StatServerFile(name)
+		return err == nil
+	}
+
+	g.Describe("RemoveStaleLocks", func() {
+		g.BeforeEach(func() {
+			_ = fs.TruncateRootDirectory()
+			for _, d := range []string{"world", "world_nether", "plugins"} {
+				if err := os.Mkdir(filepath.Join(rfs.root, "server", d), 0o755); err != nil {
+					panic(err)
+				}
+			}
+			for _, f := range []string{"world/session.lock", "world_nether/session.lock", "plugins/data.lock", "server.properties"} {
+				if err := rfs.CreateServerFileFromString(f, "x"); err != nil {
+					panic(err)
+				}
+			}
+		})
+
+		g.It("removes files matching a name in any directory", func() {
+			removed, err := fs.RemoveStaleLocks([]string{"session.lock"})
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(2)
+			g.Assert(exists("world/session.lock")).IsFalse()
+			g.Assert(exists("world_nether/session.lock")).IsFalse()
+			g.Assert(exists("plugins/data.lock")).IsTrue()
+			g.Assert(exists("server.properties")).IsTrue()
+		})
+
+		g.It("matches patterns containing a slash against the full path", func() {
+			removed, err := fs.RemoveStaleLocks([]string{"plugins/*.lock", "world/*.lock"})
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(2)
+			g.Assert(exists("world_nether/session.lock")).IsTrue()
+			g.Assert(exists("plugins/data.lock")).IsFalse()
+		})
+
+		g.It("does not remove directories", func() {
+			removed, err := fs.RemoveStaleLocks([]string{"world"})
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(0)
+			g.Assert(exists("world")).IsTrue()
+		})
+	})
+}
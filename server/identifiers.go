@@ -0,0 +1,69 @@
+package server
+
+import (
+	"strings"
+	"unicode"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/asaskevich/govalidator"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// checkIdentifiers checks the identifiers of a server received from the Panel
+// before they are used. The UUID must always be a valid UUIDv4 since it is used in
+// paths on the host, while the values applied to the container as labels are
+// either sanitized or rejected depending on the mode provided.
+func checkIdentifiers(c *Configuration, mode string) error {
+	if !govalidator.IsUUIDv4(c.Uuid) {
+		return errors.Errorf("server: uuid %q received from the Panel is not a valid UUIDv4", c.Uuid)
+	}
+
+	reject := mode == config.ServerIdentifiersReject
+	clean := func(field string, v string) (string, error) {
+		s := sanitizeLabelValue(v)
+		if s != v && reject {
+			return "", errors.Errorf("server: %s of server %s contains control characters or invalid UTF-8", field, c.Uuid)
+		}
+		return s, nil
+	}
+
+	var err error
+	if c.Meta.Name, err = clean("name", c.Meta.Name); err != nil {
+		return err
+	}
+	if c.Meta.Description, err = clean("description", c.Meta.Description); err != nil {
+		return err
+	}
+	for k, v := range c.Meta.Metadata {
+		if c.Meta.Metadata[k], err = clean("metadata field \""+sanitizeLabelValue(k)+"\"", v); err != nil {
+			return err
+		}
+	}
+	for k, v := range c.Labels {
+		if !config.IsValidLabelKey(k) {
+			if reject {
+				return errors.Errorf("server: label %q of server %s is not a valid container label", k, c.Uuid)
+			}
+			log.WithField("server", c.Uuid).WithField("label", sanitizeLabelValue(k)).Warn("dropping label assigned by the Panel that is not a valid container label")
+			delete(c.Labels, k)
+			continue
+		}
+		if c.Labels[k], err = clean("label \""+k+"\"", v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sanitizeLabelValue removes invalid UTF-8 and control characters from a value so
+// that it can be safely applied to a container as a label.
+func sanitizeLabelValue(v string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(v, ""))
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestCheckIdentifiers(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("checkIdentifiers", func() {
+		const id = "9f1c6f2e-5b3a-4c7d-8e9f-0a1b2c3d4e5f"
+		newConfig := func() *Configuration {
+			return &Configuration{
+				Uuid: id,
+				Meta: ConfigurationMeta{
+					Name:     "My\x00 Server\n",
+					Metadata: map[string]string{"owner": "bad\xffvalue"},
+				},
+				Labels: map[string]string{"tier": "low\t", "bad\nkey": "x", "bad\xffkey": "x", "": "x", "com.docker.compose": "y", "traefik.http.routers.myRouter.rule": "Host(`a`)"},
+			}
+		}
+
+		g.It("rejects a uuid that is not a valid UUIDv4", func() {
+			for _, v := range []string{"", "../../etc", "{" + id + "}", "9f1c6f2e-5b3a-1c7d-8e9f-0a1b2c3d4e5f"} {
+				c := newConfig()
+				c.Uuid = v
+				g.Assert(checkIdentifiers(c, config.ServerIdentifiersSanitize) != nil).IsTrue()
+			}
+		})
+
+		g.It("sanitizes values and drops invalid label keys", func() {
+			c := newConfig()
+			g.Assert(checkIdentifiers(c, config.ServerIdentifiersSanitize)).IsNil()
+			g.Assert(c.Meta.Name).Equal("My Server")
+			g.Assert(c.Meta.Metadata["owner"]).Equal("badvalue")
+			g.Assert(c.Labels).Equal(map[string]string{"tier": "low", "traefik.http.routers.myRouter.rule": "Host(`a`)"})
+		})
+
+		g.It("rejects unsafe values when configured to", func() {
+			g.Assert(checkIdentifiers(newConfig(), config.ServerIdentifiersReject) != nil).IsTrue()
+
+			c := &Configuration{Uuid: id, Meta: ConfigurationMeta{Name: "Server"}, Labels: map[string]string{"tier": "low"}}
+			g.Assert(checkIdentifiers(c, config.ServerIdentifiersReject)).IsNil()
+		})
+	})
+}
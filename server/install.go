@@ -1,11 +1,11 @@
 package server
 
 import (
-	"bufio"
 	"context"
 	"html/template"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -95,6 +95,9 @@ func (s *Server) Reinstall() error {
 
 // Internal installation function used to simplify reporting back to the Panel.
 func (s *Server) internalInstall() error {
+	if err := s.checkEnvironmentPolicy(); err != nil {
+		return err
+	}
 	script, err := s.client.GetInstallationScript(s.Context(), s.ID())
 	if err != nil {
 		return err
@@ -205,9 +208,57 @@ func (ip *InstallationProcess) Run() error {
 		ip.Server.Log().WithField("error", err).Warn("failed to complete after-execute step of installation process")
 	}
 
+	if err := ip.runPostInstallHook(); err != nil {
+		if config.Get().System.PostInstallHook.FailInstall {
+			return errors.WrapIf(err, "install: post-install hook failed")
+		}
+		ip.Server.Log().WithField("error", err).Warn("post-install hook failed, continuing with installation")
+	}
+
 	return nil
 }
 
+// runPostInstallHook executes the post-install hook command defined in the
+// configuration, if there is one. The output of the command is appended to the
+// installation log for the server.
+func (ip *InstallationProcess) runPostInstallHook() error {
+	hook := config.Get().System.PostInstallHook
+	if hook.Command == "" {
+		return nil
+	}
+	a := ip.Server.Config().Allocations.DefaultMapping
+	data := config.PostInstallHookData{Uuid: ip.Server.ID(), Ip: a.Ip, Port: a.Port}
+	cmd, err := hook.Render(data)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ip.Server.Context(), time.Duration(hook.Timeout)*time.Second)
+	defer cancel()
+
+	ip.Server.Log().WithField("command", cmd).Info("executing post-install hook for server")
+	c := exec.CommandContext(ctx, "/bin/sh", "-c", cmd)
+	c.Env = append(os.Environ(), data.Env()...)
+	out, err := c.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = errors.Errorf("command did not complete within %d seconds", hook.Timeout)
+	}
+
+	f, ferr := os.OpenFile(ip.GetLogPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if ferr != nil {
+		ip.Server.Log().WithField("error", ferr).Warn("failed to open installation log for post-install hook output")
+	} else {
+		defer f.Close()
+		_, _ = f.WriteString("\n|\n| Post-Install Hook Output\n| ------------------------------\n")
+		_, _ = f.Write(out)
+		if err != nil {
+			_, _ = f.WriteString("\n(hook failed: " + err.Error() + ")\n")
+		}
+	}
+
+	return errors.WithStackIf(err)
+}
+
 // Returns the location of the temporary data for the installation process.
 func (ip *InstallationProcess) tempDir() string {
 	return filepath.Join(config.Get().System.TmpDirectory, ip.Server.ID())
@@ -258,7 +309,9 @@ func (ip *InstallationProcess) pullInstallationImage() error {
 		imagePullOptions.RegistryAuth = b64
 	}
 
-	r, err := ip.client.ImagePull(ip.Server.Context(), ip.Script.ContainerImage, imagePullOptions)
+	err := environment.PullImage(ip.Server.Context(), ip.client, ip.Script.ContainerImage, imagePullOptions, func(status string) {
+		log.Debug(status)
+	})
 	if err != nil {
 		images, ierr := ip.client.ImageList(ip.Server.Context(), types.ImageListOptions{})
 		if ierr != nil {
@@ -286,19 +339,6 @@ func (ip *InstallationProcess) pullInstallationImage() error {
 
 		return err
 	}
-	defer r.Close()
-
-	log.WithField("image", ip.Script.ContainerImage).Debug("pulling docker image... this could take a bit of time")
-
-	// Block continuation until the image has been pulled successfully.
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		log.Debug(scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
 
 	return nil
 }
@@ -387,6 +427,27 @@ func (ip *InstallationProcess) AfterExecute(containerId string) error {
 	return nil
 }
 
+// environmentVariables returns the environment variables for the installation
+// container. These are the server's own environment variables, followed by any
+// host proxy settings and installation specific variables defined in the
+// configuration. Docker uses the last value defined for a variable, so the
+// configured installation variables take priority.
+func (ip *InstallationProcess) environmentVariables() []string {
+	cfg := config.Get().System
+	out := ip.Server.GetEnvironmentVariables()
+	if cfg.InstallUseHostProxy {
+		for _, k := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+			if v, ok := os.LookupEnv(k); ok {
+				out = append(out, k+"="+v)
+			}
+		}
+	}
+	for k, v := range cfg.InstallEnvironment {
+		out = append(out, k+"="+v)
+	}
+	return out
+}
+
 // Execute executes the installation process inside a specially created docker
 // container.
 func (ip *InstallationProcess) Execute() (string, error) {
@@ -405,7 +466,7 @@ func (ip *InstallationProcess) Execute() (string, error) {
 		Tty:          true,
 		Cmd:          []string{ip.Script.Entrypoint, "/mnt/install/install.sh"},
 		Image:        ip.Script.ContainerImage,
-		Env:          ip.Server.GetEnvironmentVariables(),
+		Env:          ip.environmentVariables(),
 		Labels: map[string]string{
 			"Service":       "Pterodactyl",
 			"ContainerType": "server_installer",
@@ -435,7 +496,7 @@ func (ip *InstallationProcess) Execute() (string, error) {
 		},
 		DNS:         cfg.Docker.Network.Dns,
 		LogConfig:   cfg.Docker.ContainerLogConfig(),
-		NetworkMode: container.NetworkMode(cfg.Docker.Network.Mode),
+		NetworkMode: container.NetworkMode(cfg.Docker.ContainerNetworkMode()),
 		UsernsMode:  container.UsernsMode(cfg.Docker.UsernsMode),
 	}
 
@@ -479,12 +540,25 @@ func (ip *InstallationProcess) Execute() (string, error) {
 		}
 	}(r.ID)
 
-	sChan, eChan := ip.client.ContainerWait(ctx, r.ID, container.WaitConditionNotRunning)
+	// If there is a timeout for the installation stop waiting once it is exceeded, the
+	// container is then removed by the caller.
+	wctx := ctx
+	if timeout := cfg.System.InstallTimeout; timeout > 0 {
+		var wcancel context.CancelFunc
+		wctx, wcancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer wcancel()
+	}
+
+	sChan, eChan := ip.client.ContainerWait(wctx, r.ID, container.WaitConditionNotRunning)
 	select {
 	case err := <-eChan:
 		// Once the container has stopped running we can mark the install process as being completed.
 		if err == nil {
 			ip.Server.Events().Publish(DaemonMessageEvent, "Installation process completed.")
+		} else if errors.Is(wctx.Err(), context.DeadlineExceeded) {
+			ip.Server.Log().WithField("timeout", cfg.System.InstallTimeout).Warn("installation process exceeded the install timeout, aborting")
+			ip.Server.Events().Publish(DaemonMessageEvent, "Installation process exceeded the maximum allowed time and was aborted.")
+			return "", errors.Errorf("install: installation process did not complete within %d seconds", cfg.System.InstallTimeout)
 		} else {
 			return "", err
 		}
@@ -505,7 +579,9 @@ func (ip *InstallationProcess) StreamOutput(ctx context.Context, id string) erro
 	}
 	defer reader.Close()
 
-	err = system.ScanReader(reader, ip.Server.Sink(system.InstallSink).Push)
+	err = system.ScanReader(reader, func(v []byte) {
+		ip.Server.Sink(system.InstallSink).Push(SanitizeConsoleOutput(v))
+	})
 	if err != nil && !errors.Is(err, context.Canceled) {
 		ip.Server.Log().WithFields(log.Fields{"container_id": id, "error": err}).Warn("error processing install output lines")
 	}
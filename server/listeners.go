@@ -10,6 +10,8 @@ import (
 	"github.com/apex/log"
 
 	"github.com/pterodactyl/wings/events"
+	"github.com/pterodactyl/wings/internal/logforward"
+	"github.com/pterodactyl/wings/internal/masking"
 	"github.com/pterodactyl/wings/system"
 
 	"github.com/pterodactyl/wings/environment"
@@ -59,11 +61,18 @@ func (dsl *diskSpaceLimiter) Trigger() {
 // output lines to determine if the server is started yet, and if the output is
 // not being throttled, will send the data over to the websocket.
 func (s *Server) processConsoleOutputEvent(v []byte) {
+	v = SanitizeConsoleOutput(v)
+
 	// Always process the console output, but do this in a seperate thread since we
 	// don't really care about side-effects from this call, and don't want it to block
 	// the console sending logic.
 	go s.onConsoleOutput(v)
 
+	if h := s.ConsoleHistory(); h != nil {
+		h.Push(v)
+	}
+	logforward.Forward(logforward.Entry{Stream: logforward.StreamConsole, Server: s.ID(), Message: masking.String(string(v))})
+
 	// If the console is being throttled, do nothing else with it, we don't want
 	// to waste time. This code previously terminated server instances after violating
 	// different throttle limits. That code was clunky and difficult to reason about,
@@ -151,6 +160,8 @@ func (s *Server) onConsoleOutput(data []byte) {
 		return
 	}
 
+	s.observeAutoStopOutput(data)
+
 	processConfiguration := s.ProcessConfiguration()
 
 	// Make a copy of the data provided since it is by reference, otherwise you'll
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +19,8 @@ import (
 	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
 	"github.com/pterodactyl/wings/environment/docker"
+	"github.com/pterodactyl/wings/internal/masking"
+	"github.com/pterodactyl/wings/internal/metrics"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/server/filesystem"
 )
@@ -26,6 +29,8 @@ type Manager struct {
 	mu      sync.RWMutex
 	client  remote.Client
 	servers []*Server
+
+	statesMu sync.Mutex
 }
 
 // NewManager returns a new server manager instance. This will boot up all the
@@ -135,7 +140,10 @@ func (m *Manager) Remove(filter func(match *Server) bool) {
 	for _, v := range m.servers {
 		if !filter(v) {
 			r = append(r, v)
+			continue
 		}
+		masking.Remove(v.ID())
+		metrics.RemoveServer(v.ID())
 	}
 	m.servers = r
 }
@@ -145,16 +153,26 @@ func (m *Manager) Remove(filter func(match *Server) bool) {
 // runner command to avoid hammering disk I/O when tons of server switch states
 // at once. It is fine if this file falls slightly out of sync, it is just here
 // to make recovering from an unexpected system reboot a little easier.
+//
+// If state persistence is enabled the desired state of each server is written
+// instead, see Server.DesiredState.
 func (m *Manager) PersistStates() error {
+	desired := config.Get().System.StatePersistence.Enabled
 	states := map[string]string{}
 	for _, s := range m.All() {
-		states[s.ID()] = s.Environment.State()
+		if desired {
+			states[s.ID()] = s.DesiredState()
+		} else {
+			states[s.ID()] = s.Environment.State()
+		}
 	}
 	data, err := json.Marshal(states)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	if err := os.WriteFile(config.Get().System.GetStatesPath(), data, 0o644); err != nil {
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+	if err := writeFileAtomic(config.Get().System.GetStatesPath(), data, 0o644); err != nil {
 		return errors.WithStack(err)
 	}
 	return nil
@@ -174,8 +192,13 @@ func (m *Manager) ReadStates() (map[string]string, error) {
 	out := make(map[string]string, 0)
 	// Only return states for servers that we're currently tracking in the system.
 	for id, state := range states {
-		if _, ok := m.Get(id); ok {
+		if s, ok := m.Get(id); ok {
 			out[id] = state
+			// Carry the desired state over until the server has been restored, so
+			// that it is not lost if the states are written before then.
+			if state == environment.ProcessRunningState || state == environment.ProcessStartingState {
+				s.desiredState.Store(environment.ProcessRunningState)
+			}
 		}
 	}
 	return out, nil
@@ -205,10 +228,21 @@ func (m *Manager) InitServer(data remote.ServerConfigurationResponse) (*Server,
 	// this logic in. When we're ready to support other environment we'll need to make
 	// some modifications here, obviously.
 	settings := environment.Settings{
-		Mounts:      s.Mounts(),
-		Allocations: s.cfg.Allocations,
-		Limits:      s.cfg.Build,
-		Labels:      s.cfg.Labels,
+		Mounts:          s.Mounts(),
+		Allocations:     s.cfg.Allocations,
+		Limits:          s.cfg.Build,
+		Labels:          s.cfg.containerLabels(),
+		NetworkMode:     s.cfg.NetworkMode,
+		StopGracePeriod: s.cfg.StopGracePeriod,
+		Tmpfs:           s.cfg.Tmpfs,
+		ReadonlyRootfs:  s.cfg.ReadonlyRootfs,
+		Init:            s.cfg.Init,
+		ShmSize:         s.cfg.ShmSize,
+		Privileged:      s.cfg.Container.Privileged,
+		NetworkEgress:   s.cfg.NetworkEgressLimit,
+		NetworkIngress:  s.cfg.NetworkIngressLimit,
+		IoLimits:        s.cfg.IoLimits,
+		GroupAdd:        s.cfg.GroupAdd,
 	}
 
 	envCfg := environment.NewConfiguration(settings, s.GetEnvironmentVariables())
@@ -246,10 +280,20 @@ func (m *Manager) init(ctx context.Context) error {
 	start := time.Now()
 	log.WithField("total_configs", len(servers)).Info("processing servers returned by the API")
 
+	conflicts := findDuplicateServers(ctx, servers)
+	strict := config.Get().System.StrictServerUuids
+	for uuid, entries := range conflicts {
+		log.WithField("server", uuid).WithField("conflicts", entries).WithField("strict", strict).Error("found multiple servers using the same uuid, these must be resolved manually")
+	}
+
 	pool := workerpool.New(runtime.NumCPU())
 	log.Debugf("using %d workerpools to instantiate server instances", runtime.NumCPU())
 	for _, data := range servers {
 		data := data
+		if _, ok := conflicts[strings.ToLower(data.Uuid)]; ok && strict {
+			log.WithField("server", data.Uuid).Error("refusing to load server with a duplicate uuid, skipping...")
+			continue
+		}
 		pool.Submit(func() {
 			// Parse the json.RawMessage into an expected struct value. We do this here so that a single broken
 			// server does not cause the entire boot process to hang, and allows us to show more useful error
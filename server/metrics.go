@@ -0,0 +1,57 @@
+package server
+
+import (
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/internal/metrics"
+)
+
+// CollectMetrics writes the capacity and utilization of the node, along with the
+// resource usage of each server on it, to the metrics writer.
+func (m *Manager) CollectMetrics(w *metrics.Writer) {
+	summary := m.NodeSummary()
+	mib := float64(1024 * 1024)
+
+	w.Gauge("wings_node_servers", "The number of servers on the node.", metrics.Sample{Value: float64(summary.Servers)})
+	w.Gauge("wings_node_running_servers", "The number of servers on the node that are running.", metrics.Sample{Value: float64(summary.RunningServers)})
+	w.Gauge("wings_node_suspended_servers", "The number of servers on the node that are suspended.", metrics.Sample{Value: float64(summary.SuspendedServers)})
+	w.Gauge("wings_node_committed_memory_bytes", "The memory committed to servers with a memory limit.", metrics.Sample{Value: float64(summary.CommittedMemory) * mib})
+	w.Gauge("wings_node_committed_disk_bytes", "The disk space committed to servers with a disk limit.", metrics.Sample{Value: float64(summary.CommittedDisk) * mib})
+	w.Gauge("wings_node_committed_cpu_percent", "The CPU committed to servers with a CPU limit, as a percentage of a single thread.", metrics.Sample{Value: float64(summary.CommittedCpu)})
+	w.Gauge("wings_node_disk_used_bytes", "The disk space used by all servers on the node.", metrics.Sample{Value: float64(summary.DiskUsedBytes)})
+	if summary.CapacityConfigured {
+		w.Gauge("wings_node_capacity_memory_bytes", "The configured memory capacity of the node.", metrics.Sample{Value: float64(summary.Capacity.Memory) * mib})
+		w.Gauge("wings_node_capacity_disk_bytes", "The configured disk capacity of the node.", metrics.Sample{Value: float64(summary.Capacity.Disk) * mib})
+		w.Gauge("wings_node_capacity_cpu_percent", "The configured CPU capacity of the node, as a percentage of a single thread.", metrics.Sample{Value: float64(summary.Capacity.Cpu)})
+	}
+
+	active, queued := environment.ImagePulls()
+	w.Gauge("wings_docker_image_pulls_active", "The number of Docker image pulls in progress.", metrics.Sample{Value: float64(active)})
+	w.Gauge("wings_docker_image_pulls_queued", "The number of Docker image pulls waiting for another pull to complete.", metrics.Sample{Value: float64(queued)})
+
+	active, queued = BackupRestores()
+	w.Gauge("wings_backup_restores_active", "The number of backup restorations in progress.", metrics.Sample{Value: float64(active)})
+	w.Gauge("wings_backup_restores_queued", "The number of backup restorations waiting for another restoration to complete.", metrics.Sample{Value: float64(queued)})
+
+	servers := m.All()
+	var memory, cpu, disk, rx, tx, running []metrics.Sample
+	for _, s := range servers {
+		p := s.Proc()
+		l := metrics.Labels{"server": s.ID()}
+		isRunning := 0.0
+		if p.State.Load() == environment.ProcessRunningState {
+			isRunning = 1
+		}
+		memory = append(memory, metrics.Sample{Labels: l, Value: float64(p.Memory)})
+		cpu = append(cpu, metrics.Sample{Labels: l, Value: p.CpuAbsolute})
+		disk = append(disk, metrics.Sample{Labels: l, Value: float64(p.Disk)})
+		rx = append(rx, metrics.Sample{Labels: l, Value: float64(p.Network.RxBytes)})
+		tx = append(tx, metrics.Sample{Labels: l, Value: float64(p.Network.TxBytes)})
+		running = append(running, metrics.Sample{Labels: l, Value: isRunning})
+	}
+	w.Gauge("wings_server_running", "Whether the server is running.", running...)
+	w.Gauge("wings_server_memory_bytes", "The memory used by the server.", memory...)
+	w.Gauge("wings_server_cpu_absolute", "The CPU used by the server, as a percentage of a single thread.", cpu...)
+	w.Gauge("wings_server_disk_bytes", "The disk space used by the server.", disk...)
+	w.Gauge("wings_server_network_rx_bytes", "The bytes received by the server's container.", rx...)
+	w.Gauge("wings_server_network_tx_bytes", "The bytes transmitted by the server's container.", tx...)
+}
@@ -59,9 +59,10 @@ func (s *Server) customMounts() []environment.Mount {
 
 			mounted = true
 			mounts = append(mounts, environment.Mount{
-				Source:   source,
-				Target:   target,
-				ReadOnly: m.ReadOnly,
+				Source:      source,
+				Target:      target,
+				ReadOnly:    m.ReadOnly,
+				Propagation: config.Get().MountPropagationFor(allowed),
 			})
 
 			break
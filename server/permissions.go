@@ -0,0 +1,89 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+var (
+	permissionFixOnce sync.Once
+	permissionFixSem  *semaphore.Weighted
+)
+
+// fixPermissions recursively sets the owner of all the files in the server's
+// root directory. The number of servers that can do this at the same time is
+// bounded by the configured concurrency, any additional servers wait for one
+// of the running fixes to complete.
+func (s *Server) fixPermissions() error {
+	permissionFixOnce.Do(func() {
+		permissionFixSem = semaphore.NewWeighted(int64(config.Get().System.PermissionFixConcurrency))
+	})
+	if err := permissionFixSem.Acquire(s.Context(), 1); err != nil {
+		return err
+	}
+	defer permissionFixSem.Release(1)
+	return s.fs.Chown("/")
+}
+
+// checkOwnership makes sure that the server's root directory is owned by the
+// system user before the server is started, since the server process is unable
+// to write to its files otherwise. Depending on the configuration the ownership
+// is either fixed or an error is returned.
+func (s *Server) checkOwnership() error {
+	p, err := s.fs.MisownedPath()
+	if err != nil {
+		return errors.WithMessage(err, "failed to check ownership of server root directory")
+	}
+	if p == "" {
+		return nil
+	}
+
+	u := config.Get().System.User
+	if !config.Get().System.FixOwnershipOnStart {
+		return errors.Errorf("server root directory is not owned by the system user (%d:%d), %s has a different owner: fix the ownership of the files or enable system.fix_ownership_on_start", u.Uid, u.Gid, p)
+	}
+	s.Log().WithField("path", p).WithField("uid", u.Uid).WithField("gid", u.Gid).Warn("server root directory is not owned by the system user, fixing ownership before starting")
+	s.PublishConsoleOutputFromDaemon("正在修复服务器文件的所有权，这可能需要几秒钟...")
+	if err := s.fixPermissions(); err != nil {
+		return errors.WithMessage(err, "failed to fix ownership of server root directory")
+	}
+	s.Log().Info("fixed ownership of server root directory")
+	return nil
+}
+
+// NormalizeOwnership changes the owner of any server files that were written by the
+// server process as another user back to the system user. If the Docker daemon is
+// remapping user namespaces the configured offset is added to the uid and gid. This
+// shares the concurrency limit used when fixing permissions.
+func (s *Server) NormalizeOwnership() error {
+	cfg := config.Get().System
+	uid, gid := cfg.User.Uid, cfg.User.Gid
+	if off := cfg.OwnershipNormalization.UsernsOffset; off > 0 && environment.UsernsRemapped() {
+		uid += off
+		gid += off
+	}
+
+	permissionFixOnce.Do(func() {
+		permissionFixSem = semaphore.NewWeighted(int64(config.Get().System.PermissionFixConcurrency))
+	})
+	if err := permissionFixSem.Acquire(s.Context(), 1); err != nil {
+		return err
+	}
+	defer permissionFixSem.Release(1)
+
+	start := time.Now()
+	n, err := s.fs.NormalizeOwnership(uid, gid)
+	if err != nil {
+		return errors.WithMessage(err, "failed to normalize ownership of server files")
+	}
+	if n > 0 {
+		s.Log().WithField("files", n).WithField("uid", uid).WithField("gid", gid).WithField("duration", time.Since(start).Round(time.Millisecond)).Info("normalized ownership of server files")
+	}
+	return nil
+}
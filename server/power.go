@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"emperror.dev/errors"
+	"github.com/docker/docker/client"
 	"github.com/google/uuid"
 
 	"github.com/pterodactyl/wings/config"
@@ -169,6 +170,10 @@ func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error
 // Execute a few functions before actually calling the environment start commands. This ensures
 // that everything is ready to go for environment booting, and that the server can even be started.
 func (s *Server) onBeforeStart() error {
+	// Stop listening for connections to wake the server, so that its ports are free to
+	// be bound by the container.
+	s.closeWakeListener()
+
 	s.Log().Info("syncing server configuration with panel")
 	if err := s.Sync(); err != nil {
 		return errors.WithMessage(err, "unable to sync server data from Panel instance")
@@ -180,10 +185,36 @@ func (s *Server) onBeforeStart() error {
 		return ErrSuspended
 	}
 
+	if err := s.checkEnvironmentPolicy(); err != nil {
+		return err
+	}
+
+	if err := s.checkResourceBounds(); err != nil {
+		s.PublishConsoleOutputFromDaemon(err.Error())
+		return err
+	}
+
+	// Clear the console output of the previous run, in the same way that the logs of
+	// the container are truncated when it is started.
+	if h := s.ConsoleHistory(); h != nil {
+		h.Reset()
+	}
+
+	if config.Get().System.ValidateStartupVariables {
+		if err := s.validateStartupVariables(); err != nil {
+			s.PublishConsoleOutputFromDaemon(err.Error())
+			return err
+		}
+	}
+
 	// Ensure we sync the server information with the environment so that any new environment variables
 	// and process resource limits are correctly applied.
 	s.SyncWithEnvironment()
 
+	if s.outdatedImage.SwapIf(false) {
+		s.Log().WithField("image", s.Config().Container.Image).Info("re-creating server container using updated image")
+	}
+
 	// If a server has unlimited disk space, we don't care enough to block the startup to check remaining.
 	// However, we should trigger a size anyway, as it'd be good to kick it off for other processes.
 	if s.DiskSpace() <= 0 {
@@ -205,15 +236,51 @@ func (s *Server) onBeforeStart() error {
 	s.UpdateConfigurationFiles()
 	s.Log().Debug("updated server configuration files")
 
+	if config.Get().System.CleanStaleLocksOnStart {
+		s.removeStaleLocks()
+	}
+
+	if config.Get().System.WriteEnvFile {
+		s.Log().Debug("writing server environment file...")
+		if err := s.writeEnvFile(); err != nil {
+			return err
+		}
+	}
+
 	if config.Get().System.CheckPermissionsOnBoot {
 		s.PublishConsoleOutputFromDaemon("确保文件权限设置正确，这可能需要几秒钟...")
 		// Ensure all the server file permissions are set correctly before booting the process.
 		s.Log().Debug("chowning server root directory...")
-		if err := s.Filesystem().Chown("/"); err != nil {
+		if err := s.fixPermissions(); err != nil {
 			return errors.WithMessage(err, "failed to chown root server directory during pre-boot process")
 		}
+	} else if err := s.checkOwnership(); err != nil {
+		return err
 	}
 
 	s.Log().Info("已完成服务器预检，开始启动进程...")
 	return nil
 }
+
+// removeStaleLocks removes the lock files left behind in the data directory of the
+// server by a previous run that crashed. Nothing is removed if the container of the
+// server is still running, since the locks are held by that process. Failures are
+// only logged so that they do not prevent the server from starting.
+func (s *Server) removeStaleLocks() {
+	ctx, cancel := context.WithTimeout(s.Context(), time.Second*10)
+	defer cancel()
+	if running, err := s.Environment.IsRunning(ctx); err != nil && !client.IsErrNotFound(err) {
+		s.Log().WithField("error", err).Warn("failed to check if server container is running, not removing stale lock files")
+		return
+	} else if running {
+		return
+	}
+
+	removed, err := s.Filesystem().RemoveStaleLocks(config.Get().System.StaleLocks.PatternsFor(s.Config().Egg.ID))
+	for _, p := range removed {
+		s.Log().WithField("path", p).Info("removed stale lock file from server data directory")
+	}
+	if err != nil {
+		s.Log().WithField("error", err).Warn("failed to remove stale lock files from server data directory")
+	}
+}
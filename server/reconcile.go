@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/docker/docker/client"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+// stateRepair is the repair made to a server whose recorded state does not match
+// the state of its container.
+type stateRepair string
+
+const (
+	stateRepairNone        stateRepair = ""
+	stateRepairMarkRunning stateRepair = "mark_running"
+	stateRepairMarkOffline stateRepair = "mark_offline"
+	stateRepairStart       stateRepair = "start"
+	stateRepairStop        stateRepair = "stop"
+)
+
+// reconcileRepair returns the repair to make to a server with the recorded state
+// provided, given whether its container is running. Servers that are starting or
+// stopping are in the middle of a transition and are never repaired.
+func reconcileRepair(policy string, recorded string, running bool) stateRepair {
+	trustState := policy == config.StateReconciliationTrustState
+	switch recorded {
+	case environment.ProcessRunningState:
+		if running {
+			return stateRepairNone
+		}
+		if trustState {
+			return stateRepairStart
+		}
+		return stateRepairMarkOffline
+	case environment.ProcessOfflineState:
+		if !running {
+			return stateRepairNone
+		}
+		if trustState {
+			return stateRepairStop
+		}
+		return stateRepairMarkRunning
+	}
+	return stateRepairNone
+}
+
+// Reconcile compares the state recorded for the server with the state of its
+// container and repairs any discrepancy according to the policy provided. Servers
+// that are being installed, transferred or restored, or that are executing a
+// power action, are left alone.
+func (s *Server) Reconcile(ctx context.Context, policy string) error {
+	if s.IsInstalling() || s.IsTransferring() || s.IsRestoring() || s.ExecutingPowerAction() {
+		return nil
+	}
+	if policy == config.StateReconciliationTrustPanel {
+		if err := s.Sync(); err != nil {
+			return errors.WrapIf(err, "server: failed to sync configuration with the Panel")
+		}
+	}
+
+	recorded := s.Environment.State()
+	running, err := s.Environment.IsRunning(ctx)
+	if err != nil && !client.IsErrNotFound(err) {
+		return errors.WrapIf(err, "server: failed to check the state of the container")
+	}
+
+	repair := reconcileRepair(policy, recorded, running)
+	if policy == config.StateReconciliationTrustPanel && running && s.IsSuspended() {
+		repair = stateRepairStop
+	}
+	if repair == stateRepairNone {
+		return nil
+	}
+
+	s.Log().WithFields(log.Fields{
+		"policy":            policy,
+		"recorded_state":    recorded,
+		"container_running": running,
+		"repair":            string(repair),
+	}).Warn("repairing server whose recorded state does not match its container")
+
+	switch repair {
+	case stateRepairMarkRunning:
+		s.Environment.SetState(environment.ProcessRunningState)
+		return s.Environment.Attach(ctx)
+	case stateRepairMarkOffline:
+		s.Environment.SetState(environment.ProcessOfflineState)
+	case stateRepairStart:
+		return s.HandlePowerActionFrom(PowerTriggerReconcile, PowerActionStart)
+	case stateRepairStop:
+		return s.HandlePowerActionFrom(PowerTriggerReconcile, PowerActionStop)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+func TestReconcileRepair(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("reconcileRepair", func() {
+		g.It("does nothing when the recorded state matches the container", func() {
+			for _, p := range []string{config.StateReconciliationTrustContainer, config.StateReconciliationTrustState} {
+				g.Assert(reconcileRepair(p, environment.ProcessRunningState, true)).Equal(stateRepairNone)
+				g.Assert(reconcileRepair(p, environment.ProcessOfflineState, false)).Equal(stateRepairNone)
+			}
+		})
+
+		g.It("updates the recorded state when trusting the container", func() {
+			g.Assert(reconcileRepair(config.StateReconciliationTrustContainer, environment.ProcessOfflineState, true)).Equal(stateRepairMarkRunning)
+			g.Assert(reconcileRepair(config.StateReconciliationTrustContainer, environment.ProcessRunningState, false)).Equal(stateRepairMarkOffline)
+			g.Assert(reconcileRepair(config.StateReconciliationTrustPanel, environment.ProcessOfflineState, true)).Equal(stateRepairMarkRunning)
+		})
+
+		g.It("starts or stops the container when trusting the recorded state", func() {
+			g.Assert(reconcileRepair(config.StateReconciliationTrustState, environment.ProcessOfflineState, true)).Equal(stateRepairStop)
+			g.Assert(reconcileRepair(config.StateReconciliationTrustState, environment.ProcessRunningState, false)).Equal(stateRepairStart)
+		})
+
+		g.It("leaves servers that are starting or stopping alone", func() {
+			g.Assert(reconcileRepair(config.StateReconciliationTrustState, environment.ProcessStartingState, false)).Equal(stateRepairNone)
+			g.Assert(reconcileRepair(config.StateReconciliationTrustContainer, environment.ProcessStoppingState, true)).Equal(stateRepairNone)
+		})
+	})
+}
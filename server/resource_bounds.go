@@ -0,0 +1,57 @@
+package server
+
+import (
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+// resourceBoundViolation is a resource assigned by the Panel that is outside the
+// bounds configured for this node.
+type resourceBoundViolation struct {
+	Resource string
+	Assigned int64
+	Bounded  int64
+}
+
+// applyResourceBounds returns the limits moved into the given bounds, along with
+// every resource that had to be changed to do so. Unlimited assignments are
+// replaced with the maximum of the bound when one is set.
+func applyResourceBounds(b config.ResourceBounds, l environment.Limits) (environment.Limits, []resourceBoundViolation) {
+	var violations []resourceBoundViolation
+	apply := func(name string, rb config.ResourceBound, v *int64, unlimited bool) {
+		bounded := *v
+		if unlimited {
+			if rb.Max > 0 {
+				bounded = rb.Max
+			}
+		} else {
+			bounded = rb.Clamp(bounded)
+		}
+		if bounded != *v {
+			violations = append(violations, resourceBoundViolation{Resource: name, Assigned: *v, Bounded: bounded})
+			*v = bounded
+		}
+	}
+	apply("memory", b.Memory, &l.MemoryLimit, l.MemoryLimit <= 0)
+	apply("disk", b.Disk, &l.DiskSpace, l.DiskSpace <= 0)
+	apply("cpu", b.Cpu, &l.CpuLimit, l.CpuLimit <= 0)
+	apply("swap", b.Swap, &l.Swap, l.Swap < 0)
+	return l, violations
+}
+
+// checkResourceBounds returns an error if the resources assigned to the server
+// are outside the bounds of this node and the bounds are configured to reject
+// such assignments.
+func (s *Server) checkResourceBounds() error {
+	b := config.Get().System.ResourceBounds
+	if b.Mode != config.ResourceBoundsReject {
+		return nil
+	}
+	if _, violations := applyResourceBounds(b, s.Config().Build); len(violations) > 0 {
+		v := violations[0]
+		return errors.WithMessagef(ErrResourceOutOfBounds, "%s assignment of %d is outside the bounds of this node", v.Resource, v.Assigned)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+func TestApplyResourceBounds(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("applyResourceBounds", func() {
+		g.It("does not change limits when no bounds are set", func() {
+			l := environment.Limits{MemoryLimit: 1 << 20, DiskSpace: 0, CpuLimit: 400, Swap: -1}
+			out, v := applyResourceBounds(config.ResourceBounds{}, l)
+			g.Assert(out).Equal(l)
+			g.Assert(len(v)).Equal(0)
+		})
+
+		g.It("clamps values into the bounds", func() {
+			b := config.ResourceBounds{
+				Memory: config.ResourceBound{Min: 128, Max: 8192},
+				Cpu:    config.ResourceBound{Min: 50, Max: 400},
+			}
+			out, v := applyResourceBounds(b, environment.Limits{MemoryLimit: 1 << 20, CpuLimit: 10})
+			g.Assert(out.MemoryLimit).Equal(int64(8192))
+			g.Assert(out.CpuLimit).Equal(int64(50))
+			g.Assert(len(v)).Equal(2)
+			g.Assert(v[0].Resource).Equal("memory")
+			g.Assert(v[0].Assigned).Equal(int64(1 << 20))
+		})
+
+		g.It("replaces unlimited values with the maximum", func() {
+			b := config.ResourceBounds{
+				Disk: config.ResourceBound{Max: 10240},
+				Swap: config.ResourceBound{Min: 0, Max: 512},
+			}
+			out, v := applyResourceBounds(b, environment.Limits{DiskSpace: 0, Swap: -1})
+			g.Assert(out.DiskSpace).Equal(int64(10240))
+			g.Assert(out.Swap).Equal(int64(512))
+			g.Assert(len(v)).Equal(2)
+		})
+
+		g.It("does not raise unlimited values to the minimum", func() {
+			b := config.ResourceBounds{Memory: config.ResourceBound{Min: 128}}
+			out, v := applyResourceBounds(b, environment.Limits{MemoryLimit: 0})
+			g.Assert(out.MemoryLimit).Equal(int64(0))
+			g.Assert(len(v)).Equal(0)
+		})
+	})
+}
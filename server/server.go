@@ -16,6 +16,7 @@ import (
 	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
 	"github.com/pterodactyl/wings/events"
+	"github.com/pterodactyl/wings/internal/masking"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/server/filesystem"
 	"github.com/pterodactyl/wings/system"
@@ -62,10 +63,18 @@ type Server struct {
 	transferring *system.AtomicBool
 	restoring    *system.AtomicBool
 
+	// Tracks if the server container is running an outdated image and should be
+	// re-created with the currently assigned image on the next start.
+	outdatedImage *system.AtomicBool
+
 	// The console throttler instance used to control outputs.
 	throttler    *ConsoleThrottle
 	throttleOnce sync.Once
 
+	// Retains the recent console output of the server, if enabled.
+	consoleHistory     *system.RingBuffer
+	consoleHistoryOnce sync.Once
+
 	// Tracks open websocket connections for the server.
 	wsBag       *WebsocketBag
 	wsBagLocker sync.Mutex
@@ -74,6 +83,15 @@ type Server struct {
 
 	logSink     *system.SinkPool
 	installSink *system.SinkPool
+
+	// Tracks the activity of the server for stopping it automatically when idle.
+	autoStop autoStopState
+
+	// The state the server should be in when Wings boots.
+	desiredState system.AtomicString
+
+	// Tracks the changes in the power state of the server for the state transition log.
+	transitions stateTransitions
 }
 
 // New returns a new server instance with a context and all of the default
@@ -88,6 +106,8 @@ func New(client remote.Client) (*Server, error) {
 		transferring: system.NewAtomicBool(false),
 		restoring:    system.NewAtomicBool(false),
 		powerLock:    system.NewLocker(),
+
+		outdatedImage: system.NewAtomicBool(false),
 		sinks: map[system.SinkName]*system.SinkPool{
 			system.LogSink:     system.NewSinkPool(),
 			system.InstallSink: system.NewSinkPool(),
@@ -155,6 +175,7 @@ func (s *Server) GetEnvironmentVariables() []string {
 		fmt.Sprintf("SERVER_PORT=%d", s.Config().Allocations.DefaultMapping.Port),
 	}
 
+	policy := config.Get().System.PanelEnvironmentPolicy
 eloop:
 	for k := range s.Config().EnvVars {
 		// Don't allow any environment variables that we have already set above.
@@ -164,12 +185,33 @@ eloop:
 			}
 		}
 
-		out = append(out, fmt.Sprintf("%s=%s", strings.ToUpper(k), s.Config().EnvVars.Get(k)))
+		// Variables violating the policy are logged when the configuration is synced.
+		v := s.Config().EnvVars.Get(k)
+		if err := policy.Check(k, v); err != nil {
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s=%s", strings.ToUpper(k), v))
 	}
 
 	return out
 }
 
+// checkEnvironmentPolicy returns an error if any of the environment variables
+// sent by the Panel violate the environment policy and the policy is configured
+// to reject them.
+func (s *Server) checkEnvironmentPolicy() error {
+	policy := config.Get().System.PanelEnvironmentPolicy
+	if policy.Action != config.EnvironmentPolicyReject {
+		return nil
+	}
+	for k := range s.Config().EnvVars {
+		if err := policy.Check(k, s.Config().EnvVars.Get(k)); err != nil {
+			return errors.WithMessage(err, "server: environment sent by the Panel violates the environment policy")
+		}
+	}
+	return nil
+}
+
 func (s *Server) Log() *log.Entry {
 	return log.WithField("server", s.ID())
 }
@@ -216,6 +258,35 @@ func (s *Server) SyncWithConfiguration(cfg remote.ServerConfigurationResponse) e
 		return errors.WithStackIf(err)
 	}
 
+	// Check the identifiers of the server before any of them are used in paths on the
+	// host or applied to the container as labels.
+	if err := checkIdentifiers(&c, config.Get().System.ServerIdentifiers); err != nil {
+		return err
+	}
+
+	// Move the resources assigned by the Panel into the bounds of this node. When the
+	// bounds are configured to reject assignments instead, the server is refused when
+	// it is started.
+	if bounds := config.Get().System.ResourceBounds; bounds.Mode == config.ResourceBoundsClamp {
+		var violations []resourceBoundViolation
+		c.Build, violations = applyResourceBounds(bounds, c.Build)
+		for _, v := range violations {
+			log.WithFields(log.Fields{"server": c.Uuid, "resource": v.Resource, "assigned": v.Assigned, "clamped": v.Bounded}).
+				Warn("clamped resource assignment from the Panel to the bounds of this node")
+		}
+	}
+
+	policy := config.Get().System.PanelEnvironmentPolicy
+	for k := range c.EnvVars {
+		if err := policy.Check(k, c.EnvVars.Get(k)); err != nil {
+			log.WithField("server", c.Uuid).WithField("error", err).Warn("removing environment variable sent by the Panel that violates the environment policy")
+		}
+	}
+
+	// Register the values of secret environment variables so they are masked in the
+	// daemon logs and in forwarded console output.
+	masking.Set(c.Uuid, secretValues(config.Get().System.SecretMasking, c.EnvVars))
+
 	s.cfg.mu.Lock()
 	defer s.cfg.mu.Unlock()
 
@@ -235,6 +306,20 @@ func (s *Server) SyncWithConfiguration(cfg remote.ServerConfigurationResponse) e
 	return nil
 }
 
+// secretValues returns the values of the environment variables whose names match
+// the secret masking patterns.
+func secretValues(m config.SecretMasking, vars environment.Variables) []string {
+	var out []string
+	for k := range vars {
+		if m.Matches(k) {
+			if v := vars.Get(k); v != "" {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
 // Reads the log file for a server up to a specified number of bytes.
 func (s *Server) ReadLogfile(len int) ([]string, error) {
 	return s.Environment.Readlog(len)
@@ -277,7 +362,7 @@ func (s *Server) EnsureDataDirectoryExists() error {
 			if err := os.MkdirAll(s.fs.Path(), 0o700); err != nil {
 				return errors.WithStack(err)
 			}
-			if err := s.fs.Chown("/"); err != nil {
+			if err := s.fixPermissions(); err != nil {
 				s.Log().WithField("error", err).Warn("server: failed to chown server data directory")
 			}
 		} else {
@@ -296,9 +381,12 @@ func (s *Server) OnStateChange() {
 	// Update the currently tracked state for the server.
 	s.resources.State.Store(st)
 
+	s.updateDesiredState(prevState, st)
+
 	// Emit the event to any listeners that are currently registered.
 	if prevState != s.Environment.State() {
 		s.Log().WithField("status", st).Debug("saw server status change event")
+		s.logStateTransition(prevState, st)
 		s.Events().Publish(StatusEvent, st)
 	}
 
@@ -309,6 +397,16 @@ func (s *Server) OnStateChange() {
 		s.Events().Publish(StatsEvent, s.Proc())
 	}
 
+	// Once the server process has stopped, change the ownership of any files it wrote
+	// as another user back to the system user.
+	if st == environment.ProcessOfflineState && prevState != st && config.Get().System.OwnershipNormalization.PostStop() {
+		go func(server *Server) {
+			if err := server.NormalizeOwnership(); err != nil {
+				server.Log().WithField("error", err).Warn("failed to normalize ownership of server files after stopping")
+			}
+		}(s)
+	}
+
 	// If server was in an online state, and is now in an offline state we should handle
 	// that as a crash event. In that scenario, check the last crash time, and the crash
 	// counter.
@@ -346,10 +444,46 @@ func (s *Server) IsRunning() bool {
 // instance on Wings. This includes the information needed by the Panel in order
 // to show resource utilization and the current state on this system.
 type APIResponse struct {
-	State         string        `json:"state"`
-	IsSuspended   bool          `json:"is_suspended"`
-	Utilization   ResourceUsage `json:"utilization"`
-	Configuration Configuration `json:"configuration"`
+	State         string                `json:"state"`
+	IsSuspended   bool                  `json:"is_suspended"`
+	TooManyFiles  bool                  `json:"too_many_files"`
+	Utilization   ResourceUsage         `json:"utilization"`
+	Configuration Configuration         `json:"configuration"`
+	Effective     EffectiveServerConfig `json:"effective"`
+}
+
+// EffectiveServerConfig is the configuration that is applied to the container of
+// a server once the resources assigned by the Panel are mapped using the node
+// configuration.
+type EffectiveServerConfig struct {
+	CpuLimitMode string `json:"cpu_limit_mode"`
+	// CpuQuota is the CPU time in microseconds that the container may use in each
+	// CpuPeriod, or 0 if the CPU is not capped.
+	CpuQuota  int64 `json:"cpu_quota"`
+	CpuPeriod int64 `json:"cpu_period"`
+	CpuShares int64 `json:"cpu_shares"`
+	// MemoryLimit and MemorySwap are in bytes.
+	MemoryLimit int64 `json:"memory_limit"`
+	MemorySwap  int64 `json:"memory_swap"`
+	// MemorySwappiness is nil if the default of the host is used.
+	MemorySwappiness *int64 `json:"memory_swappiness"`
+	IoWeight         uint16 `json:"io_weight"`
+}
+
+// EffectiveServerConfig returns the configuration that is applied to the container
+// of the server.
+func (s *Server) EffectiveServerConfig() EffectiveServerConfig {
+	r := s.Config().Build.AsContainerResources()
+	return EffectiveServerConfig{
+		CpuLimitMode:     config.Get().System.CpuLimitMode,
+		CpuQuota:         r.CPUQuota,
+		CpuPeriod:        r.CPUPeriod,
+		CpuShares:        r.CPUShares,
+		MemoryLimit:      r.Memory,
+		MemorySwap:       r.MemorySwap,
+		MemorySwappiness: r.MemorySwappiness,
+		IoWeight:         r.BlkioWeight,
+	}
 }
 
 // ToAPIResponse returns the server struct as an API object that can be consumed
@@ -358,7 +492,9 @@ func (s *Server) ToAPIResponse() APIResponse {
 	return APIResponse{
 		State:         s.Environment.State(),
 		IsSuspended:   s.IsSuspended(),
+		TooManyFiles:  s.Filesystem().TooManyFiles(),
 		Utilization:   s.Proc(),
 		Configuration: *s.Config(),
+		Effective:     s.EffectiveServerConfig(),
 	}
 }
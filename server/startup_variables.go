@@ -0,0 +1,207 @@
+package server
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// EggVariable is a variable defined by the egg of a server, along with the
+// validation rules the Panel applies to its value.
+type EggVariable struct {
+	Name        string `json:"name"`
+	EnvVariable string `json:"env_variable"`
+
+	// Rules are the validation rules for the variable in the format used by the
+	// Panel, such as "required|string|max:20".
+	Rules string `json:"rules"`
+}
+
+// StartupVariablesError is returned when a server cannot be started because the
+// values of the variables of its egg do not pass their validation rules.
+type StartupVariablesError struct {
+	// Missing are the names of required variables that do not have a value.
+	Missing []string
+	// Invalid describes each of the variables with a value that is not valid.
+	Invalid []string
+}
+
+func (e *StartupVariablesError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing required variables: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "invalid variables: "+strings.Join(e.Invalid, "; "))
+	}
+	return "server: cannot start with " + strings.Join(parts, ", ")
+}
+
+// validateStartupVariables checks the environment variables of the server
+// against the validation rules of the variables of its egg, returning a
+// StartupVariablesError listing every variable that is missing or invalid.
+func (s *Server) validateStartupVariables() error {
+	cfg := s.Config()
+	verr := &StartupVariablesError{}
+	for _, v := range cfg.Egg.Variables {
+		name := v.EnvVariable
+		if v.Name != "" {
+			name = v.Name + " (" + v.EnvVariable + ")"
+		}
+		var val string
+		switch value := cfg.EnvVars[v.EnvVariable].(type) {
+		case nil:
+		case float64:
+			// Numbers in the JSON from the Panel are decoded as floats, which should
+			// not be formatted with a fractional part when they are whole numbers.
+			val = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			val = cfg.EnvVars.Get(v.EnvVariable)
+		}
+		missing, err := checkVariableRules(v.Rules, val)
+		if missing {
+			verr.Missing = append(verr.Missing, name)
+		} else if err != nil {
+			verr.Invalid = append(verr.Invalid, name+" "+err.Error())
+		}
+	}
+	if len(verr.Missing) > 0 || len(verr.Invalid) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// checkVariableRules checks a value against validation rules in the format used
+// by the Panel. True is returned if the value is required but empty, otherwise
+// an error is returned describing the first rule the value does not pass. Rules
+// that are not understood are ignored, leaving them to the Panel.
+func checkVariableRules(rules, value string) (bool, error) {
+	list := splitRules(rules)
+
+	numeric := false
+	for _, r := range list {
+		if r == "integer" || r == "numeric" {
+			numeric = true
+		}
+	}
+	for _, r := range list {
+		if r == "required" && value == "" {
+			return true, nil
+		}
+	}
+	// Rules other than required are only applied to variables that have a value.
+	if value == "" {
+		return false, nil
+	}
+
+	for _, r := range list {
+		name, arg, _ := strings.Cut(strings.TrimSpace(r), ":")
+		switch name {
+		case "integer":
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				return false, errors.New("must be an integer")
+			}
+		case "numeric":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return false, errors.New("must be a number")
+			}
+		case "boolean":
+			switch value {
+			case "0", "1", "true", "false":
+			default:
+				return false, errors.New("must be true or false")
+			}
+		case "min", "max", "between":
+			bounds := strings.Split(arg, ",")
+			if name == "between" && len(bounds) != 2 {
+				continue
+			}
+			size := float64(len([]rune(value)))
+			if numeric {
+				size, _ = strconv.ParseFloat(value, 64)
+			}
+			for i, b := range bounds {
+				limit, err := strconv.ParseFloat(b, 64)
+				if err != nil {
+					continue
+				}
+				if (name == "min" || (name == "between" && i == 0)) && size < limit {
+					return false, errors.Errorf("must be at least %s", b)
+				}
+				if (name == "max" || (name == "between" && i == 1)) && size > limit {
+					return false, errors.Errorf("must be at most %s", b)
+				}
+			}
+		case "in":
+			if !contains(strings.Split(arg, ","), value) {
+				return false, errors.Errorf("must be one of %s", arg)
+			}
+		case "regex", "not_regex":
+			// Patterns are written as in PHP, such as "/^[a-z]+$/i".
+			pattern := arg
+			if len(pattern) > 1 && pattern[0] == '/' {
+				if end := strings.LastIndex(pattern, "/"); end > 0 {
+					flags := pattern[end+1:]
+					pattern = pattern[1:end]
+					if strings.Contains(flags, "i") {
+						pattern = "(?i)" + pattern
+					}
+				}
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				continue
+			}
+			if matched := re.MatchString(value); name == "regex" && !matched {
+				return false, errors.New("does not match the required format")
+			} else if name == "not_regex" && matched {
+				return false, errors.New("matches a format that is not allowed")
+			}
+		}
+	}
+	return false, nil
+}
+
+// splitRules splits validation rules on "|". The pattern of a regex or not_regex
+// rule may itself contain a "|", so the parts following one of those rules are
+// joined back onto it until its pattern is closed by its delimiter.
+func splitRules(rules string) []string {
+	parts := strings.Split(rules, "|")
+	list := make([]string, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		r := parts[i]
+		name, arg, _ := strings.Cut(strings.TrimSpace(r), ":")
+		if (name == "regex" || name == "not_regex") && len(arg) > 0 {
+			for !regexClosed(arg) && i+1 < len(parts) {
+				i++
+				r += "|" + parts[i]
+				arg += "|" + parts[i]
+			}
+		}
+		list = append(list, r)
+	}
+	return list
+}
+
+// regexClosed returns true if the PHP style pattern provided ends with the same
+// delimiter it starts with, optionally followed by flags.
+func regexClosed(pattern string) bool {
+	if len(pattern) < 2 {
+		return false
+	}
+	end := strings.TrimRightFunc(pattern, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	})
+	return len(end) > 1 && end[len(end)-1] == pattern[0]
+}
+
+func contains(list []string, v string) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
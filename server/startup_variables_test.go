@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func TestCheckVariableRules(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("checkVariableRules", func() {
+		g.It("reports required variables without a value as missing", func() {
+			missing, err := checkVariableRules("required|string|max:20", "")
+			g.Assert(missing).IsTrue()
+			g.Assert(err).IsNil()
+		})
+
+		g.It("skips the other rules for optional variables without a value", func() {
+			missing, err := checkVariableRules("nullable|integer|min:1", "")
+			g.Assert(missing).IsFalse()
+			g.Assert(err).IsNil()
+		})
+
+		g.It("checks the length of strings and the value of numbers", func() {
+			_, err := checkVariableRules("required|string|max:5", "abcdef")
+			g.Assert(err == nil).IsFalse()
+
+			_, err = checkVariableRules("required|integer|between:1,100", "50")
+			g.Assert(err).IsNil()
+
+			_, err = checkVariableRules("required|integer|between:1,100", "500")
+			g.Assert(err == nil).IsFalse()
+
+			_, err = checkVariableRules("required|integer", "1.5")
+			g.Assert(err == nil).IsFalse()
+		})
+
+		g.It("checks in and regex rules", func() {
+			_, err := checkVariableRules("required|in:survival,creative", "creative")
+			g.Assert(err).IsNil()
+
+			_, err = checkVariableRules("required|in:survival,creative", "hardcore")
+			g.Assert(err == nil).IsFalse()
+
+			_, err = checkVariableRules("required|regex:/^(latest|[0-9.]+)$/", "1.20.4")
+			g.Assert(err).IsNil()
+
+			_, err = checkVariableRules("required|regex:/^(latest|[0-9.]+)$/", "newest")
+			g.Assert(err == nil).IsFalse()
+
+			_, err = checkVariableRules("regex:/^(latest|[0-9.]+)$/|max:10", "latest")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("checks not_regex rules", func() {
+			_, err := checkVariableRules("required|string|not_regex:/^(root|admin)$/i", "steve")
+			g.Assert(err).IsNil()
+
+			_, err = checkVariableRules("required|string|not_regex:/^(root|admin)$/i", "Admin")
+			g.Assert(err == nil).IsFalse()
+
+			_, err = checkVariableRules("not_regex:/[^a-z]/|max:20", "valid")
+			g.Assert(err).IsNil()
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/environment"
+)
+
+// stateChanged is notified each time the desired state of any server changes.
+var stateChanged = make(chan struct{}, 1)
+
+// DesiredState returns the state the server should be in, which is the state it
+// is restored to when Wings boots. This differs from the current state of the
+// server if it stopped without being stopped through Wings, such as when it
+// crashed or the host is shutting down, in which case it should be running.
+func (s *Server) DesiredState() string {
+	if st := s.desiredState.Load(); st != "" {
+		return st
+	}
+	return environment.ProcessOfflineState
+}
+
+// updateDesiredState updates the desired state of the server after it changes
+// from the previous state to the current one.
+func (s *Server) updateDesiredState(prev, st string) {
+	desired := s.desiredState.Load()
+	switch st {
+	case environment.ProcessStartingState, environment.ProcessRunningState:
+		desired = environment.ProcessRunningState
+	case environment.ProcessStoppingState:
+		desired = environment.ProcessOfflineState
+	case environment.ProcessOfflineState:
+		// A server that goes offline without stopping first was not stopped by
+		// Wings, so it should still be running.
+		if prev != environment.ProcessStartingState && prev != environment.ProcessRunningState {
+			desired = environment.ProcessOfflineState
+		}
+	}
+	if desired == s.desiredState.Load() {
+		return
+	}
+	s.desiredState.Store(desired)
+	select {
+	case stateChanged <- struct{}{}:
+	default:
+	}
+}
+
+// PersistStatesOnChange writes the states of the servers to the disk each time
+// the desired state of a server changes, until the context is canceled. Changes
+// that happen while the states are being written are coalesced into a single
+// write.
+func (m *Manager) PersistStatesOnChange(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stateChanged:
+			if err := m.PersistStates(); err != nil {
+				log.WithField("error", err).Warn("failed to persist server states to disk")
+			}
+		}
+	}
+}
+
+// writeFileAtomic writes data to the file at the path provided by writing it to
+// a temporary file in the same directory and renaming it over the file, so that
+// the file is never left partially written.
+func writeFileAtomic(p string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+// PowerTrigger identifies what caused a change in the power state of a server.
+type PowerTrigger string
+
+const (
+	PowerTriggerPanel       PowerTrigger = "panel"
+	PowerTriggerUser        PowerTrigger = "user"
+	PowerTriggerCrash       PowerTrigger = "crash"
+	PowerTriggerAutoStop    PowerTrigger = "auto_stop"
+	PowerTriggerWake        PowerTrigger = "wake"
+	PowerTriggerBoot        PowerTrigger = "boot"
+	PowerTriggerInstall     PowerTrigger = "install"
+	PowerTriggerImageChange PowerTrigger = "image_change"
+	PowerTriggerSuspension  PowerTrigger = "suspension"
+	PowerTriggerProcessExit PowerTrigger = "process_exit"
+	PowerTriggerReconcile   PowerTrigger = "reconcile"
+	PowerTriggerUnknown     PowerTrigger = "unknown"
+)
+
+var (
+	stateLogOnce sync.Once
+	stateLogger  log.Interface
+)
+
+// stateTransitionLogger returns the logger that state transitions are written to,
+// which is either the main Wings log or a dedicated file of JSON entries.
+func stateTransitionLogger() log.Interface {
+	stateLogOnce.Do(func() {
+		stateLogger = log.Log
+		file := config.Get().System.StateTransitionLog.File
+		if file == "" {
+			return
+		}
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			log.WithField("file", file).WithField("error", err).Warn("failed to open state transition log file, state transitions will be written to the main log")
+			return
+		}
+		stateLogger = &log.Logger{Handler: json.New(f), Level: log.InfoLevel}
+	})
+	return stateLogger
+}
+
+// stateTransitions tracks what most recently requested a change in the power
+// state of a server, and when the server entered its current state.
+type stateTransitions struct {
+	mu        sync.Mutex
+	trigger   PowerTrigger
+	action    PowerAction
+	requested time.Time
+	since     time.Time
+}
+
+// HandlePowerActionFrom is the same as HandlePowerAction, however the state
+// transitions caused by the action are attributed to the trigger provided in the
+// state transition log.
+func (s *Server) HandlePowerActionFrom(trigger PowerTrigger, action PowerAction, waitSeconds ...int) error {
+	s.setPowerTrigger(trigger, action)
+	return s.HandlePowerAction(action, waitSeconds...)
+}
+
+// setPowerTrigger records what requested the power action that is about to be
+// performed for the server.
+func (s *Server) setPowerTrigger(trigger PowerTrigger, action PowerAction) {
+	s.transitions.mu.Lock()
+	defer s.transitions.mu.Unlock()
+	s.transitions.trigger = trigger
+	s.transitions.action = action
+	s.transitions.requested = time.Now()
+}
+
+// logStateTransition writes an entry to the state transition log for a change in
+// the power state of the server. The trigger is kept until the server is running,
+// or has stopped once the power action that requested it has completed, so that
+// every transition caused by an action such as a restart is attributed to it.
+func (s *Server) logStateTransition(prev, st string) {
+	s.transitions.mu.Lock()
+	defer s.transitions.mu.Unlock()
+
+	t := &s.transitions
+	now := time.Now()
+	trigger, action, requested := t.trigger, t.action, t.requested
+	if trigger == "" {
+		trigger = PowerTriggerUnknown
+		if st == environment.ProcessOfflineState && (prev == environment.ProcessStartingState || prev == environment.ProcessRunningState) {
+			trigger = PowerTriggerProcessExit
+		}
+	}
+	if st == environment.ProcessRunningState || (st == environment.ProcessOfflineState && !s.ExecutingPowerAction()) {
+		t.trigger, t.action, t.requested = "", "", time.Time{}
+	}
+
+	if config.Get().System.StateTransitionLog.Enabled {
+		fields := log.Fields{"server": s.ID(), "previous_state": prev, "state": st, "trigger": trigger}
+		if action != "" {
+			fields["action"] = action
+		}
+		if !t.since.IsZero() {
+			fields["previous_state_duration"] = now.Sub(t.since).Round(time.Millisecond).String()
+		}
+		if !requested.IsZero() {
+			fields["since_requested"] = now.Sub(requested).Round(time.Millisecond).String()
+		}
+		stateTransitionLogger().WithFields(fields).Info("server power state changed")
+	}
+	t.since = now
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/environment"
+)
+
+func TestDesiredState(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("DesiredState", func() {
+		var s *Server
+
+		g.BeforeEach(func() {
+			s = &Server{}
+		})
+
+		g.It("defaults to offline", func() {
+			g.Assert(s.DesiredState()).Equal(environment.ProcessOfflineState)
+		})
+
+		g.It("is running once the server starts", func() {
+			s.updateDesiredState(environment.ProcessOfflineState, environment.ProcessStartingState)
+			g.Assert(s.DesiredState()).Equal(environment.ProcessRunningState)
+		})
+
+		g.It("remains running if the server goes offline without stopping", func() {
+			s.updateDesiredState(environment.ProcessStartingState, environment.ProcessRunningState)
+			s.updateDesiredState(environment.ProcessRunningState, environment.ProcessOfflineState)
+			g.Assert(s.DesiredState()).Equal(environment.ProcessRunningState)
+		})
+
+		g.It("is offline once the server is stopped", func() {
+			s.updateDesiredState(environment.ProcessStartingState, environment.ProcessRunningState)
+			s.updateDesiredState(environment.ProcessRunningState, environment.ProcessStoppingState)
+			s.updateDesiredState(environment.ProcessStoppingState, environment.ProcessOfflineState)
+			g.Assert(s.DesiredState()).Equal(environment.ProcessOfflineState)
+		})
+	})
+}
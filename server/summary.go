@@ -0,0 +1,92 @@
+package server
+
+import (
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+// NodeSummary is a summary of the capacity of the node and the resources that
+// have been committed to the servers on it.
+type NodeSummary struct {
+	// Capacity is the configured capacity of the node. CapacityConfigured is false
+	// if no capacity has been defined, in which case no utilization is reported.
+	Capacity           config.NodeCapacity `json:"capacity"`
+	CapacityConfigured bool                `json:"capacity_configured"`
+
+	Servers          int `json:"servers"`
+	RunningServers   int `json:"running_servers"`
+	SuspendedServers int `json:"suspended_servers"`
+
+	// The resources committed to servers, in MiB for memory and disk and as a
+	// percentage of a single thread for CPU. Servers without a limit for a resource
+	// are counted separately, since they can use as much of it as is available.
+	CommittedMemory int64 `json:"committed_memory"`
+	CommittedDisk   int64 `json:"committed_disk"`
+	CommittedCpu    int64 `json:"committed_cpu"`
+	UnlimitedMemory int   `json:"unlimited_memory"`
+	UnlimitedDisk   int   `json:"unlimited_disk"`
+	UnlimitedCpu    int   `json:"unlimited_cpu"`
+	DiskUsedBytes   int64 `json:"disk_used_bytes"`
+
+	// The committed resources as a percentage of the capacity of the node. These are
+	// only set when the capacity of the resource is defined.
+	MemoryUtilization *float64 `json:"memory_utilization,omitempty"`
+	DiskUtilization   *float64 `json:"disk_utilization,omitempty"`
+	CpuUtilization    *float64 `json:"cpu_utilization,omitempty"`
+}
+
+// NodeSummary returns a summary of the capacity of the node and the resources
+// committed to the servers on it. The limits of each server are read while
+// holding its configuration lock so the tally is consistent with concurrent
+// updates from the Panel.
+func (m *Manager) NodeSummary() NodeSummary {
+	capacity := config.Get().System.Capacity
+	out := NodeSummary{Capacity: capacity, CapacityConfigured: capacity.IsConfigured()}
+
+	for _, s := range m.All() {
+		s.cfg.mu.RLock()
+		build := s.cfg.Build
+		suspended := s.cfg.Suspended
+		s.cfg.mu.RUnlock()
+
+		out.Servers++
+		if suspended {
+			out.SuspendedServers++
+		}
+		if st := s.Environment.State(); st == environment.ProcessRunningState || st == environment.ProcessStartingState {
+			out.RunningServers++
+		}
+
+		if build.MemoryLimit > 0 {
+			out.CommittedMemory += build.MemoryLimit
+		} else {
+			out.UnlimitedMemory++
+		}
+		if build.DiskSpace > 0 {
+			out.CommittedDisk += build.DiskSpace
+		} else {
+			out.UnlimitedDisk++
+		}
+		if build.CpuLimit > 0 {
+			out.CommittedCpu += build.CpuLimit
+		} else {
+			out.UnlimitedCpu++
+		}
+		out.DiskUsedBytes += s.Filesystem().CachedUsage()
+	}
+
+	out.MemoryUtilization = utilization(out.CommittedMemory, capacity.Memory)
+	out.DiskUtilization = utilization(out.CommittedDisk, capacity.Disk)
+	out.CpuUtilization = utilization(out.CommittedCpu, capacity.Cpu)
+	return out
+}
+
+// utilization returns the committed amount as a percentage of the capacity, or
+// nil if the capacity is not defined.
+func utilization(committed, capacity int64) *float64 {
+	if capacity <= 0 {
+		return nil
+	}
+	v := float64(committed) / float64(capacity) * 100
+	return &v
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/internal/progress"
 	"github.com/pterodactyl/wings/server/filesystem"
 )
@@ -31,16 +32,57 @@ type Archive struct {
 	archive *filesystem.Archive
 }
 
-// NewArchive returns a new archive associated with the given transfer.
+// NewArchive returns a new archive associated with the given transfer, which is
+// compressed using the compression negotiated with the destination node, or gzip
+// if none was negotiated.
 func NewArchive(t *Transfer, size uint64) *Archive {
+	cfg := config.Get().System.Transfers
+	compression := t.compression
+	if compression == "" {
+		compression = config.TransferCompressionGzip
+	}
+	level := cfg.Level()
+	if compression != cfg.Compression {
+		level = 0
+	}
 	return &Archive{
 		archive: &filesystem.Archive{
 			Filesystem: t.Server.Filesystem(),
 			Progress:   progress.NewProgress(size),
+			Format:     archiveFormat(compression),
+			Level:      level,
 		},
 	}
 }
 
+// archiveFormat returns the archive format used for the transfer compression.
+func archiveFormat(compression string) string {
+	switch compression {
+	case config.TransferCompressionGzip:
+		return config.BackupFormatTarGz
+	case config.TransferCompressionZstd:
+		return config.BackupFormatZstd
+	default:
+		return filesystem.ArchiveFormatTar
+	}
+}
+
+// SupportedCompression is the compression of transfer archives that this node is
+// able to receive, which is advertised to source nodes.
+var SupportedCompression = []string{config.TransferCompressionGzip, config.TransferCompressionZstd, config.TransferCompressionNone}
+
+// Capabilities is the response of a destination node describing the transfers it
+// is able to receive.
+type Capabilities struct {
+	Compression []string `json:"compression"`
+}
+
+// Name returns the file name of the archive, which is used by the destination
+// node to identify the compression of the archive.
+func (a *Archive) Name() string {
+	return "archive" + filesystem.ArchiveExtension(a.archive.Format)
+}
+
 // Stream returns a reader that can be used to stream the contents of the archive.
 func (a *Archive) Stream(ctx context.Context, w io.Writer) error {
 	return a.archive.Stream(ctx, w)
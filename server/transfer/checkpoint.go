@@ -0,0 +1,117 @@
+package transfer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// Direction is the direction of a transfer relative to this node.
+type Direction string
+
+const (
+	DirectionIncoming Direction = "incoming"
+	DirectionOutgoing Direction = "outgoing"
+)
+
+// Checkpoint is the record of a transfer that is in progress, which is stored on
+// the disk so that the transfer can be recovered if Wings is stopped before the
+// transfer completes.
+type Checkpoint struct {
+	Server    string    `json:"server"`
+	Direction Direction `json:"direction"`
+	Status    Status    `json:"status"`
+	// URL is the destination node of an outgoing transfer. The token used to send the
+	// server to it is only kept in memory, so that it is never written to the disk.
+	URL       string    `json:"url,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// checkpointDirectory returns the directory that transfer checkpoints are stored in.
+func checkpointDirectory() string {
+	return filepath.Join(config.Get().System.RootDirectory, "transfers")
+}
+
+func checkpointPath(d Direction, id string) string {
+	return filepath.Join(checkpointDirectory(), string(d)+"-"+id+".json")
+}
+
+// SaveCheckpoint writes the checkpoint of a transfer to the disk, replacing any
+// existing checkpoint for the same server and direction. If checkpoints are not
+// enabled this is a no-op.
+func SaveCheckpoint(cp Checkpoint) error {
+	if !config.Get().System.Transfers.Checkpoints {
+		return nil
+	}
+	if err := os.MkdirAll(checkpointDirectory(), 0o700); err != nil {
+		return errors.Wrap(err, "transfer: failed to create checkpoint directory")
+	}
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Write the checkpoint to a temporary file first so that a checkpoint is never
+	// left partially written.
+	p := checkpointPath(cp.Direction, cp.Server)
+	if err := os.WriteFile(p+".tmp", b, 0o600); err != nil {
+		return errors.Wrap(err, "transfer: failed to write checkpoint")
+	}
+	return errors.Wrap(os.Rename(p+".tmp", p), "transfer: failed to write checkpoint")
+}
+
+// RemoveCheckpoint removes the checkpoint of a transfer from the disk.
+func RemoveCheckpoint(d Direction, id string) {
+	if err := os.Remove(checkpointPath(d, id)); err != nil && !os.IsNotExist(err) {
+		log.WithField("subsystem", "transfer").WithField("server", id).WithError(err).Warn("failed to remove transfer checkpoint")
+	}
+}
+
+// loadCheckpoint returns the checkpoint of a transfer, or nil if there is none.
+func loadCheckpoint(d Direction, id string) (*Checkpoint, error) {
+	b, err := os.ReadFile(checkpointPath(d, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, errors.Wrap(err, "transfer: failed to parse checkpoint")
+	}
+	return &cp, nil
+}
+
+// loadCheckpoints returns all the transfer checkpoints stored on the disk.
+func loadCheckpoints() ([]Checkpoint, error) {
+	entries, err := os.ReadDir(checkpointDirectory())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	var out []Checkpoint
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(checkpointDirectory(), e.Name()))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var cp Checkpoint
+		if err := json.Unmarshal(b, &cp); err != nil {
+			return nil, errors.Wrapf(err, "transfer: failed to parse checkpoint %s", e.Name())
+		}
+		out = append(out, cp)
+	}
+	return out, nil
+}
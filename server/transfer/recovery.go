@@ -0,0 +1,219 @@
+package transfer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+// recoveryAction is what is done with the checkpoint of an interrupted transfer.
+type recoveryAction int
+
+const (
+	// recoveryDiscard removes the checkpoint of a transfer that completed.
+	recoveryDiscard recoveryAction = iota
+	// recoveryRollback reports the transfer to the Panel as failed and removes any
+	// partial server data.
+	recoveryRollback
+	// recoveryReportSuccess reports a transfer that was received in full to the
+	// Panel as successful.
+	recoveryReportSuccess
+	// recoveryAwaitResume keeps the partial server data while waiting for the
+	// source node to send the server again.
+	recoveryAwaitResume
+)
+
+// outgoingAction returns how an interrupted outgoing transfer is recovered. The
+// token needed to send the server to the destination again is never written to
+// the disk, so a transfer that has not completed is always rolled back and must
+// be started again from the Panel, which issues a new token.
+func outgoingAction(exists bool) recoveryAction {
+	if !exists {
+		// The server no longer belongs to this node, so the transfer completed before
+		// the checkpoint was removed.
+		return recoveryDiscard
+	}
+	return recoveryRollback
+}
+
+// incomingAction returns how an interrupted incoming transfer is recovered.
+func incomingAction(exists bool, status Status, resumes bool) recoveryAction {
+	switch {
+	case exists:
+		// The server belongs to this node, so the transfer completed.
+		return recoveryDiscard
+	case status == StatusCompleted:
+		// The archive was received and verified, but Wings stopped before the Panel
+		// was told that the transfer was successful.
+		return recoveryReportSuccess
+	case resumes:
+		return recoveryAwaitResume
+	default:
+		return recoveryRollback
+	}
+}
+
+// removeContainer removes the container of a server that was partially transferred
+// to this node.
+var removeContainer = func(ctx context.Context, id string) error {
+	cli, err := environment.Docker()
+	if err != nil {
+		return err
+	}
+	err = cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{RemoveVolumes: true, Force: true})
+	if err != nil && !client.IsErrNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Recover recovers the transfers that were interrupted by Wings being stopped
+// using the checkpoints stored on the disk. This is a no-op if checkpoints are
+// not enabled.
+func Recover(ctx context.Context, m *server.Manager) {
+	if !config.Get().System.Transfers.Checkpoints {
+		return
+	}
+	checkpoints, err := loadCheckpoints()
+	if err != nil {
+		log.WithField("subsystem", "transfer").WithError(err).Error("failed to load transfer checkpoints")
+		return
+	}
+	for _, cp := range checkpoints {
+		switch cp.Direction {
+		case DirectionOutgoing:
+			recoverOutgoing(ctx, m, cp)
+		case DirectionIncoming:
+			recoverIncoming(ctx, m, cp)
+		}
+	}
+}
+
+func checkpointLog(cp Checkpoint) *log.Entry {
+	return log.WithFields(log.Fields{"subsystem": "transfer", "server": cp.Server, "direction": cp.Direction})
+}
+
+// recoverOutgoing recovers a transfer of a server from this node. The server
+// data on this node is only removed by the Panel once the destination node has
+// reported the transfer as successful, so it is always safe to report the
+// transfer as failed.
+func recoverOutgoing(ctx context.Context, m *server.Manager, cp Checkpoint) {
+	l := checkpointLog(cp)
+	_, ok := m.Get(cp.Server)
+	if outgoingAction(ok) == recoveryDiscard {
+		l.Info("interrupted outgoing transfer was completed, removing checkpoint")
+		RemoveCheckpoint(DirectionOutgoing, cp.Server)
+		return
+	}
+
+	l.Info("rolling back interrupted outgoing transfer")
+	if err := m.Client().SetTransferStatus(ctx, cp.Server, false); err != nil {
+		// Keep the checkpoint so that reporting the failure is retried the next time
+		// Wings boots.
+		l.WithError(err).Error("failed to report interrupted transfer to the panel")
+		return
+	}
+	RemoveCheckpoint(DirectionOutgoing, cp.Server)
+}
+
+// recoverIncoming recovers a transfer of a server to this node.
+func recoverIncoming(ctx context.Context, m *server.Manager, cp Checkpoint) {
+	l := checkpointLog(cp)
+	_, ok := m.Get(cp.Server)
+	switch incomingAction(ok, cp.Status, config.Get().System.Transfers.Resumes()) {
+	case recoveryDiscard:
+		l.Info("interrupted incoming transfer was completed, removing checkpoint")
+		RemoveCheckpoint(DirectionIncoming, cp.Server)
+	case recoveryReportSuccess:
+		l.Info("reporting interrupted incoming transfer as successful")
+		err := m.Client().SetTransferStatus(ctx, cp.Server, true)
+		if err == nil {
+			RemoveCheckpoint(DirectionIncoming, cp.Server)
+			if err := loadServer(ctx, m, cp.Server); err != nil {
+				l.WithError(err).Error("failed to load transferred server")
+			}
+			return
+		}
+		if !remote.IsRequestError(err) {
+			l.WithError(err).Error("failed to report interrupted transfer to the panel")
+			return
+		}
+		l.WithError(err).Warn("panel refused interrupted incoming transfer, rolling back")
+		rollbackIncoming(ctx, m, cp)
+	case recoveryAwaitResume:
+		AwaitResume(m, cp)
+	default:
+		rollbackIncoming(ctx, m, cp)
+	}
+}
+
+// AwaitResume waits for an interrupted incoming transfer to be resumed by the
+// source node, keeping the partial server data in the meantime. If the transfer
+// has not been resumed once the resume timeout is reached it is rolled back.
+func AwaitResume(m *server.Manager, cp Checkpoint) {
+	timeout := time.Duration(config.Get().System.Transfers.ResumeTimeout) * time.Second
+	checkpointLog(cp).WithField("timeout", timeout).Info("waiting for interrupted incoming transfer to be resumed")
+	time.AfterFunc(timeout, func() {
+		if Incoming().Get(cp.Server) != nil {
+			return
+		}
+		// The transfer was resumed and either finished or was interrupted again, in
+		// which case the new checkpoint is handled separately.
+		current, err := loadCheckpoint(DirectionIncoming, cp.Server)
+		if err != nil || current == nil || !current.StartedAt.Equal(cp.StartedAt) {
+			return
+		}
+		checkpointLog(cp).Warn("interrupted incoming transfer was not resumed in time")
+		rollbackIncoming(context.Background(), m, cp)
+	})
+}
+
+// rollbackIncoming reports an interrupted incoming transfer to the Panel as failed
+// and removes the container and partial server data.
+func rollbackIncoming(ctx context.Context, m *server.Manager, cp Checkpoint) {
+	l := checkpointLog(cp)
+	l.Info("rolling back interrupted incoming transfer")
+	if _, ok := m.Get(cp.Server); ok {
+		// Never remove the data of a server that belongs to this node.
+		l.Warn("server belongs to this node, not removing its data")
+		RemoveCheckpoint(DirectionIncoming, cp.Server)
+		return
+	}
+	if err := m.Client().SetTransferStatus(ctx, cp.Server, false); err != nil && !remote.IsRequestError(err) {
+		l.WithError(err).Error("failed to report interrupted transfer to the panel")
+		return
+	}
+	if err := removeContainer(ctx, cp.Server); err != nil {
+		l.WithError(err).Error("failed to remove container of partially transferred server")
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(config.Get().System.Data, cp.Server)); err != nil {
+		l.WithError(err).Error("failed to remove partial server data")
+		return
+	}
+	RemoveCheckpoint(DirectionIncoming, cp.Server)
+}
+
+// loadServer adds a server that was transferred to this node to the manager.
+func loadServer(ctx context.Context, m *server.Manager, uuid string) error {
+	c, err := m.Client().GetServerConfiguration(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	s, err := m.InitServer(c)
+	if err != nil {
+		return err
+	}
+	m.Add(s)
+	return s.CreateEnvironment()
+}
@@ -0,0 +1,113 @@
+package transfer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+type statusClient struct {
+	remote.Client
+	err      error
+	statuses []bool
+}
+
+func (c *statusClient) SetTransferStatus(_ context.Context, _ string, successful bool) error {
+	c.statuses = append(c.statuses, successful)
+	return c.err
+}
+
+func TestRecovery(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("outgoingAction", func() {
+		g.It("discards the checkpoint once the server has left this node", func() {
+			g.Assert(outgoingAction(false)).Equal(recoveryDiscard)
+		})
+
+		g.It("rolls back transfers of servers still on this node", func() {
+			g.Assert(outgoingAction(true)).Equal(recoveryRollback)
+		})
+	})
+
+	g.Describe("incomingAction", func() {
+		g.It("discards the checkpoint once the server belongs to this node", func() {
+			g.Assert(incomingAction(true, StatusProcessing, true)).Equal(recoveryDiscard)
+			g.Assert(incomingAction(true, StatusCompleted, false)).Equal(recoveryDiscard)
+		})
+
+		g.It("reports transfers that were received in full as successful", func() {
+			g.Assert(incomingAction(false, StatusCompleted, false)).Equal(recoveryReportSuccess)
+			g.Assert(incomingAction(false, StatusCompleted, true)).Equal(recoveryReportSuccess)
+		})
+
+		g.It("waits for partial transfers to resume only when resuming is enabled", func() {
+			g.Assert(incomingAction(false, StatusProcessing, true)).Equal(recoveryAwaitResume)
+			g.Assert(incomingAction(false, StatusProcessing, false)).Equal(recoveryRollback)
+		})
+	})
+
+	g.Describe("rollbackIncoming", func() {
+		var root string
+		var removed []string
+		cp := Checkpoint{Server: "c1d0a1f5-3b1a-4e8b-9a53-2d0e1b9b7c6a", Direction: DirectionIncoming, Status: StatusProcessing, StartedAt: time.Now()}
+
+		original := removeContainer
+		g.After(func() {
+			removeContainer = original
+		})
+
+		g.BeforeEach(func() {
+			root = t.TempDir()
+			removed = nil
+			removeContainer = func(_ context.Context, id string) error {
+				removed = append(removed, id)
+				return nil
+			}
+			config.Set(&config.Configuration{
+				AuthenticationToken: "test",
+				System: config.SystemConfiguration{
+					RootDirectory: root,
+					Data:          filepath.Join(root, "volumes"),
+					Transfers:     config.Transfers{Checkpoints: true},
+				},
+			})
+			g.Assert(os.MkdirAll(filepath.Join(root, "volumes", cp.Server), 0o700)).IsNil()
+			g.Assert(SaveCheckpoint(cp)).IsNil()
+		})
+
+		g.It("removes the container, partial data and checkpoint", func() {
+			c := &statusClient{}
+			rollbackIncoming(context.Background(), server.NewEmptyManager(c), cp)
+
+			g.Assert(c.statuses).Equal([]bool{false})
+			g.Assert(removed).Equal([]string{cp.Server})
+			_, err := os.Stat(filepath.Join(root, "volumes", cp.Server))
+			g.Assert(os.IsNotExist(err)).IsTrue()
+			current, err := loadCheckpoint(DirectionIncoming, cp.Server)
+			g.Assert(err).IsNil()
+			g.Assert(current == nil).IsTrue()
+		})
+
+		g.It("keeps everything when the Panel cannot be told about the failure", func() {
+			c := &statusClient{err: errors.New("connection refused")}
+			rollbackIncoming(context.Background(), server.NewEmptyManager(c), cp)
+
+			g.Assert(len(removed)).Equal(0)
+			_, err := os.Stat(filepath.Join(root, "volumes", cp.Server))
+			g.Assert(err).IsNil()
+			current, err := loadCheckpoint(DirectionIncoming, cp.Server)
+			g.Assert(err).IsNil()
+			g.Assert(current != nil).IsTrue()
+		})
+	})
+}
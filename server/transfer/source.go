@@ -4,16 +4,63 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	neturl "net/url"
 	"time"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/internal/progress"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
 )
 
+// negotiateCompression returns the compression to use for the archive sent to the
+// destination node. The configured compression is only used if the destination
+// advertises support for it, otherwise gzip is used since it is the only
+// compression that nodes without support for negotiation are able to receive.
+func (t *Transfer) negotiateCompression(ctx context.Context, url, token string) string {
+	cfg := config.Get()
+	want := cfg.System.Transfers.Compression
+	if want == config.TransferCompressionGzip {
+		return want
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return config.TransferCompressionGzip
+	}
+	req.Header.Set("Authorization", token)
+	req.Header.Set("X-Wings-Node", cfg.Uuid)
+	if cfg.System.Transfers.HandshakeToken != "" {
+		req.Header.Set("X-Wings-Transfer-Token", cfg.System.Transfers.HandshakeToken)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Log().WithField("error", err).Warn("failed to request transfer capabilities of destination, using gzip compression")
+		return config.TransferCompressionGzip
+	}
+	defer res.Body.Close()
+
+	var caps Capabilities
+	if res.StatusCode == http.StatusOK {
+		_ = json.NewDecoder(res.Body).Decode(&caps)
+	}
+	for _, c := range caps.Compression {
+		if c == want {
+			return want
+		}
+	}
+	t.Log().WithField("compression", want).Info("destination does not support configured transfer compression, using gzip compression")
+	return config.TransferCompressionGzip
+}
+
 // PushArchiveToTarget POSTs the archive to the target node and returns the
 // response body.
 func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
@@ -23,6 +70,15 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 	t.SendMessage("Preparing to stream server data to destination...")
 	t.SetStatus(StatusProcessing)
 
+	cfg := config.Get()
+	if cfg.System.Transfers.RequireTls {
+		if u, err := neturl.Parse(url); err != nil || u.Scheme != "https" {
+			t.Error(errors.New("destination does not use https"), "Destination node does not use TLS, refusing to transfer server.")
+			return nil, errors.New("transfer destination does not use https and system.transfers.require_tls is enabled")
+		}
+	}
+
+	t.compression = t.negotiateCompression(ctx, url, token)
 	a, err := t.Archive()
 	if err != nil {
 		t.Error(err, "Failed to get archive for transfer.")
@@ -56,6 +112,12 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 		return nil, err
 	}
 	req.Header.Set("Authorization", token)
+	// Identify this node to the destination so that it can check the request against
+	// its allowlist of source nodes.
+	req.Header.Set("X-Wings-Node", cfg.Uuid)
+	if cfg.System.Transfers.HandshakeToken != "" {
+		req.Header.Set("X-Wings-Transfer-Token", cfg.System.Transfers.HandshakeToken)
+	}
 
 	// Create a new multipart writer that writes the archive to the pipe.
 	mp := multipart.NewWriter(writer)
@@ -77,7 +139,7 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 		h := sha256.New()
 		tee := io.TeeReader(src, h)
 
-		dest, err := mp.CreateFormFile("archive", "archive.tar.gz")
+		dest, err := mp.CreateFormFile("archive", a.Name())
 		if err != nil {
 			errChan <- errors.New("failed to create form file")
 			return
@@ -164,3 +226,73 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 		return v, nil
 	}
 }
+
+// Push sends the server to the destination node, reporting the transfer to the
+// Panel as failed if it can not be completed. When transfer checkpoints are
+// enabled the transfer is recorded so that it can be recovered if Wings is
+// stopped, and when transfers are resumed, failures to reach the destination
+// node are retried until the resume timeout is reached.
+func (t *Transfer) Push(client remote.Client, url, token string) {
+	t.push(client, Checkpoint{
+		Server:    t.Server.ID(),
+		Direction: DirectionOutgoing,
+		Status:    StatusProcessing,
+		URL:       url,
+		StartedAt: time.Now(),
+	}, token)
+}
+
+func (t *Transfer) push(client remote.Client, cp Checkpoint, token string) {
+	if err := SaveCheckpoint(cp); err != nil {
+		t.Log().WithError(err).Warn("failed to save transfer checkpoint")
+	}
+
+	cfg := config.Get().System.Transfers
+	timeout := time.Duration(cfg.ResumeTimeout) * time.Second
+	for {
+		_, err := t.PushArchiveToTarget(cp.URL, token)
+		if err == nil {
+			break
+		}
+
+		// Retry if the destination node could not be reached, as it may have been
+		// restarted and will wait for the transfer to be resumed.
+		var uerr *neturl.Error
+		if cfg.Resumes() && errors.As(err, &uerr) && !errors.Is(err, context.Canceled) && time.Since(cp.StartedAt) < timeout {
+			t.Log().WithError(err).Warn("failed to reach destination node, retrying transfer")
+			t.SendMessage("Lost connection to the destination node, retrying...")
+			t.archive = nil
+			select {
+			case <-t.ctx.Done():
+				err = t.ctx.Err()
+			case <-time.After(15 * time.Second):
+				continue
+			}
+		}
+
+		if err := client.SetTransferStatus(context.Background(), t.Server.ID(), false); err != nil {
+			t.Log().WithField("status", false).WithError(err).Error("failed to set transfer status")
+		} else {
+			RemoveCheckpoint(DirectionOutgoing, t.Server.ID())
+		}
+		t.Server.Events().Publish(server.TransferStatusEvent, "failure")
+		t.Server.SetTransferring(false)
+
+		if err == context.Canceled {
+			t.Log().Debug("canceled")
+			t.SendMessage("Canceled.")
+			return
+		}
+
+		t.Log().WithError(err).Error("failed to push archive to target")
+		return
+	}
+
+	// DO NOT NOTIFY THE PANEL OF SUCCESS HERE. The only node that should send
+	// a success status is the destination node.  When we send a failure status,
+	// the panel will automatically cancel the transfer and attempt to reset
+	// the server state on the destination node, we just need to make sure
+	// we clean up our statuses for failure.
+	RemoveCheckpoint(DirectionOutgoing, t.Server.ID())
+	t.Log().Debug("transfer complete")
+}
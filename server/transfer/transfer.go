@@ -53,6 +53,8 @@ type Transfer struct {
 
 	// archive is the archive that is being created for the transfer.
 	archive *Archive
+	// compression is the compression negotiated with the destination node.
+	compression string
 }
 
 // New returns a new transfer instance for the given server.
@@ -1,8 +1,10 @@
 package server
 
 import (
+	"context"
 	"time"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment/docker"
 
 	"github.com/pterodactyl/wings/environment"
@@ -25,10 +27,21 @@ func (s *Server) SyncWithEnvironment() {
 
 	// Update the environment settings using the new information from this server.
 	s.Environment.Config().SetSettings(environment.Settings{
-		Mounts:      s.Mounts(),
-		Allocations: cfg.Allocations,
-		Limits:      cfg.Build,
-		Labels:      cfg.Labels,
+		Mounts:          s.Mounts(),
+		Allocations:     cfg.Allocations,
+		Limits:          cfg.Build,
+		Labels:          cfg.containerLabels(),
+		NetworkMode:     cfg.NetworkMode,
+		StopGracePeriod: cfg.StopGracePeriod,
+		Tmpfs:           cfg.Tmpfs,
+		ReadonlyRootfs:  cfg.ReadonlyRootfs,
+		Init:            cfg.Init,
+		ShmSize:         cfg.ShmSize,
+		Privileged:      cfg.Container.Privileged,
+		NetworkEgress:   cfg.NetworkEgressLimit,
+		NetworkIngress:  cfg.NetworkIngressLimit,
+		IoLimits:        cfg.IoLimits,
+		GroupAdd:        cfg.GroupAdd,
 	})
 
 	// For Docker specific environments we also want to update the configured image
@@ -37,6 +50,10 @@ func (s *Server) SyncWithEnvironment() {
 		s.Log().Debug("syncing stop configuration with configured docker environment")
 		e.SetImage(cfg.Container.Image)
 		e.SetStopConfiguration(s.ProcessConfiguration().Stop)
+
+		if config.Get().System.AutoRecreateOnImageChange {
+			s.checkForImageChange(e)
+		}
 	}
 
 	// If build limits are changed, environment variables also change. Plus, any modifications to
@@ -48,6 +65,10 @@ func (s *Server) SyncWithEnvironment() {
 	if !s.IsSuspended() {
 		// Update the environment in place, allowing memory and CPU usage to be adjusted
 		// on the fly without the user needing to reboot (theoretically).
+		if err := s.checkResourceBounds(); err != nil {
+			s.Log().WithField("error", err).Warn("refusing to perform on-the-fly update of the server environment")
+			return
+		}
 		s.Log().Info("performing server limit modification on-the-fly")
 		if err := s.Environment.InSituUpdate(); err != nil {
 			// This is not a failure, the process is still running fine and will fix itself on the
@@ -56,9 +77,13 @@ func (s *Server) SyncWithEnvironment() {
 		}
 	} else {
 		// Checks if the server is now in a suspended state. If so and a server process is currently running it
-		// will be gracefully stopped (and terminated if it refuses to stop).
-		if s.Environment.State() != environment.ProcessOfflineState {
+		// will be gracefully stopped (and terminated if it refuses to stop), unless this has been disabled in
+		// which case the process is left running but is unable to be started again once it stops.
+		if s.Environment.State() != environment.ProcessOfflineState && !config.Get().System.StopSuspendedServers {
+			s.Log().Info("server suspended with running process state, leaving it running until it is stopped")
+		} else if s.Environment.State() != environment.ProcessOfflineState {
 			s.Log().Info("server suspended with running process state, terminating now")
+			s.setPowerTrigger(PowerTriggerSuspension, PowerActionStop)
 
 			go func(s *Server) {
 				if err := s.Environment.WaitForStop(s.Context(), time.Minute, true); err != nil {
@@ -68,3 +93,39 @@ func (s *Server) SyncWithEnvironment() {
 		}
 	}
 }
+
+// checkForImageChange flags the server if the image currently assigned to it
+// differs from the image used by its running container. The container is always
+// re-created when the server starts, so flagged servers pick up the new image on
+// their next restart. If configured, the server is restarted right away unless it
+// is suspended or busy with an installation, transfer, or restoration.
+func (s *Server) checkForImageChange(e *docker.Environment) {
+	if e.State() == environment.ProcessOfflineState {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context(), time.Second*10)
+	defer cancel()
+	changed, err := e.ImageChanged(ctx)
+	if err != nil {
+		s.Log().WithField("error", err).Warn("failed to compare assigned image with running server container")
+		return
+	}
+	if !changed {
+		return
+	}
+
+	image := s.Config().Container.Image
+	s.outdatedImage.Store(true)
+	if !config.Get().System.RecreateImmediatelyOnImageChange || s.IsSuspended() || s.IsInstalling() || s.IsTransferring() || s.IsRestoring() {
+		s.Log().WithField("image", image).Info("server container is running an outdated image, it will be re-created on next restart")
+		return
+	}
+
+	s.Log().WithField("image", image).Info("server container is running an outdated image, restarting server to re-create it")
+	go func(s *Server) {
+		if err := s.HandlePowerActionFrom(PowerTriggerImageChange, PowerActionRestart, 30); err != nil {
+			s.Log().WithField("error", err).Warn("failed to restart server to apply updated image")
+		}
+	}(s)
+}
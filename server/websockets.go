@@ -10,6 +10,9 @@ import (
 type WebsocketBag struct {
 	mu    sync.Mutex
 	conns map[uuid.UUID]*context.CancelFunc
+	// active is the number of connections that have acquired a slot, which are
+	// the connections that have authenticated.
+	active int
 }
 
 // Websockets returns the websocket bag which contains all the currently open websocket connections
@@ -25,6 +28,29 @@ func (s *Server) Websockets() *WebsocketBag {
 	return s.wsBag
 }
 
+// Acquire reserves a slot for a new websocket connection, returning false if the
+// server already has max connections open. A max of 0 is unlimited. Every
+// successful call must be paired with a call to Release.
+func (w *WebsocketBag) Acquire(max int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if max > 0 && w.active >= max {
+		return false
+	}
+	w.active++
+	return true
+}
+
+// Release frees a slot acquired for a websocket connection.
+func (w *WebsocketBag) Release() {
+	w.mu.Lock()
+	if w.active > 0 {
+		w.active--
+	}
+	w.mu.Unlock()
+}
+
 // Push adds a new websocket connection to the end of the stack.
 func (w *WebsocketBag) Push(u uuid.UUID, cancel *context.CancelFunc) {
 	w.mu.Lock()
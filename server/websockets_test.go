@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestWebsocketBag(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("WebsocketBag", func() {
+		g.It("refuses connections beyond the limit until one is released", func() {
+			w := &WebsocketBag{}
+			g.Assert(w.Acquire(2)).IsTrue()
+			g.Assert(w.Acquire(2)).IsTrue()
+			g.Assert(w.Acquire(2)).IsFalse()
+
+			w.Release()
+			g.Assert(w.Acquire(2)).IsTrue()
+		})
+
+		g.It("allows an unlimited number of connections when the limit is 0", func() {
+			w := &WebsocketBag{}
+			for i := 0; i < 100; i++ {
+				g.Assert(w.Acquire(0)).IsTrue()
+			}
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"emperror.dev/errors"
 	"github.com/apex/log"
 
+	"github.com/pterodactyl/wings/internal/audit"
 	"github.com/pterodactyl/wings/internal/database"
 	"github.com/pterodactyl/wings/internal/models"
 )
@@ -57,3 +58,16 @@ func (eh *eventHandler) MustLog(e models.Event, fa FileAction) {
 		log.WithField("error", errors.WithStack(err)).WithField("event", e).Error("sftp: failed to log event")
 	}
 }
+
+// Audit records a file operation performed over SFTP in the audit log.
+func (eh *eventHandler) Audit(operation string, fa FileAction, err error) {
+	audit.Log(audit.Record{
+		Source:    audit.SourceSftp,
+		User:      eh.user,
+		Server:    eh.server,
+		IP:        eh.ip,
+		Operation: operation,
+		Path:      fa.Entity,
+		Target:    fa.Target,
+	}, err)
+}
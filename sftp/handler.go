@@ -59,6 +59,19 @@ func NewHandler(sc *ssh.ServerConn, srv *server.Server) (*Handler, error) {
 	}, nil
 }
 
+// hasSpaceAvailable determines if the server has space available for a file to be
+// uploaded, using the disk check strategy from the SFTP configuration.
+func (h *Handler) hasSpaceAvailable() bool {
+	switch config.Get().System.Sftp.DiskCheckStrategy {
+	case config.SftpDiskCheckCached:
+		return h.fs.MaxDisk() == 0 || h.fs.CachedUsage() <= h.fs.MaxDisk()
+	case config.SftpDiskCheckStatfs:
+		return h.fs.HasSpaceAvailableStatfs()
+	default:
+		return h.fs.HasSpaceAvailable(true)
+	}
+}
+
 // Handlers returns the sftp.Handlers for this struct.
 func (h *Handler) Handlers() sftp.Handlers {
 	return sftp.Handlers{
@@ -69,8 +82,39 @@ func (h *Handler) Handlers() sftp.Handlers {
 	}
 }
 
+// auditOperations maps the SFTP methods to the operations recorded in the audit log.
+var auditOperations = map[string]string{
+	"Get":     "read",
+	"Put":     "write",
+	"Open":    "write",
+	"Setstat": "chmod",
+	"Rename":  "rename",
+	"Rmdir":   "delete",
+	"Remove":  "delete",
+	"Mkdir":   "create_directory",
+	"Symlink": "symlink",
+}
+
+// audit records the result of a request in the audit log.
+func (h *Handler) audit(request *sftp.Request, err error) {
+	op, ok := auditOperations[request.Method]
+	if !ok {
+		return
+	}
+	if err == sftp.ErrSSHFxOk {
+		err = nil
+	}
+	h.events.Audit(op, FileAction{Entity: request.Filepath, Target: request.Target}, err)
+}
+
 // Fileread creates a reader for a file on the system and returns the reader back.
 func (h *Handler) Fileread(request *sftp.Request) (io.ReaderAt, error) {
+	r, err := h.fileread(request)
+	h.audit(request, err)
+	return r, err
+}
+
+func (h *Handler) fileread(request *sftp.Request) (io.ReaderAt, error) {
 	// Check first if the user can actually open and view a file. This permission is named
 	// really poorly, but it is checking if they can read. There is an addition permission,
 	// "save-files" which determines if they can write that file.
@@ -92,13 +136,19 @@ func (h *Handler) Fileread(request *sftp.Request) (io.ReaderAt, error) {
 
 // Filewrite handles the write actions for a file on the system.
 func (h *Handler) Filewrite(request *sftp.Request) (io.WriterAt, error) {
+	w, err := h.filewrite(request)
+	h.audit(request, err)
+	return w, err
+}
+
+func (h *Handler) filewrite(request *sftp.Request) (io.WriterAt, error) {
 	if h.ro {
 		return nil, sftp.ErrSSHFxOpUnsupported
 	}
 	l := h.logger.WithField("source", request.Filepath)
 	// If the user doesn't have enough space left on the server it should respond with an
 	// error since we won't be letting them write this file to the disk.
-	if !h.fs.HasSpaceAvailable(true) {
+	if !h.hasSpaceAvailable() {
 		return nil, ErrSSHQuotaExceeded
 	}
 
@@ -121,7 +171,13 @@ func (h *Handler) Filewrite(request *sftp.Request) (io.WriterAt, error) {
 	if !h.can(permission) {
 		return nil, sftp.ErrSSHFxPermissionDenied
 	}
-	f, err := h.fs.Touch(request.Filepath, os.O_RDWR|os.O_TRUNC)
+	if permission == PermissionFileCreate {
+		if err := checkPathLimits(request.Filepath, 0); err != nil {
+			l.WithField("error", err).Debug("refusing to create file")
+			return nil, err
+		}
+	}
+	f, err := h.fs.TouchTracked(request.Filepath, os.O_RDWR|os.O_TRUNC)
 	if err != nil {
 		l.WithField("flags", request.Flags).WithField("error", err).Error("failed to open existing file on system")
 		return nil, sftp.ErrSSHFxFailure
@@ -134,12 +190,18 @@ func (h *Handler) Filewrite(request *sftp.Request) (io.WriterAt, error) {
 		event = server.ActivitySftpCreate
 	}
 	h.events.MustLog(event, FileAction{Entity: request.Filepath})
-	return f, nil
+	return &diskFullWriter{File: f, h: h, path: request.Filepath}, nil
 }
 
 // Filecmd hander for basic SFTP system calls related to files, but not anything to do with reading
 // or writing to those files.
 func (h *Handler) Filecmd(request *sftp.Request) error {
+	err := h.filecmd(request)
+	h.audit(request, err)
+	return err
+}
+
+func (h *Handler) filecmd(request *sftp.Request) error {
 	if h.ro {
 		return sftp.ErrSSHFxOpUnsupported
 	}
@@ -177,6 +239,15 @@ func (h *Handler) Filecmd(request *sftp.Request) error {
 		if !h.can(PermissionFileUpdate) {
 			return sftp.ErrSSHFxPermissionDenied
 		}
+		below, err := h.fs.TreeDepth(request.Filepath, config.Get().System.Sftp.MaxPathDepth)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			l.WithField("error", err).Error("failed to get depth of file being renamed")
+			return sftp.ErrSSHFxFailure
+		}
+		if err := checkPathLimits(request.Target, below); err != nil {
+			l.WithField("error", err).Debug("refusing to rename file")
+			return err
+		}
 		if err := h.fs.Rename(request.Filepath, request.Target); err != nil {
 			if errors.Is(err, os.ErrNotExist) {
 				return sftp.ErrSSHFxNoSuchFile
@@ -205,6 +276,10 @@ func (h *Handler) Filecmd(request *sftp.Request) error {
 		if !h.can(PermissionFileCreate) {
 			return sftp.ErrSSHFxPermissionDenied
 		}
+		if err := checkPathLimits(request.Filepath, 0); err != nil {
+			l.WithField("error", err).Debug("refusing to create directory")
+			return err
+		}
 		name := strings.Split(filepath.Clean(request.Filepath), "/")
 		p := strings.Join(name[0:len(name)-1], "/")
 		if err := h.fs.CreateDirectory(name[len(name)-1], p); err != nil {
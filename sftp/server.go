@@ -20,6 +20,7 @@ import (
 	"golang.org/x/crypto/ssh"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/metrics"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/server"
 )
@@ -35,6 +36,13 @@ type SFTPServer struct {
 	BasePath string
 	ReadOnly bool
 	Listen   string
+
+	// Limits on the number of concurrent connections, a value of 0 is unlimited.
+	MaxConnectionsPerServer int
+	MaxConnectionsPerIP     int
+
+	servers *connectionCounter
+	ips     *connectionCounter
 }
 
 func New(m *server.Manager) *SFTPServer {
@@ -44,6 +52,12 @@ func New(m *server.Manager) *SFTPServer {
 		BasePath: cfg.Data,
 		ReadOnly: cfg.Sftp.ReadOnly,
 		Listen:   cfg.Sftp.Address + ":" + strconv.Itoa(cfg.Sftp.Port),
+
+		MaxConnectionsPerServer: cfg.Sftp.MaxConnectionsPerServer,
+		MaxConnectionsPerIP:     cfg.Sftp.MaxConnectionsPerIP,
+
+		servers: newConnectionCounter(),
+		ips:     newConnectionCounter(),
 	}
 }
 
@@ -104,7 +118,14 @@ func (c *SFTPServer) Run() error {
 
 	for {
 		if conn, _ := listener.Accept(); conn != nil {
+			ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+			if !c.ips.Acquire(ip, c.MaxConnectionsPerIP) {
+				log.WithField("ip", ip).Warn("sftp: rejecting inbound connection, too many open connections from this address")
+				_ = conn.Close()
+				continue
+			}
 			go func(conn net.Conn) {
+				defer c.ips.Release(ip)
 				defer conn.Close()
 				if err := c.AcceptInbound(conn, conf); err != nil {
 					log.WithField("error", err).WithField("ip", conn.RemoteAddr().String()).Error("sftp: failed to accept inbound connection")
@@ -125,6 +146,27 @@ func (c *SFTPServer) AcceptInbound(conn net.Conn, config *ssh.ServerConfig) erro
 	defer sconn.Close()
 	go ssh.DiscardRequests(reqs)
 
+	// Enforce the connection limit for the server now that we know which server the
+	// user has authenticated against. Reject the session with a message the client
+	// can display to the user rather than silently dropping the connection.
+	uuid := sconn.Permissions.Extensions["uuid"]
+	if s, ok := c.manager.Get(uuid); ok && s.IsSuspended() {
+		log.WithField("server", uuid).WithField("ip", conn.RemoteAddr().String()).Debug("sftp: rejecting inbound connection, server is suspended")
+		if ch, ok := <-chans; ok {
+			_ = ch.Reject(ssh.Prohibited, "this server is suspended, SFTP access is unavailable until it is unsuspended")
+		}
+		return nil
+	}
+	if !c.servers.Acquire(uuid, c.MaxConnectionsPerServer) {
+		log.WithField("server", uuid).WithField("ip", conn.RemoteAddr().String()).Warn("sftp: rejecting inbound connection, too many open connections for server")
+		if ch, ok := <-chans; ok {
+			_ = ch.Reject(ssh.ResourceShortage, "too many open SFTP connections for this server, please close an existing connection and try again")
+		}
+		return nil
+	}
+	defer c.servers.Release(uuid)
+	defer metrics.SftpSessionOpened()()
+
 	for ch := range chans {
 		// If its not a session channel we just move on because its not something we
 		// know how to handle at this point.
@@ -153,7 +195,6 @@ func (c *SFTPServer) AcceptInbound(conn net.Conn, config *ssh.ServerConfig) erro
 		//
 		// This will also attempt to match a specific server out of the global server
 		// store and return nil if there is no match.
-		uuid := sconn.Permissions.Extensions["uuid"]
 		srv := c.manager.Find(func(s *server.Server) bool {
 			if uuid == "" {
 				return false
@@ -3,6 +3,16 @@ package sftp
 import (
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/ufs"
+	"github.com/pterodactyl/wings/server/filesystem"
 )
 
 const (
@@ -12,6 +22,28 @@ const (
 	ErrSSHQuotaExceeded = fxErr(15)
 )
 
+// ErrSSHInsufficientSpace is returned to the client when a write fails because there
+// is not enough disk space available.
+var ErrSSHInsufficientSpace = errors.New("insufficient disk space")
+
+// checkPathLimits returns an error that is sent to the client if the given path is
+// nested within more directories, or has a name longer, than allowed by the SFTP
+// configuration. below is the depth of the tree below the path when a directory is
+// moved to it, which is nested that much deeper once moved.
+func checkPathLimits(p string, below int) error {
+	cfg := config.Get().System.Sftp
+	parts := strings.Split(strings.Trim(filepath.Clean("/"+p), "/"), "/")
+	// The last part is the name of the file or directory itself, which is not one of
+	// the directories it is nested within.
+	if len(parts)-1+below > cfg.MaxPathDepth {
+		return errors.Errorf("path is nested more than %d directories deep", cfg.MaxPathDepth)
+	}
+	if name := parts[len(parts)-1]; len(name) > cfg.MaxNameLength {
+		return errors.Errorf("name is longer than %d bytes", cfg.MaxNameLength)
+	}
+	return nil
+}
+
 type ListerAt []os.FileInfo
 
 // ListAt returns the number of entries copied and an io.EOF error if we made it to the end of the file list.
@@ -38,3 +70,95 @@ func (e fxErr) Error() string {
 		return "Failure"
 	}
 }
+
+// diskFullWriter wraps a file that is being written to over SFTP, aborting the
+// transfer once a write fails because there is not enough disk space, either on
+// the host or within the disk limit of the server. Depending on the configuration
+// the partially written file is then removed.
+type diskFullWriter struct {
+	ufs.File
+
+	h    *Handler
+	path string
+
+	mu     sync.Mutex
+	failed bool
+	closed bool
+}
+
+func (w *diskFullWriter) WriteAt(b []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failed {
+		return 0, ErrSSHInsufficientSpace
+	}
+	n, err := w.File.WriteAt(b, off)
+	if err == nil || !isDiskFull(err) {
+		return n, err
+	}
+
+	w.failed = true
+	l := w.h.logger.WithField("source", w.path)
+	if !config.Get().System.Sftp.RemovePartialOnDiskFull {
+		l.Warn("aborted sftp upload because there is not enough disk space, keeping the partially written file")
+		return n, ErrSSHInsufficientSpace
+	}
+	w.closed = true
+	_ = w.File.Close()
+	if err := w.h.fs.Delete(w.path); err != nil {
+		l.WithField("error", err).Warn("failed to remove partially written file after running out of disk space")
+	}
+	l.Warn("aborted sftp upload because there is not enough disk space, removed the partially written file")
+	return n, ErrSSHInsufficientSpace
+}
+
+func (w *diskFullWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.File.Close()
+}
+
+// isDiskFull returns true if the error was caused by there not being enough disk
+// space available to complete a write.
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) || filesystem.IsErrorCode(err, filesystem.ErrCodeDiskSpace)
+}
+
+// connectionCounter tracks the number of active connections for a given key,
+// such as a server UUID or a remote IP address.
+type connectionCounter struct {
+	mu sync.Mutex
+	m  map[string]int
+}
+
+func newConnectionCounter() *connectionCounter {
+	return &connectionCounter{m: make(map[string]int)}
+}
+
+// Acquire increments the number of active connections for the key and returns
+// true, unless doing so would exceed max in which case false is returned. A max
+// of 0 allows an unlimited number of connections.
+func (cc *connectionCounter) Acquire(key string, max int) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if max > 0 && cc.m[key] >= max {
+		return false
+	}
+	cc.m[key]++
+	return true
+}
+
+// Release decrements the number of active connections for the key.
+func (cc *connectionCounter) Release(key string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.m[key] <= 1 {
+		delete(cc.m, key)
+		return
+	}
+	cc.m[key]--
+}
@@ -48,3 +48,61 @@ func (r *Rate) Reset() {
 	r.last = time.Now()
 	r.mu.Unlock()
 }
+
+// Limiter is implemented by rate limiters that allow a number of items to be
+// taken over a period of time.
+type Limiter interface {
+	Try() bool
+	Reset()
+}
+
+// LeakyBucket is a rate limiter that drains continuously at a rate of limit items
+// per duration, allowing up to limit plus burst items to be taken at once. Unlike
+// Rate, the count is never reset to zero all at once, so output that bursts just
+// before and after the end of a period is not treated as a sudden spike.
+type LeakyBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	level    float64
+	last     time.Time
+	// now returns the current time, which is replaced in tests.
+	now func() time.Time
+}
+
+// NewLeakyBucket returns a leaky bucket rate limiter draining limit items per
+// duration with a capacity of limit plus burst items.
+func NewLeakyBucket(limit uint64, duration time.Duration, burst uint64) *LeakyBucket {
+	return &LeakyBucket{
+		rate:     float64(limit) / float64(duration),
+		capacity: float64(limit + burst),
+		last:     time.Now(),
+		now:      time.Now,
+	}
+}
+
+// Try returns true if there is room in the bucket for another item, or false if
+// the bucket is full.
+func (b *LeakyBucket) Try() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.level -= float64(now.Sub(b.last)) * b.rate
+	if b.level < 0 {
+		b.level = 0
+	}
+	b.last = now
+	if b.level+1 > b.capacity {
+		return false
+	}
+	b.level++
+	return true
+}
+
+// Reset empties the bucket.
+func (b *LeakyBucket) Reset() {
+	b.mu.Lock()
+	b.level = 0
+	b.last = b.now()
+	b.mu.Unlock()
+}
@@ -57,6 +57,55 @@ func TestRate(t *testing.T) {
 	})
 }
 
+func TestLeakyBucket(t *testing.T) {
+	g := Goblin(t)
+
+	// newBucket returns a leaky bucket using a clock that only moves when advanced.
+	newBucket := func(limit uint64, duration time.Duration, burst uint64) (*LeakyBucket, func(time.Duration)) {
+		now := time.Unix(1700000000, 0)
+		b := NewLeakyBucket(limit, duration, burst)
+		b.now = func() time.Time { return now }
+		b.last = now
+		return b, func(d time.Duration) { now = now.Add(d) }
+	}
+
+	g.Describe("LeakyBucket", func() {
+		g.It("allows the limit plus the burst to be taken at once", func() {
+			b, _ := newBucket(10, time.Second, 5)
+			for i := 0; i < 15; i++ {
+				g.Assert(b.Try()).IsTrue()
+			}
+			g.Assert(b.Try()).IsFalse()
+		})
+
+		g.It("drains gradually over time", func() {
+			b, advance := newBucket(10, time.Millisecond*100, 0)
+			for i := 0; i < 10; i++ {
+				g.Assert(b.Try()).IsTrue()
+			}
+			g.Assert(b.Try()).IsFalse()
+
+			// Half the period frees up half of the bucket.
+			advance(time.Millisecond * 50)
+			var n int
+			for b.Try() {
+				n++
+			}
+			g.Assert(n).Equal(5)
+		})
+
+		g.It("empties when reset", func() {
+			b, _ := newBucket(10, time.Second, 0)
+			for i := 0; i < 10; i++ {
+				b.Try()
+			}
+			g.Assert(b.Try()).IsFalse()
+			b.Reset()
+			g.Assert(b.Try()).IsTrue()
+		})
+	})
+}
+
 func BenchmarkRate_Try(b *testing.B) {
 	r := NewRate(10, time.Millisecond*100)
 
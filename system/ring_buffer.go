@@ -0,0 +1,60 @@
+package system
+
+import (
+	"sync"
+)
+
+// RingBuffer retains the most recent lines pushed into it, discarding the oldest
+// line once it is full.
+type RingBuffer struct {
+	mu    sync.Mutex
+	lines [][]byte
+	start int
+	count int
+}
+
+// NewRingBuffer returns a new RingBuffer that retains up to size lines.
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{lines: make([][]byte, size)}
+}
+
+// Push adds a copy of the line to the buffer, replacing the oldest line if the
+// buffer is full.
+func (rb *RingBuffer) Push(line []byte) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if len(rb.lines) == 0 {
+		return
+	}
+	v := make([]byte, len(line))
+	copy(v, line)
+	if rb.count < len(rb.lines) {
+		rb.lines[(rb.start+rb.count)%len(rb.lines)] = v
+		rb.count++
+		return
+	}
+	rb.lines[rb.start] = v
+	rb.start = (rb.start + 1) % len(rb.lines)
+}
+
+// Lines returns the lines in the buffer from oldest to newest.
+func (rb *RingBuffer) Lines() []string {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	out := make([]string, rb.count)
+	for i := 0; i < rb.count; i++ {
+		out[i] = string(rb.lines[(rb.start+i)%len(rb.lines)])
+	}
+	return out
+}
+
+// Reset removes all the lines from the buffer.
+func (rb *RingBuffer) Reset() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	for i := range rb.lines {
+		rb.lines[i] = nil
+	}
+	rb.start = 0
+	rb.count = 0
+}
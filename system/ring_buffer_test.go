@@ -0,0 +1,49 @@
+package system
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestRingBuffer(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("RingBuffer", func() {
+		g.It("returns the lines in the order they were pushed", func() {
+			rb := NewRingBuffer(3)
+			rb.Push([]byte("a"))
+			rb.Push([]byte("b"))
+
+			g.Assert(rb.Lines()).Equal([]string{"a", "b"})
+		})
+
+		g.It("discards the oldest lines once full", func() {
+			rb := NewRingBuffer(3)
+			for _, v := range []string{"a", "b", "c", "d", "e"} {
+				rb.Push([]byte(v))
+			}
+
+			g.Assert(rb.Lines()).Equal([]string{"c", "d", "e"})
+		})
+
+		g.It("copies the lines pushed into it", func() {
+			rb := NewRingBuffer(1)
+			b := []byte("a")
+			rb.Push(b)
+			b[0] = 'b'
+
+			g.Assert(rb.Lines()).Equal([]string{"a"})
+		})
+
+		g.It("removes all the lines when reset", func() {
+			rb := NewRingBuffer(2)
+			rb.Push([]byte("a"))
+			rb.Reset()
+
+			g.Assert(rb.Lines()).Equal([]string{})
+			rb.Push([]byte("b"))
+			g.Assert(rb.Lines()).Equal([]string{"b"})
+		})
+	})
+}
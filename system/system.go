@@ -55,13 +55,13 @@ type System struct {
 	OSType        string `json:"os_type"`
 }
 
-func GetSystemInformation() (*Information, error) {
+func GetSystemInformation(c *client.Client) (*Information, error) {
 	k, err := kernel.GetKernelVersion()
 	if err != nil {
 		return nil, err
 	}
 
-	version, info, err := GetDockerInfo(context.Background())
+	version, info, err := GetDockerInfo(context.Background(), c)
 	if err != nil {
 		return nil, err
 	}
@@ -122,14 +122,7 @@ func GetSystemInformation() (*Information, error) {
 	}, nil
 }
 
-func GetDockerInfo(ctx context.Context) (types.Version, system.Info, error) {
-	// TODO: find a way to re-use the client from the docker environment.
-	c, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return types.Version{}, system.Info{}, err
-	}
-	defer c.Close()
-
+func GetDockerInfo(ctx context.Context, c *client.Client) (types.Version, system.Info, error) {
 	dockerVersion, err := c.ServerVersion(ctx)
 	if err != nil {
 		return types.Version{}, system.Info{}, err